@@ -0,0 +1,108 @@
+package mediasoup
+
+import "encoding/json"
+
+type ActiveSpeakerObserverOptions struct {
+	/**
+	 * Interval in ms for checking the dominant speaker. Default 300.
+	 */
+	Interval int `json:"interval,omitempty"`
+
+	/**
+	 * Custom application data.
+	 */
+	AppData interface{} `json:"appData,omitempty"`
+}
+
+func NewActiveSpeakerObserverOptions() ActiveSpeakerObserverOptions {
+	return ActiveSpeakerObserverOptions{
+		Interval: 300,
+		AppData:  H{},
+	}
+}
+
+type ActiveSpeakerObserverActivity struct {
+	/**
+	 * The producer instance.
+	 */
+	Producer *Producer
+}
+
+type ActiveSpeakerObserver struct {
+	IRtpObserver
+	logger Logger
+}
+
+/**
+ * @emits dominantspeaker - (activity: ActiveSpeakerObserverActivity)
+ */
+func newActiveSpeakerObserver(params rtpObserverParams) *ActiveSpeakerObserver {
+	o := &ActiveSpeakerObserver{
+		IRtpObserver: newRtpObserver(params),
+		logger:       NewLogger("ActiveSpeakerObserver"),
+	}
+
+	o.handleWorkerNotifications(params)
+
+	return o
+}
+
+/**
+ * Observer.
+ *
+ * @emits close
+ * @emits pause
+ * @emits resume
+ * @emits addproducer - (producer: Producer)
+ * @emits removeproducer - (producer: Producer)
+ * @emits dominantspeaker - (activity: ActiveSpeakerObserverActivity)
+ */
+func (o *ActiveSpeakerObserver) Observer() IEventEmitter {
+	return o.IRtpObserver.Observer()
+}
+
+/**
+ * OnDominantSpeaker adds a listener of "dominantspeaker" and returns the
+ * function removing it.
+ */
+func (o *ActiveSpeakerObserver) OnDominantSpeaker(listener func(ActiveSpeakerObserverActivity)) func() {
+	return o.subscribe("dominantspeaker", listener)
+}
+
+func (o *ActiveSpeakerObserver) handleWorkerNotifications(params rtpObserverParams) {
+	rtpObserverId := params.internal.RtpObserverId
+	getProducerById := params.getProducerById
+
+	type eventInfo struct {
+		ProducerId string `json:"producerId,omitempty"`
+	}
+
+	params.channel.On(rtpObserverId, func(event string, data []byte) {
+		switch event {
+		case "dominantspeaker":
+			var info eventInfo
+
+			if err := json.Unmarshal(data, &info); err != nil {
+				o.logger.Error(`unmarshal event failed: %s`, err)
+				break
+			}
+
+			// The Producer may have been closed in the meanwhile.
+			producer := getProducerById(info.ProducerId)
+			if producer == nil {
+				break
+			}
+
+			activity := ActiveSpeakerObserverActivity{
+				Producer: producer,
+			}
+
+			o.SafeEmit("dominantspeaker", activity)
+
+			// Emit observer event.
+			o.Observer().SafeEmit("dominantspeaker", activity)
+		default:
+			o.logger.Error(`ignoring unknown event "%s"`, event)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package mediasoup
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateActiveSpeakerObserver_Succeeds(t *testing.T) {
+	worker := CreateTestWorker()
+	router, _ := worker.CreateRouter(RouterOptions{
+		MediaCodecs: audioLevelMediaCodecs,
+	})
+	activeSpeakerObserver, err := router.CreateActiveSpeakerObserver(func(o *ActiveSpeakerObserverOptions) {
+		o.Interval = 500
+	})
+	assert.NoError(t, err)
+	assert.False(t, activeSpeakerObserver.Closed())
+	assert.False(t, activeSpeakerObserver.Paused())
+
+	result, _ := router.Dump()
+
+	assert.Equal(t, []string{activeSpeakerObserver.Id()}, result.RtpObserverIds)
+}
+
+func TestCreateActiveSpeakerObserver_Pause_Resume(t *testing.T) {
+	worker := CreateTestWorker()
+	router, _ := worker.CreateRouter(RouterOptions{
+		MediaCodecs: audioLevelMediaCodecs,
+	})
+	activeSpeakerObserver, err := router.CreateActiveSpeakerObserver()
+	assert.NoError(t, err)
+
+	activeSpeakerObserver.Pause()
+	assert.True(t, activeSpeakerObserver.Paused())
+
+	activeSpeakerObserver.Resume()
+	assert.False(t, activeSpeakerObserver.Paused())
+}
+
+func TestCreateActiveSpeakerObserver_Close(t *testing.T) {
+	worker := CreateTestWorker()
+	router, _ := worker.CreateRouter(RouterOptions{
+		MediaCodecs: audioLevelMediaCodecs,
+	})
+	activeSpeakerObserver, err := router.CreateActiveSpeakerObserver()
+	assert.NoError(t, err)
+
+	activeSpeakerObserver.Close()
+	assert.True(t, activeSpeakerObserver.Closed())
+
+	result, _ := router.Dump()
+	assert.Empty(t, result.RtpObserverIds)
+}
+
+func TestCreateActiveSpeakerObserver_Router_Close(t *testing.T) {
+	worker := CreateTestWorker()
+	router, _ := worker.CreateRouter(RouterOptions{
+		MediaCodecs: audioLevelMediaCodecs,
+	})
+	activeSpeakerObserver, err := router.CreateActiveSpeakerObserver()
+	assert.NoError(t, err)
+
+	routerclose := false
+	activeSpeakerObserver.On("routerclose", func() {
+		routerclose = true
+	})
+	router.Close()
+
+	assert.True(t, activeSpeakerObserver.Closed())
+	assert.True(t, routerclose)
+}
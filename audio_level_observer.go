@@ -82,6 +82,20 @@ func (o *AudioLevelObserver) Observer() IEventEmitter {
 	return o.IRtpObserver.Observer()
 }
 
+/**
+ * OnVolumes adds a listener of "volumes" and returns the function removing it.
+ */
+func (o *AudioLevelObserver) OnVolumes(listener func([]AudioLevelObserverVolume)) func() {
+	return o.subscribe("volumes", listener)
+}
+
+/**
+ * OnSilence adds a listener of "silence" and returns the function removing it.
+ */
+func (o *AudioLevelObserver) OnSilence(listener func()) func() {
+	return o.subscribe("silence", listener)
+}
+
 func (o *AudioLevelObserver) handleWorkerNotifications(params rtpObserverParams) {
 	rtpObserverId := params.internal.RtpObserverId
 	getProducerById := params.getProducerById
@@ -1,6 +1,7 @@
 package mediasoup
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -22,6 +23,9 @@ const (
 type workerResponse struct {
 	data json.RawMessage
 	err  error
+	// unanswered is set if the request was sent but its response is not
+	// waited for anymore, the worker may still carry it out.
+	unanswered bool
 }
 
 func (r workerResponse) Unmarshal(v interface{}) error {
@@ -75,8 +79,6 @@ func newChannel(producerSocket, consumerSocket net.Conn, pid int) *Channel {
 		closeCh:        make(chan struct{}),
 	}
 
-	go channel.runReadLoop()
-
 	return channel
 }
 
@@ -95,11 +97,22 @@ func (c *Channel) Closed() bool {
 	return atomic.LoadInt32(&c.closed) > 0
 }
 
-func (c *Channel) Request(method string, internal interface{}, data ...interface{}) (rsp workerResponse) {
+func (c *Channel) Request(method string, internal interface{}, data ...interface{}) workerResponse {
+	return c.RequestContext(context.Background(), method, internal, data...)
+}
+
+// RequestContext sends a request to the worker and waits for the response. If
+// ctx has no deadline, the request times out after 15s plus 0.1s per pending
+// request.
+func (c *Channel) RequestContext(ctx context.Context, method string, internal interface{}, data ...interface{}) (rsp workerResponse) {
 	if c.Closed() {
 		rsp.err = NewInvalidStateError("PayloadChannel closed")
 		return
 	}
+	if rsp.err = ctx.Err(); rsp.err != nil {
+		return
+	}
+
 	id := int64(1)
 
 	if atomic.LoadInt64(&c.nextId) < 4294967295 {
@@ -113,7 +126,7 @@ func (c *Channel) Request(method string, internal interface{}, data ...interface
 	sent := sentInfo{
 		id:     id,
 		method: method,
-		respCh: make(chan workerResponse),
+		respCh: make(chan workerResponse, 1),
 	}
 	c.sents.Store(id, sent)
 
@@ -145,15 +158,16 @@ func (c *Channel) Request(method string, internal interface{}, data ...interface
 		return
 	}
 
-	timeout := 1000 * (15 + (0.1 * float64(size)))
-	timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
-	defer timer.Stop()
+	timeoutCh, stop := requestTimeout(ctx, size)
+	defer stop()
 
 	select {
 	case rsp = <-sent.respCh:
 		return
-	case <-timer.C:
-		rsp.err = errors.New("Channel request timeout")
+	case <-timeoutCh:
+		rsp.err, rsp.unanswered = errors.New("Channel request timeout"), true
+	case <-ctx.Done():
+		rsp.err, rsp.unanswered = ctx.Err(), true
 	case <-c.closeCh:
 		rsp.err = NewInvalidStateError("Channel closed")
 	}
@@ -161,6 +175,20 @@ func (c *Channel) Request(method string, internal interface{}, data ...interface
 	return
 }
 
+// requestTimeout returns the default request timeout channel, which is nil if
+// the context deadline comes earlier.
+func requestTimeout(ctx context.Context, size int64) (<-chan time.Time, func()) {
+	timeout := time.Duration(1000*(15+(0.1*float64(size)))) * time.Millisecond
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+		return nil, func() {}
+	}
+
+	timer := time.NewTimer(timeout)
+
+	return timer.C, func() { timer.Stop() }
+}
+
 func (c *Channel) runReadLoop() {
 	decoder := netstring.NewDecoder()
 
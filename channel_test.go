@@ -0,0 +1,31 @@
+package mediasoup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	timeoutCh, stop := requestTimeout(context.Background(), 0)
+	defer stop()
+	assert.NotNil(t, timeoutCh)
+
+	// The context deadline comes earlier.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	timeoutCh, stop = requestTimeout(ctx, 0)
+	defer stop()
+	assert.Nil(t, timeoutCh)
+
+	// The default timeout comes earlier.
+	ctx, cancel = context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	timeoutCh, stop = requestTimeout(ctx, 0)
+	defer stop()
+	assert.NotNil(t, timeoutCh)
+}
@@ -0,0 +1,116 @@
+// Package chunk splits messages too large for a data channel into fragments,
+// and reassembles them on the receiving side.
+//
+// DataProducer.Send fails beyond the maxSctpMessageSize of the transports
+// (262144 by default) or the 4MiB payload limit of the PayloadChannel. A
+// Sender splits each message into fragments no larger than
+// SenderOptions.MaxFragmentSize, and a Reassembler rebuilds the messages from
+// the fragments received by a DataConsumer.
+//
+// # Wire format
+//
+// Every message sent by a Sender is split into one or more fragments, even
+// when it fits in one. Each fragment is a binary data channel message (PPID
+// 53), made of a 14-byte header followed by a part of the message. The header
+// fields are unsigned big-endian integers:
+//
+//	offset  size  field
+//	0       1     version, 1
+//	1       1     flags, bit 0 set if the message is UTF-8 text
+//	2       4     message id, distinct among the messages being sent
+//	6       4     fragment index, from 0
+//	10      4     fragment count, at least 1
+//	14      -     fragment payload
+//
+// The message is the concatenation of the payloads of its fragments ordered
+// by index. Fragments of different messages may be interleaved, and the
+// fragments of a message may arrive out of order on an unordered data
+// channel. A browser reads the header with a DataView:
+//
+//	const view = new DataView(event.data)
+//	const text = (view.getUint8(1) & 1) === 1
+//	const id = view.getUint32(2)
+//	const index = view.getUint32(6)
+//	const count = view.getUint32(10)
+//	const payload = new Uint8Array(event.data, 14)
+package chunk
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// HeaderSize is the size of the header of a fragment.
+const HeaderSize = 14
+
+// Version of the wire format.
+const Version = 1
+
+// flagText is set in the flags of the fragments of a text message.
+const flagText = 1
+
+var (
+	/**
+	 * ErrInvalidFragment is the reason of dropping a message whose fragments
+	 * don't agree on the fragment count or the flags.
+	 */
+	ErrInvalidFragment = errors.New("chunk: invalid fragment")
+
+	/**
+	 * ErrMessageTooLarge is the reason of dropping a message larger than
+	 * ReassemblerOptions.MaxMessageSize or made of more fragments than
+	 * ReassemblerOptions.MaxFragments.
+	 */
+	ErrMessageTooLarge = errors.New("chunk: message too large")
+
+	/**
+	 * ErrReassemblyTimeout is the reason of dropping a message not completed
+	 * within ReassemblerOptions.Timeout.
+	 */
+	ErrReassemblyTimeout = errors.New("chunk: reassembly timeout")
+
+	/**
+	 * ErrBufferFull is the reason of dropping the oldest incomplete messages
+	 * once they exceed ReassemblerOptions.MaxBufferedSize,
+	 * MaxBufferedFragments or MaxPendingMessages.
+	 */
+	ErrBufferFull = errors.New("chunk: reassembly buffer full")
+)
+
+// header is the header of a fragment.
+type header struct {
+	flags uint8
+	id    uint32
+	index uint32
+	count uint32
+}
+
+func (h header) text() bool {
+	return h.flags&flagText != 0
+}
+
+func putHeader(b []byte, h header) {
+	b[0] = Version
+	b[1] = h.flags
+	binary.BigEndian.PutUint32(b[2:], h.id)
+	binary.BigEndian.PutUint32(b[6:], h.index)
+	binary.BigEndian.PutUint32(b[10:], h.count)
+}
+
+func parseHeader(fragment []byte) (h header, err error) {
+	if len(fragment) < HeaderSize || fragment[0] != Version {
+		return h, ErrInvalidFragment
+	}
+
+	h = header{
+		flags: fragment[1],
+		id:    binary.BigEndian.Uint32(fragment[2:]),
+		index: binary.BigEndian.Uint32(fragment[6:]),
+		count: binary.BigEndian.Uint32(fragment[10:]),
+	}
+	if h.count == 0 || h.index >= h.count {
+		return h, ErrInvalidFragment
+	}
+
+	return
+}
@@ -0,0 +1,277 @@
+package chunk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transmitter records the sent fragments.
+type transmitter struct {
+	fragments [][]byte
+}
+
+func (t *transmitter) Send(data []byte, ppid ...int) error {
+	t.fragments = append(t.fragments, data)
+	return nil
+}
+
+type drop struct {
+	id     uint32
+	reason error
+}
+
+func newTestReassembler(t *testing.T, options ReassemblerOptions) (*Reassembler, chan Message, chan drop) {
+	r := NewReassembler(options)
+	t.Cleanup(r.Close)
+
+	messages := make(chan Message, 10)
+	drops := make(chan drop, 10)
+
+	r.On("message", func(message Message) { messages <- message })
+	r.On("drop", func(id uint32, reason error) { drops <- drop{id, reason} })
+
+	return r, messages, drops
+}
+
+func receiveMessage(t *testing.T, messages chan Message) Message {
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("message not emitted")
+	}
+	return Message{}
+}
+
+func receiveDrop(t *testing.T, drops chan drop) drop {
+	select {
+	case drop := <-drops:
+		return drop
+	case <-time.After(time.Second):
+		t.Fatal("drop not emitted")
+	}
+	return drop{}
+}
+
+func TestSender_WireFormat(t *testing.T) {
+	tx := &transmitter{}
+	sender := NewSender(tx, SenderOptions{MaxFragmentSize: HeaderSize + 4})
+
+	require.NoError(t, sender.SendText("hello world"))
+	require.Len(t, tx.fragments, 3)
+
+	for index, fragment := range tx.fragments {
+		assert.EqualValues(t, Version, fragment[0])
+		assert.EqualValues(t, 1, fragment[1], "text flag")
+		assert.EqualValues(t, 1, binary.BigEndian.Uint32(fragment[2:]), "message id")
+		assert.EqualValues(t, index, binary.BigEndian.Uint32(fragment[6:]), "fragment index")
+		assert.EqualValues(t, 3, binary.BigEndian.Uint32(fragment[10:]), "fragment count")
+	}
+	assert.Equal(t, "hell", string(tx.fragments[0][HeaderSize:]))
+	assert.Equal(t, "rld", string(tx.fragments[2][HeaderSize:]))
+
+	// An empty message is sent in a fragment.
+	tx.fragments = nil
+	require.NoError(t, sender.Send(nil))
+	require.Len(t, tx.fragments, 1)
+	assert.Len(t, tx.fragments[0], HeaderSize)
+	assert.EqualValues(t, 0, tx.fragments[0][1])
+	assert.EqualValues(t, 2, binary.BigEndian.Uint32(tx.fragments[0][2:]))
+}
+
+func TestReassembler_OutOfOrder(t *testing.T) {
+	tx := &transmitter{}
+	sender := NewSender(tx, SenderOptions{MaxFragmentSize: HeaderSize + 10})
+
+	data1 := make([]byte, 95)
+	rand.Read(data1)
+	require.NoError(t, sender.Send(data1))
+	require.NoError(t, sender.SendText("a text message"))
+	require.NoError(t, sender.Send(nil))
+
+	// The fragments of the messages are interleaved, shuffled and
+	// duplicated.
+	fragments := append([][]byte{}, tx.fragments...)
+	rand.Shuffle(len(fragments), func(i, j int) { fragments[i], fragments[j] = fragments[j], fragments[i] })
+	fragments = append(fragments, fragments[0])
+
+	r, messages, _ := newTestReassembler(t, ReassemblerOptions{})
+
+	for _, fragment := range fragments {
+		r.Push(fragment)
+	}
+
+	received := make(map[uint32]Message)
+	for i := 0; i < 3; i++ {
+		message := receiveMessage(t, messages)
+		received[message.Id] = message
+	}
+	assert.Equal(t, Message{Id: 1, Data: data1}, received[1])
+	assert.Equal(t, Message{Id: 2, Data: []byte("a text message"), Text: true}, received[2])
+	assert.Empty(t, received[3].Data)
+
+	count, size := r.Buffered()
+	assert.Zero(t, count)
+	assert.Zero(t, size)
+}
+
+func TestReassembler_Limits(t *testing.T) {
+	tx := &transmitter{}
+	sender := NewSender(tx, SenderOptions{MaxFragmentSize: HeaderSize + 10})
+
+	r, messages, drops := newTestReassembler(t, ReassemblerOptions{
+		MaxMessageSize:  25,
+		MaxBufferedSize: 25,
+	})
+
+	clock := time.Now()
+	r.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	// Too large.
+	require.NoError(t, sender.Send(make([]byte, 30)))
+	for _, fragment := range tx.fragments {
+		r.Push(fragment)
+	}
+	assert.Equal(t, drop{1, ErrMessageTooLarge}, receiveDrop(t, drops))
+
+	// Buffer full, the oldest incomplete message is dropped.
+	tx.fragments = nil
+	require.NoError(t, sender.Send(make([]byte, 20)))
+	require.NoError(t, sender.Send(make([]byte, 20)))
+	require.NoError(t, sender.Send(make([]byte, 20)))
+
+	r.Push(tx.fragments[0])
+	r.Push(tx.fragments[2])
+	r.Push(tx.fragments[4])
+	assert.Equal(t, drop{2, ErrBufferFull}, receiveDrop(t, drops))
+
+	r.Push(tx.fragments[1])
+	r.Push(tx.fragments[3])
+	assert.Equal(t, uint32(3), receiveMessage(t, messages).Id)
+
+	// Invalid fragments are ignored.
+	r.Push([]byte{2, 0, 0})
+	r.Push(append([]byte{2}, tx.fragments[5][1:]...))
+
+	// The fragment count of a message changed.
+	invalid := append([]byte{}, tx.fragments[5]...)
+	binary.BigEndian.PutUint32(invalid[10:], 3)
+	r.Push(invalid)
+	assert.Equal(t, drop{4, ErrInvalidFragment}, receiveDrop(t, drops))
+
+	select {
+	case message := <-messages:
+		t.Fatalf("unexpected message %d", message.Id)
+	default:
+	}
+}
+
+// fragment builds a fragment of the given message.
+func fragment(id, index, count uint32, payload []byte) []byte {
+	b := make([]byte, HeaderSize+len(payload))
+	putHeader(b, header{id: id, index: index, count: count})
+	copy(b[HeaderSize:], payload)
+	return b
+}
+
+func TestReassembler_FragmentLimits(t *testing.T) {
+	r, _, drops := newTestReassembler(t, ReassemblerOptions{
+		MaxFragments:         4,
+		MaxPendingMessages:   2,
+		MaxBufferedFragments: 2,
+	})
+
+	clock := time.Now()
+	r.now = func() time.Time {
+		clock = clock.Add(time.Millisecond)
+		return clock
+	}
+
+	// Too many fragments, the late fragments of the message are ignored.
+	r.Push(fragment(1, 0, 5, nil))
+	assert.Equal(t, drop{1, ErrMessageTooLarge}, receiveDrop(t, drops))
+	r.Push(fragment(1, 1, 5, nil))
+
+	// Too many pending messages, even without a payload.
+	r.Push(fragment(2, 0, 4, nil))
+	r.Push(fragment(3, 0, 4, nil))
+	r.Push(fragment(4, 0, 4, nil))
+	assert.Equal(t, drop{2, ErrBufferFull}, receiveDrop(t, drops))
+
+	messages, _ := r.Buffered()
+	assert.Equal(t, 2, messages)
+
+	// Too many buffered fragments.
+	r.Push(fragment(3, 1, 4, nil))
+	assert.Equal(t, drop{3, ErrBufferFull}, receiveDrop(t, drops))
+
+	messages, _ = r.Buffered()
+	assert.Equal(t, 1, messages)
+	assert.Equal(t, 1, r.fragments)
+	assert.Empty(t, drops)
+}
+
+func TestReassembler_Timeout(t *testing.T) {
+	tx := &transmitter{}
+	sender := NewSender(tx, SenderOptions{MaxFragmentSize: HeaderSize + 10})
+
+	// Expired explicitly rather than by the ticker.
+	clock := time.Now()
+	r, messages, drops := newTestReassembler(t, ReassemblerOptions{Timeout: time.Hour})
+	r.now = func() time.Time { return clock }
+
+	require.NoError(t, sender.Send(make([]byte, 20)))
+	r.Push(tx.fragments[0])
+
+	clock = clock.Add(2 * time.Hour)
+	r.expire()
+	assert.Equal(t, drop{1, ErrReassemblyTimeout}, receiveDrop(t, drops))
+
+	// The late fragments of the message are ignored.
+	r.Push(tx.fragments[1])
+
+	count, _ := r.Buffered()
+	assert.Zero(t, count)
+	assert.Empty(t, messages)
+}
+
+func TestReassembleDataConsumer(t *testing.T) {
+	router, _ := workertest.NewRouter(t)
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+	dataProducer, err := transport.ProduceData(mediasoup.DataProducerOptions{Label: "files"})
+	require.NoError(t, err)
+	dataConsumer, err := transport.ConsumeData(mediasoup.DataConsumerOptions{DataProducerId: dataProducer.Id()})
+	require.NoError(t, err)
+
+	r := ReassembleDataConsumer(dataConsumer, ReassemblerOptions{})
+	messages := make(chan Message, 1)
+	r.On("message", func(message Message) { messages <- message })
+
+	// Larger than the payload limit of the PayloadChannel.
+	data := make([]byte, mediasoup.NS_PAYLOAD_MAX_LEN+1000)
+	rand.Read(data)
+
+	require.NoError(t, NewSender(dataProducer, SenderOptions{}).Send(data))
+
+	select {
+	case message := <-messages:
+		assert.True(t, bytes.Equal(data, message.Data))
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not emitted")
+	}
+
+	dataConsumer.Close()
+	assert.Eventually(t, r.Closed, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,341 @@
+package chunk
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+type ReassemblerOptions struct {
+	/**
+	 * Time after which an incomplete message is dropped. Default 30s.
+	 */
+	Timeout time.Duration
+
+	/**
+	 * Maximum size of a message. Default MaxBufferedSize.
+	 */
+	MaxMessageSize int
+
+	/**
+	 * Maximum total size of the incomplete messages, the oldest ones are
+	 * dropped beyond it. Default 64MB.
+	 */
+	MaxBufferedSize int
+
+	/**
+	 * Maximum fragment count of a message. Default 4096.
+	 */
+	MaxFragments int
+
+	/**
+	 * Maximum number of incomplete messages, the oldest ones are dropped
+	 * beyond it. Default 1024.
+	 */
+	MaxPendingMessages int
+
+	/**
+	 * Maximum total number of fragments of the incomplete messages, the
+	 * oldest ones are dropped beyond it. Default 65536.
+	 */
+	MaxBufferedFragments int
+}
+
+/**
+ * Message is a reassembled message.
+ */
+type Message struct {
+	Id   uint32
+	Data []byte
+	Text bool
+}
+
+/**
+ * Reassembler rebuilds the messages from their fragments.
+ *
+ * @emits message - (message: Message)
+ * @emits drop - (messageId: uint32, reason: error)
+ * @emits close
+ */
+type Reassembler struct {
+	mediasoup.IEventEmitter
+	logger  mediasoup.Logger
+	options ReassemblerOptions
+	pending map[uint32]*pendingMessage
+	// finished holds the ids of the reassembled or dropped messages, whose
+	// late fragments are ignored until the timeout.
+	finished map[uint32]time.Time
+	buffered int
+	// fragments is the number of fragments of the pending messages.
+	fragments int
+	closed    uint32
+	done      chan struct{}
+	locker    sync.Mutex
+	// now returns the current time, replaced in tests.
+	now func() time.Time
+}
+
+// pendingMessage is a message being reassembled.
+type pendingMessage struct {
+	text      bool
+	count     uint32
+	fragments map[uint32][]byte
+	size      int
+	firstSeen time.Time
+}
+
+/**
+ * NewReassembler creates a Reassembler fed by Push.
+ */
+func NewReassembler(options ReassemblerOptions) *Reassembler {
+	logger := mediasoup.NewLogger("ChunkReassembler")
+
+	logger.Debug("constructor()")
+
+	if options.Timeout <= 0 {
+		options.Timeout = 30 * time.Second
+	}
+	if options.MaxBufferedSize <= 0 {
+		options.MaxBufferedSize = 64 << 20
+	}
+	if options.MaxMessageSize <= 0 {
+		options.MaxMessageSize = options.MaxBufferedSize
+	}
+	if options.MaxFragments <= 0 {
+		options.MaxFragments = 4096
+	}
+	if options.MaxPendingMessages <= 0 {
+		options.MaxPendingMessages = 1024
+	}
+	if options.MaxBufferedFragments <= 0 {
+		options.MaxBufferedFragments = 65536
+	}
+
+	r := &Reassembler{
+		IEventEmitter: mediasoup.NewEventEmitter(),
+		logger:        logger,
+		options:       options,
+		pending:       make(map[uint32]*pendingMessage),
+		finished:      make(map[uint32]time.Time),
+		done:          make(chan struct{}),
+		now:           time.Now,
+	}
+
+	go r.expireLoop()
+
+	return r
+}
+
+/**
+ * ReassembleDataConsumer creates a Reassembler fed by the messages of a
+ * DataConsumer. It is closed with the DataConsumer.
+ */
+func ReassembleDataConsumer(dataConsumer *mediasoup.DataConsumer, options ReassemblerOptions) *Reassembler {
+	r := NewReassembler(options)
+
+	unsubscribe := dataConsumer.OnMessage(func(payload []byte, ppid int) {
+		r.Push(payload)
+	})
+
+	r.On("close", unsubscribe)
+	dataConsumer.Observer().On("close", r.Close)
+
+	if dataConsumer.Closed() {
+		r.Close()
+	}
+
+	return r
+}
+
+/**
+ * Whether the Reassembler is closed.
+ */
+func (r *Reassembler) Closed() bool {
+	return atomic.LoadUint32(&r.closed) > 0
+}
+
+/**
+ * Close the Reassembler, dropping the incomplete messages.
+ */
+func (r *Reassembler) Close() {
+	if !atomic.CompareAndSwapUint32(&r.closed, 0, 1) {
+		return
+	}
+
+	r.logger.Debug("close()")
+
+	close(r.done)
+
+	r.locker.Lock()
+	r.pending = make(map[uint32]*pendingMessage)
+	r.finished = make(map[uint32]time.Time)
+	r.buffered = 0
+	r.fragments = 0
+	r.locker.Unlock()
+
+	r.SafeEmit("close")
+}
+
+/**
+ * Buffered returns the number and the total size of the incomplete messages.
+ */
+func (r *Reassembler) Buffered() (messages, size int) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	return len(r.pending), r.buffered
+}
+
+/**
+ * Push adds a fragment, and emits "message" once all the fragments of its
+ * message are pushed. The fragment is copied.
+ */
+func (r *Reassembler) Push(fragment []byte) {
+	if r.Closed() {
+		return
+	}
+
+	h, err := parseHeader(fragment)
+	if err != nil {
+		// Its message, if any, times out.
+		r.logger.Warn("ignoring invalid fragment")
+		return
+	}
+
+	payload := fragment[HeaderSize:]
+
+	// Fast path of the messages sent in a single fragment.
+	if h.count == 1 {
+		if len(payload) > r.options.MaxMessageSize {
+			r.SafeEmit("drop", h.id, ErrMessageTooLarge)
+			return
+		}
+		r.SafeEmit("message", Message{
+			Id:   h.id,
+			Data: append([]byte(nil), payload...),
+			Text: h.text(),
+		})
+		return
+	}
+
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	if _, ok := r.finished[h.id]; ok {
+		return
+	}
+
+	msg, ok := r.pending[h.id]
+
+	if !ok {
+		if h.count > uint32(r.options.MaxFragments) {
+			r.drop(h.id, ErrMessageTooLarge)
+			return
+		}
+		msg = &pendingMessage{
+			text:      h.text(),
+			count:     h.count,
+			fragments: make(map[uint32][]byte),
+			firstSeen: r.now(),
+		}
+		r.pending[h.id] = msg
+	} else if msg.count != h.count || msg.text != h.text() {
+		r.drop(h.id, ErrInvalidFragment)
+		return
+	}
+
+	// Duplicates are ignored.
+	if _, ok := msg.fragments[h.index]; ok {
+		return
+	}
+
+	if msg.size+len(payload) > r.options.MaxMessageSize {
+		r.drop(h.id, ErrMessageTooLarge)
+		return
+	}
+
+	msg.fragments[h.index] = append([]byte(nil), payload...)
+	msg.size += len(payload)
+	r.buffered += len(payload)
+	r.fragments++
+
+	if uint32(len(msg.fragments)) == msg.count {
+		data := make([]byte, 0, msg.size)
+
+		for index := uint32(0); index < msg.count; index++ {
+			data = append(data, msg.fragments[index]...)
+		}
+
+		delete(r.pending, h.id)
+		r.buffered -= msg.size
+		r.fragments -= len(msg.fragments)
+		r.finished[h.id] = r.now()
+
+		r.SafeEmit("message", Message{Id: h.id, Data: data, Text: msg.text})
+		return
+	}
+
+	// Make room by dropping the oldest messages.
+	for r.buffered > r.options.MaxBufferedSize ||
+		r.fragments > r.options.MaxBufferedFragments ||
+		len(r.pending) > r.options.MaxPendingMessages {
+		var oldestId uint32
+		var oldest *pendingMessage
+
+		for id, msg := range r.pending {
+			if oldest == nil || msg.firstSeen.Before(oldest.firstSeen) {
+				oldestId, oldest = id, msg
+			}
+		}
+		r.drop(oldestId, ErrBufferFull)
+	}
+}
+
+// drop forgets a message. r.locker must be held.
+func (r *Reassembler) drop(id uint32, reason error) {
+	r.logger.Debug("drop() [messageId:%d]: %s", id, reason)
+
+	if msg, ok := r.pending[id]; ok {
+		delete(r.pending, id)
+		r.buffered -= msg.size
+		r.fragments -= len(msg.fragments)
+	}
+	r.finished[id] = r.now()
+
+	r.SafeEmit("drop", id, reason)
+}
+
+// expireLoop drops the messages not completed in time.
+func (r *Reassembler) expireLoop() {
+	ticker := time.NewTicker(r.options.Timeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.expire()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *Reassembler) expire() {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	deadline := r.now().Add(-r.options.Timeout)
+
+	for id, msg := range r.pending {
+		if msg.firstSeen.Before(deadline) {
+			r.drop(id, ErrReassemblyTimeout)
+		}
+	}
+	for id, finishedAt := range r.finished {
+		if finishedAt.Before(deadline) {
+			delete(r.finished, id)
+		}
+	}
+}
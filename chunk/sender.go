@@ -0,0 +1,93 @@
+package chunk
+
+import (
+	"sync/atomic"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+/**
+ * Transmitter sends data channel messages. It is implemented by
+ * *mediasoup.DataProducer and by *mediasoup.DataConsumer.
+ */
+type Transmitter interface {
+	Send(data []byte, ppid ...int) error
+}
+
+type SenderOptions struct {
+	/**
+	 * Maximum size of a fragment, header included. It must not exceed the
+	 * maxSctpMessageSize of the transports the messages go through. Default
+	 * 262144.
+	 */
+	MaxFragmentSize int
+}
+
+/**
+ * Sender splits the messages into fragments sent by a Transmitter. It may be
+ * used concurrently.
+ */
+type Sender struct {
+	transmitter Transmitter
+	options     SenderOptions
+	nextId      uint32
+}
+
+func NewSender(transmitter Transmitter, options SenderOptions) *Sender {
+	if options.MaxFragmentSize <= HeaderSize {
+		options.MaxFragmentSize = 262144
+	}
+
+	return &Sender{
+		transmitter: transmitter,
+		options:     options,
+	}
+}
+
+/**
+ * Send a binary message.
+ */
+func (s *Sender) Send(data []byte) error {
+	return s.send(data, 0)
+}
+
+/**
+ * Send a text message.
+ */
+func (s *Sender) SendText(message string) error {
+	return s.send([]byte(message), flagText)
+}
+
+func (s *Sender) send(data []byte, flags uint8) error {
+	maxPayloadSize := s.options.MaxFragmentSize - HeaderSize
+	count := (len(data) + maxPayloadSize - 1) / maxPayloadSize
+
+	if count == 0 {
+		count = 1
+	}
+
+	id := atomic.AddUint32(&s.nextId, 1)
+
+	for index := 0; index < count; index++ {
+		payload := data[index*maxPayloadSize:]
+
+		if len(payload) > maxPayloadSize {
+			payload = payload[:maxPayloadSize]
+		}
+
+		fragment := make([]byte, HeaderSize+len(payload))
+		putHeader(fragment, header{
+			flags: flags,
+			id:    id,
+			index: uint32(index),
+			count: uint32(count),
+		})
+		copy(fragment[HeaderSize:], payload)
+
+		if err := s.transmitter.Send(fragment, mediasoup.PPID_WEBRTC_BINARY); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
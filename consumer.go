@@ -1,11 +1,14 @@
 package mediasoup
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"sync"
 	"sync/atomic"
+
+	"github.com/pion/rtp"
 )
 
 type ConsumerOptions struct {
@@ -41,6 +44,12 @@ type ConsumerOptions struct {
 	 */
 	PreferredLayers *ConsumerLayers `json:"preferredLayers,omitempty"`
 
+	/**
+	 * The MID for the Consumer. If not specified, a sequentially growing
+	 * number is assigned.
+	 */
+	Mid string `json:"mid,omitempty"`
+
 	/**
 	 * Whether this Consumer should consume all RTP streams generated by the
 	 * Producer.
@@ -113,7 +122,7 @@ type ConsumerLayers struct {
 	/**
 	 * The spatial layer index (from 0 to N).
 	 */
-	SpatialLayer uint8 `json:"spatialLayer,omitempty"`
+	SpatialLayer uint8 `json:"spatialLayer"`
 
 	/**
 	 * The temporal layer index (from 0 to N).
@@ -259,6 +268,13 @@ func (consumer *Consumer) RtpParameters() RtpParameters {
 	return consumer.data.RtpParameters
 }
 
+/**
+ * Ids of the header extensions of the RTP packets sent by the Consumer.
+ */
+func (consumer *Consumer) HeaderExtensionIds() RtpHeaderExtensionIds {
+	return NewRtpHeaderExtensionIds(consumer.data.RtpParameters.HeaderExtensions)
+}
+
 // Consumer type.
 func (consumer *Consumer) Type() ConsumerType {
 	return consumer.data.Type
@@ -313,8 +329,80 @@ func (consumer *Consumer) Observer() IEventEmitter {
 	return consumer.observer
 }
 
+/**
+ * OnTransportClose adds a listener of "transportclose" and returns the function
+ * removing it.
+ */
+func (consumer *Consumer) OnTransportClose(listener func()) func() {
+	return subscribe(consumer.IEventEmitter, "transportclose", listener)
+}
+
+/**
+ * OnProducerClose adds a listener of "producerclose" and returns the function
+ * removing it.
+ */
+func (consumer *Consumer) OnProducerClose(listener func()) func() {
+	return subscribe(consumer.IEventEmitter, "producerclose", listener)
+}
+
+/**
+ * OnProducerPause adds a listener of "producerpause" and returns the function
+ * removing it.
+ */
+func (consumer *Consumer) OnProducerPause(listener func()) func() {
+	return subscribe(consumer.IEventEmitter, "producerpause", listener)
+}
+
+/**
+ * OnProducerResume adds a listener of "producerresume" and returns the function
+ * removing it.
+ */
+func (consumer *Consumer) OnProducerResume(listener func()) func() {
+	return subscribe(consumer.IEventEmitter, "producerresume", listener)
+}
+
+/**
+ * OnScore adds a listener of "score" and returns the function removing it.
+ */
+func (consumer *Consumer) OnScore(listener func(ConsumerScore)) func() {
+	return subscribe(consumer.IEventEmitter, "score", listener)
+}
+
+/**
+ * OnLayersChange adds a listener of "layerschange" and returns the function
+ * removing it. The listener gets nil when no layers are being sent.
+ */
+func (consumer *Consumer) OnLayersChange(listener func(*ConsumerLayers)) func() {
+	return subscribe(consumer.IEventEmitter, "@layerschange", listener)
+}
+
+/**
+ * OnRtpPacket adds a listener of "rtp" (just emitted by Consumers created on
+ * a DirectTransport) getting the parsed packets, and returns the function
+ * removing it. Invalid packets are dropped.
+ */
+func (consumer *Consumer) OnRtpPacket(listener func(*rtp.Packet)) func() {
+	return subscribe(consumer.IEventEmitter, "rtp", func(payload []byte) {
+		if packet, ok := parseRtp(consumer.logger, payload); ok {
+			listener(packet)
+		}
+	})
+}
+
+/**
+ * OnTrace adds a listener of "trace" and returns the function removing it.
+ */
+func (consumer *Consumer) OnTrace(listener func(ConsumerTraceEventData)) func() {
+	return subscribe(consumer.IEventEmitter, "trace", listener)
+}
+
 // Close the Consumer.
-func (consumer *Consumer) Close() (err error) {
+func (consumer *Consumer) Close() error {
+	return consumer.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but with a context.
+func (consumer *Consumer) CloseContext(ctx context.Context) (err error) {
 	if atomic.CompareAndSwapUint32(&consumer.closed, 0, 1) {
 		consumer.logger.Debug("close()")
 
@@ -322,7 +410,7 @@ func (consumer *Consumer) Close() (err error) {
 		consumer.channel.RemoveAllListeners(consumer.internal.ConsumerId)
 		consumer.payloadChannel.RemoveAllListeners(consumer.internal.ConsumerId)
 
-		consumer.channel.Request("consumer.close", consumer.internal)
+		err = consumer.channel.RequestContext(ctx, "consumer.close", consumer.internal).Err()
 
 		consumer.Emit("@close")
 
@@ -349,27 +437,42 @@ func (consumer *Consumer) transportClosed() {
 }
 
 // Dump Consumer.
-func (consumer *Consumer) Dump() (dump *ConsumerDump, err error) {
+func (consumer *Consumer) Dump() (*ConsumerDump, error) {
+	return consumer.DumpContext(context.Background())
+}
+
+// DumpContext is like Dump but with a context.
+func (consumer *Consumer) DumpContext(ctx context.Context) (dump *ConsumerDump, err error) {
 	consumer.logger.Debug("dump()")
 
-	resp := consumer.channel.Request("consumer.dump", consumer.internal)
+	resp := consumer.channel.RequestContext(ctx, "consumer.dump", consumer.internal)
 	err = resp.Unmarshal(&dump)
 
 	return
 }
 
 // Get Consumer stats.
-func (consumer *Consumer) GetStats() (stats []*ConsumerStat, err error) {
+func (consumer *Consumer) GetStats() ([]*ConsumerStat, error) {
+	return consumer.GetStatsContext(context.Background())
+}
+
+// GetStatsContext is like GetStats but with a context.
+func (consumer *Consumer) GetStatsContext(ctx context.Context) (stats []*ConsumerStat, err error) {
 	consumer.logger.Debug("getStats()")
 
-	resp := consumer.channel.Request("consumer.getStats", consumer.internal)
+	resp := consumer.channel.RequestContext(ctx, "consumer.getStats", consumer.internal)
 	err = resp.Unmarshal(&stats)
 
 	return
 }
 
 // Pause the Consumer.
-func (consumer *Consumer) Pause() (err error) {
+func (consumer *Consumer) Pause() error {
+	return consumer.PauseContext(context.Background())
+}
+
+// PauseContext is like Pause but with a context.
+func (consumer *Consumer) PauseContext(ctx context.Context) (err error) {
 	consumer.locker.Lock()
 	defer consumer.locker.Unlock()
 
@@ -377,7 +480,7 @@ func (consumer *Consumer) Pause() (err error) {
 
 	wasPaused := consumer.paused || consumer.producerPaused
 
-	response := consumer.channel.Request("consumer.pause", consumer.internal)
+	response := consumer.channel.RequestContext(ctx, "consumer.pause", consumer.internal)
 
 	if err = response.Err(); err != nil {
 		return
@@ -394,7 +497,12 @@ func (consumer *Consumer) Pause() (err error) {
 }
 
 // Resume the Consumer.
-func (consumer *Consumer) Resume() (err error) {
+func (consumer *Consumer) Resume() error {
+	return consumer.ResumeContext(context.Background())
+}
+
+// ResumeContext is like Resume but with a context.
+func (consumer *Consumer) ResumeContext(ctx context.Context) (err error) {
 	consumer.locker.Lock()
 	defer consumer.locker.Unlock()
 
@@ -402,7 +510,7 @@ func (consumer *Consumer) Resume() (err error) {
 
 	wasPaused := consumer.paused || consumer.producerPaused
 
-	response := consumer.channel.Request("consumer.resume", consumer.internal)
+	response := consumer.channel.RequestContext(ctx, "consumer.resume", consumer.internal)
 
 	if err = response.Err(); err != nil {
 		return
@@ -419,10 +527,15 @@ func (consumer *Consumer) Resume() (err error) {
 }
 
 // Set preferred video layers.
-func (consumer *Consumer) SetPreferredLayers(layers ConsumerLayers) (err error) {
+func (consumer *Consumer) SetPreferredLayers(layers ConsumerLayers) error {
+	return consumer.SetPreferredLayersContext(context.Background(), layers)
+}
+
+// SetPreferredLayersContext is like SetPreferredLayers but with a context.
+func (consumer *Consumer) SetPreferredLayersContext(ctx context.Context, layers ConsumerLayers) (err error) {
 	consumer.logger.Debug("setPreferredLayers()")
 
-	response := consumer.channel.Request("consumer.setPreferredLayers", consumer.internal, layers)
+	response := consumer.channel.RequestContext(ctx, "consumer.setPreferredLayers", consumer.internal, layers)
 	fmt.Printf("setPreferredLayers: %s\n", response.data)
 	err = response.Unmarshal(&consumer.preferredLayers)
 
@@ -430,10 +543,15 @@ func (consumer *Consumer) SetPreferredLayers(layers ConsumerLayers) (err error)
 }
 
 // Set priority.
-func (consumer *Consumer) SetPriority(priority uint32) (err error) {
+func (consumer *Consumer) SetPriority(priority uint32) error {
+	return consumer.SetPriorityContext(context.Background(), priority)
+}
+
+// SetPriorityContext is like SetPriority but with a context.
+func (consumer *Consumer) SetPriorityContext(ctx context.Context, priority uint32) (err error) {
 	consumer.logger.Debug("setPriority()")
 
-	response := consumer.channel.Request("consumer.setPriority", consumer.internal, H{"priority": priority})
+	response := consumer.channel.RequestContext(ctx, "consumer.setPriority", consumer.internal, H{"priority": priority})
 
 	var result struct {
 		Priority uint32
@@ -448,17 +566,27 @@ func (consumer *Consumer) SetPriority(priority uint32) (err error) {
 }
 
 // Unset priority.
-func (consumer *Consumer) UnsetPriority() (err error) {
+func (consumer *Consumer) UnsetPriority() error {
+	return consumer.UnsetPriorityContext(context.Background())
+}
+
+// UnsetPriorityContext is like UnsetPriority but with a context.
+func (consumer *Consumer) UnsetPriorityContext(ctx context.Context) error {
 	consumer.logger.Debug("unsetPriority()")
 
-	return consumer.SetPriority(1)
+	return consumer.SetPriorityContext(ctx, 1)
 }
 
 // Request a key frame to the Producer.
 func (consumer *Consumer) RequestKeyFrame() error {
+	return consumer.RequestKeyFrameContext(context.Background())
+}
+
+// RequestKeyFrameContext is like RequestKeyFrame but with a context.
+func (consumer *Consumer) RequestKeyFrameContext(ctx context.Context) error {
 	consumer.logger.Debug("requestKeyFrame()")
 
-	response := consumer.channel.Request("consumer.requestKeyFrame", consumer.internal)
+	response := consumer.channel.RequestContext(ctx, "consumer.requestKeyFrame", consumer.internal)
 
 	return response.Err()
 }
@@ -467,13 +595,18 @@ func (consumer *Consumer) RequestKeyFrame() error {
  * Enable 'trace' event.
  */
 func (consumer *Consumer) EnableTraceEvent(types ...ConsumerTraceEventType) error {
+	return consumer.EnableTraceEventContext(context.Background(), types...)
+}
+
+// EnableTraceEventContext is like EnableTraceEvent but with a context.
+func (consumer *Consumer) EnableTraceEventContext(ctx context.Context, types ...ConsumerTraceEventType) error {
 	consumer.logger.Debug("enableTraceEvent()")
 
 	if types == nil {
 		types = []ConsumerTraceEventType{}
 	}
 
-	response := consumer.channel.Request("consumer.enableTraceEvent", consumer.internal, H{"types": types})
+	response := consumer.channel.RequestContext(ctx, "consumer.enableTraceEvent", consumer.internal, H{"types": types})
 
 	return response.Err()
 }
@@ -544,13 +677,20 @@ func (consumer *Consumer) handleWorkerNotifications() {
 			consumer.observer.SafeEmit("score", score)
 
 		case "layerschange":
-			var layers ConsumerLayers
+			var currentLayers *ConsumerLayers
 
-			json.Unmarshal(data, &layers)
+			json.Unmarshal(data, &currentLayers)
 
-			consumer.currentLayers = &layers
+			consumer.currentLayers = currentLayers
+
+			var layers ConsumerLayers
+
+			if currentLayers != nil {
+				layers = *currentLayers
+			}
 
 			consumer.SafeEmit("layerschange", layers)
+			consumer.SafeEmit("@layerschange", currentLayers)
 
 			// Emit observer event.
 			consumer.observer.SafeEmit("layerschange", layers)
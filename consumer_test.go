@@ -350,6 +350,19 @@ func (suite *ConsumerTestingSuite) TestTransportConsume_Succeeds() {
 	suite.ElementsMatch(transportDump.ConsumerIds, expectedTransportDump.ConsumerIds)
 }
 
+func (suite *ConsumerTestingSuite) TestTransportConsumeWithMidSucceeds() {
+	consumer, err := suite.transport2.Consume(ConsumerOptions{
+		ProducerId:      suite.audioProducer.Id(),
+		RtpCapabilities: suite.consumerDeviceCapabilities,
+		Mid:             "audio-1",
+	})
+	suite.Require().NoError(err)
+	suite.Equal("audio-1", consumer.RtpParameters().Mid)
+
+	// The given mid does not consume a sequential one.
+	suite.Equal("0", suite.audioConsumer().RtpParameters().Mid)
+}
+
 func (suite *ConsumerTestingSuite) TestTransportConsume_UnsupportedError() {
 	router, transport2, audioProducer := suite.router, suite.transport2, suite.audioProducer
 
@@ -581,13 +594,27 @@ func (suite *ConsumerTestingSuite) TestConsumerSetPreferredLayersSucceed() {
 func (suite *ConsumerTestingSuite) TestConsumerSetPreferredLayersRejectWithTypeError() {
 	videoConsumer := suite.videoConsumer(false)
 
-	err := videoConsumer.SetPreferredLayers(ConsumerLayers{})
+	// ConsumerLayers always carries spatialLayer, so send raw requests without it.
+	err := videoConsumer.channel.Request("consumer.setPreferredLayers", videoConsumer.internal, H{}).Err()
 	suite.Require().IsType(TypeError{}, err)
 
-	err = videoConsumer.SetPreferredLayers(ConsumerLayers{TemporalLayer: 2})
+	err = videoConsumer.channel.Request("consumer.setPreferredLayers", videoConsumer.internal, H{"temporalLayer": 2}).Err()
 	suite.Require().IsType(TypeError{}, err)
 }
 
+func (suite *ConsumerTestingSuite) TestConsumerSetPreferredLayersToTheLowestSpatialLayerSucceeds() {
+	videoConsumer := suite.videoConsumer(false)
+
+	// The spatial layer 0 is sent, not omitted.
+	err := videoConsumer.SetPreferredLayers(ConsumerLayers{})
+	suite.Require().NoError(err)
+	suite.Require().Equal(&ConsumerLayers{SpatialLayer: 0, TemporalLayer: 0}, videoConsumer.PreferredLayers())
+
+	err = videoConsumer.SetPreferredLayers(ConsumerLayers{TemporalLayer: 2})
+	suite.Require().NoError(err)
+	suite.Require().Equal(&ConsumerLayers{SpatialLayer: 0, TemporalLayer: 0}, videoConsumer.PreferredLayers())
+}
+
 func (suite *ConsumerTestingSuite) TestConsumerSetPrioritySucceed() {
 	videoConsumer := suite.videoConsumer(false)
 
@@ -671,6 +698,35 @@ func (suite *ConsumerTestingSuite) TestConsumerEmitsScore() {
 	suite.Equal(ConsumerScore{ProducerScore: 8, Score: 8}, audioConsumer.Score())
 }
 
+func (suite *ConsumerTestingSuite) TestConsumerOnLayersChange() {
+	videoConsumer := suite.videoConsumer(false)
+
+	onLayersChange := NewMockFunc(suite.T())
+	onLayersChangeFn := onLayersChange.Fn()
+
+	unsubscribe := videoConsumer.OnLayersChange(func(layers *ConsumerLayers) { onLayersChangeFn(layers) })
+
+	channel := videoConsumer.channel
+
+	channel.Emit(videoConsumer.Id(), "layerschange", []byte(`{"spatialLayer": 1, "temporalLayer": 0}`))
+
+	onLayersChange.ExpectCalledTimes(1)
+	onLayersChange.ExpectCalledWith(&ConsumerLayers{SpatialLayer: 1, TemporalLayer: 0})
+	suite.Equal(&ConsumerLayers{SpatialLayer: 1, TemporalLayer: 0}, videoConsumer.CurrentLayers())
+
+	channel.Emit(videoConsumer.Id(), "layerschange", []byte(`null`))
+
+	onLayersChange.ExpectCalledTimes(2)
+	onLayersChange.ExpectCalledWith((*ConsumerLayers)(nil))
+	suite.Nil(videoConsumer.CurrentLayers())
+
+	unsubscribe()
+
+	channel.Emit(videoConsumer.Id(), "layerschange", []byte(`{"spatialLayer": 0, "temporalLayer": 0}`))
+
+	onLayersChange.ExpectCalledTimes(2)
+}
+
 func (suite *ConsumerTestingSuite) TestConsumerClose() {
 	audioConsumer := suite.audioConsumer()
 	videoConsumer := suite.videoConsumer(true)
@@ -1,6 +1,7 @@
 package mediasoup
 
 import (
+	"context"
 	"encoding/json"
 	"sync/atomic"
 )
@@ -35,6 +36,14 @@ type DataConsumerOptions struct {
 	 */
 	MaxRetransmits uint16 `json:"maxRetransmits,omitempty"`
 
+	/**
+	 * Just if consuming over SCTP.
+	 * SCTP stream id of the DataConsumer, below the MIS of the transport. It
+	 * must not be used by another DataConsumer of the transport. Defaults to
+	 * the next available one.
+	 */
+	SctpStreamId *uint16 `json:"sctpStreamId,omitempty"`
+
 	/**
 	 * Custom application data.
 	 */
@@ -186,8 +195,52 @@ func (c *DataConsumer) Observer() IEventEmitter {
 	return c.observer
 }
 
+/**
+ * OnTransportClose adds a listener of "transportclose" and returns the function
+ * removing it.
+ */
+func (c *DataConsumer) OnTransportClose(listener func()) func() {
+	return subscribe(c.IEventEmitter, "transportclose", listener)
+}
+
+/**
+ * OnDataProducerClose adds a listener of "dataproducerclose" and returns the function
+ * removing it.
+ */
+func (c *DataConsumer) OnDataProducerClose(listener func()) func() {
+	return subscribe(c.IEventEmitter, "dataproducerclose", listener)
+}
+
+/**
+ * OnSctpSendBufferFull adds a listener of "sctpsendbufferfull" and returns the function
+ * removing it.
+ */
+func (c *DataConsumer) OnSctpSendBufferFull(listener func()) func() {
+	return subscribe(c.IEventEmitter, "sctpsendbufferfull", listener)
+}
+
+/**
+ * OnBufferedAmountLow adds a listener of "bufferedamountlow" and returns the function
+ * removing it.
+ */
+func (c *DataConsumer) OnBufferedAmountLow(listener func(bufferedAmount int64)) func() {
+	return subscribe(c.IEventEmitter, "bufferedamountlow", listener)
+}
+
+/**
+ * OnMessage adds a listener of "message" and returns the function removing it.
+ */
+func (c *DataConsumer) OnMessage(listener func(payload []byte, ppid int)) func() {
+	return subscribe(c.IEventEmitter, "message", listener)
+}
+
 // Close the DataConsumer.
-func (c *DataConsumer) Close() (err error) {
+func (c *DataConsumer) Close() error {
+	return c.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but with a context.
+func (c *DataConsumer) CloseContext(ctx context.Context) (err error) {
 	if atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
 		c.logger.Debug("close()")
 
@@ -195,10 +248,9 @@ func (c *DataConsumer) Close() (err error) {
 		c.channel.RemoveAllListeners(c.Id())
 		c.payloadChannel.RemoveAllListeners(c.Id())
 
-		response := c.channel.Request("dataConsumer.close", c.internal)
-
-		if err = response.Err(); err != nil {
-			return
+		// Closed even if the request fails, like the Router and the Transport.
+		if err = c.channel.RequestContext(ctx, "dataConsumer.close", c.internal).Err(); err != nil {
+			c.logger.Error("close() failed: %s", err)
 		}
 
 		c.Emit("@close")
@@ -226,20 +278,30 @@ func (c *DataConsumer) transportClosed() {
 }
 
 // Dump DataConsumer.
-func (c *DataConsumer) Dump() (data DataConsumerDump, err error) {
+func (c *DataConsumer) Dump() (DataConsumerDump, error) {
+	return c.DumpContext(context.Background())
+}
+
+// DumpContext is like Dump but with a context.
+func (c *DataConsumer) DumpContext(ctx context.Context) (data DataConsumerDump, err error) {
 	c.logger.Debug("dump()")
 
-	resp := c.channel.Request("dataConsumer.dump", c.internal)
+	resp := c.channel.RequestContext(ctx, "dataConsumer.dump", c.internal)
 	err = resp.Unmarshal(&data)
 
 	return
 }
 
 // Get DataConsumer stats.
-func (c *DataConsumer) GetStats() (stats []*DataConsumerStat, err error) {
+func (c *DataConsumer) GetStats() ([]*DataConsumerStat, error) {
+	return c.GetStatsContext(context.Background())
+}
+
+// GetStatsContext is like GetStats but with a context.
+func (c *DataConsumer) GetStatsContext(ctx context.Context) (stats []*DataConsumerStat, err error) {
 	c.logger.Debug("getStats()")
 
-	resp := c.channel.Request("dataConsumer.getStats", c.internal)
+	resp := c.channel.RequestContext(ctx, "dataConsumer.getStats", c.internal)
 	err = resp.Unmarshal(&stats)
 
 	return
@@ -249,9 +311,14 @@ func (c *DataConsumer) GetStats() (stats []*DataConsumerStat, err error) {
  * Set buffered amount low threshold.
  */
 func (c *DataConsumer) SetBufferedAmountLowThreshold(threshold int) error {
+	return c.SetBufferedAmountLowThresholdContext(context.Background(), threshold)
+}
+
+// SetBufferedAmountLowThresholdContext is like SetBufferedAmountLowThreshold but with a context.
+func (c *DataConsumer) SetBufferedAmountLowThresholdContext(ctx context.Context, threshold int) error {
 	c.logger.Debug("setBufferedAmountLowThreshold() [threshold:%s]", threshold)
 
-	resp := c.channel.Request("dataConsumer.setBufferedAmountLowThreshold", c.internal, H{
+	resp := c.channel.RequestContext(ctx, "dataConsumer.setBufferedAmountLowThreshold", c.internal, H{
 		"threshold": threshold,
 	})
 
@@ -261,7 +328,12 @@ func (c *DataConsumer) SetBufferedAmountLowThreshold(threshold int) error {
 /**
  * Send data.
  */
-func (c *DataConsumer) Send(data []byte, ppid ...int) (err error) {
+func (c *DataConsumer) Send(data []byte, ppid ...int) error {
+	return c.SendContext(context.Background(), data, ppid...)
+}
+
+// SendContext is like Send but with a context.
+func (c *DataConsumer) SendContext(ctx context.Context, data []byte, ppid ...int) (err error) {
 	/*
 	 * +-------------------------------+----------+
 	 * | Value                         | SCTP     |
@@ -293,7 +365,7 @@ func (c *DataConsumer) Send(data []byte, ppid ...int) (err error) {
 		data = make([]byte, 1)
 	}
 
-	resp := c.payloadChannel.Request("dataConsumer.send", c.internal, H{"ppid": ppid}, data)
+	resp := c.payloadChannel.RequestContext(ctx, "dataConsumer.send", c.internal, H{"ppid": ppidVal}, data)
 
 	return resp.Err()
 }
@@ -302,29 +374,39 @@ func (c *DataConsumer) Send(data []byte, ppid ...int) (err error) {
  * Send text.
  */
 func (c *DataConsumer) SendText(message string) error {
+	return c.SendTextContext(context.Background(), message)
+}
+
+// SendTextContext is like SendText but with a context.
+func (c *DataConsumer) SendTextContext(ctx context.Context, message string) error {
 	ppid := 51
 
 	if len(message) == 0 {
 		ppid = 56
 	}
 
-	return c.Send([]byte(message), ppid)
+	return c.SendContext(ctx, []byte(message), ppid)
 }
 
 /**
  * Get buffered amount size.
  */
-func (c *DataConsumer) GetBufferedAmount() (bufferedAmount int64, err error) {
+func (c *DataConsumer) GetBufferedAmount() (int64, error) {
+	return c.GetBufferedAmountContext(context.Background())
+}
+
+// GetBufferedAmountContext is like GetBufferedAmount but with a context.
+func (c *DataConsumer) GetBufferedAmountContext(ctx context.Context) (bufferedAmount int64, err error) {
 	c.logger.Debug("getBufferedAmount()")
 
-	resp := c.channel.Request("dataConsumer.getBufferedAmount", c.internal)
+	resp := c.channel.RequestContext(ctx, "dataConsumer.getBufferedAmount", c.internal)
 
 	var result struct {
-		BufferAmount int64
+		BufferedAmount int64 `json:"bufferedAmount"`
 	}
 	err = resp.Unmarshal(&result)
 
-	return result.BufferAmount, err
+	return result.BufferedAmount, err
 }
 
 func (c *DataConsumer) handleWorkerNotifications() {
@@ -345,11 +427,11 @@ func (c *DataConsumer) handleWorkerNotifications() {
 
 		case "bufferedamountlow":
 			var result struct {
-				BufferAmount int64
+				BufferedAmount int64 `json:"bufferedAmount"`
 			}
 			json.Unmarshal(data, &result)
 
-			c.SafeEmit("bufferedamountlow", result.BufferAmount)
+			c.SafeEmit("bufferedamountlow", result.BufferedAmount)
 
 		default:
 			c.logger.Error(`ignoring unknown event "%s" in channel listener`, event)
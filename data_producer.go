@@ -1,6 +1,9 @@
 package mediasoup
 
-import "sync/atomic"
+import (
+	"context"
+	"sync/atomic"
+)
 
 type DataProducerOptions struct {
 	/**
@@ -163,8 +166,21 @@ func (p *DataProducer) Observer() IEventEmitter {
 	return p.observer
 }
 
+/**
+ * OnTransportClose adds a listener of "transportclose" and returns the function
+ * removing it.
+ */
+func (p *DataProducer) OnTransportClose(listener func()) func() {
+	return subscribe(p.IEventEmitter, "transportclose", listener)
+}
+
 // Close the DataProducer.
-func (p *DataProducer) Close() (err error) {
+func (p *DataProducer) Close() error {
+	return p.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but with a context.
+func (p *DataProducer) CloseContext(ctx context.Context) (err error) {
 	if atomic.CompareAndSwapUint32(&p.closed, 0, 1) {
 		p.logger.Debug("close()")
 
@@ -172,10 +188,9 @@ func (p *DataProducer) Close() (err error) {
 		p.channel.RemoveAllListeners(p.Id())
 		p.payloadChannel.RemoveAllListeners(p.Id())
 
-		response := p.channel.Request("dataProducer.close", p.internal)
-
-		if err = response.Err(); err != nil {
-			return
+		// Closed even if the request fails, like the Router and the Transport.
+		if err = p.channel.RequestContext(ctx, "dataProducer.close", p.internal).Err(); err != nil {
+			p.logger.Error("close() failed: %s", err)
 		}
 
 		p.Emit("@close")
@@ -199,19 +214,29 @@ func (p *DataProducer) transportClosed() {
 }
 
 // Dump DataConsumer.
-func (p *DataProducer) Dump() (dump DataProducerDump, err error) {
+func (p *DataProducer) Dump() (DataProducerDump, error) {
+	return p.DumpContext(context.Background())
+}
+
+// DumpContext is like Dump but with a context.
+func (p *DataProducer) DumpContext(ctx context.Context) (dump DataProducerDump, err error) {
 	p.logger.Debug("dump()")
 
-	resp := p.channel.Request("dataProducer.dump", p.internal)
+	resp := p.channel.RequestContext(ctx, "dataProducer.dump", p.internal)
 	err = resp.Unmarshal(&dump)
 	return
 }
 
 // Get DataConsumer stats.
-func (p *DataProducer) GetStats() (stats []*DataProducerStat, err error) {
+func (p *DataProducer) GetStats() ([]*DataProducerStat, error) {
+	return p.GetStatsContext(context.Background())
+}
+
+// GetStatsContext is like GetStats but with a context.
+func (p *DataProducer) GetStatsContext(ctx context.Context) (stats []*DataProducerStat, err error) {
 	p.logger.Debug("getStats()")
 
-	resp := p.channel.Request("dataProducer.getStats", p.internal)
+	resp := p.channel.RequestContext(ctx, "dataProducer.getStats", p.internal)
 	err = resp.Unmarshal(&stats)
 
 	return
@@ -0,0 +1,201 @@
+// Package databus publishes server messages on named topics to the data
+// channels of the subscribing peers.
+//
+// A Bus owns a DirectTransport, in which each Topic is a DataProducer
+// labelled with the topic name. Subscribing a peer consumes it on the peer's
+// transport, so that the worker fans the published messages out.
+//
+// Subscriptions with a filter consume a second DataProducer of the Topic,
+// which never sends. The Bus sends them the messages accepted by their filter
+// with DataConsumer.Send instead, one request per subscriber and message.
+package databus
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+const defaultProtocol = "databus"
+
+type BusOptions struct {
+	/**
+	 * Maximum size of the published messages. Default 262144.
+	 */
+	MaxMessageSize uint32
+
+	/**
+	 * Maximum number of DataConsumer.Send requests sent at the same time to
+	 * the filtered subscribers of a message. Default 16.
+	 */
+	MaxConcurrency int
+}
+
+/**
+ * Bus holds the topics published in a Router. It is closed with the Router.
+ *
+ * @emits close
+ */
+type Bus struct {
+	mediasoup.IEventEmitter
+	logger    mediasoup.Logger
+	options   BusOptions
+	transport *mediasoup.DirectTransport
+	topics    map[string]*Topic
+	closed    uint32
+	locker    sync.Mutex
+}
+
+func NewBus(router *mediasoup.Router, options BusOptions) (bus *Bus, err error) {
+	logger := mediasoup.NewLogger("DataBus")
+
+	logger.Debug("constructor()")
+
+	if options.MaxMessageSize == 0 {
+		options.MaxMessageSize = 262144
+	}
+	if options.MaxConcurrency <= 0 {
+		options.MaxConcurrency = 16
+	}
+
+	transport, err := router.CreateDirectTransport(mediasoup.DirectTransportOptions{
+		MaxMessageSize: options.MaxMessageSize,
+	})
+	if err != nil {
+		return
+	}
+
+	bus = &Bus{
+		IEventEmitter: mediasoup.NewEventEmitter(),
+		logger:        logger,
+		options:       options,
+		transport:     transport,
+		topics:        make(map[string]*Topic),
+	}
+
+	transport.Observer().On("close", func() { bus.Close() })
+
+	return
+}
+
+/**
+ * Whether the Bus is closed.
+ */
+func (b *Bus) Closed() bool {
+	return atomic.LoadUint32(&b.closed) > 0
+}
+
+/**
+ * Close the Bus with its topics.
+ */
+func (b *Bus) Close() {
+	if !atomic.CompareAndSwapUint32(&b.closed, 0, 1) {
+		return
+	}
+
+	b.logger.Debug("close()")
+
+	b.locker.Lock()
+	topics := b.topics
+	b.topics = make(map[string]*Topic)
+	b.locker.Unlock()
+
+	for _, topic := range topics {
+		topic.Close()
+	}
+
+	b.transport.Close()
+
+	b.SafeEmit("close")
+}
+
+/**
+ * Transport publishing the topics.
+ */
+func (b *Bus) Transport() *mediasoup.DirectTransport {
+	return b.transport
+}
+
+/**
+ * Topic returns the topic of the given name, nil if none.
+ */
+func (b *Bus) Topic(name string) *Topic {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+
+	return b.topics[name]
+}
+
+/**
+ * Topics returns the topics sorted by name.
+ */
+func (b *Bus) Topics() []*Topic {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+
+	topics := make([]*Topic, 0, len(b.topics))
+
+	for _, topic := range b.topics {
+		topics = append(topics, topic)
+	}
+	sort.Slice(topics, func(i, j int) bool { return topics[i].name < topics[j].name })
+
+	return topics
+}
+
+/**
+ * CreateTopic creates a topic, whose name is the label of its DataProducers.
+ */
+func (b *Bus) CreateTopic(name string, options TopicOptions) (*Topic, error) {
+	return b.CreateTopicContext(context.Background(), name, options)
+}
+
+// CreateTopicContext is like CreateTopic but with a context.
+func (b *Bus) CreateTopicContext(ctx context.Context, name string, options TopicOptions) (topic *Topic, err error) {
+	b.logger.Debug("createTopic() [name:%s]", name)
+
+	if len(name) == 0 {
+		return nil, mediasoup.NewTypeError("missing topic name")
+	}
+	if !options.Unordered && (options.MaxPacketLifeTime > 0 || options.MaxRetransmits > 0) {
+		return nil, mediasoup.NewTypeError("maxPacketLifeTime and maxRetransmits require unordered delivery")
+	}
+	if options.MaxPacketLifeTime > 0 && options.MaxRetransmits > 0 {
+		return nil, mediasoup.NewTypeError("just maxPacketLifeTime or maxRetransmits can be given")
+	}
+	if len(options.Protocol) == 0 {
+		options.Protocol = defaultProtocol
+	}
+
+	// Topics are created one at a time, so that a name is not taken twice.
+	b.locker.Lock()
+	defer b.locker.Unlock()
+
+	if b.Closed() {
+		return nil, mediasoup.NewInvalidStateError("Bus closed")
+	}
+	if _, ok := b.topics[name]; ok {
+		return nil, mediasoup.NewTypeError(`topic "%s" already exists`, name)
+	}
+
+	topic, err = newTopic(ctx, b, name, options)
+	if err != nil {
+		return
+	}
+	b.topics[name] = topic
+
+	return
+}
+
+// removeTopic forgets a closed topic.
+func (b *Bus) removeTopic(topic *Topic) {
+	b.locker.Lock()
+	defer b.locker.Unlock()
+
+	if b.topics[topic.name] == topic {
+		delete(b.topics, topic.name)
+	}
+}
@@ -0,0 +1,198 @@
+package databus
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createPeerTransport(t *testing.T, router *mediasoup.Router) *mediasoup.WebRtcTransport {
+	transport, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps:  []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+		EnableSctp: true,
+	})
+	require.NoError(t, err)
+
+	return transport
+}
+
+func TestBus_Topics(t *testing.T) {
+	router, _ := workertest.NewRouter(t)
+
+	bus, err := NewBus(router, BusOptions{})
+	require.NoError(t, err)
+
+	chat, err := bus.CreateTopic("chat", TopicOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "chat", chat.DataProducer().Label())
+	assert.Equal(t, "databus", chat.DataProducer().Protocol())
+
+	events, err := bus.CreateTopic("events", TopicOptions{Protocol: "json"})
+	require.NoError(t, err)
+	assert.Equal(t, "json", events.Protocol())
+
+	assert.Equal(t, []*Topic{chat, events}, bus.Topics())
+	assert.Same(t, chat, bus.Topic("chat"))
+
+	_, err = bus.CreateTopic("chat", TopicOptions{})
+	assert.IsType(t, mediasoup.TypeError{}, err)
+	_, err = bus.CreateTopic("", TopicOptions{})
+	assert.IsType(t, mediasoup.TypeError{}, err)
+	_, err = bus.CreateTopic("lossy", TopicOptions{MaxRetransmits: 2})
+	assert.IsType(t, mediasoup.TypeError{}, err)
+
+	chat.Close()
+	assert.True(t, chat.DataProducer().Closed())
+	assert.Nil(t, bus.Topic("chat"))
+
+	// The Bus is closed with the Router.
+	router.Close()
+	assert.Eventually(t, bus.Closed, time.Second, 5*time.Millisecond)
+	assert.True(t, events.Closed())
+	assert.Empty(t, bus.Topics())
+
+	_, err = bus.CreateTopic("chat", TopicOptions{})
+	assert.Error(t, err)
+}
+
+func TestTopic_Subscribe(t *testing.T) {
+	router, _ := workertest.NewRouter(t)
+
+	bus, err := NewBus(router, BusOptions{})
+	require.NoError(t, err)
+	defer bus.Close()
+
+	topic, err := bus.CreateTopic("positions", TopicOptions{Unordered: true, MaxPacketLifeTime: 100})
+	require.NoError(t, err)
+
+	transport := createPeerTransport(t, router)
+
+	subscription, err := topic.Subscribe(transport, SubscribeOptions{AppData: "peer1"})
+	require.NoError(t, err)
+	assert.Equal(t, transport.Id(), subscription.TransportId())
+	assert.Equal(t, "peer1", subscription.AppData())
+
+	dataConsumer := subscription.DataConsumer()
+	assert.Equal(t, topic.DataProducer().Id(), dataConsumer.DataProducerId())
+	assert.Equal(t, "positions", dataConsumer.Label())
+	assert.EqualValues(t, "sctp", dataConsumer.Type())
+	assert.False(t, *dataConsumer.SctpStreamParameters().Ordered)
+	assert.EqualValues(t, 100, dataConsumer.SctpStreamParameters().MaxPacketLifeTime)
+
+	_, err = topic.Subscribe(transport, SubscribeOptions{})
+	assert.IsType(t, mediasoup.TypeError{}, err)
+
+	// A transport without SCTP cannot subscribe.
+	transport2, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+	})
+	require.NoError(t, err)
+	_, err = topic.Subscribe(transport2, SubscribeOptions{})
+	assert.Error(t, err)
+
+	assert.Equal(t, []*Subscription{subscription}, topic.Subscriptions())
+
+	// The subscription is removed with the transport, which can subscribe
+	// again.
+	transport.Close()
+	assert.Eventually(t, func() bool { return len(topic.Subscriptions()) == 0 }, time.Second, 5*time.Millisecond)
+
+	transport = createPeerTransport(t, router)
+	subscription, err = topic.Subscribe(transport, SubscribeOptions{})
+	require.NoError(t, err)
+
+	// The subscriptions are closed with the topic.
+	topic.Close()
+	assert.Eventually(t, subscription.Closed, time.Second, 5*time.Millisecond)
+}
+
+func TestTopic_Publish(t *testing.T) {
+	router, process := workertest.NewRouter(t)
+
+	bus, err := NewBus(router, BusOptions{})
+	require.NoError(t, err)
+	defer bus.Close()
+
+	topic, err := bus.CreateTopic("rooms", TopicOptions{})
+	require.NoError(t, err)
+
+	all, err := topic.Subscribe(createPeerTransport(t, router), SubscribeOptions{})
+	require.NoError(t, err)
+
+	roomA, err := topic.Subscribe(createPeerTransport(t, router), SubscribeOptions{
+		Filter: func(message Message) bool { return message.Attributes["room"] == "a" },
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, topic.DataProducer().Id(), roomA.DataConsumer().DataProducerId())
+
+	// The messages published are received by a DirectTransport subscriber.
+	directTransport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+	direct, err := topic.Subscribe(directTransport, SubscribeOptions{})
+	require.NoError(t, err)
+
+	type received struct {
+		data string
+		ppid int
+	}
+	messages := make(chan received, 10)
+	direct.DataConsumer().OnMessage(func(payload []byte, ppid int) {
+		messages <- received{string(payload), ppid}
+	})
+
+	for _, room := range []string{"a", "b", "a"} {
+		require.NoError(t, topic.Publish(Message{
+			Data:       []byte("hello " + room),
+			Text:       true,
+			Attributes: map[string]string{"room": room},
+		}))
+	}
+
+	for _, room := range []string{"a", "b", "a"} {
+		select {
+		case message := <-messages:
+			assert.Equal(t, received{"hello " + room, mediasoup.PPID_WEBRTC_STRING}, message)
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+	}
+
+	stats, err := all.GetStats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, stats.MessagesSent)
+	assert.EqualValues(t, 0, stats.MessagesFiltered)
+
+	stats, err = roomA.GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, roomA.Id(), stats.SubscriptionId)
+	assert.EqualValues(t, 2, stats.MessagesSent)
+	assert.EqualValues(t, len("hello a")*2, stats.BytesSent)
+	assert.EqualValues(t, 1, stats.MessagesFiltered)
+
+	// Failing to send to a subscription is counted.
+	process.SetHandler("dataConsumer.send", func(req workertest.Request) (interface{}, error) {
+		return nil, errors.New("send failed")
+	})
+
+	require.NoError(t, topic.Publish(Message{
+		Data:       []byte{1, 2, 3},
+		Attributes: map[string]string{"room": "a"},
+	}))
+
+	allStats, err := topic.GetStats()
+	require.NoError(t, err)
+	assert.Len(t, allStats, 3)
+
+	for _, stats := range allStats {
+		if stats.SubscriptionId == roomA.Id() {
+			assert.EqualValues(t, 1, stats.MessagesFailed)
+		} else {
+			assert.EqualValues(t, 4, stats.MessagesSent)
+		}
+	}
+}
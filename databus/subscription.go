@@ -0,0 +1,137 @@
+package databus
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+type SubscribeOptions struct {
+	/**
+	 * Filter tells whether a message is sent to the subscription. Default
+	 * nil, all the messages are sent.
+	 */
+	Filter func(message Message) bool
+
+	/**
+	 * Custom application data, also given to the DataConsumer.
+	 */
+	AppData interface{}
+}
+
+/**
+ * Subscription is the DataConsumer of a topic in the transport of a peer. It
+ * is closed with its DataConsumer.
+ */
+type Subscription struct {
+	// Accessed atomically, first for the 64-bit alignment.
+	messagesFiltered uint64
+	messagesFailed   uint64
+	topic            *Topic
+	transportId      string
+	dataConsumer     *mediasoup.DataConsumer
+	filter           func(message Message) bool
+	appData          interface{}
+}
+
+/**
+ * SubscriptionStats holds the delivery stats of a subscription.
+ */
+type SubscriptionStats struct {
+	SubscriptionId string
+	TransportId    string
+	AppData        interface{}
+
+	/**
+	 * Messages and bytes sent by the DataConsumer, and bytes buffered to be
+	 * sent, from DataConsumer.GetStats.
+	 */
+	MessagesSent   int64
+	BytesSent      int64
+	BufferedAmount uint32
+
+	/**
+	 * Messages rejected by the filter.
+	 */
+	MessagesFiltered int64
+
+	/**
+	 * Messages which failed to be sent to the DataConsumer.
+	 */
+	MessagesFailed int64
+}
+
+/**
+ * Subscription id, the id of its DataConsumer.
+ */
+func (s *Subscription) Id() string {
+	return s.dataConsumer.Id()
+}
+
+func (s *Subscription) Topic() *Topic {
+	return s.topic
+}
+
+/**
+ * Id of the subscribed transport.
+ */
+func (s *Subscription) TransportId() string {
+	return s.transportId
+}
+
+func (s *Subscription) DataConsumer() *mediasoup.DataConsumer {
+	return s.dataConsumer
+}
+
+/**
+ * App custom data.
+ */
+func (s *Subscription) AppData() interface{} {
+	return s.appData
+}
+
+/**
+ * Whether the Subscription is closed.
+ */
+func (s *Subscription) Closed() bool {
+	return s.dataConsumer.Closed()
+}
+
+/**
+ * Close the Subscription and its DataConsumer.
+ */
+func (s *Subscription) Close() error {
+	return s.dataConsumer.Close()
+}
+
+/**
+ * GetStats returns the delivery stats of the subscription.
+ */
+func (s *Subscription) GetStats() (SubscriptionStats, error) {
+	return s.GetStatsContext(context.Background())
+}
+
+// GetStatsContext is like GetStats but with a context.
+func (s *Subscription) GetStatsContext(ctx context.Context) (stats SubscriptionStats, err error) {
+	dataConsumerStats, err := s.dataConsumer.GetStatsContext(ctx)
+	if err != nil {
+		return
+	}
+
+	stats = SubscriptionStats{
+		SubscriptionId:   s.Id(),
+		TransportId:      s.transportId,
+		AppData:          s.appData,
+		MessagesFiltered: int64(atomic.LoadUint64(&s.messagesFiltered)),
+		MessagesFailed:   int64(atomic.LoadUint64(&s.messagesFailed)),
+	}
+
+	for _, stat := range dataConsumerStats {
+		stats.MessagesSent += stat.MessagesSent
+		stats.BytesSent += stat.BytesSent
+		stats.BufferedAmount += stat.BufferedAmount
+	}
+
+	return
+}
@@ -0,0 +1,390 @@
+package databus
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+type TopicOptions struct {
+	/**
+	 * Protocol of the DataProducers of the topic. Default "databus".
+	 */
+	Protocol string
+
+	/**
+	 * Whether the messages may be delivered out of order, which also makes
+	 * them unreliable if MaxPacketLifeTime or MaxRetransmits is given.
+	 * Default false, ordered and reliable.
+	 */
+	Unordered bool
+
+	/**
+	 * When unordered, the time (in milliseconds) after which a message stops
+	 * being retransmitted.
+	 */
+	MaxPacketLifeTime uint16
+
+	/**
+	 * When unordered, the maximum number of times a message is
+	 * retransmitted.
+	 */
+	MaxRetransmits uint16
+
+	/**
+	 * Custom application data.
+	 */
+	AppData interface{}
+}
+
+/**
+ * Message is a published message.
+ */
+type Message struct {
+	Data []byte
+
+	/**
+	 * Whether Data is UTF-8 text, sent with the WebRTC String PPID.
+	 */
+	Text bool
+
+	/**
+	 * Attributes given to the filters of the subscriptions, they are not
+	 * sent.
+	 */
+	Attributes map[string]string
+}
+
+/**
+ * Topic fans the published messages out to its subscriptions. It is closed
+ * with its Bus, or when its DataProducer is closed.
+ *
+ * @emits subscribe - (subscription: *Subscription)
+ * @emits close
+ */
+type Topic struct {
+	mediasoup.IEventEmitter
+	logger   mediasoup.Logger
+	bus      *Bus
+	name     string
+	options  TopicOptions
+	producer *mediasoup.DataProducer
+	// filteredProducer is consumed by the filtered subscriptions, it never
+	// sends.
+	filteredProducer *mediasoup.DataProducer
+	subscriptions    map[string]*Subscription
+	// Ids of the subscribed transports, reserved while subscribing.
+	transportIds map[string]struct{}
+	// Number of subscriptions without filter.
+	broadcasts int
+	closed     uint32
+	locker     sync.Mutex
+}
+
+func newTopic(ctx context.Context, bus *Bus, name string, options TopicOptions) (topic *Topic, err error) {
+	dataProducerOptions := mediasoup.DataProducerOptions{
+		Label:    name,
+		Protocol: options.Protocol,
+		AppData:  options.AppData,
+	}
+
+	producer, err := bus.transport.ProduceDataContext(ctx, dataProducerOptions)
+	if err != nil {
+		return
+	}
+	filteredProducer, err := bus.transport.ProduceDataContext(ctx, dataProducerOptions)
+	if err != nil {
+		producer.Close()
+		return
+	}
+
+	topic = &Topic{
+		IEventEmitter:    mediasoup.NewEventEmitter(),
+		logger:           mediasoup.NewLogger("DataBusTopic"),
+		bus:              bus,
+		name:             name,
+		options:          options,
+		producer:         producer,
+		filteredProducer: filteredProducer,
+		subscriptions:    make(map[string]*Subscription),
+		transportIds:     make(map[string]struct{}),
+	}
+
+	producer.Observer().On("close", func() { topic.Close() })
+	filteredProducer.Observer().On("close", func() { topic.Close() })
+
+	return
+}
+
+/**
+ * Topic name, the label of its DataProducers.
+ */
+func (t *Topic) Name() string {
+	return t.name
+}
+
+/**
+ * Protocol of the DataProducers of the topic.
+ */
+func (t *Topic) Protocol() string {
+	return t.options.Protocol
+}
+
+/**
+ * App custom data.
+ */
+func (t *Topic) AppData() interface{} {
+	return t.options.AppData
+}
+
+/**
+ * DataProducer consumed by the subscriptions without filter.
+ */
+func (t *Topic) DataProducer() *mediasoup.DataProducer {
+	return t.producer
+}
+
+/**
+ * Whether the Topic is closed.
+ */
+func (t *Topic) Closed() bool {
+	return atomic.LoadUint32(&t.closed) > 0
+}
+
+/**
+ * Close the Topic, which closes its subscriptions.
+ */
+func (t *Topic) Close() {
+	if !atomic.CompareAndSwapUint32(&t.closed, 0, 1) {
+		return
+	}
+
+	t.logger.Debug("close() [name:%s]", t.name)
+
+	// The DataConsumers of the subscriptions are closed with the
+	// DataProducers.
+	t.producer.Close()
+	t.filteredProducer.Close()
+
+	t.bus.removeTopic(t)
+
+	t.SafeEmit("close")
+}
+
+/**
+ * Subscriptions returns the alive subscriptions sorted by id.
+ */
+func (t *Topic) Subscriptions() []*Subscription {
+	t.locker.Lock()
+	defer t.locker.Unlock()
+
+	subscriptions := make([]*Subscription, 0, len(t.subscriptions))
+
+	for _, subscription := range t.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].Id() < subscriptions[j].Id()
+	})
+
+	return subscriptions
+}
+
+/**
+ * Subscribe consumes the topic on the transport of a peer, which must have
+ * SCTP enabled unless it is a DirectTransport. A transport can subscribe once
+ * to a topic.
+ */
+func (t *Topic) Subscribe(transport mediasoup.ITransport, options SubscribeOptions) (*Subscription, error) {
+	return t.SubscribeContext(context.Background(), transport, options)
+}
+
+// SubscribeContext is like Subscribe but with a context.
+func (t *Topic) SubscribeContext(ctx context.Context, transport mediasoup.ITransport, options SubscribeOptions) (subscription *Subscription, err error) {
+	t.logger.Debug("subscribe() [name:%s, transportId:%s]", t.name, transport.Id())
+
+	if t.Closed() {
+		return nil, mediasoup.NewInvalidStateError("Topic closed")
+	}
+
+	t.locker.Lock()
+	if _, ok := t.transportIds[transport.Id()]; ok {
+		t.locker.Unlock()
+		return nil, mediasoup.NewTypeError(`transport already subscribed to topic "%s"`, t.name)
+	}
+	t.transportIds[transport.Id()] = struct{}{}
+	t.locker.Unlock()
+
+	dataProducer := t.producer
+
+	if options.Filter != nil {
+		dataProducer = t.filteredProducer
+	}
+
+	dataConsumerOptions := mediasoup.DataConsumerOptions{
+		DataProducerId: dataProducer.Id(),
+		AppData:        options.AppData,
+	}
+
+	// The delivery is set by the SCTP stream parameters of the DataConsumer.
+	if _, ok := transport.(*mediasoup.DirectTransport); !ok {
+		dataConsumerOptions.Ordered = mediasoup.Bool(!t.options.Unordered)
+		dataConsumerOptions.MaxPacketLifeTime = t.options.MaxPacketLifeTime
+		dataConsumerOptions.MaxRetransmits = t.options.MaxRetransmits
+	}
+
+	dataConsumer, err := transport.ConsumeDataContext(ctx, dataConsumerOptions)
+	if err != nil {
+		t.locker.Lock()
+		delete(t.transportIds, transport.Id())
+		t.locker.Unlock()
+		return
+	}
+
+	subscription = &Subscription{
+		topic:        t,
+		transportId:  transport.Id(),
+		dataConsumer: dataConsumer,
+		filter:       options.Filter,
+		appData:      options.AppData,
+	}
+
+	t.locker.Lock()
+	t.subscriptions[dataConsumer.Id()] = subscription
+	if subscription.filter == nil {
+		t.broadcasts++
+	}
+	t.locker.Unlock()
+
+	remove := func() {
+		t.locker.Lock()
+		defer t.locker.Unlock()
+
+		if _, ok := t.subscriptions[dataConsumer.Id()]; !ok {
+			return
+		}
+		delete(t.subscriptions, dataConsumer.Id())
+		delete(t.transportIds, subscription.transportId)
+
+		if subscription.filter == nil {
+			t.broadcasts--
+		}
+	}
+
+	dataConsumer.Observer().On("close", remove)
+
+	// The DataConsumer may have been closed before observing it.
+	if dataConsumer.Closed() {
+		remove()
+	}
+
+	t.SafeEmit("subscribe", subscription)
+
+	return
+}
+
+/**
+ * Publish sends a message to the subscriptions. Failing to send it to a
+ * filtered subscription is counted in its stats, not returned.
+ */
+func (t *Topic) Publish(message Message) error {
+	return t.PublishContext(context.Background(), message)
+}
+
+// PublishContext is like Publish but with a context, which bounds the
+// requests sent to the filtered subscriptions.
+func (t *Topic) PublishContext(ctx context.Context, message Message) (err error) {
+	if t.Closed() {
+		return mediasoup.NewInvalidStateError("Topic closed")
+	}
+
+	ppid := messagePpid(message)
+
+	t.locker.Lock()
+	broadcasts := t.broadcasts
+	filtered := make([]*Subscription, 0, len(t.subscriptions)-broadcasts)
+	for _, subscription := range t.subscriptions {
+		if subscription.filter != nil {
+			filtered = append(filtered, subscription)
+		}
+	}
+	t.locker.Unlock()
+
+	if broadcasts > 0 {
+		if err = t.producer.Send(message.Data, ppid); err != nil {
+			return
+		}
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, t.bus.options.MaxConcurrency)
+
+	for _, subscription := range filtered {
+		if !subscription.filter(message) {
+			atomic.AddUint64(&subscription.messagesFiltered, 1)
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(subscription *Subscription) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := subscription.dataConsumer.SendContext(ctx, message.Data, ppid); err != nil {
+				t.logger.Debug("publish() | sending to DataConsumer failed [dataConsumerId:%s]: %s",
+					subscription.Id(), err)
+
+				atomic.AddUint64(&subscription.messagesFailed, 1)
+			}
+		}(subscription)
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+/**
+ * GetStats returns the delivery stats of the subscriptions, sorted by id.
+ */
+func (t *Topic) GetStats() ([]SubscriptionStats, error) {
+	return t.GetStatsContext(context.Background())
+}
+
+// GetStatsContext is like GetStats but with a context.
+func (t *Topic) GetStatsContext(ctx context.Context) (stats []SubscriptionStats, err error) {
+	for _, subscription := range t.Subscriptions() {
+		stat, err := subscription.GetStatsContext(ctx)
+		if err != nil {
+			// Closed meanwhile.
+			if subscription.Closed() {
+				continue
+			}
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+
+	return
+}
+
+// messagePpid returns the WebRTC PPID of a message.
+func messagePpid(message Message) int {
+	switch {
+	case message.Text && len(message.Data) > 0:
+		return mediasoup.PPID_WEBRTC_STRING
+	case message.Text:
+		return 56
+	case len(message.Data) > 0:
+		return mediasoup.PPID_WEBRTC_BINARY
+	default:
+		return 57
+	}
+}
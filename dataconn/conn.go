@@ -0,0 +1,431 @@
+// Package dataconn exposes a DataProducer and a DataConsumer of a
+// DirectTransport as a net.Conn, so that Go protocols (JSON-RPC, gob,
+// protobuf streams...) can run directly over data channels.
+//
+// The DataProducer carries the written messages and the DataConsumer the read
+// ones. A Conn is message-oriented: each Write sends a message and each Read
+// returns one. A Stream is byte-oriented, like a TCP connection.
+package dataconn
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+/**
+ * ErrReadBufferFull is returned by Read once the messages received and not
+ * read yet exceed Options.ReadBufferSize. The Conn is then closed.
+ */
+var ErrReadBufferFull = errors.New("dataconn: read buffer full")
+
+type Options struct {
+	/**
+	 * PPID of the written messages. Default mediasoup.PPID_WEBRTC_BINARY.
+	 */
+	Ppid int
+
+	/**
+	 * Maximum size in bytes of the messages received and not read yet.
+	 * Default 1MB.
+	 */
+	ReadBufferSize int
+
+	/**
+	 * Maximum size of the messages written by a Stream, larger writes are
+	 * split. Default 262144, the default SCTP maxMessageSize.
+	 */
+	MaxMessageSize int
+
+	/**
+	 * DataConsumer delivering the written messages to the peer (e.g. in its
+	 * WebRtcTransport). When given, writes are held while its SCTP send
+	 * buffer is full or its buffered amount is above HighWaterMark, until it
+	 * emits "bufferedamountlow". Optional.
+	 */
+	FlowControl *mediasoup.DataConsumer
+
+	/**
+	 * Buffered amount of FlowControl above which writes are held. Default
+	 * 1MB.
+	 */
+	HighWaterMark int64
+
+	/**
+	 * Buffered amount of FlowControl below which held writes resume, set as
+	 * its buffered amount low threshold. Default HighWaterMark / 2.
+	 */
+	LowWaterMark int
+}
+
+/**
+ * Addr is the address of a Conn end, the id of its DataProducer.
+ */
+type Addr struct {
+	Id string
+}
+
+func (a Addr) Network() string {
+	return "mediasoup-data"
+}
+
+func (a Addr) String() string {
+	return a.Id
+}
+
+/**
+ * Conn is a message-oriented net.Conn over a DataProducer, carrying the
+ * written messages, and a DataConsumer, carrying the read ones.
+ *
+ * The Conn owns both: closing it closes them, and it is closed when either is
+ * closed. Reads then return the messages already received followed by io.EOF.
+ */
+type Conn struct {
+	logger        mediasoup.Logger
+	dataProducer  *mediasoup.DataProducer
+	dataConsumer  *mediasoup.DataConsumer
+	options       Options
+	readDeadline  *deadline
+	writeDeadline *deadline
+	unsubscribes  []func()
+	closed        uint32
+	locker        sync.Mutex
+	// The fields below are guarded by locker.
+	messages    []message
+	buffered    int
+	readErr     error
+	localClosed bool
+	held        bool
+	lowCount    uint64
+	// changed is closed and replaced when the fields above change.
+	changed chan struct{}
+}
+
+type message struct {
+	data []byte
+	ppid int
+}
+
+/**
+ * NewConn creates a Conn writing to dataProducer and reading from
+ * dataConsumer, which usually consumes the DataProducer of the peer.
+ */
+func NewConn(dataProducer *mediasoup.DataProducer, dataConsumer *mediasoup.DataConsumer, options Options) *Conn {
+	logger := mediasoup.NewLogger("DataConn")
+
+	logger.Debug("constructor() [dataProducerId:%s, dataConsumerId:%s]", dataProducer.Id(), dataConsumer.Id())
+
+	if options.Ppid == 0 {
+		options.Ppid = mediasoup.PPID_WEBRTC_BINARY
+	}
+	if options.ReadBufferSize <= 0 {
+		options.ReadBufferSize = 1 << 20
+	}
+	if options.MaxMessageSize <= 0 {
+		options.MaxMessageSize = 262144
+	}
+	if options.HighWaterMark <= 0 {
+		options.HighWaterMark = 1 << 20
+	}
+	if options.LowWaterMark <= 0 {
+		options.LowWaterMark = int(options.HighWaterMark / 2)
+	}
+
+	c := &Conn{
+		logger:        logger,
+		dataProducer:  dataProducer,
+		dataConsumer:  dataConsumer,
+		options:       options,
+		readDeadline:  newDeadline(),
+		writeDeadline: newDeadline(),
+		changed:       make(chan struct{}),
+	}
+
+	c.unsubscribes = append(c.unsubscribes, dataConsumer.OnMessage(c.handleMessage))
+
+	dataConsumer.Observer().On("close", func() { c.shutdown(io.EOF) })
+	dataProducer.Observer().On("close", func() { c.shutdown(io.EOF) })
+
+	if flowControl := options.FlowControl; flowControl != nil {
+		c.unsubscribes = append(c.unsubscribes,
+			flowControl.OnSctpSendBufferFull(func() {
+				c.logger.Debug("SCTP send buffer full, holding writes")
+				c.update(func() { c.held = true })
+			}),
+			flowControl.OnBufferedAmountLow(func(bufferedAmount int64) {
+				c.update(func() {
+					c.held = false
+					c.lowCount++
+				})
+			}),
+		)
+		if err := flowControl.SetBufferedAmountLowThreshold(options.LowWaterMark); err != nil {
+			logger.Warn("setting buffered amount low threshold failed: %s", err)
+		}
+	}
+
+	// Either may have been closed before subscribing.
+	if dataProducer.Closed() || dataConsumer.Closed() {
+		c.shutdown(io.EOF)
+	}
+
+	return c
+}
+
+/**
+ * DataProducer carrying the written messages.
+ */
+func (c *Conn) DataProducer() *mediasoup.DataProducer {
+	return c.dataProducer
+}
+
+/**
+ * DataConsumer carrying the read messages.
+ */
+func (c *Conn) DataConsumer() *mediasoup.DataConsumer {
+	return c.dataConsumer
+}
+
+/**
+ * ReadMessage reads a message and its PPID. The returned slice is owned by
+ * the caller.
+ */
+func (c *Conn) ReadMessage() (data []byte, ppid int, err error) {
+	for {
+		c.locker.Lock()
+
+		if c.localClosed {
+			c.locker.Unlock()
+			return nil, 0, io.ErrClosedPipe
+		}
+		if len(c.messages) > 0 {
+			msg := c.messages[0]
+			c.messages[0] = message{}
+			c.messages = c.messages[1:]
+			c.buffered -= len(msg.data)
+			c.locker.Unlock()
+			return msg.data, msg.ppid, nil
+		}
+		if c.readErr != nil {
+			err = c.readErr
+			c.locker.Unlock()
+			return nil, 0, err
+		}
+		changed := c.changed
+		c.locker.Unlock()
+
+		select {
+		case <-changed:
+		case <-c.readDeadline.wait():
+			return nil, 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+/**
+ * Read reads a message into b. If b is too short, the rest of the message is
+ * discarded and io.ErrShortBuffer is returned.
+ */
+func (c *Conn) Read(b []byte) (n int, err error) {
+	data, _, err := c.ReadMessage()
+	if err != nil {
+		return 0, err
+	}
+	n = copy(b, data)
+
+	if n < len(data) {
+		err = io.ErrShortBuffer
+	}
+	return
+}
+
+/**
+ * Write sends b as a message. Empty writes send nothing.
+ */
+func (c *Conn) Write(b []byte) (n int, err error) {
+	if err = c.waitWritable(); err != nil {
+		return
+	}
+	if len(b) == 0 {
+		return
+	}
+
+	c.locker.Lock()
+	lowCount := c.lowCount
+	c.locker.Unlock()
+
+	if err = c.dataProducer.Send(b, c.options.Ppid); err != nil {
+		return
+	}
+
+	if c.options.FlowControl != nil {
+		c.checkBufferedAmount(lowCount)
+	}
+
+	return len(b), nil
+}
+
+/**
+ * Close closes the Conn with its DataProducer and DataConsumer.
+ */
+func (c *Conn) Close() error {
+	c.locker.Lock()
+	c.localClosed = true
+	c.locker.Unlock()
+
+	c.shutdown(io.ErrClosedPipe)
+
+	return nil
+}
+
+/**
+ * LocalAddr returns the id of the DataProducer.
+ */
+func (c *Conn) LocalAddr() net.Addr {
+	return Addr{Id: c.dataProducer.Id()}
+}
+
+/**
+ * RemoteAddr returns the id of the DataProducer consumed by the DataConsumer.
+ */
+func (c *Conn) RemoteAddr() net.Addr {
+	return Addr{Id: c.dataConsumer.DataProducerId()}
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+/**
+ * SetWriteDeadline bounds the time a write is held by the flow control.
+ */
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *Conn) handleMessage(payload []byte, ppid int) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	if c.readErr != nil {
+		return
+	}
+
+	// Empty messages are sent with a dummy byte.
+	if ppid == 56 || ppid == 57 {
+		payload = nil
+	}
+
+	if c.buffered+len(payload) > c.options.ReadBufferSize {
+		c.logger.Warn("read buffer full, closing")
+
+		c.readErr = ErrReadBufferFull
+		c.broadcast()
+
+		go c.shutdown(ErrReadBufferFull)
+		return
+	}
+
+	c.messages = append(c.messages, message{data: payload, ppid: ppid})
+	c.buffered += len(payload)
+	c.broadcast()
+}
+
+// waitWritable waits until the writes are not held, the Conn is closed or the
+// write deadline is exceeded.
+func (c *Conn) waitWritable() error {
+	for {
+		c.locker.Lock()
+
+		if atomic.LoadUint32(&c.closed) > 0 {
+			c.locker.Unlock()
+			return io.ErrClosedPipe
+		}
+		if !c.held {
+			c.locker.Unlock()
+			return nil
+		}
+		changed := c.changed
+		c.locker.Unlock()
+
+		select {
+		case <-changed:
+		case <-c.writeDeadline.wait():
+			return os.ErrDeadlineExceeded
+		}
+	}
+}
+
+// checkBufferedAmount holds the writes if the buffered amount of the flow
+// control DataConsumer is above the high water mark, unless it emitted
+// "bufferedamountlow" since lowCount was read.
+func (c *Conn) checkBufferedAmount(lowCount uint64) {
+	bufferedAmount, err := c.options.FlowControl.GetBufferedAmount()
+	if err != nil {
+		c.logger.Debug("getting buffered amount failed: %s", err)
+		return
+	}
+	if bufferedAmount <= c.options.HighWaterMark {
+		return
+	}
+
+	c.update(func() {
+		if c.lowCount == lowCount {
+			c.logger.Debug("buffered amount %d above high water mark, holding writes", bufferedAmount)
+			c.held = true
+		}
+	})
+}
+
+// shutdown closes the Conn, reads returning err once the received messages
+// are read.
+func (c *Conn) shutdown(err error) {
+	if !atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		return
+	}
+
+	c.logger.Debug("close()")
+
+	c.update(func() {
+		if c.readErr == nil {
+			c.readErr = err
+		}
+	})
+
+	for _, unsubscribe := range c.unsubscribes {
+		unsubscribe()
+	}
+
+	c.dataProducer.Close()
+	c.dataConsumer.Close()
+}
+
+// update calls fn with c.locker held and wakes up the waiting reads and
+// writes.
+func (c *Conn) update(fn func()) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	fn()
+	c.broadcast()
+}
+
+// broadcast wakes up the waiting reads and writes. c.locker must be held.
+func (c *Conn) broadcast() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
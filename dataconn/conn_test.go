@@ -0,0 +1,238 @@
+package dataconn
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ net.Conn = (*Conn)(nil)
+var _ net.Conn = (*Stream)(nil)
+
+type testPair struct {
+	process   *workertest.Process
+	producer1 *mediasoup.DataProducer
+	consumer1 *mediasoup.DataConsumer
+	producer2 *mediasoup.DataProducer
+	consumer2 *mediasoup.DataConsumer
+}
+
+// newTestPair creates two DataProducers of a DirectTransport, each one
+// consumed by a DataConsumer of the other end.
+func newTestPair(t *testing.T) *testPair {
+	router, process := workertest.NewRouter(t)
+
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+
+	pair := &testPair{process: process}
+
+	pair.producer1, err = transport.ProduceData(mediasoup.DataProducerOptions{Label: "1"})
+	require.NoError(t, err)
+	pair.producer2, err = transport.ProduceData(mediasoup.DataProducerOptions{Label: "2"})
+	require.NoError(t, err)
+
+	pair.consumer1, err = transport.ConsumeData(mediasoup.DataConsumerOptions{DataProducerId: pair.producer2.Id()})
+	require.NoError(t, err)
+	pair.consumer2, err = transport.ConsumeData(mediasoup.DataConsumerOptions{DataProducerId: pair.producer1.Id()})
+	require.NoError(t, err)
+
+	return pair
+}
+
+func (p *testPair) conns(options Options) (*Conn, *Conn) {
+	return NewConn(p.producer1, p.consumer1, options), NewConn(p.producer2, p.consumer2, options)
+}
+
+func TestConn_ReadWrite(t *testing.T) {
+	conn1, conn2 := newTestPair(t).conns(Options{})
+	defer conn1.Close()
+	defer conn2.Close()
+
+	assert.Equal(t, conn1.LocalAddr(), conn2.RemoteAddr())
+	assert.Equal(t, "mediasoup-data", conn1.LocalAddr().Network())
+
+	for _, msg := range []string{"hello", "world"} {
+		n, err := conn1.Write([]byte(msg))
+		require.NoError(t, err)
+		assert.Equal(t, len(msg), n)
+	}
+
+	data, ppid, err := conn2.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	assert.Equal(t, mediasoup.PPID_WEBRTC_BINARY, ppid)
+
+	// The rest of a message is discarded.
+	buf := make([]byte, 3)
+	n, err := conn2.Read(buf)
+	assert.Equal(t, io.ErrShortBuffer, err)
+	assert.Equal(t, "wor", string(buf[:n]))
+
+	_, err = conn2.Write([]byte("back"))
+	require.NoError(t, err)
+
+	data, _, err = conn1.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "back", string(data))
+}
+
+func TestConn_ReadDeadline(t *testing.T) {
+	conn1, conn2 := newTestPair(t).conns(Options{})
+	defer conn1.Close()
+	defer conn2.Close()
+
+	require.NoError(t, conn2.SetReadDeadline(time.Now().Add(20*time.Millisecond)))
+
+	_, err := conn2.Read(make([]byte, 10))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr))
+	assert.True(t, netErr.Timeout())
+
+	// A past deadline fails at once.
+	require.NoError(t, conn2.SetReadDeadline(time.Now().Add(-time.Second)))
+	_, err = conn2.Read(make([]byte, 10))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+
+	require.NoError(t, conn2.SetReadDeadline(time.Time{}))
+
+	_, err = conn1.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	data, _, err := conn2.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestConn_FlowControl(t *testing.T) {
+	pair := newTestPair(t)
+
+	conn := NewConn(pair.producer1, pair.consumer1, Options{
+		FlowControl:   pair.consumer2,
+		HighWaterMark: 1000,
+	})
+	defer conn.Close()
+
+	dump, err := pair.consumer2.Dump()
+	require.NoError(t, err)
+	assert.EqualValues(t, 500, dump.BufferedAmountLowThreshold)
+
+	// The SCTP send buffer is full.
+	require.NoError(t, pair.process.Notify(pair.consumer2.Id(), "sctpsendbufferfull", nil))
+
+	require.NoError(t, conn.SetWriteDeadline(time.Now().Add(50*time.Millisecond)))
+	assert.Eventually(t, func() bool {
+		_, err := conn.Write([]byte("held"))
+		return errors.Is(err, os.ErrDeadlineExceeded)
+	}, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, pair.process.Notify(pair.consumer2.Id(), "bufferedamountlow", mediasoup.H{"bufferedAmount": 400}))
+	require.NoError(t, conn.SetWriteDeadline(time.Now().Add(time.Second)))
+
+	_, err = conn.Write([]byte("resumed"))
+	require.NoError(t, err)
+
+	// The buffered amount is above the high water mark.
+	pair.process.SetHandler("dataConsumer.getBufferedAmount", func(req workertest.Request) (interface{}, error) {
+		return mediasoup.H{"bufferedAmount": 2000}, nil
+	})
+
+	_, err = conn.Write([]byte("buffered"))
+	require.NoError(t, err)
+
+	require.NoError(t, conn.SetWriteDeadline(time.Now().Add(20*time.Millisecond)))
+	_, err = conn.Write([]byte("held"))
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+
+	require.NoError(t, pair.process.Notify(pair.consumer2.Id(), "bufferedamountlow", mediasoup.H{"bufferedAmount": 400}))
+	require.NoError(t, conn.SetWriteDeadline(time.Time{}))
+
+	_, err = conn.Write([]byte("resumed"))
+	require.NoError(t, err)
+}
+
+func TestConn_Close(t *testing.T) {
+	pair := newTestPair(t)
+	conn1, conn2 := pair.conns(Options{})
+
+	_, err := conn1.Write([]byte("bye"))
+	require.NoError(t, err)
+
+	data, _, err := conn2.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "bye", string(data))
+
+	require.NoError(t, conn1.Close())
+	assert.True(t, pair.producer1.Closed())
+	assert.True(t, pair.consumer1.Closed())
+
+	_, err = conn1.Write([]byte("closed"))
+	assert.Equal(t, io.ErrClosedPipe, err)
+	_, err = conn1.Read(make([]byte, 10))
+	assert.Equal(t, io.ErrClosedPipe, err)
+
+	// The peer is closed with the DataProducer it consumes.
+	_, _, err = conn2.ReadMessage()
+	assert.Equal(t, io.EOF, err)
+	assert.True(t, pair.producer2.Closed())
+
+	_, err = conn2.Write([]byte("closed"))
+	assert.Equal(t, io.ErrClosedPipe, err)
+}
+
+func TestConn_ReadBufferFull(t *testing.T) {
+	conn1, conn2 := newTestPair(t).conns(Options{ReadBufferSize: 8})
+	defer conn1.Close()
+
+	_, err := conn1.Write([]byte("12345"))
+	require.NoError(t, err)
+	_, err = conn1.Write([]byte("67890"))
+	require.NoError(t, err)
+
+	data, _, err := conn2.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "12345", string(data))
+
+	_, _, err = conn2.ReadMessage()
+	assert.Equal(t, ErrReadBufferFull, err)
+}
+
+func TestStream(t *testing.T) {
+	pair := newTestPair(t)
+	stream1 := NewStream(pair.producer1, pair.consumer1, Options{MaxMessageSize: 16})
+	stream2 := NewStream(pair.producer2, pair.consumer2, Options{MaxMessageSize: 16})
+	defer stream1.Close()
+	defer stream2.Close()
+
+	type request struct {
+		Method string
+		Params []string
+	}
+	sent := request{Method: "echo", Params: []string{"a long enough parameter", "to be split in messages"}}
+
+	go gob.NewEncoder(stream1).Encode(sent)
+
+	var received request
+	require.NoError(t, gob.NewDecoder(stream2).Decode(&received))
+	assert.Equal(t, sent, received)
+
+	n, err := stream2.Write([]byte("0123456789abcdefghij"))
+	require.NoError(t, err)
+	assert.Equal(t, 20, n)
+
+	buf := make([]byte, 20)
+	_, err = io.ReadFull(stream1, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789abcdefghij", string(buf))
+}
@@ -0,0 +1,70 @@
+package dataconn
+
+import (
+	"sync"
+	"time"
+)
+
+// deadline is a read or write deadline, whose channel is closed once it is
+// exceeded.
+type deadline struct {
+	locker sync.Mutex
+	timer  *time.Timer
+	// done is closed by the timer.
+	done chan struct{}
+}
+
+func newDeadline() *deadline {
+	return &deadline{done: make(chan struct{})}
+}
+
+// set sets the deadline, the zero time meaning none.
+func (d *deadline) set(t time.Time) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	// Wait for a firing timer to close done.
+	if d.timer != nil && !d.timer.Stop() {
+		<-d.done
+	}
+	d.timer = nil
+
+	exceeded := isClosed(d.done)
+
+	if t.IsZero() {
+		if exceeded {
+			d.done = make(chan struct{})
+		}
+		return
+	}
+
+	if dur := time.Until(t); dur > 0 {
+		if exceeded {
+			d.done = make(chan struct{})
+		}
+		done := d.done
+		d.timer = time.AfterFunc(dur, func() { close(done) })
+		return
+	}
+
+	if !exceeded {
+		close(d.done)
+	}
+}
+
+// wait returns a channel closed once the deadline is exceeded.
+func (d *deadline) wait() chan struct{} {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	return d.done
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,71 @@
+package dataconn
+
+import (
+	"sync"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+/**
+ * Stream is a byte-oriented net.Conn over a Conn. Reads return the received
+ * bytes regardless of the message boundaries, and writes larger than
+ * Options.MaxMessageSize are split into several messages.
+ *
+ * Messages are delivered in order only if the data channel is ordered and
+ * reliable, which are the defaults of the SCTP stream parameters.
+ */
+type Stream struct {
+	*Conn
+	readLocker sync.Mutex
+	// pending is the unread rest of the last read message.
+	pending []byte
+}
+
+/**
+ * NewStream creates a Stream writing to dataProducer and reading from
+ * dataConsumer.
+ */
+func NewStream(dataProducer *mediasoup.DataProducer, dataConsumer *mediasoup.DataConsumer, options Options) *Stream {
+	return &Stream{
+		Conn: NewConn(dataProducer, dataConsumer, options),
+	}
+}
+
+func (s *Stream) Read(b []byte) (n int, err error) {
+	s.readLocker.Lock()
+	defer s.readLocker.Unlock()
+
+	if len(b) == 0 {
+		return
+	}
+
+	for len(s.pending) == 0 {
+		if s.pending, _, err = s.Conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+
+	n = copy(b, s.pending)
+	s.pending = s.pending[n:]
+
+	return
+}
+
+func (s *Stream) Write(b []byte) (n int, err error) {
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > s.options.MaxMessageSize {
+			chunk = chunk[:s.options.MaxMessageSize]
+		}
+
+		written, err := s.Conn.Write(chunk)
+		n += written
+
+		if err != nil {
+			return n, err
+		}
+		b = b[written:]
+	}
+
+	return
+}
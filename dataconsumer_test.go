@@ -1,7 +1,9 @@
 package mediasoup
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -129,6 +131,106 @@ func (suite *DataConsumerTestingSuite) TestDataConsumerDumpSucceeds() {
 	suite.Equal("bar", data.Protocol)
 }
 
+func (suite *DataConsumerTestingSuite) TestTransportConsumeDataWithSctpStreamIdSucceeds() {
+	sctpStreamId := uint16(10)
+
+	dataConsumer1, err := suite.transport2.ConsumeData(DataConsumerOptions{
+		DataProducerId: suite.dataProducer.Id(),
+		SctpStreamId:   &sctpStreamId,
+	})
+	suite.NoError(err)
+	suite.EqualValues(10, dataConsumer1.SctpStreamParameters().StreamId)
+	suite.Equal([]uint16{10}, suite.transport2.SctpStreamUsage().StreamIds)
+
+	_, err = suite.transport2.ConsumeData(DataConsumerOptions{
+		DataProducerId: suite.dataProducer.Id(),
+		SctpStreamId:   &sctpStreamId,
+	})
+	suite.IsType(NewTypeError(""), err)
+
+	dataConsumer1.Close()
+	suite.Empty(suite.transport2.SctpStreamUsage().StreamIds)
+}
+
+func (suite *DataConsumerTestingSuite) TestTransportConsumeDataBeyondNumSctpStreamsSucceeds() {
+	// The stream ids of the DataConsumers are bounded by MIS, not by OS.
+	numSctpStreams := NumSctpStreams{OS: 4, MIS: 8}
+
+	transport, err := suite.router.CreatePlainTransport(PlainTransportOptions{
+		ListenIp: TransportListenIp{
+			Ip: "127.0.0.1",
+		},
+		EnableSctp:     true,
+		NumSctpStreams: numSctpStreams,
+	})
+	suite.Require().NoError(err)
+	suite.Equal(int(numSctpStreams.MIS), transport.SctpStreamUsage().NumStreams)
+
+	// The stream ids of the closed DataConsumers are reused.
+	for i := 0; i < 4*int(numSctpStreams.MIS); i++ {
+		dataConsumer, err := transport.ConsumeData(DataConsumerOptions{
+			DataProducerId: suite.dataProducer.Id(),
+		})
+		suite.Require().NoError(err)
+		suite.Less(int(dataConsumer.SctpStreamParameters().StreamId), int(numSctpStreams.MIS))
+		suite.Equal([]uint16{dataConsumer.SctpStreamParameters().StreamId}, transport.SctpStreamUsage().StreamIds)
+
+		dataConsumer.Close()
+		suite.Empty(transport.SctpStreamUsage().StreamIds)
+	}
+
+	// Once every stream id is taken, a stream id is available again when a
+	// DataConsumer is closed.
+	var dataConsumers []*DataConsumer
+
+	for i := 0; i < int(numSctpStreams.MIS); i++ {
+		dataConsumer, err := transport.ConsumeData(DataConsumerOptions{
+			DataProducerId: suite.dataProducer.Id(),
+		})
+		suite.Require().NoError(err)
+		dataConsumers = append(dataConsumers, dataConsumer)
+	}
+	suite.Len(transport.SctpStreamUsage().StreamIds, int(numSctpStreams.MIS))
+	suite.Zero(transport.SctpStreamUsage().Available())
+
+	_, err = transport.ConsumeData(DataConsumerOptions{
+		DataProducerId: suite.dataProducer.Id(),
+	})
+	suite.Error(err)
+
+	dataConsumers[3].Close()
+
+	dataConsumer, err := transport.ConsumeData(DataConsumerOptions{
+		DataProducerId: suite.dataProducer.Id(),
+	})
+	suite.Require().NoError(err)
+	suite.Equal(dataConsumers[3].SctpStreamParameters().StreamId, dataConsumer.SctpStreamParameters().StreamId)
+
+	transportDump, err := transport.Dump()
+	suite.NoError(err)
+	suite.Len(transportDump.DataConsumerIds, int(numSctpStreams.MIS))
+}
+
+func (suite *DataConsumerTestingSuite) TestDataConsumerCloseContext_Canceled() {
+	dataConsumer1, err := suite.transport2.ConsumeData(DataConsumerOptions{
+		DataProducerId: suite.dataProducer.Id(),
+	})
+	suite.Require().NoError(err)
+
+	onObserverClose := NewMockFunc(suite.T())
+	dataConsumer1.Observer().Once("close", onObserverClose.Fn())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The DataConsumer is closed and its stream id released even if the
+	// request is canceled.
+	suite.Equal(context.Canceled, dataConsumer1.CloseContext(ctx))
+	onObserverClose.ExpectCalledTimes(1)
+	suite.True(dataConsumer1.Closed())
+	suite.Empty(suite.transport2.SctpStreamUsage().StreamIds)
+}
+
 func (suite *DataConsumerTestingSuite) TestTransportConsumeDataOnADirectTransportSucceeds() {
 	onObserverNewDataConsumer := NewMockFunc(suite.T())
 
@@ -191,6 +293,40 @@ func (suite *DataConsumerTestingSuite) TestdataConsumerGetStatsOnADirectTranspor
 	}, stats[0])
 }
 
+func (suite *DataConsumerTestingSuite) TestDataConsumerSendOnADirectTransportSucceeds() {
+	dataConsumer, err := suite.transport3.ConsumeData(DataConsumerOptions{
+		DataProducerId: suite.dataProducer.Id(),
+	})
+	suite.Require().NoError(err)
+
+	type message struct {
+		payload string
+		ppid    int
+	}
+	messages := make(chan message, 3)
+
+	dataConsumer.OnMessage(func(payload []byte, ppid int) {
+		messages <- message{string(payload), ppid}
+	})
+
+	suite.NoError(dataConsumer.Send([]byte("foo"), PPID_WEBRTC_STRING))
+	suite.NoError(dataConsumer.Send([]byte("bar")))
+	suite.NoError(dataConsumer.SendText("baz"))
+
+	for _, expected := range []message{
+		{"foo", PPID_WEBRTC_STRING},
+		{"bar", PPID_WEBRTC_BINARY},
+		{"baz", PPID_WEBRTC_STRING},
+	} {
+		select {
+		case msg := <-messages:
+			suite.Equal(expected, msg)
+		case <-time.After(time.Second):
+			suite.FailNow("message not received")
+		}
+	}
+}
+
 func (suite *DataConsumerTestingSuite) TestDataConsumerCloseSucceeds() {
 	dataConsumer1, _ := suite.transport2.ConsumeData(DataConsumerOptions{
 		DataProducerId:    suite.dataProducer.Id(),
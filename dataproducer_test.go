@@ -1,6 +1,7 @@
 package mediasoup
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/suite"
@@ -223,6 +224,25 @@ func (suite *DataProducerTestingSuite) TestDataProducerCloseSucceeds() {
 	suite.Empty(transportDump.DataProducerIds)
 }
 
+func (suite *DataProducerTestingSuite) TestDataProducerCloseContext_Canceled() {
+	onObserverClose := NewMockFunc(suite.T())
+	dataProducer1, err := suite.transport1.ProduceData(DataProducerOptions{
+		SctpStreamParameters: &SctpStreamParameters{
+			StreamId: 666,
+		},
+	})
+	suite.Require().NoError(err)
+	dataProducer1.Observer().Once("close", onObserverClose.Fn())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The DataProducer is closed even if the request is canceled.
+	suite.Equal(context.Canceled, dataProducer1.CloseContext(ctx))
+	onObserverClose.ExpectCalledTimes(1)
+	suite.True(dataProducer1.Closed())
+}
+
 func (suite *DataProducerTestingSuite) TestProducerMethodsRejectIfClosed() {
 	dataProducer1, _ := suite.transport2.ProduceData(DataProducerOptions{
 		SctpStreamParameters: &SctpStreamParameters{
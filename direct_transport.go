@@ -1,5 +1,11 @@
 package mediasoup
 
+import (
+	"context"
+
+	"github.com/pion/rtcp"
+)
+
 type DirectTransportOptions struct {
 	/**
 	 * Maximum allowed size for direct messages sent from DataProducers.
@@ -60,12 +66,37 @@ func (transport *DirectTransport) Observer() IEventEmitter {
 	return transport.ITransport.Observer()
 }
 
+/**
+ * OnRtcp adds a listener of "rtcp" and returns the function removing it.
+ */
+func (transport *DirectTransport) OnRtcp(listener func(rtcpPacket []byte)) func() {
+	return transport.subscribe("rtcp", listener)
+}
+
+/**
+ * OnRtcpPackets adds a listener of "rtcp" getting the packets of the parsed
+ * compound packet, and returns the function removing it. Invalid packets are
+ * dropped.
+ */
+func (transport *DirectTransport) OnRtcpPackets(listener func([]rtcp.Packet)) func() {
+	return transport.subscribe("rtcp", func(rtcpPacket []byte) {
+		if packets, ok := parseRtcp(transport.logger, rtcpPacket); ok {
+			listener(packets)
+		}
+	})
+}
+
 /**
  * NO-OP method in DirectTransport.
  *
  * @override
  */
-func (transport *DirectTransport) Connect(TransportConnectOptions) error {
+func (transport *DirectTransport) Connect(options TransportConnectOptions) error {
+	return transport.ConnectContext(context.Background(), options)
+}
+
+// ConnectContext is like Connect but with a context.
+func (transport *DirectTransport) ConnectContext(context.Context, TransportConnectOptions) error {
 	transport.logger.Debug("connect()")
 
 	return nil
@@ -85,6 +116,18 @@ func (transport *DirectTransport) SendRtcp(rtcpPacket []byte) error {
 	return transport.payloadChannel.Notify("transport.sendRtcp", transport.internal, nil, rtcpPacket)
 }
 
+/**
+ * Send RTCP packets in a compound packet.
+ */
+func (transport *DirectTransport) SendRtcpPackets(packets ...rtcp.Packet) error {
+	rtcpPacket, err := rtcp.Marshal(packets)
+	if err != nil {
+		return err
+	}
+
+	return transport.SendRtcp(rtcpPacket)
+}
+
 func (transport *DirectTransport) handleWorkerNotifications() {
 	transport.channel.On(transport.Id(), func(event string, data TransportTraceEventData) {
 		switch event {
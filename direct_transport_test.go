@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pion/rtp"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -171,6 +172,45 @@ func (suite *DirectTransportTestingSuite) TestDataProducerSendSucceeds() {
 	}, dataConumserStats[0])
 }
 
+func (suite *DirectTransportTestingSuite) TestProducerSendPacketSucceeds() {
+	producer := CreateAudioProducer(suite.transport)
+	transport2, _ := suite.router.CreateDirectTransport()
+	consumer, err := transport2.Consume(ConsumerOptions{
+		ProducerId:      producer.Id(),
+		RtpCapabilities: suite.router.RtpCapabilities(),
+	})
+	suite.NoError(err)
+
+	received := make(chan *rtp.Packet, 1)
+
+	consumer.OnRtpPacket(func(packet *rtp.Packet) {
+		received <- packet
+	})
+
+	packet := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    111,
+			SequenceNumber: 1,
+			Timestamp:      960,
+			SSRC:           11111111,
+		},
+		Payload: []byte{0xfc, 0xff, 0xfe},
+	}
+	suite.NoError(producer.HeaderExtensionIds().Set(packet, RtpHeaderExtensionUri_AudioLevel, []byte{0x80 | 30}))
+	suite.NoError(producer.SendPacket(packet))
+
+	select {
+	case packet := <-received:
+		suite.EqualValues(consumer.RtpParameters().Codecs[0].PayloadType, packet.PayloadType)
+		suite.EqualValues(consumer.RtpParameters().Encodings[0].Ssrc, packet.SSRC)
+		suite.Equal([]byte{0xfc, 0xff, 0xfe}, packet.Payload)
+		suite.Equal([]byte{0x80 | 30}, consumer.HeaderExtensionIds().Get(packet, RtpHeaderExtensionUri_AudioLevel))
+	case <-time.After(time.Second):
+		suite.FailNow("timeout")
+	}
+}
+
 func (suite *DirectTransportTestingSuite) TestDirectTransportMethodRejectIfclosed() {
 	onObserverClose := NewMockFunc(suite.T())
 	suite.transport.Observer().Once("close", onObserverClose.Fn())
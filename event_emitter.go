@@ -1,11 +1,133 @@
 package mediasoup
 
 import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
 	"github.com/jiyeyuran/go-eventemitter"
 )
 
 type IEventEmitter = eventemitter.IEventEmitter
 
 func NewEventEmitter() IEventEmitter {
-	return eventemitter.NewEventEmitter(eventemitter.WithLogger(NewLogger("EventEmitter")))
+	return &eventEmitter{
+		IEventEmitter: eventemitter.NewEventEmitter(eventemitter.WithLogger(NewLogger("EventEmitter"))),
+	}
+}
+
+// eventEmitter keeps the typed subscriptions of an emitter. Off() matches
+// listeners by function pointer, which cannot tell apart two closures created
+// at the same place, so each subscribed event gets a single dispatcher
+// listener calling its subscriptions in order.
+type eventEmitter struct {
+	IEventEmitter
+	locker      sync.Mutex
+	dispatchers map[string]*dispatcher
+}
+
+type dispatcher struct {
+	locker        sync.Mutex
+	subscriptions []*subscription
+}
+
+type subscription struct {
+	listener reflect.Value
+	removed  uint32
+}
+
+func (d *dispatcher) call(args []reflect.Value) []reflect.Value {
+	d.locker.Lock()
+	subscriptions := d.subscriptions
+	d.locker.Unlock()
+
+	for _, s := range subscriptions {
+		if atomic.LoadUint32(&s.removed) == 0 {
+			s.listener.Call(args)
+		}
+	}
+
+	return nil
+}
+
+func (d *dispatcher) remove(s *subscription) {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	subscriptions := make([]*subscription, 0, len(d.subscriptions))
+
+	for _, item := range d.subscriptions {
+		if item != s {
+			subscriptions = append(subscriptions, item)
+		}
+	}
+
+	d.subscriptions = subscriptions
+}
+
+func (e *eventEmitter) subscribe(evt string, listener interface{}) (unsubscribe func()) {
+	e.locker.Lock()
+	defer e.locker.Unlock()
+
+	if e.dispatchers == nil {
+		e.dispatchers = make(map[string]*dispatcher)
+	}
+
+	d, ok := e.dispatchers[evt]
+
+	if !ok {
+		d = &dispatcher{}
+		e.dispatchers[evt] = d
+		e.IEventEmitter.On(evt, reflect.MakeFunc(reflect.TypeOf(listener), d.call).Interface())
+	}
+
+	s := &subscription{listener: reflect.ValueOf(listener)}
+
+	d.locker.Lock()
+	d.subscriptions = append(d.subscriptions, s)
+	d.locker.Unlock()
+
+	return func() {
+		if atomic.CompareAndSwapUint32(&s.removed, 0, 1) {
+			d.remove(s)
+		}
+	}
+}
+
+func (e *eventEmitter) RemoveAllListeners(evt string) IEventEmitter {
+	e.locker.Lock()
+	defer e.locker.Unlock()
+
+	// Subscriptions are removed with their dispatcher.
+	if d, ok := e.dispatchers[evt]; ok {
+		d.locker.Lock()
+		for _, s := range d.subscriptions {
+			atomic.StoreUint32(&s.removed, 1)
+		}
+		d.locker.Unlock()
+		delete(e.dispatchers, evt)
+	}
+
+	e.IEventEmitter.RemoveAllListeners(evt)
+
+	return e
+}
+
+/**
+ * subscribe adds a listener of the given event and returns the function
+ * removing it. All the listeners subscribed to an event must have the same
+ * signature.
+ */
+func subscribe(emitter IEventEmitter, evt string, listener interface{}) (unsubscribe func()) {
+	if e, ok := emitter.(*eventEmitter); ok {
+		return e.subscribe(evt, listener)
+	}
+
+	emitter.On(evt, listener)
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { emitter.Off(evt, listener) })
+	}
 }
@@ -8,7 +8,11 @@ require (
 	github.com/jiyeyuran/go-eventemitter v1.1.1
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/pion/logging v0.2.2
+	github.com/pion/rtcp v1.2.6
+	github.com/pion/rtp v1.7.13
 	github.com/pion/sctp v1.7.11
+	github.com/prometheus/client_golang v1.11.0
+	github.com/prometheus/client_model v0.2.0
 	github.com/rs/zerolog v1.20.0
 	github.com/satori/go.uuid v1.2.0
 	github.com/stretchr/testify v1.6.1
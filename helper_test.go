@@ -1,6 +1,35 @@
 package mediasoup
 
-import "github.com/jiyeyuran/mediasoup-go/h264"
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/jiyeyuran/mediasoup-go/h264"
+)
+
+// fakeWorker is set when the tests run against the fake worker of the
+// workertest package, which has no media stack.
+var fakeWorker bool
+
+// useFakeWorkerIfMissing builds the fake worker of the workertest package and
+// runs the tests against it when WorkerBin does not exist, e.g. on CI.
+func useFakeWorkerIfMissing() {
+	if _, err := os.Stat(WorkerBin); err == nil {
+		return
+	}
+
+	bin := filepath.Join(os.TempDir(), "mediasoup-go-workertest", "mediasoup-worker")
+	cmd := exec.Command("go", "build", "-o", bin, "./workertest/mediasoup-worker")
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		panic(err)
+	}
+
+	WorkerBin = bin
+	fakeWorker = true
+}
 
 var consumerDeviceCapabilities = RtpCapabilities{
 	Codecs: []*RtpCodecCapability{
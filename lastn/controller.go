@@ -0,0 +1,495 @@
+// Package lastn limits the video forwarded to each subscriber of a router to
+// the N most relevant producers. The video consumers of a subscriber are
+// ordered by a ranking of producers, such as the last speakers or pinned
+// users: the top ones are forwarded in high quality, the next ones as
+// thumbnails, and the rest are paused.
+package lastn
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+type ForwardingState string
+
+const (
+	ForwardingState_Paused    ForwardingState = "paused"
+	ForwardingState_Thumbnail                 = "thumbnail"
+	ForwardingState_High                      = "high"
+)
+
+type ControllerOptions struct {
+	/**
+	 * Number of video consumers forwarded to each subscriber. Default 9.
+	 */
+	LastN int
+
+	/**
+	 * Number of forwarded consumers getting the high layers. Default 1.
+	 */
+	HighCount int
+
+	/**
+	 * Preferred layers of the top consumers. Default spatial and temporal
+	 * layers 2, which the worker lowers to the available ones.
+	 */
+	HighLayers *mediasoup.ConsumerLayers
+
+	/**
+	 * Preferred layers of the thumbnails. Default spatial layer 0.
+	 */
+	ThumbnailLayers *mediasoup.ConsumerLayers
+
+	/**
+	 * Priority of the top consumers. Default 10.
+	 */
+	HighPriority uint32
+
+	/**
+	 * Priority of the thumbnails. Default 1.
+	 */
+	ThumbnailPriority uint32
+
+	/**
+	 * Delay before applying changes, the changes made meanwhile are applied
+	 * together. Default 300ms.
+	 */
+	Debounce time.Duration
+
+	/**
+	 * SubscriberId returns the subscriber of the consumers of a transport,
+	 * such as a peer id read from its AppData. Default the transport id.
+	 */
+	SubscriberId func(transport mediasoup.ITransport) string
+}
+
+/**
+ * Transition is a change of the forwarding state of a consumer.
+ */
+type Transition struct {
+	SubscriberId string
+	Consumer     *mediasoup.Consumer
+	/**
+	 * Empty for the consumers not handled before.
+	 */
+	From ForwardingState
+	To   ForwardingState
+}
+
+/**
+ * Controller pauses and resumes the video consumers of a router, and sets
+ * their preferred layers and priority, according to the ranking of their
+ * producers for their subscriber. The consumers created in the router are
+ * managed automatically, the Controller then owns their paused state.
+ *
+ * @emits transitions - (transitions: []Transition)
+ * @emits close
+ */
+type Controller struct {
+	mediasoup.IEventEmitter
+	logger         mediasoup.Logger
+	router         *mediasoup.Router
+	options        ControllerOptions
+	subscribers    map[string]*subscriber
+	defaultRanking []string
+	timer          *time.Timer
+	closed         uint32
+	locker         sync.Mutex
+	// Serializes the applications of the changes.
+	applyLocker sync.Mutex
+}
+
+type subscriber struct {
+	ranking   []string
+	consumers []*managedConsumer
+}
+
+type managedConsumer struct {
+	consumer *mediasoup.Consumer
+	state    ForwardingState
+}
+
+/**
+ * NewController manages the video consumers of the given router, including
+ * the existing ones. The Controller is closed with the router.
+ */
+func NewController(router *mediasoup.Router, options ControllerOptions) *Controller {
+	logger := mediasoup.NewLogger("LastNController")
+
+	logger.Debug("constructor()")
+
+	if options.LastN <= 0 {
+		options.LastN = 9
+	}
+	if options.HighCount <= 0 {
+		options.HighCount = 1
+	}
+	if options.HighLayers == nil {
+		options.HighLayers = &mediasoup.ConsumerLayers{SpatialLayer: 2, TemporalLayer: 2}
+	}
+	if options.ThumbnailLayers == nil {
+		options.ThumbnailLayers = &mediasoup.ConsumerLayers{}
+	}
+	if options.HighPriority == 0 {
+		options.HighPriority = 10
+	}
+	if options.ThumbnailPriority == 0 {
+		options.ThumbnailPriority = 1
+	}
+	if options.Debounce <= 0 {
+		options.Debounce = 300 * time.Millisecond
+	}
+	if options.SubscriberId == nil {
+		options.SubscriberId = func(transport mediasoup.ITransport) string {
+			return transport.Id()
+		}
+	}
+
+	c := &Controller{
+		IEventEmitter: mediasoup.NewEventEmitter(),
+		logger:        logger,
+		router:        router,
+		options:       options,
+		subscribers:   make(map[string]*subscriber),
+	}
+
+	router.Observer().On("newtransport", c.watchTransport)
+	router.Observer().On("close", c.Close)
+
+	for _, transport := range router.Transports() {
+		c.watchTransport(transport)
+	}
+
+	return c
+}
+
+func (c *Controller) watchTransport(transport mediasoup.ITransport) {
+	subscriberId := c.options.SubscriberId(transport)
+
+	transport.Observer().On("newconsumer", func(consumer *mediasoup.Consumer) {
+		c.Manage(subscriberId, consumer)
+	})
+
+	for _, consumer := range transport.Consumers() {
+		c.Manage(subscriberId, consumer)
+	}
+}
+
+/**
+ * Whether the Controller is closed.
+ */
+func (c *Controller) Closed() bool {
+	return atomic.LoadUint32(&c.closed) > 0
+}
+
+/**
+ * Close stops managing the consumers, which keep their current state.
+ */
+func (c *Controller) Close() {
+	if !atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		return
+	}
+
+	c.logger.Debug("close()")
+
+	c.locker.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.subscribers = make(map[string]*subscriber)
+	c.locker.Unlock()
+
+	c.SafeEmit("close")
+}
+
+/**
+ * Manage adds a consumer of the given subscriber, audio consumers are
+ * ignored. The consumers created in the router don't need to be added.
+ */
+func (c *Controller) Manage(subscriberId string, consumer *mediasoup.Consumer) {
+	if c.Closed() || consumer.Closed() || consumer.Kind() != mediasoup.MediaKind_Video {
+		return
+	}
+
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	s := c.subscriber(subscriberId)
+
+	for _, mc := range s.consumers {
+		if mc.consumer == consumer {
+			return
+		}
+	}
+
+	c.logger.Debug("manage() [subscriberId:%s, consumerId:%s]", subscriberId, consumer.Id())
+
+	s.consumers = append(s.consumers, &managedConsumer{consumer: consumer})
+
+	consumer.Observer().On("close", func() {
+		c.locker.Lock()
+		defer c.locker.Unlock()
+
+		c.remove(subscriberId, consumer)
+	})
+
+	c.schedule()
+}
+
+/**
+ * SetRanking sets the producers relevant to the given subscriber, most
+ * relevant first. The consumers of other producers come next, in creation
+ * order. Nil restores the default ranking.
+ */
+func (c *Controller) SetRanking(subscriberId string, producerIds []string) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	if c.Closed() {
+		return
+	}
+
+	s := c.subscriber(subscriberId)
+
+	if producerIds == nil {
+		s.ranking = nil
+	} else {
+		s.ranking = append([]string{}, producerIds...)
+	}
+	c.schedule()
+}
+
+/**
+ * SetDefaultRanking sets the ranking of the subscribers with no ranking of
+ * their own, such as the last speakers of the room.
+ */
+func (c *Controller) SetDefaultRanking(producerIds []string) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	if c.Closed() {
+		return
+	}
+
+	c.defaultRanking = append([]string{}, producerIds...)
+	c.schedule()
+}
+
+/**
+ * State returns the forwarding state of a consumer, which is empty for
+ * consumers not handled yet.
+ */
+func (c *Controller) State(consumer *mediasoup.Consumer) ForwardingState {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	for _, s := range c.subscribers {
+		for _, mc := range s.consumers {
+			if mc.consumer == consumer {
+				return mc.state
+			}
+		}
+	}
+
+	return ""
+}
+
+/**
+ * Apply applies the pending changes without waiting for the debounce delay.
+ */
+func (c *Controller) Apply() {
+	c.locker.Lock()
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.locker.Unlock()
+
+	c.apply()
+}
+
+// subscriber returns the subscriber with the given id, which is created if
+// needed. c.locker must be held.
+func (c *Controller) subscriber(subscriberId string) *subscriber {
+	s, ok := c.subscribers[subscriberId]
+	if !ok {
+		s = &subscriber{}
+		c.subscribers[subscriberId] = s
+	}
+	return s
+}
+
+// remove removes a closed consumer, which frees its place for another one.
+// c.locker must be held.
+func (c *Controller) remove(subscriberId string, consumer *mediasoup.Consumer) {
+	s, ok := c.subscribers[subscriberId]
+	if !ok {
+		return
+	}
+
+	for i, mc := range s.consumers {
+		if mc.consumer == consumer {
+			s.consumers = append(s.consumers[:i:i], s.consumers[i+1:]...)
+			c.schedule()
+			break
+		}
+	}
+
+	if len(s.consumers) == 0 && s.ranking == nil {
+		delete(c.subscribers, subscriberId)
+	}
+}
+
+// schedule applies the changes after the debounce delay. c.locker must be
+// held.
+func (c *Controller) schedule() {
+	if c.timer == nil && !c.Closed() {
+		c.timer = time.AfterFunc(c.options.Debounce, c.apply)
+	}
+}
+
+func (c *Controller) apply() {
+	c.applyLocker.Lock()
+	defer c.applyLocker.Unlock()
+
+	c.locker.Lock()
+
+	c.timer = nil
+
+	type change struct {
+		Transition
+		managed *managedConsumer
+	}
+
+	var changes []change
+
+	for subscriberId, s := range c.subscribers {
+		for _, t := range c.plan(s) {
+			changes = append(changes, change{
+				Transition: Transition{
+					SubscriberId: subscriberId,
+					Consumer:     t.consumer,
+					From:         t.state,
+					To:           t.to,
+				},
+				managed: t.managedConsumer,
+			})
+		}
+	}
+
+	c.locker.Unlock()
+
+	// Pause first to free the bandwidth of the resumed consumers.
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].To == ForwardingState_Paused && changes[j].To != ForwardingState_Paused
+	})
+
+	var transitions []Transition
+
+	for _, change := range changes {
+		if c.Closed() {
+			return
+		}
+		if err := c.transition(change.Transition); err != nil {
+			if !change.Consumer.Closed() {
+				c.logger.Warn("transition failed [consumerId:%s, to:%s]: %s", change.Consumer.Id(), change.To, err)
+			}
+			continue
+		}
+
+		c.locker.Lock()
+		change.managed.state = change.To
+		c.locker.Unlock()
+
+		transitions = append(transitions, change.Transition)
+	}
+
+	if len(transitions) > 0 {
+		c.SafeEmit("transitions", transitions)
+	}
+}
+
+type plannedTransition struct {
+	*managedConsumer
+	to ForwardingState
+}
+
+// plan returns the consumers of a subscriber whose state must change.
+// c.locker must be held.
+func (c *Controller) plan(s *subscriber) (transitions []plannedTransition) {
+	ranking := s.ranking
+	if ranking == nil {
+		ranking = c.defaultRanking
+	}
+
+	ranks := make(map[string]int, len(ranking))
+
+	for i, producerId := range ranking {
+		if _, ok := ranks[producerId]; !ok {
+			ranks[producerId] = i
+		}
+	}
+
+	rank := func(mc *managedConsumer) int {
+		if r, ok := ranks[mc.consumer.ProducerId()]; ok {
+			return r
+		}
+		return len(ranking)
+	}
+
+	consumers := append([]*managedConsumer{}, s.consumers...)
+
+	sort.SliceStable(consumers, func(i, j int) bool {
+		return rank(consumers[i]) < rank(consumers[j])
+	})
+
+	for i, mc := range consumers {
+		to := ForwardingState_Paused
+
+		if i < c.options.HighCount && i < c.options.LastN {
+			to = ForwardingState_High
+		} else if i < c.options.LastN {
+			to = ForwardingState_Thumbnail
+		}
+
+		if to != mc.state {
+			transitions = append(transitions, plannedTransition{managedConsumer: mc, to: to})
+		}
+	}
+
+	return
+}
+
+// transition applies a transition to its consumer.
+func (c *Controller) transition(t Transition) (err error) {
+	consumer := t.Consumer
+
+	c.logger.Debug("transition() [consumerId:%s, from:%s, to:%s]", consumer.Id(), t.From, t.To)
+
+	if t.To == ForwardingState_Paused {
+		return consumer.Pause()
+	}
+
+	layers, priority := c.options.HighLayers, c.options.HighPriority
+
+	if t.To == ForwardingState_Thumbnail {
+		layers, priority = c.options.ThumbnailLayers, c.options.ThumbnailPriority
+	}
+
+	if err = consumer.SetPreferredLayers(*layers); err != nil {
+		return
+	}
+	if err = consumer.SetPriority(priority); err != nil {
+		return
+	}
+	if t.From == "" || t.From == ForwardingState_Paused {
+		err = consumer.Resume()
+	}
+
+	return
+}
@@ -0,0 +1,237 @@
+package lastn
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRoom struct {
+	router    *mediasoup.Router
+	producers []*mediasoup.Producer
+}
+
+func newTestRoom(t *testing.T, videoCount int) *testRoom {
+	router, _ := workertest.NewRouter(t)
+
+	room := &testRoom{router: router}
+
+	for i := 0; i < videoCount; i++ {
+		transport, err := router.CreateDirectTransport()
+		require.NoError(t, err)
+
+		producer, err := transport.Produce(workertest.VideoProducerOptions())
+		require.NoError(t, err)
+
+		room.producers = append(room.producers, producer)
+	}
+
+	return room
+}
+
+// subscribe creates a transport consuming all the producers of the room.
+func (r *testRoom) subscribe(t *testing.T, appData interface{}) (mediasoup.ITransport, []*mediasoup.Consumer) {
+	transport, err := r.router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+		AppData:   appData,
+	})
+	require.NoError(t, err)
+
+	var consumers []*mediasoup.Consumer
+
+	for _, producer := range r.producers {
+		consumer, err := transport.Consume(mediasoup.ConsumerOptions{
+			ProducerId:      producer.Id(),
+			RtpCapabilities: workertest.DeviceRtpCapabilities(),
+		})
+		require.NoError(t, err)
+
+		consumers = append(consumers, consumer)
+	}
+
+	return transport, consumers
+}
+
+func waitTransitions(t *testing.T, ch chan []Transition) []Transition {
+	select {
+	case transitions := <-ch:
+		return transitions
+	case <-time.After(time.Second):
+		t.Fatal("transitions not emitted")
+		return nil
+	}
+}
+
+func transitionsTo(transitions []Transition) map[*mediasoup.Consumer]ForwardingState {
+	states := make(map[*mediasoup.Consumer]ForwardingState)
+
+	for _, transition := range transitions {
+		states[transition.Consumer] = transition.To
+	}
+
+	return states
+}
+
+func TestController_Ranking(t *testing.T) {
+	room := newTestRoom(t, 4)
+	p := room.producers
+
+	controller := NewController(room.router, ControllerOptions{
+		LastN:      3,
+		HighCount:  1,
+		HighLayers: &mediasoup.ConsumerLayers{SpatialLayer: 2, TemporalLayer: 2},
+		Debounce:   10 * time.Millisecond,
+	})
+	defer controller.Close()
+
+	transitionsCh := make(chan []Transition, 10)
+	controller.On("transitions", func(transitions []Transition) { transitionsCh <- transitions })
+
+	transport, c := room.subscribe(t, nil)
+	controller.SetRanking(transport.Id(), []string{p[3].Id(), p[1].Id()})
+
+	// The consumers and the ranking are applied together.
+	transitions := waitTransitions(t, transitionsCh)
+	assert.Len(t, transitions, 4)
+	assert.Equal(t, transport.Id(), transitions[0].SubscriberId)
+	assert.Empty(t, transitions[0].From)
+	assert.Equal(t, map[*mediasoup.Consumer]ForwardingState{
+		c[3]: ForwardingState_High,
+		c[1]: ForwardingState_Thumbnail,
+		c[0]: ForwardingState_Thumbnail,
+		c[2]: ForwardingState_Paused,
+	}, transitionsTo(transitions))
+
+	assert.False(t, c[3].Paused())
+	assert.EqualValues(t, 10, c[3].Priority())
+	assert.Equal(t, &mediasoup.ConsumerLayers{SpatialLayer: 2, TemporalLayer: 2}, c[3].PreferredLayers())
+	assert.False(t, c[1].Paused())
+	assert.EqualValues(t, 1, c[1].Priority())
+	assert.Equal(t, &mediasoup.ConsumerLayers{SpatialLayer: 0, TemporalLayer: 0}, c[1].PreferredLayers())
+	assert.True(t, c[2].Paused())
+	assert.Equal(t, ForwardingState(ForwardingState_Paused), controller.State(c[2]))
+
+	// Only the changed consumers transition.
+	controller.SetRanking(transport.Id(), []string{p[2].Id(), p[3].Id()})
+
+	transitions = waitTransitions(t, transitionsCh)
+	assert.Equal(t, map[*mediasoup.Consumer]ForwardingState{
+		c[2]: ForwardingState_High,
+		c[3]: ForwardingState_Thumbnail,
+		c[1]: ForwardingState_Paused,
+	}, transitionsTo(transitions))
+	assert.Equal(t, ForwardingState_Paused, transitions[0].To)
+	assert.False(t, c[2].Paused())
+	assert.True(t, c[1].Paused())
+
+	// Closing a forwarded consumer pages in the next one.
+	require.NoError(t, c[0].Close())
+
+	transitions = waitTransitions(t, transitionsCh)
+	require.Len(t, transitions, 1)
+	assert.Equal(t, c[1], transitions[0].Consumer)
+	assert.Equal(t, ForwardingState(ForwardingState_Paused), transitions[0].From)
+	assert.Equal(t, ForwardingState(ForwardingState_Thumbnail), transitions[0].To)
+	assert.False(t, c[1].Paused())
+}
+
+func TestController_Debounce(t *testing.T) {
+	room := newTestRoom(t, 3)
+	p := room.producers
+
+	controller := NewController(room.router, ControllerOptions{LastN: 1, Debounce: 50 * time.Millisecond})
+	defer controller.Close()
+
+	transitionsCh := make(chan []Transition, 10)
+	controller.On("transitions", func(transitions []Transition) { transitionsCh <- transitions })
+
+	transport, c := room.subscribe(t, nil)
+	waitTransitions(t, transitionsCh)
+
+	for _, producer := range []*mediasoup.Producer{p[1], p[2], p[1], p[2]} {
+		controller.SetRanking(transport.Id(), []string{producer.Id()})
+	}
+
+	transitions := waitTransitions(t, transitionsCh)
+	assert.Equal(t, map[*mediasoup.Consumer]ForwardingState{
+		c[2]: ForwardingState_High,
+		c[0]: ForwardingState_Paused,
+	}, transitionsTo(transitions))
+
+	select {
+	case transitions := <-transitionsCh:
+		t.Fatalf("unexpected transitions: %v", transitions)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Apply doesn't wait.
+	controller.SetRanking(transport.Id(), []string{p[1].Id()})
+	controller.Apply()
+	assert.Equal(t, ForwardingState(ForwardingState_High), controller.State(c[1]))
+}
+
+func TestController_DefaultRankingAndSubscriberId(t *testing.T) {
+	room := newTestRoom(t, 2)
+	p := room.producers
+
+	// The existing consumers are managed too.
+	_, c1 := room.subscribe(t, mediasoup.H{"peerId": "alice"})
+	_, c2 := room.subscribe(t, mediasoup.H{"peerId": "alice"})
+	_, c3 := room.subscribe(t, mediasoup.H{"peerId": "bob"})
+
+	controller := NewController(room.router, ControllerOptions{
+		LastN:    2,
+		Debounce: time.Hour,
+		SubscriberId: func(transport mediasoup.ITransport) string {
+			appData, _ := transport.AppData().(mediasoup.H)
+			if peerId, ok := appData["peerId"].(string); ok {
+				return peerId
+			}
+			return transport.Id()
+		},
+	})
+	defer controller.Close()
+
+	controller.SetDefaultRanking([]string{p[1].Id()})
+	controller.SetRanking("bob", []string{p[0].Id()})
+	controller.Apply()
+
+	// alice has the 4 consumers of her 2 transports, which are watched in
+	// any order.
+	assert.ElementsMatch(t, []ForwardingState{ForwardingState_High, ForwardingState_Thumbnail},
+		[]ForwardingState{controller.State(c1[1]), controller.State(c2[1])})
+	assert.Equal(t, ForwardingState(ForwardingState_Paused), controller.State(c1[0]))
+	assert.Equal(t, ForwardingState(ForwardingState_Paused), controller.State(c2[0]))
+	assert.Equal(t, ForwardingState(ForwardingState_High), controller.State(c3[0]))
+	assert.Equal(t, ForwardingState(ForwardingState_Thumbnail), controller.State(c3[1]))
+
+	controller.SetRanking("bob", nil)
+	controller.Apply()
+	assert.Equal(t, ForwardingState(ForwardingState_High), controller.State(c3[1]))
+}
+
+func TestController_IgnoresAudio(t *testing.T) {
+	room := newTestRoom(t, 0)
+
+	controller := NewController(room.router, ControllerOptions{Debounce: time.Hour})
+
+	transport, err := room.router.CreateDirectTransport()
+	require.NoError(t, err)
+	producer, err := transport.Produce(workertest.AudioProducerOptions())
+	require.NoError(t, err)
+	consumer, err := transport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      producer.Id(),
+		RtpCapabilities: room.router.RtpCapabilities(),
+	})
+	require.NoError(t, err)
+
+	controller.Apply()
+	assert.Empty(t, controller.State(consumer))
+
+	room.router.Close()
+	assert.Eventually(t, controller.Closed, time.Second, 5*time.Millisecond)
+}
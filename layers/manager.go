@@ -0,0 +1,622 @@
+// Package layers picks the preferred layers of the simulcast and SVC
+// consumers of a transport from its estimated outgoing bandwidth. The
+// bandwidth is shared between the consumers according to weights assigned
+// by the application, and the layers of a consumer are capped by the size of
+// the viewport it is rendered in.
+package layers
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+type ManagerOptions struct {
+	/**
+	 * Interval of the GetStats requests reading the available outgoing
+	 * bitrate of the transport. The "bwe" trace events, enabled with
+	 * EnableTraceEvent, update it in between. Default 2s.
+	 */
+	StatsInterval time.Duration
+
+	/**
+	 * Delay before picking the layers after a change, the changes made
+	 * meanwhile are handled together. Default 200ms.
+	 */
+	Debounce time.Duration
+
+	/**
+	 * Bitrate of the top layers of the consumers whose encodings have no
+	 * maxBitrate. Default 1000000.
+	 */
+	DefaultMaxBitrate int
+
+	/**
+	 * Size of the top spatial layer, to which viewport sizes are compared.
+	 * Default 1280x720.
+	 */
+	SourceWidth  int
+	SourceHeight int
+
+	/**
+	 * GetProducerById returns the producer of a consumer, whose encodings give
+	 * the bitrate and the size of each simulcast stream. Optional.
+	 */
+	GetProducerById func(producerId string) *mediasoup.Producer
+}
+
+/**
+ * Hints given by the application about a consumer.
+ */
+type Hints struct {
+	/**
+	 * Share of the bandwidth relatively to the other consumers, also used as
+	 * the priority of the consumer (from 1 to 255). Default 1.
+	 */
+	Weight uint32
+
+	/**
+	 * Size of the viewport rendering the consumer, 0 for no limit.
+	 */
+	Width  int
+	Height int
+}
+
+/**
+ * Decision is a change of the layers of a consumer.
+ */
+type Decision struct {
+	Consumer *mediasoup.Consumer
+	Layers   mediasoup.ConsumerLayers
+	Priority uint32
+
+	/**
+	 * Estimated bitrate of the layers.
+	 */
+	Bitrate int64
+
+	/**
+	 * Available outgoing bitrate of the transport, 0 if not known yet.
+	 */
+	AvailableBitrate int64
+
+	/**
+	 * Whether higher layers were left out for lack of bandwidth.
+	 */
+	BandwidthLimited bool
+
+	/**
+	 * Whether higher layers were left out because of the viewport size.
+	 */
+	ViewportLimited bool
+}
+
+/**
+ * Manager sets the preferred layers and the priority of the simulcast and
+ * SVC consumers of a transport. Consumers created in the transport are
+ * managed automatically.
+ *
+ * @emits decisions - (decisions: []Decision)
+ * @emits close
+ */
+type Manager struct {
+	mediasoup.IEventEmitter
+	logger           mediasoup.Logger
+	transport        mediasoup.ITransport
+	options          ManagerOptions
+	consumers        map[*mediasoup.Consumer]*managedConsumer
+	availableBitrate int64
+	timer            *time.Timer
+	removeTrace      func()
+	closeCh          chan struct{}
+	closed           uint32
+	locker           sync.Mutex
+	// Serializes the applications of the decisions.
+	applyLocker sync.Mutex
+}
+
+type managedConsumer struct {
+	hints        Hints
+	layers       *mediasoup.ConsumerLayers
+	priority     uint32
+	unsubscribes []func()
+}
+
+// step is a candidate pair of layers of a consumer.
+type step struct {
+	layers  mediasoup.ConsumerLayers
+	bitrate int64
+}
+
+/**
+ * NewManager manages the consumers of the given transport, including the
+ * existing ones. The Manager is closed with the transport.
+ */
+func NewManager(transport mediasoup.ITransport, options ManagerOptions) *Manager {
+	logger := mediasoup.NewLogger("LayersManager")
+
+	logger.Debug("constructor()")
+
+	if options.StatsInterval <= 0 {
+		options.StatsInterval = 2 * time.Second
+	}
+	if options.Debounce <= 0 {
+		options.Debounce = 200 * time.Millisecond
+	}
+	if options.DefaultMaxBitrate <= 0 {
+		options.DefaultMaxBitrate = 1000000
+	}
+	if options.SourceWidth <= 0 || options.SourceHeight <= 0 {
+		options.SourceWidth, options.SourceHeight = 1280, 720
+	}
+
+	m := &Manager{
+		IEventEmitter: mediasoup.NewEventEmitter(),
+		logger:        logger,
+		transport:     transport,
+		options:       options,
+		consumers:     make(map[*mediasoup.Consumer]*managedConsumer),
+		closeCh:       make(chan struct{}),
+	}
+
+	m.removeTrace = transport.OnTrace(m.handleTrace)
+
+	transport.Observer().On("newconsumer", m.Manage)
+	transport.Observer().On("close", m.Close)
+
+	for _, consumer := range transport.Consumers() {
+		m.Manage(consumer)
+	}
+
+	go m.statsLoop()
+
+	return m
+}
+
+/**
+ * Whether the Manager is closed.
+ */
+func (m *Manager) Closed() bool {
+	return atomic.LoadUint32(&m.closed) > 0
+}
+
+/**
+ * Close stops managing the consumers, which keep their current layers.
+ */
+func (m *Manager) Close() {
+	if !atomic.CompareAndSwapUint32(&m.closed, 0, 1) {
+		return
+	}
+
+	m.logger.Debug("close()")
+
+	close(m.closeCh)
+	m.removeTrace()
+
+	m.locker.Lock()
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	for _, mc := range m.consumers {
+		for _, unsubscribe := range mc.unsubscribes {
+			unsubscribe()
+		}
+	}
+	m.consumers = make(map[*mediasoup.Consumer]*managedConsumer)
+	m.locker.Unlock()
+
+	m.SafeEmit("close")
+}
+
+/**
+ * Manage adds a consumer, which is ignored unless it is a simulcast or SVC
+ * consumer. The consumers created in the transport don't need to be added.
+ */
+func (m *Manager) Manage(consumer *mediasoup.Consumer) {
+	typ := consumer.Type()
+
+	if m.Closed() || consumer.Closed() || (typ != mediasoup.ConsumerType_Simulcast && typ != mediasoup.ConsumerType_Svc) {
+		return
+	}
+
+	m.locker.Lock()
+	defer m.locker.Unlock()
+
+	if _, ok := m.consumers[consumer]; ok {
+		return
+	}
+
+	m.logger.Debug("manage() [consumerId:%s]", consumer.Id())
+
+	// Changes of the producer scores make layers available or not, and the
+	// current layers show how the worker follows the preferred ones.
+	m.consumers[consumer] = &managedConsumer{
+		hints: Hints{Weight: 1},
+		unsubscribes: []func(){
+			consumer.OnScore(func(mediasoup.ConsumerScore) { m.schedule() }),
+			consumer.OnLayersChange(func(*mediasoup.ConsumerLayers) { m.schedule() }),
+		},
+	}
+
+	consumer.Observer().On("close", func() {
+		m.locker.Lock()
+		defer m.locker.Unlock()
+
+		if mc, ok := m.consumers[consumer]; ok {
+			for _, unsubscribe := range mc.unsubscribes {
+				unsubscribe()
+			}
+			delete(m.consumers, consumer)
+			m.scheduleLocked()
+		}
+	})
+
+	m.scheduleLocked()
+}
+
+/**
+ * SetHints sets the hints of a managed consumer.
+ */
+func (m *Manager) SetHints(consumer *mediasoup.Consumer, hints Hints) {
+	if hints.Weight == 0 {
+		hints.Weight = 1
+	} else if hints.Weight > 255 {
+		hints.Weight = 255
+	}
+
+	m.locker.Lock()
+	defer m.locker.Unlock()
+
+	if mc, ok := m.consumers[consumer]; ok {
+		mc.hints = hints
+		m.scheduleLocked()
+	}
+}
+
+/**
+ * SetAvailableBitrate sets the available outgoing bitrate of the transport,
+ * until the next estimation.
+ */
+func (m *Manager) SetAvailableBitrate(bitrate int64) {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+
+	if bitrate != m.availableBitrate {
+		m.availableBitrate = bitrate
+		m.scheduleLocked()
+	}
+}
+
+/**
+ * AvailableBitrate returns the last available outgoing bitrate of the
+ * transport, 0 if not known yet.
+ */
+func (m *Manager) AvailableBitrate() int64 {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+
+	return m.availableBitrate
+}
+
+/**
+ * Apply picks the layers without waiting for the debounce delay.
+ */
+func (m *Manager) Apply() {
+	m.locker.Lock()
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	m.locker.Unlock()
+
+	m.apply()
+}
+
+func (m *Manager) handleTrace(trace mediasoup.TransportTraceEventData) {
+	if trace.Type != mediasoup.TransportTraceEventType_Bwe {
+		return
+	}
+
+	var info struct {
+		AvailableBitrate int64 `json:"availableBitrate"`
+	}
+
+	data, err := json.Marshal(trace.Info)
+	if err == nil {
+		err = json.Unmarshal(data, &info)
+	}
+	if err != nil {
+		m.logger.Warn("invalid bwe trace: %s", err)
+		return
+	}
+
+	m.SetAvailableBitrate(info.AvailableBitrate)
+}
+
+func (m *Manager) statsLoop() {
+	ticker := time.NewTicker(m.options.StatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			stats, err := m.transport.GetStats()
+			if err != nil {
+				if !m.transport.Closed() {
+					m.logger.Warn("getting transport stats failed: %s", err)
+				}
+				continue
+			}
+			if len(stats) > 0 && stats[0].AvailableOutgoingBitrate > 0 {
+				m.SetAvailableBitrate(stats[0].AvailableOutgoingBitrate)
+			}
+		case <-m.closeCh:
+			return
+		}
+	}
+}
+
+func (m *Manager) schedule() {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+
+	m.scheduleLocked()
+}
+
+// scheduleLocked picks the layers after the debounce delay. m.locker must be
+// held.
+func (m *Manager) scheduleLocked() {
+	if m.timer == nil && !m.Closed() {
+		m.timer = time.AfterFunc(m.options.Debounce, m.apply)
+	}
+}
+
+func (m *Manager) apply() {
+	m.applyLocker.Lock()
+	defer m.applyLocker.Unlock()
+
+	m.locker.Lock()
+	m.timer = nil
+	decisions := m.decide()
+	m.locker.Unlock()
+
+	var applied []Decision
+
+	for _, decision := range decisions {
+		if m.Closed() {
+			return
+		}
+
+		consumer := decision.Consumer
+
+		m.logger.Debug("apply() [consumerId:%s, spatialLayer:%d, temporalLayer:%d, priority:%d]",
+			consumer.Id(), decision.Layers.SpatialLayer, decision.Layers.TemporalLayer, decision.Priority)
+
+		err := consumer.SetPreferredLayers(decision.Layers)
+		if err == nil && consumer.Priority() != decision.Priority {
+			err = consumer.SetPriority(decision.Priority)
+		}
+		if err != nil {
+			if !consumer.Closed() {
+				m.logger.Warn("setting layers failed [consumerId:%s]: %s", consumer.Id(), err)
+			}
+			continue
+		}
+
+		m.locker.Lock()
+		if mc, ok := m.consumers[consumer]; ok {
+			layers := decision.Layers
+			mc.layers = &layers
+			mc.priority = decision.Priority
+		}
+		m.locker.Unlock()
+
+		applied = append(applied, decision)
+	}
+
+	if len(applied) > 0 {
+		m.SafeEmit("decisions", applied)
+	}
+}
+
+// decide shares the available bitrate between the consumers and returns the
+// decisions changing their layers. Every consumer gets its lowest layers, then
+// the consumer with the lowest bitrate relatively to its weight is upgraded
+// to its next layers as long as they fit. m.locker must be held.
+func (m *Manager) decide() (decisions []Decision) {
+	type allocation struct {
+		consumer        *mediasoup.Consumer
+		managed         *managedConsumer
+		steps           []step
+		index           int
+		viewportLimited bool
+	}
+
+	allocations := make([]*allocation, 0, len(m.consumers))
+
+	for consumer, mc := range m.consumers {
+		steps, viewportLimited := m.steps(consumer, mc.hints)
+
+		allocations = append(allocations, &allocation{
+			consumer:        consumer,
+			managed:         mc,
+			steps:           steps,
+			viewportLimited: viewportLimited,
+		})
+	}
+
+	// Stable order for equal shares.
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].consumer.Id() < allocations[j].consumer.Id()
+	})
+
+	remaining := m.availableBitrate
+
+	for _, a := range allocations {
+		remaining -= a.steps[0].bitrate
+	}
+
+	for {
+		var next *allocation
+
+		for _, a := range allocations {
+			if a.index+1 >= len(a.steps) {
+				continue
+			}
+			cost := a.steps[a.index+1].bitrate - a.steps[a.index].bitrate
+			if m.availableBitrate > 0 && cost > remaining {
+				continue
+			}
+			if next == nil || float64(a.steps[a.index].bitrate)/float64(a.managed.hints.Weight) <
+				float64(next.steps[next.index].bitrate)/float64(next.managed.hints.Weight) {
+				next = a
+			}
+		}
+
+		if next == nil {
+			break
+		}
+
+		remaining -= next.steps[next.index+1].bitrate - next.steps[next.index].bitrate
+		next.index++
+	}
+
+	for _, a := range allocations {
+		s := a.steps[a.index]
+		mc := a.managed
+
+		if mc.layers != nil && *mc.layers == s.layers && mc.priority == mc.hints.Weight {
+			continue
+		}
+
+		decisions = append(decisions, Decision{
+			Consumer:         a.consumer,
+			Layers:           s.layers,
+			Priority:         mc.hints.Weight,
+			Bitrate:          s.bitrate,
+			AvailableBitrate: m.availableBitrate,
+			BandwidthLimited: a.index+1 < len(a.steps),
+			ViewportLimited:  a.viewportLimited,
+		})
+	}
+
+	return
+}
+
+// steps returns the layers a consumer can receive ordered by bitrate, and
+// whether the viewport left out higher spatial layers.
+func (m *Manager) steps(consumer *mediasoup.Consumer, hints Hints) (steps []step, viewportLimited bool) {
+	var encoding mediasoup.RtpEncodingParameters
+
+	if encodings := consumer.RtpParameters().Encodings; len(encodings) > 0 {
+		encoding = encodings[0]
+	}
+
+	scalabilityMode := mediasoup.ParseScalabilityMode(encoding.ScalabilityMode)
+	spatialLayers := int(scalabilityMode.SpatialLayers)
+	temporalLayers := int(scalabilityMode.TemporalLayers)
+
+	maxBitrate := encoding.MaxBitrate
+	if maxBitrate <= 0 {
+		maxBitrate = m.options.DefaultMaxBitrate
+	}
+
+	// Each spatial layer has half the size and a quarter of the bitrate of
+	// the next one, unless the producer encodings tell otherwise.
+	spatialBitrates := make([]int64, spatialLayers)
+	scales := make([]float64, spatialLayers)
+
+	for s := spatialLayers - 1; s >= 0; s-- {
+		if s == spatialLayers-1 {
+			spatialBitrates[s], scales[s] = int64(maxBitrate), 1
+		} else {
+			spatialBitrates[s], scales[s] = spatialBitrates[s+1]/4, scales[s+1]*2
+		}
+	}
+
+	if m.options.GetProducerById != nil {
+		if producer := m.options.GetProducerById(consumer.ProducerId()); producer != nil {
+			encodings := producer.RtpParameters().Encodings
+
+			if len(encodings) == spatialLayers {
+				top := float64(encodings[len(encodings)-1].ScaleResolutionDownBy)
+				if top <= 0 {
+					top = 1
+				}
+
+				for s, e := range encodings {
+					if e.MaxBitrate > 0 {
+						spatialBitrates[s] = int64(e.MaxBitrate)
+					}
+					if e.ScaleResolutionDownBy > 0 {
+						scales[s] = float64(e.ScaleResolutionDownBy) / top
+					}
+				}
+			}
+		}
+	}
+
+	// Highest spatial layer needed by the viewport.
+	maxSpatialLayer := spatialLayers - 1
+
+	if hints.Width > 0 || hints.Height > 0 {
+		for s := 0; s < spatialLayers; s++ {
+			width := float64(m.options.SourceWidth) / scales[s]
+			height := float64(m.options.SourceHeight) / scales[s]
+
+			if width >= float64(hints.Width) && height >= float64(hints.Height) {
+				viewportLimited = s < maxSpatialLayer
+				maxSpatialLayer = s
+				break
+			}
+		}
+	}
+
+	// Spatial layers whose stream is not received by the producer are left
+	// out, unless no stream is received yet.
+	available := func(s int) bool { return true }
+
+	if scores := consumer.Score().ProducerScores; len(scores) == spatialLayers {
+		for _, score := range scores {
+			if score > 0 {
+				available = func(s int) bool { return scores[s] > 0 }
+				break
+			}
+		}
+	}
+
+	// Temporal layers are raised before spatial ones, layers which would not
+	// raise the bitrate are skipped.
+	for s := 0; s <= maxSpatialLayer; s++ {
+		if !available(s) {
+			continue
+		}
+		// Each temporal layer doubles the bitrate of the previous one.
+		for t := 0; t < temporalLayers; t++ {
+			bitrate := spatialBitrates[s] >> uint(temporalLayers-1-t)
+
+			if len(steps) > 0 && bitrate <= steps[len(steps)-1].bitrate {
+				continue
+			}
+
+			steps = append(steps, step{
+				layers: mediasoup.ConsumerLayers{
+					SpatialLayer:  uint8(s),
+					TemporalLayer: uint8(t),
+				},
+				bitrate: bitrate,
+			})
+		}
+	}
+
+	if len(steps) == 0 {
+		steps = append(steps, step{bitrate: spatialBitrates[0] >> uint(temporalLayers-1)})
+	}
+
+	return
+}
@@ -0,0 +1,223 @@
+package layers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testTransport struct {
+	mediasoup.ITransport
+	router    *mediasoup.Router
+	process   *workertest.Process
+	producers map[string]*mediasoup.Producer
+}
+
+func newTestTransport(t *testing.T) *testTransport {
+	router, process := workertest.NewRouter(t)
+
+	transport, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+	})
+	require.NoError(t, err)
+
+	return &testTransport{
+		ITransport: transport,
+		router:     router,
+		process:    process,
+		producers:  make(map[string]*mediasoup.Producer),
+	}
+}
+
+func (tt *testTransport) newManager(options ManagerOptions) (*Manager, chan []Decision) {
+	if options.Debounce == 0 {
+		options.Debounce = 10 * time.Millisecond
+	}
+	if options.StatsInterval == 0 {
+		options.StatsInterval = time.Hour
+	}
+	options.GetProducerById = func(producerId string) *mediasoup.Producer {
+		return tt.producers[producerId]
+	}
+
+	manager := NewManager(tt, options)
+	decisions := make(chan []Decision, 10)
+	manager.On("decisions", func(d []Decision) { decisions <- d })
+
+	return manager, decisions
+}
+
+// consume creates a consumer of a new simulcast producer whose streams have
+// 100000, 300000 and 900000 bps.
+func (tt *testTransport) consume(t *testing.T, options mediasoup.ProducerOptions) *mediasoup.Consumer {
+	producerTransport, err := tt.router.CreateDirectTransport()
+	require.NoError(t, err)
+
+	producer, err := producerTransport.Produce(options)
+	require.NoError(t, err)
+	tt.producers[producer.Id()] = producer
+
+	consumer, err := tt.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      producer.Id(),
+		RtpCapabilities: workertest.DeviceRtpCapabilities(),
+	})
+	require.NoError(t, err)
+
+	return consumer
+}
+
+func waitDecisions(t *testing.T, ch chan []Decision) map[*mediasoup.Consumer]Decision {
+	select {
+	case decisions := <-ch:
+		result := make(map[*mediasoup.Consumer]Decision)
+		for _, decision := range decisions {
+			result[decision.Consumer] = decision
+		}
+		return result
+	case <-time.After(time.Second):
+		t.Fatal("decisions not emitted")
+		return nil
+	}
+}
+
+func TestManager_SharesBandwidthByWeight(t *testing.T) {
+	tt := newTestTransport(t)
+	a := tt.consume(t, workertest.VideoProducerOptions())
+	b := tt.consume(t, workertest.VideoProducerOptions())
+
+	manager, decisionsCh := tt.newManager(ManagerOptions{})
+	defer manager.Close()
+
+	// Unknown bandwidth, the top layers are chosen.
+	decisions := waitDecisions(t, decisionsCh)
+	assert.Equal(t, mediasoup.ConsumerLayers{SpatialLayer: 2, TemporalLayer: 2}, decisions[a].Layers)
+	assert.EqualValues(t, 900000, decisions[a].Bitrate)
+	assert.False(t, decisions[a].BandwidthLimited)
+
+	manager.SetAvailableBitrate(1200000)
+
+	decisions = waitDecisions(t, decisionsCh)
+	assert.Equal(t, mediasoup.ConsumerLayers{SpatialLayer: 2, TemporalLayer: 1}, decisions[a].Layers)
+	assert.Equal(t, mediasoup.ConsumerLayers{SpatialLayer: 2, TemporalLayer: 1}, decisions[b].Layers)
+	assert.EqualValues(t, 450000, decisions[b].Bitrate)
+	assert.EqualValues(t, 1200000, decisions[b].AvailableBitrate)
+	assert.True(t, decisions[b].BandwidthLimited)
+
+	manager.SetHints(b, Hints{Weight: 3})
+
+	decisions = waitDecisions(t, decisionsCh)
+	assert.Equal(t, mediasoup.ConsumerLayers{SpatialLayer: 1, TemporalLayer: 2}, decisions[a].Layers)
+	assert.Equal(t, mediasoup.ConsumerLayers{SpatialLayer: 2, TemporalLayer: 2}, decisions[b].Layers)
+	assert.EqualValues(t, 3, decisions[b].Priority)
+
+	assert.Equal(t, &mediasoup.ConsumerLayers{SpatialLayer: 1, TemporalLayer: 2}, a.PreferredLayers())
+	assert.Equal(t, &mediasoup.ConsumerLayers{SpatialLayer: 2, TemporalLayer: 2}, b.PreferredLayers())
+	assert.EqualValues(t, 1, a.Priority())
+	assert.EqualValues(t, 3, b.Priority())
+
+	// Closing a consumer frees its bandwidth.
+	require.NoError(t, b.Close())
+
+	decisions = waitDecisions(t, decisionsCh)
+	assert.Equal(t, mediasoup.ConsumerLayers{SpatialLayer: 2, TemporalLayer: 2}, decisions[a].Layers)
+}
+
+func TestManager_Viewport(t *testing.T) {
+	tt := newTestTransport(t)
+	options := workertest.VideoProducerOptions()
+	for i, scale := range []int{4, 2, 1} {
+		options.RtpParameters.Encodings[i].ScaleResolutionDownBy = scale
+	}
+	consumer := tt.consume(t, options)
+
+	manager, decisionsCh := tt.newManager(ManagerOptions{SourceWidth: 1280, SourceHeight: 720})
+	defer manager.Close()
+
+	waitDecisions(t, decisionsCh)
+
+	manager.SetHints(consumer, Hints{Width: 640, Height: 360})
+
+	decision := waitDecisions(t, decisionsCh)[consumer]
+	assert.Equal(t, mediasoup.ConsumerLayers{SpatialLayer: 1, TemporalLayer: 2}, decision.Layers)
+	assert.True(t, decision.ViewportLimited)
+	assert.False(t, decision.BandwidthLimited)
+
+	manager.SetHints(consumer, Hints{Width: 100, Height: 100})
+
+	decision = waitDecisions(t, decisionsCh)[consumer]
+	assert.Equal(t, mediasoup.ConsumerLayers{SpatialLayer: 0, TemporalLayer: 2}, decision.Layers)
+}
+
+func TestManager_BweTraceAndStats(t *testing.T) {
+	tt := newTestTransport(t)
+	consumer := tt.consume(t, workertest.VideoProducerOptions())
+
+	manager, decisionsCh := tt.newManager(ManagerOptions{StatsInterval: 20 * time.Millisecond})
+	defer manager.Close()
+
+	waitDecisions(t, decisionsCh)
+
+	require.NoError(t, tt.process.Notify(tt.Id(), "trace", mediasoup.H{
+		"type":      "bwe",
+		"direction": "out",
+		"info":      mediasoup.H{"availableBitrate": 200000, "type": "transport-cc"},
+	}))
+
+	decision := waitDecisions(t, decisionsCh)[consumer]
+	assert.Equal(t, mediasoup.ConsumerLayers{SpatialLayer: 1, TemporalLayer: 1}, decision.Layers)
+	assert.EqualValues(t, 200000, decision.AvailableBitrate)
+	assert.True(t, decision.BandwidthLimited)
+
+	tt.process.SetStats(tt.Id(), []mediasoup.H{{
+		"type":                     "webrtc-transport",
+		"transportId":              tt.Id(),
+		"availableOutgoingBitrate": 500000,
+	}})
+
+	decision = waitDecisions(t, decisionsCh)[consumer]
+	assert.Equal(t, mediasoup.ConsumerLayers{SpatialLayer: 2, TemporalLayer: 1}, decision.Layers)
+	assert.EqualValues(t, 500000, manager.AvailableBitrate())
+}
+
+func TestManager_ProducerScores(t *testing.T) {
+	tt := newTestTransport(t)
+	consumer := tt.consume(t, workertest.VideoProducerOptions())
+
+	manager, decisionsCh := tt.newManager(ManagerOptions{})
+	defer manager.Close()
+
+	waitDecisions(t, decisionsCh)
+
+	// The top stream is not received.
+	require.NoError(t, tt.process.Notify(consumer.Id(), "score", mediasoup.ConsumerScore{
+		Score:          10,
+		ProducerScore:  10,
+		ProducerScores: []uint16{10, 10, 0},
+	}))
+
+	decision := waitDecisions(t, decisionsCh)[consumer]
+	assert.Equal(t, mediasoup.ConsumerLayers{SpatialLayer: 1, TemporalLayer: 2}, decision.Layers)
+}
+
+func TestManager_IgnoresSimpleConsumers(t *testing.T) {
+	tt := newTestTransport(t)
+	consumer := tt.consume(t, workertest.AudioProducerOptions())
+
+	manager, decisionsCh := tt.newManager(ManagerOptions{})
+
+	manager.Apply()
+
+	select {
+	case decisions := <-decisionsCh:
+		t.Fatalf("unexpected decisions: %v", decisions)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Nil(t, consumer.PreferredLayers())
+
+	tt.Close()
+	assert.Eventually(t, manager.Closed, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,423 @@
+package mediasource
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+// span is a range of bytes of a file.
+type span struct {
+	offset int64
+	size   int
+}
+
+// fileFrame is a frame of a file, whose data is read from its spans when it
+// is sent.
+type fileFrame struct {
+	spans     []span
+	timestamp time.Duration
+	duration  time.Duration
+	keyFrame  bool
+}
+
+// fileIndex lists the frames of a file.
+type fileIndex struct {
+	mimeType string
+	channels int
+	frames   []*fileFrame
+}
+
+// indexFile reads the frames of an IVF, Ogg/Opus or Annex-B H264 file.
+func indexFile(r io.Reader, frameRate float64) (*fileIndex, error) {
+	reader := &countingReader{r: bufio.NewReader(r)}
+
+	magic, err := reader.r.Peek(4)
+	if err != nil {
+		return nil, mediasoup.NewTypeError("unsupported file format")
+	}
+
+	var index *fileIndex
+
+	switch {
+	case string(magic) == "DKIF":
+		index, err = indexIvf(reader)
+	case string(magic) == "OggS":
+		index, err = indexOgg(reader)
+	case bytes.HasPrefix(magic, []byte{0, 0, 1}) || bytes.Equal(magic, []byte{0, 0, 0, 1}):
+		index, err = indexH264(reader, frameRate)
+	default:
+		return nil, mediasoup.NewTypeError("unsupported file format")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(index.frames) == 0 {
+		return nil, mediasoup.NewTypeError("no frame in file")
+	}
+
+	index.setDurations()
+
+	return index, nil
+}
+
+// setDurations sets the duration of the frames from the timestamp of the
+// next frame, the last frame lasts as long as the previous one.
+func (index *fileIndex) setDurations() {
+	frames := index.frames
+
+	for i := 0; i < len(frames)-1; i++ {
+		if frames[i].duration == 0 {
+			frames[i].duration = frames[i+1].timestamp - frames[i].timestamp
+		}
+	}
+
+	if last := frames[len(frames)-1]; last.duration == 0 {
+		if len(frames) > 1 {
+			last.duration = frames[len(frames)-2].duration
+		} else {
+			last.duration = 20 * time.Millisecond
+		}
+	}
+}
+
+// duration returns the duration of the file.
+func (index *fileIndex) duration() time.Duration {
+	last := index.frames[len(index.frames)-1]
+
+	return last.timestamp + last.duration
+}
+
+type countingReader struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.offset += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.offset++
+	}
+	return b, err
+}
+
+func (c *countingReader) Discard(n int) error {
+	discarded, err := c.r.Discard(n)
+	c.offset += int64(discarded)
+	return err
+}
+
+// unexpectedEOF converts the end of file in the middle of a structure to a
+// truncated file error.
+func unexpectedEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New("truncated file")
+	}
+	return err
+}
+
+func indexIvf(r *countingReader) (*fileIndex, error) {
+	header := make([]byte, 32)
+
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	index := &fileIndex{}
+
+	switch fourcc := string(header[8:12]); fourcc {
+	case "VP80":
+		index.mimeType = "video/VP8"
+	case "VP90":
+		index.mimeType = "video/VP9"
+	default:
+		return nil, mediasoup.NewTypeError("unsupported IVF codec: %s", fourcc)
+	}
+
+	headerSize := int(binary.LittleEndian.Uint16(header[6:]))
+	rate := int64(binary.LittleEndian.Uint32(header[16:]))
+	scale := int64(binary.LittleEndian.Uint32(header[20:]))
+
+	if rate == 0 || scale == 0 {
+		return nil, mediasoup.NewTypeError("invalid IVF time base")
+	}
+	if headerSize > len(header) {
+		if err := r.Discard(headerSize - len(header)); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+	}
+
+	frameHeader := make([]byte, 12)
+
+	for {
+		if _, err := io.ReadFull(r, frameHeader); err == io.EOF {
+			return index, nil
+		} else if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		size := int(binary.LittleEndian.Uint32(frameHeader))
+		pts := int64(binary.LittleEndian.Uint64(frameHeader[4:]))
+		offset := r.offset
+
+		first, _ := r.r.Peek(size)
+		keyFrame := len(first) > 0 && isIvfKeyFrame(index.mimeType, first)
+
+		if err := r.Discard(size); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		index.frames = append(index.frames, &fileFrame{
+			spans:     []span{{offset: offset, size: size}},
+			timestamp: time.Duration(pts * scale * int64(time.Second) / rate),
+			keyFrame:  keyFrame,
+		})
+	}
+}
+
+func isIvfKeyFrame(mimeType string, frame []byte) bool {
+	if mimeType == "video/VP8" {
+		// The P bit of the frame tag is 0 for key frames.
+		return frame[0]&0x01 == 0
+	}
+
+	// VP9 uncompressed header: frame_marker, profile, show_existing_frame and
+	// frame_type, which is 0 for key frames.
+	profile := frame[0]>>5&0x01 | frame[0]>>3&0x02
+	shift := uint(2)
+	if profile == 3 {
+		shift = 1
+	}
+	showExistingFrame := frame[0] >> (shift + 1) & 0x01
+	frameType := frame[0] >> shift & 0x01
+
+	return frame[0]>>6 == 2 && showExistingFrame == 0 && frameType == 0
+}
+
+func indexOgg(r *countingReader) (*fileIndex, error) {
+	index := &fileIndex{mimeType: "audio/opus", channels: 2}
+	header := make([]byte, 27)
+	lacing := make([]byte, 255)
+
+	var (
+		serial    uint32
+		packets   int
+		spans     []span
+		first     []byte
+		timestamp time.Duration
+	)
+
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return index, nil
+		} else if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if string(header[:4]) != "OggS" {
+			return nil, errors.New("invalid Ogg page")
+		}
+
+		pageSerial := binary.LittleEndian.Uint32(header[14:])
+		if packets == 0 && len(spans) == 0 {
+			serial = pageSerial
+		}
+
+		segments := int(header[26])
+		if _, err := io.ReadFull(r, lacing[:segments]); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+
+		size := 0
+		for _, l := range lacing[:segments] {
+			size += int(l)
+		}
+		offset := r.offset
+		data := make([]byte, size)
+
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		// Other logical streams are ignored.
+		if pageSerial != serial {
+			continue
+		}
+
+		position := 0
+
+		for _, l := range lacing[:segments] {
+			if len(spans) == 0 {
+				first = data[position:]
+			}
+			spans = append(spans, span{offset: offset + int64(position), size: int(l)})
+			position += int(l)
+
+			// The packet continues in the next segment.
+			if l == 255 {
+				continue
+			}
+
+			switch packets {
+			case 0:
+				if len(first) < 19 || string(first[:8]) != "OpusHead" {
+					return nil, mediasoup.NewTypeError("unsupported Ogg codec")
+				}
+				index.channels = int(first[9])
+
+			case 1:
+				// OpusTags
+
+			default:
+				// Empty packets, such as the one of an empty last page, are
+				// skipped.
+				if len(spans) == 1 && l == 0 {
+					break
+				}
+				duration := opusPacketDuration(first)
+
+				index.frames = append(index.frames, &fileFrame{
+					spans:     spans,
+					timestamp: timestamp,
+					duration:  duration,
+					keyFrame:  true,
+				})
+				timestamp += duration
+			}
+
+			packets++
+			spans = nil
+		}
+	}
+}
+
+// opusPacketDuration returns the duration of an Opus packet read from its
+// TOC byte (RFC 6716, section 3.1).
+func opusPacketDuration(packet []byte) time.Duration {
+	if len(packet) == 0 {
+		return 0
+	}
+
+	toc := packet[0]
+	config := toc >> 3
+
+	var frameDuration time.Duration
+
+	switch {
+	case config < 12: // SILK
+		frameDuration = []time.Duration{10, 20, 40, 60}[config%4] * time.Millisecond
+	case config < 16: // Hybrid
+		frameDuration = []time.Duration{10, 20}[config%2] * time.Millisecond
+	default: // CELT
+		frameDuration = []time.Duration{2500, 5000, 10000, 20000}[config%4] * time.Microsecond
+	}
+
+	frames := 1
+
+	switch toc & 0x03 {
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) > 1 {
+			frames = int(packet[1] & 0x3f)
+		}
+	}
+
+	return frameDuration * time.Duration(frames)
+}
+
+// indexH264 splits an Annex-B byte stream in access units (ITU-T H.264,
+// section 7.4.1.2.3), which keep their start codes.
+func indexH264(r *countingReader, frameRate float64) (*fileIndex, error) {
+	if frameRate <= 0 {
+		frameRate = 30
+	}
+
+	index := &fileIndex{mimeType: "video/H264"}
+	frameDuration := time.Duration(float64(time.Second) / frameRate)
+
+	var (
+		current   *fileFrame
+		start     int64
+		hasSlice  bool
+		zeros     int
+		nalStart  int64
+		nalHeader []byte
+	)
+
+	closeFrame := func(end int64) {
+		if current != nil {
+			current.spans = []span{{offset: start, size: int(end - start)}}
+			index.frames = append(index.frames, current)
+		}
+	}
+
+	// handleNal is called with the first two bytes of each NAL unit.
+	handleNal := func(header []byte) {
+		nalType := header[0] & 0x1f
+		isSlice := nalType >= 1 && nalType <= 5
+		// first_mb_in_slice is 0 when the first bit of the slice header is 1.
+		firstSlice := isSlice && len(header) > 1 && header[1]&0x80 != 0
+		startsFrame := (nalType >= 6 && nalType <= 9) || (nalType >= 14 && nalType <= 18) || firstSlice
+
+		if current == nil || (hasSlice && startsFrame) {
+			closeFrame(nalStart)
+
+			current = &fileFrame{
+				timestamp: time.Duration(len(index.frames)) * frameDuration,
+				duration:  frameDuration,
+			}
+			start = nalStart
+			hasSlice = false
+		}
+		if isSlice {
+			hasSlice = true
+		}
+		if nalType == 5 {
+			current.keyFrame = true
+		}
+	}
+
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if nalHeader != nil {
+			nalHeader = append(nalHeader, b)
+			if len(nalHeader) == 2 {
+				handleNal(nalHeader)
+				nalHeader = nil
+			}
+		}
+
+		switch {
+		case b == 0:
+			zeros++
+			continue
+		case b == 1 && zeros >= 2:
+			nalStart = r.offset - 1 - int64(zeros)
+			nalHeader = []byte{}
+		}
+		zeros = 0
+	}
+
+	if len(nalHeader) == 1 {
+		handleNal(nalHeader)
+	}
+	closeFrame(r.offset)
+
+	return index, nil
+}
@@ -0,0 +1,442 @@
+package mediasource
+
+import (
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+type FilePlayerOptions struct {
+	/**
+	 * Path of the IVF (VP8 or VP9), Ogg (Opus) or Annex-B H264 file.
+	 */
+	Path string
+
+	/**
+	 * Frame rate of H264 files, which carry no timing. Default 30.
+	 */
+	FrameRate float64
+
+	/**
+	 * Playback speed relative to real time, 2 plays twice as fast. Default 1.
+	 */
+	Speed float64
+
+	/**
+	 * Whether the file is played again from the start when it ends.
+	 */
+	Loop bool
+
+	/**
+	 * Whether the playback starts paused. Default false.
+	 */
+	Paused bool
+
+	/**
+	 * Options of the MediaSource sending the frames.
+	 */
+	MediaSourceOptions MediaSourceOptions
+
+	/**
+	 * Custom application data of the Producer.
+	 */
+	AppData interface{}
+}
+
+/**
+ * FilePlayer plays a file through a Producer created on its own
+ * DirectTransport. The Producer can be consumed like any other.
+ *
+ * @emits ended
+ * @emits close
+ */
+type FilePlayer struct {
+	mediasoup.IEventEmitter
+	logger    mediasoup.Logger
+	transport *mediasoup.DirectTransport
+	source    *MediaSource
+	file      *os.File
+	index     *fileIndex
+	speed     float64
+	loop      bool
+	// Index of the next frame.
+	position int
+	paused   bool
+	ended    bool
+	// Wall clock time and media time elapsed since the playback started or
+	// was resumed or seeked, the frames are sent at playbackStart+elapsed/speed.
+	playbackStart time.Time
+	elapsed       time.Duration
+	pausedAt      time.Time
+	wakeCh        chan struct{}
+	closeCh       chan struct{}
+	closed        uint32
+	locker        sync.Mutex
+}
+
+/**
+ * NewFilePlayer opens a file and creates a Producer in the router playing it.
+ */
+func NewFilePlayer(router *mediasoup.Router, options FilePlayerOptions) (player *FilePlayer, err error) {
+	logger := mediasoup.NewLogger("FilePlayer")
+
+	logger.Debug("constructor()")
+
+	if options.Speed <= 0 {
+		options.Speed = 1
+	}
+
+	file, err := os.Open(options.Path)
+	if err != nil {
+		return
+	}
+
+	index, err := indexFile(file, options.FrameRate)
+	if err != nil {
+		file.Close()
+		return
+	}
+
+	codec, err := findCodec(router, index.mimeType)
+	if err != nil {
+		file.Close()
+		return
+	}
+
+	transport, err := router.CreateDirectTransport()
+	if err != nil {
+		file.Close()
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			transport.Close()
+			file.Close()
+		}
+	}()
+
+	producer, err := transport.Produce(mediasoup.ProducerOptions{
+		Kind: codec.Kind,
+		RtpParameters: mediasoup.RtpParameters{
+			Codecs: []*mediasoup.RtpCodecParameters{
+				{
+					MimeType:    codec.MimeType,
+					PayloadType: codec.PreferredPayloadType,
+					ClockRate:   codec.ClockRate,
+					Channels:    codec.Channels,
+					Parameters:  codec.Parameters,
+				},
+			},
+			Encodings: []mediasoup.RtpEncodingParameters{{Ssrc: rand.Uint32()}},
+		},
+		AppData: options.AppData,
+	})
+	if err != nil {
+		return
+	}
+
+	source, err := NewMediaSource(transport, producer, options.MediaSourceOptions)
+	if err != nil {
+		return
+	}
+
+	player = &FilePlayer{
+		IEventEmitter: mediasoup.NewEventEmitter(),
+		logger:        logger,
+		transport:     transport,
+		source:        source,
+		file:          file,
+		index:         index,
+		speed:         options.Speed,
+		loop:          options.Loop,
+		paused:        options.Paused,
+		playbackStart: time.Now(),
+		pausedAt:      time.Now(),
+		wakeCh:        make(chan struct{}, 1),
+		closeCh:       make(chan struct{}),
+	}
+
+	source.On("close", func() { player.Close() })
+	transport.OnRouterClose(func() { player.Close() })
+
+	go player.run()
+
+	return
+}
+
+// findCodec returns the codec of the router with the given MIME type, H264
+// must use packetization mode 1.
+func findCodec(router *mediasoup.Router, mimeType string) (*mediasoup.RtpCodecCapability, error) {
+	for _, codec := range router.RtpCapabilities().Codecs {
+		if !strings.EqualFold(codec.MimeType, mimeType) {
+			continue
+		}
+		if strings.EqualFold(mimeType, "video/H264") && codec.Parameters.PacketizationMode != 1 {
+			continue
+		}
+		return codec, nil
+	}
+
+	return nil, mediasoup.NewUnsupportedError("router does not support %s", mimeType)
+}
+
+/**
+ * Producer playing the file.
+ */
+func (p *FilePlayer) Producer() *mediasoup.Producer {
+	return p.source.Producer()
+}
+
+/**
+ * DirectTransport of the Producer.
+ */
+func (p *FilePlayer) Transport() *mediasoup.DirectTransport {
+	return p.transport
+}
+
+/**
+ * Duration of the file.
+ */
+func (p *FilePlayer) Duration() time.Duration {
+	return p.index.duration()
+}
+
+/**
+ * Position of the next frame in the file.
+ */
+func (p *FilePlayer) Position() time.Duration {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	if p.position >= len(p.index.frames) {
+		return p.index.duration()
+	}
+
+	return p.index.frames[p.position].timestamp
+}
+
+/**
+ * Whether the playback is paused.
+ */
+func (p *FilePlayer) Paused() bool {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	return p.paused
+}
+
+/**
+ * Whether the file ended without looping. Seeking or resuming plays it again.
+ */
+func (p *FilePlayer) Ended() bool {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	return p.ended
+}
+
+/**
+ * Whether the FilePlayer is closed.
+ */
+func (p *FilePlayer) Closed() bool {
+	return atomic.LoadUint32(&p.closed) > 0
+}
+
+/**
+ * Pause the playback.
+ */
+func (p *FilePlayer) Pause() {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	if p.paused {
+		return
+	}
+
+	p.logger.Debug("pause()")
+
+	p.paused = true
+	p.pausedAt = time.Now()
+	p.wake()
+}
+
+/**
+ * Resume the playback, the RTP timestamps keep following the wall clock. A
+ * file which ended is played from the start.
+ */
+func (p *FilePlayer) Resume() {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	if !p.paused && !p.ended {
+		return
+	}
+
+	p.logger.Debug("resume()")
+
+	if p.paused {
+		p.source.Skip(time.Since(p.pausedAt))
+	}
+	if p.ended {
+		p.position = 0
+	}
+	p.paused = false
+	p.ended = false
+	p.restart()
+}
+
+/**
+ * Seek moves the playback to the given position. Video is played from the
+ * last key frame before the position.
+ */
+func (p *FilePlayer) Seek(position time.Duration) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	p.logger.Debug("seek() [position:%s]", position)
+
+	frames := p.index.frames
+	next := 0
+
+	for i, f := range frames {
+		if f.timestamp > position {
+			break
+		}
+		if f.keyFrame {
+			next = i
+		}
+		// Audio starts at the first frame ending after the position.
+		if p.index.mimeType == "audio/opus" && f.timestamp+f.duration <= position {
+			next = i + 1
+		}
+	}
+
+	p.position = next
+	p.ended = false
+	p.restart()
+}
+
+/**
+ * Close stops the playback and closes the transport and the file.
+ */
+func (p *FilePlayer) Close() {
+	if !atomic.CompareAndSwapUint32(&p.closed, 0, 1) {
+		return
+	}
+
+	p.logger.Debug("close()")
+
+	close(p.closeCh)
+
+	p.source.Close()
+	p.transport.Close()
+
+	p.locker.Lock()
+	p.file.Close()
+	p.locker.Unlock()
+
+	p.SafeEmit("close")
+}
+
+// restart resets the pacing of the frames. p.locker must be held.
+func (p *FilePlayer) restart() {
+	p.playbackStart = time.Now()
+	p.elapsed = 0
+	p.wake()
+}
+
+// wake makes run check the state of the playback. p.locker must be held.
+func (p *FilePlayer) wake() {
+	select {
+	case p.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (p *FilePlayer) run() {
+	for {
+		p.locker.Lock()
+
+		if p.paused || p.ended {
+			p.locker.Unlock()
+
+			select {
+			case <-p.wakeCh:
+				continue
+			case <-p.closeCh:
+				return
+			}
+		}
+
+		if p.position >= len(p.index.frames) {
+			if !p.loop {
+				p.ended = true
+				p.locker.Unlock()
+				p.SafeEmit("ended")
+				continue
+			}
+			p.position = 0
+		}
+
+		f := p.index.frames[p.position]
+		due := p.playbackStart.Add(time.Duration(float64(p.elapsed) / p.speed))
+
+		if wait := time.Until(due); wait > 0 {
+			p.locker.Unlock()
+
+			timer := time.NewTimer(wait)
+
+			select {
+			case <-timer.C:
+			case <-p.wakeCh:
+				timer.Stop()
+			case <-p.closeCh:
+				timer.Stop()
+				return
+			}
+			continue
+		}
+
+		data, err := p.readFrame(f)
+
+		p.position++
+		p.elapsed += f.duration
+		p.locker.Unlock()
+
+		if err != nil {
+			p.logger.Error("reading frame failed: %s", err)
+			p.Close()
+			return
+		}
+
+		if err := p.source.WriteFrame(data, f.duration); err != nil && !p.Closed() {
+			p.logger.Warn("sending frame failed: %s", err)
+		}
+	}
+}
+
+// readFrame reads the data of a frame. p.locker must be held.
+func (p *FilePlayer) readFrame(f *fileFrame) ([]byte, error) {
+	size := 0
+	for _, s := range f.spans {
+		size += s.size
+	}
+
+	data := make([]byte, size)
+	position := 0
+
+	for _, s := range f.spans {
+		if _, err := p.file.ReadAt(data[position:position+s.size], s.offset); err != nil {
+			return nil, err
+		}
+		position += s.size
+	}
+
+	return data, nil
+}
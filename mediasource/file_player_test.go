@@ -0,0 +1,383 @@
+package mediasource
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/h264"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeIvf writes a VP8 IVF file of 30 fps whose frames carry their index,
+// every third frame is a key frame.
+func writeIvf(t *testing.T, frames int) string {
+	data := []byte("DKIF\x00\x00\x20\x00VP80")
+	data = append(data, 0x80, 0x02, 0xe0, 0x01) // 640x480
+	data = append(data, 30, 0, 0, 0, 1, 0, 0, 0)
+	data = append(data, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	for i := 0; i < frames; i++ {
+		frame := []byte{0x01, byte(i), 0xaa, 0xbb}
+		if i%3 == 0 {
+			frame[0] = 0x00
+		}
+		header := make([]byte, 12)
+		binary.LittleEndian.PutUint32(header, uint32(len(frame)))
+		binary.LittleEndian.PutUint64(header[4:], uint64(i))
+
+		data = append(data, header...)
+		data = append(data, frame...)
+	}
+
+	return writeFile(t, "video.ivf", data)
+}
+
+func oggPage(serial uint32, headerType byte, packets ...[]byte) []byte {
+	lacing := []byte{}
+	body := []byte{}
+
+	for _, packet := range packets {
+		for i := 0; i < len(packet)/255; i++ {
+			lacing = append(lacing, 255)
+		}
+		lacing = append(lacing, byte(len(packet)%255))
+		body = append(body, packet...)
+	}
+
+	page := []byte("OggS\x00")
+	page = append(page, headerType)
+	page = append(page, make([]byte, 20)...)
+	binary.LittleEndian.PutUint32(page[14:], serial)
+	page = append(page, byte(len(lacing)))
+	page = append(page, lacing...)
+
+	return append(page, body...)
+}
+
+// writeOgg writes an Ogg/Opus file of 20ms packets whose second byte is
+// their index.
+func writeOgg(t *testing.T, packets int) string {
+	head := []byte("OpusHead\x01\x02\x00\x00\x80\xbb\x00\x00\x00\x00\x00")
+
+	data := oggPage(1, 0x02, head)
+	data = append(data, oggPage(1, 0, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00"))...)
+	// Another logical stream is ignored.
+	data = append(data, oggPage(2, 0x02, []byte("foo"))...)
+
+	// Two packets per page.
+	for i := 0; i < packets; i += 2 {
+		page := [][]byte{{0xfc, byte(i)}}
+		if i+1 < packets {
+			page = append(page, []byte{0xfc, byte(i + 1)})
+		}
+		data = append(data, oggPage(1, 0, page...)...)
+	}
+
+	return writeFile(t, "audio.ogg", data)
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, ioutil.WriteFile(path, data, 0644))
+
+	return path
+}
+
+func TestIndexFile_Ivf(t *testing.T) {
+	data, _ := ioutil.ReadFile(writeIvf(t, 4))
+
+	index, err := indexFile(bytes.NewReader(data), 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, "video/VP8", index.mimeType)
+	require.Len(t, index.frames, 4)
+
+	for i, f := range index.frames {
+		assert.Equal(t, time.Duration(i)*time.Second/30, f.timestamp)
+		assert.Equal(t, i%3 == 0, f.keyFrame)
+		assert.Equal(t, []byte{byte(i)}, data[f.spans[0].offset+1:f.spans[0].offset+2])
+	}
+	assert.InDelta(t, 4*time.Second/30, index.duration(), float64(time.Microsecond))
+}
+
+func TestIndexFile_Ogg(t *testing.T) {
+	data, _ := ioutil.ReadFile(writeOgg(t, 3))
+
+	// A packet spanning two pages.
+	long := append([]byte{0xfc}, bytes.Repeat([]byte{0x01}, 299)...)
+	data = append(data, oggPage(1, 0, long[:255])[:27]...)
+	data[len(data)-1] = 1
+	data = append(data, 255)
+	data = append(data, long[:255]...)
+	data = append(data, oggPage(1, 0x01, long[255:])...)
+	// Empty last page.
+	data = append(data, oggPage(1, 0x04, nil)...)
+
+	index, err := indexFile(bytes.NewReader(data), 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, "audio/opus", index.mimeType)
+	assert.Equal(t, 2, index.channels)
+	require.Len(t, index.frames, 4)
+
+	for i, f := range index.frames[:3] {
+		assert.Equal(t, time.Duration(i)*20*time.Millisecond, f.timestamp)
+		assert.Equal(t, []span{{offset: f.spans[0].offset, size: 2}}, f.spans)
+		assert.Equal(t, byte(i), data[f.spans[0].offset+1])
+	}
+
+	spans := index.frames[3].spans
+	require.Len(t, spans, 2)
+	assert.Equal(t, 255, spans[0].size)
+	assert.Equal(t, 45, spans[1].size)
+	assert.Equal(t, 80*time.Millisecond, index.duration())
+}
+
+func TestIndexFile_H264(t *testing.T) {
+	sps := []byte{0, 0, 0, 1, 0x67, 0x42, 0xe0, 0x1f}
+	pps := []byte{0, 0, 1, 0x68, 0xce, 0x38}
+	idr := []byte{0, 0, 0, 1, 0x65, 0x88, 0x01}
+	// Second slice of the IDR picture, first_mb_in_slice is not 0.
+	idr2 := []byte{0, 0, 1, 0x65, 0x40, 0x02}
+	p := []byte{0, 0, 0, 1, 0x41, 0x9a, 0x03}
+
+	stream := bytes.Join([][]byte{sps, pps, idr, idr2, p, p}, nil)
+
+	index, err := indexFile(bytes.NewReader(stream), 25)
+	require.NoError(t, err)
+
+	assert.Equal(t, "video/H264", index.mimeType)
+	require.Len(t, index.frames, 3)
+
+	first := index.frames[0]
+	assert.True(t, first.keyFrame)
+	assert.Equal(t, bytes.Join([][]byte{sps, pps, idr, idr2}, nil), stream[first.spans[0].offset:first.spans[0].offset+int64(first.spans[0].size)])
+
+	for i, f := range index.frames[1:] {
+		assert.False(t, f.keyFrame)
+		assert.Equal(t, p, stream[f.spans[0].offset:f.spans[0].offset+int64(f.spans[0].size)])
+		assert.Equal(t, time.Duration(i+1)*40*time.Millisecond, f.timestamp)
+	}
+}
+
+func TestIndexFile_Unsupported(t *testing.T) {
+	_, err := indexFile(bytes.NewReader([]byte("RIFF....WAVE")), 0)
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+}
+
+func TestOpusPacketDuration(t *testing.T) {
+	assert.Equal(t, 20*time.Millisecond, opusPacketDuration([]byte{0xfc}))
+	assert.Equal(t, 40*time.Millisecond, opusPacketDuration([]byte{0xfd}))
+	assert.Equal(t, 60*time.Millisecond, opusPacketDuration([]byte{0x18}))
+	assert.Equal(t, 2500*time.Microsecond, opusPacketDuration([]byte{0x80}))
+	assert.Equal(t, 60*time.Millisecond, opusPacketDuration([]byte{0xfb, 0x03}))
+}
+
+type testPlayer struct {
+	*FilePlayer
+	frames chan *rtp.Packet
+}
+
+func newTestPlayer(t *testing.T, options FilePlayerOptions) *testPlayer {
+	mediaCodecs := append(workertest.MediaCodecs(), &mediasoup.RtpCodecCapability{
+		Kind:      "video",
+		MimeType:  "video/H264",
+		ClockRate: 90000,
+		Parameters: mediasoup.RtpCodecSpecificParameters{
+			RtpParameter: h264.RtpParameter{PacketizationMode: 1, ProfileLevelId: "42e01f"},
+		},
+	})
+
+	router, _ := workertest.NewRouter(t, mediaCodecs...)
+
+	// The playback starts once consumed.
+	paused := options.Paused
+	options.Paused = true
+
+	player, err := NewFilePlayer(router, options)
+	require.NoError(t, err)
+
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+
+	consumer, err := transport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      player.Producer().Id(),
+		RtpCapabilities: router.RtpCapabilities(),
+	})
+	require.NoError(t, err)
+
+	// The last packet of each frame.
+	frames := make(chan *rtp.Packet, 100)
+
+	consumer.OnRtpPacket(func(packet *rtp.Packet) {
+		if packet.Marker {
+			frames <- packet
+		}
+	})
+
+	if !paused {
+		player.Resume()
+	}
+
+	return &testPlayer{FilePlayer: player, frames: frames}
+}
+
+func (p *testPlayer) receive(t *testing.T, count int) (frames []*rtp.Packet) {
+	for i := 0; i < count; i++ {
+		select {
+		case frame := <-p.frames:
+			frames = append(frames, frame)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d frames, expected %d", i, count)
+		}
+	}
+	return
+}
+
+// vp8FrameIndex returns the index carried by a VP8 frame sent in one packet.
+func vp8FrameIndex(t *testing.T, packet *rtp.Packet) int {
+	data, err := (&codecs.VP8Packet{}).Unmarshal(packet.Payload)
+	require.NoError(t, err)
+
+	return int(data[1])
+}
+
+func TestFilePlayer_Ivf(t *testing.T) {
+	p := newTestPlayer(t, FilePlayerOptions{Path: writeIvf(t, 10), Speed: 10})
+
+	ended := make(chan struct{})
+	p.On("ended", func() { close(ended) })
+
+	assert.Equal(t, mediasoup.MediaKind("video"), p.Producer().Kind())
+	assert.InDelta(t, time.Second/3, p.Duration(), float64(time.Microsecond))
+
+	frames := p.receive(t, 10)
+
+	for i, frame := range frames {
+		assert.Equal(t, i, vp8FrameIndex(t, frame))
+		assert.Equal(t, frames[0].Timestamp+uint32(i*3000), frame.Timestamp)
+	}
+
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("ended not emitted")
+	}
+	assert.True(t, p.Ended())
+	assert.Equal(t, p.Duration(), p.Position())
+
+	// Resuming plays the file again.
+	p.Resume()
+	assert.Equal(t, 0, vp8FrameIndex(t, p.receive(t, 1)[0]))
+}
+
+func TestFilePlayer_Pacing(t *testing.T) {
+	p := newTestPlayer(t, FilePlayerOptions{Path: writeOgg(t, 6)})
+
+	start := time.Now()
+	frames := p.receive(t, 6)
+
+	// The last packet is sent 100ms after the first one.
+	assert.True(t, time.Since(start) >= 90*time.Millisecond)
+
+	for i, frame := range frames {
+		assert.Equal(t, []byte{0xfc, byte(i)}, frame.Payload)
+		assert.Equal(t, frames[0].Timestamp+uint32(i*960), frame.Timestamp)
+	}
+}
+
+func TestFilePlayer_Loop(t *testing.T) {
+	p := newTestPlayer(t, FilePlayerOptions{Path: writeOgg(t, 3), Speed: 10, Loop: true})
+
+	frames := p.receive(t, 7)
+
+	for i, frame := range frames {
+		assert.Equal(t, byte(i%3), frame.Payload[1])
+		// Timestamps keep increasing across loops.
+		assert.Equal(t, frames[0].Timestamp+uint32(i*960), frame.Timestamp)
+	}
+	assert.False(t, p.Ended())
+
+	onClose := make(chan struct{})
+	p.On("close", func() { close(onClose) })
+
+	p.Producer().Close()
+
+	select {
+	case <-onClose:
+	case <-time.After(time.Second):
+		t.Fatal("close not emitted")
+	}
+	assert.True(t, p.Closed())
+	assert.True(t, p.Transport().Closed())
+}
+
+func TestFilePlayer_PauseSeekResume(t *testing.T) {
+	p := newTestPlayer(t, FilePlayerOptions{Path: writeIvf(t, 10), Speed: 10, Paused: true})
+
+	assert.True(t, p.Paused())
+
+	select {
+	case <-p.frames:
+		t.Fatal("frame sent while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Frame 5 is not a key frame, the playback starts from frame 3.
+	p.Seek(5 * time.Second / 30)
+	assert.Equal(t, 3*time.Second/30, p.Position())
+
+	p.Resume()
+	assert.False(t, p.Paused())
+
+	frames := p.receive(t, 2)
+	assert.Equal(t, 3, vp8FrameIndex(t, frames[0]))
+	assert.Equal(t, 4, vp8FrameIndex(t, frames[1]))
+
+	p.Pause()
+	// A frame may be on its way.
+	time.Sleep(20 * time.Millisecond)
+	for len(p.frames) > 0 {
+		<-p.frames
+	}
+	position := p.Position()
+
+	select {
+	case <-p.frames:
+		t.Fatal("frame sent while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, position, p.Position())
+
+	p.Close()
+	assert.True(t, p.Producer().Closed())
+}
+
+func TestFilePlayer_H264(t *testing.T) {
+	stream := []byte{0, 0, 0, 1, 0x67, 0x42, 0xe0, 0x1f, 0, 0, 0, 1, 0x68, 0xce, 0x38, 0, 0, 0, 1, 0x65, 0x88, 0x01}
+	for i := 0; i < 3; i++ {
+		stream = append(stream, 0, 0, 0, 1, 0x41, 0x9a, byte(i))
+	}
+
+	p := newTestPlayer(t, FilePlayerOptions{
+		Path:      writeFile(t, "video.h264", stream),
+		FrameRate: 25,
+		Speed:     10,
+	})
+
+	frames := p.receive(t, 4)
+
+	for i, frame := range frames {
+		assert.Equal(t, frames[0].Timestamp+uint32(i*3600), frame.Timestamp)
+	}
+	assert.Equal(t, []byte{0x41, 0x9a, 2}, frames[3].Payload)
+}
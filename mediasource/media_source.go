@@ -0,0 +1,264 @@
+// Package mediasource injects encoded media in a router through a Producer
+// created on a DirectTransport. Frames are packetized in RTP packets carrying
+// the payload type, SSRC and clock rate of the Producer's RTP parameters, and
+// FilePlayer reads them from IVF, Ogg and H264 files.
+package mediasource
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+)
+
+type MediaSourceOptions struct {
+	/**
+	 * Maximum size of the RTP packets. Default 1200.
+	 */
+	Mtu int
+
+	/**
+	 * Interval of the RTCP Sender Reports sent through the transport. Default
+	 * 0, no Sender Report is sent.
+	 */
+	SenderReportInterval time.Duration
+}
+
+/**
+ * MediaSource packetizes encoded frames and sends them to a Producer created
+ * on a DirectTransport. The frames are Opus packets, VP8 or VP9 frames, or
+ * H264 access units in Annex-B format.
+ *
+ * @emits close
+ */
+type MediaSource struct {
+	mediasoup.IEventEmitter
+	logger     mediasoup.Logger
+	transport  *mediasoup.DirectTransport
+	producer   *mediasoup.Producer
+	packetizer rtp.Packetizer
+	ssrc       uint32
+	clockRate  int
+	// Difference between the durations and the samples added to the timestamp.
+	remainder   int64
+	packetCount uint32
+	octetCount  uint32
+	// Timestamp of the last frame and the time it was sent.
+	lastTimestamp uint32
+	lastSentAt    time.Time
+	closed        uint32
+	closeCh       chan struct{}
+	locker        sync.Mutex
+}
+
+/**
+ * NewMediaSource returns a MediaSource sending to the given Producer of the
+ * given DirectTransport.
+ */
+func NewMediaSource(transport *mediasoup.DirectTransport, producer *mediasoup.Producer, options MediaSourceOptions) (*MediaSource, error) {
+	logger := mediasoup.NewLogger("MediaSource")
+
+	logger.Debug("constructor()")
+
+	rtpParameters := producer.RtpParameters()
+
+	if len(rtpParameters.Codecs) == 0 || len(rtpParameters.Encodings) == 0 {
+		return nil, mediasoup.NewTypeError("missing codec or encoding")
+	}
+	if options.Mtu <= 0 {
+		options.Mtu = 1200
+	}
+
+	codec := rtpParameters.Codecs[0]
+	ssrc := rtpParameters.Encodings[0].Ssrc
+
+	payloader := newPayloader(codec.MimeType)
+	if payloader == nil {
+		return nil, mediasoup.NewTypeError("unsupported codec: %s", codec.MimeType)
+	}
+	if ssrc == 0 {
+		return nil, mediasoup.NewTypeError("missing encoding ssrc")
+	}
+
+	packetizer := rtp.NewPacketizer(uint16(options.Mtu), codec.PayloadType, ssrc,
+		payloader, rtp.NewRandomSequencer(), uint32(codec.ClockRate))
+
+	if id, ok := producer.HeaderExtensionIds()[mediasoup.RtpHeaderExtensionUri_AbsSendTime]; ok {
+		packetizer.EnableAbsSendTime(int(id))
+	}
+
+	source := &MediaSource{
+		IEventEmitter: mediasoup.NewEventEmitter(),
+		logger:        logger,
+		transport:     transport,
+		producer:      producer,
+		packetizer:    packetizer,
+		ssrc:          ssrc,
+		clockRate:     codec.ClockRate,
+		closeCh:       make(chan struct{}),
+	}
+
+	producer.OnTransportClose(func() { source.Close() })
+	producer.Observer().On("close", func() { source.Close() })
+
+	if options.SenderReportInterval > 0 {
+		go source.senderReportLoop(options.SenderReportInterval)
+	}
+
+	return source, nil
+}
+
+func newPayloader(mimeType string) rtp.Payloader {
+	switch strings.ToLower(mimeType) {
+	case "audio/opus":
+		return &codecs.OpusPayloader{}
+	case "video/vp8":
+		return &codecs.VP8Payloader{EnablePictureID: true}
+	case "video/vp9":
+		return &codecs.VP9Payloader{}
+	case "video/h264":
+		return &codecs.H264Payloader{}
+	}
+	return nil
+}
+
+/**
+ * Producer the frames are sent to.
+ */
+func (s *MediaSource) Producer() *mediasoup.Producer {
+	return s.producer
+}
+
+/**
+ * Whether the MediaSource is closed.
+ */
+func (s *MediaSource) Closed() bool {
+	return atomic.LoadUint32(&s.closed) > 0
+}
+
+/**
+ * Close stops sending. It is called when the Producer is closed.
+ */
+func (s *MediaSource) Close() {
+	if !atomic.CompareAndSwapUint32(&s.closed, 0, 1) {
+		return
+	}
+
+	s.logger.Debug("close()")
+
+	close(s.closeCh)
+
+	s.SafeEmit("close")
+}
+
+/**
+ * WriteFrame sends a frame, the timestamp of the next frame is increased by
+ * duration.
+ */
+func (s *MediaSource) WriteFrame(frame []byte, duration time.Duration) error {
+	if s.Closed() {
+		return mediasoup.NewInvalidStateError("MediaSource closed")
+	}
+
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	packets := s.packetizer.Packetize(frame, s.samples(duration))
+
+	for _, packet := range packets {
+		if err := s.producer.SendPacket(packet); err != nil {
+			return err
+		}
+		s.packetCount++
+		s.octetCount += uint32(len(packet.Payload))
+	}
+
+	if len(packets) > 0 {
+		s.lastTimestamp = packets[0].Timestamp
+		s.lastSentAt = time.Now()
+	}
+
+	return nil
+}
+
+/**
+ * Skip increases the timestamp of the next frame by duration, as if frames
+ * of that duration were sent.
+ */
+func (s *MediaSource) Skip(duration time.Duration) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	s.packetizer.SkipSamples(s.samples(duration))
+}
+
+// samples converts a duration to the nearest number of samples, the
+// difference is kept for the next call so that timestamps don't drift.
+// s.locker must be held.
+func (s *MediaSource) samples(duration time.Duration) uint32 {
+	total := int64(duration)*int64(s.clockRate) + s.remainder
+	samples := (total + int64(time.Second)/2) / int64(time.Second)
+
+	s.remainder = total - samples*int64(time.Second)
+
+	return uint32(samples)
+}
+
+/**
+ * SendSenderReport sends a RTCP Sender Report through the transport. Nothing
+ * is sent before the first frame.
+ */
+func (s *MediaSource) SendSenderReport() error {
+	s.locker.Lock()
+
+	if s.lastSentAt.IsZero() {
+		s.locker.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	elapsed := int64(now.Sub(s.lastSentAt)) * int64(s.clockRate) / int64(time.Second)
+
+	report := &rtcp.SenderReport{
+		SSRC:        s.ssrc,
+		NTPTime:     ntpTime(now),
+		RTPTime:     s.lastTimestamp + uint32(elapsed),
+		PacketCount: s.packetCount,
+		OctetCount:  s.octetCount,
+	}
+
+	s.locker.Unlock()
+
+	return s.transport.SendRtcpPackets(report)
+}
+
+func (s *MediaSource) senderReportLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.SendSenderReport(); err != nil {
+				s.logger.Warn("sending Sender Report failed: %s", err)
+			}
+		case <-s.closeCh:
+			return
+		}
+	}
+}
+
+// ntpTime converts a time to the 64 bits NTP format.
+func ntpTime(t time.Time) uint64 {
+	const ntpEpochOffset = 2208988800
+
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+
+	return seconds<<32 | fraction
+}
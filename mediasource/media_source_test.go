@@ -0,0 +1,232 @@
+package mediasource
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/h264"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSource struct {
+	*MediaSource
+	process  *workertest.Process
+	consumer *mediasoup.Consumer
+	packets  chan *rtp.Packet
+}
+
+func newTestSource(t *testing.T, producerOptions mediasoup.ProducerOptions, options MediaSourceOptions) *testSource {
+	mediaCodecs := append(workertest.MediaCodecs(), &mediasoup.RtpCodecCapability{
+		Kind:      "video",
+		MimeType:  "video/H264",
+		ClockRate: 90000,
+		Parameters: mediasoup.RtpCodecSpecificParameters{
+			RtpParameter: h264.RtpParameter{PacketizationMode: 1, ProfileLevelId: "42e01f"},
+		},
+	})
+
+	router, process := workertest.NewRouter(t, mediaCodecs...)
+
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+
+	producer, err := transport.Produce(producerOptions)
+	require.NoError(t, err)
+
+	consumer, err := transport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      producer.Id(),
+		RtpCapabilities: router.RtpCapabilities(),
+	})
+	require.NoError(t, err)
+
+	source, err := NewMediaSource(transport, producer, options)
+	require.NoError(t, err)
+
+	packets := make(chan *rtp.Packet, 100)
+	consumer.OnRtpPacket(func(packet *rtp.Packet) { packets <- packet })
+
+	return &testSource{
+		MediaSource: source,
+		process:     process,
+		consumer:    consumer,
+		packets:     packets,
+	}
+}
+
+func (s *testSource) receive(t *testing.T, count int) (packets []*rtp.Packet) {
+	for i := 0; i < count; i++ {
+		select {
+		case packet := <-s.packets:
+			packets = append(packets, packet)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d packets, expected %d", i, count)
+		}
+	}
+	return
+}
+
+func TestMediaSource_Opus(t *testing.T) {
+	s := newTestSource(t, workertest.AudioProducerOptions(), MediaSourceOptions{})
+	assert.NotNil(t, s.Producer())
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.WriteFrame([]byte{0xfc, byte(i)}, 20*time.Millisecond))
+	}
+	packets := s.receive(t, 3)
+
+	// The worker forwards the packets with the payload type and ssrc of the
+	// consumer.
+	for i, packet := range packets {
+		assert.EqualValues(t, s.consumer.RtpParameters().Codecs[0].PayloadType, packet.PayloadType)
+		assert.EqualValues(t, s.consumer.RtpParameters().Encodings[0].Ssrc, packet.SSRC)
+		assert.True(t, packet.Marker)
+		assert.Equal(t, []byte{0xfc, byte(i)}, packet.Payload)
+		assert.Equal(t, packets[0].SequenceNumber+uint16(i), packet.SequenceNumber)
+		assert.Equal(t, packets[0].Timestamp+uint32(i*960), packet.Timestamp)
+	}
+}
+
+func TestMediaSource_TimestampsDontDrift(t *testing.T) {
+	s := newTestSource(t, workertest.VideoProducerOptions(), MediaSourceOptions{})
+
+	// 30 fps, 3000 samples per frame.
+	frameDuration := time.Second / 30
+
+	for i := 0; i < 31; i++ {
+		require.NoError(t, s.WriteFrame(bytes.Repeat([]byte{0x01}, 10), frameDuration))
+	}
+	s.Skip(time.Second)
+	require.NoError(t, s.WriteFrame(bytes.Repeat([]byte{0x01}, 10), frameDuration))
+
+	packets := s.receive(t, 32)
+
+	assert.Equal(t, packets[0].Timestamp+90000, packets[30].Timestamp)
+	assert.Equal(t, packets[0].Timestamp+90000+3000+90000, packets[31].Timestamp)
+}
+
+func TestMediaSource_Vp8Mtu(t *testing.T) {
+	s := newTestSource(t, workertest.VideoProducerOptions(), MediaSourceOptions{Mtu: 200})
+
+	frame := make([]byte, 1000)
+	for i := range frame {
+		frame[i] = byte(i)
+	}
+	require.NoError(t, s.WriteFrame(frame, time.Second/30))
+
+	packets := s.receive(t, 6)
+	depacketizer := &codecs.VP8Packet{}
+	data := []byte{}
+
+	for i, packet := range packets {
+		assert.True(t, packet.MarshalSize() <= 200)
+		assert.Equal(t, i == len(packets)-1, packet.Marker)
+		assert.Equal(t, packets[0].Timestamp, packet.Timestamp)
+
+		payload, err := depacketizer.Unmarshal(packet.Payload)
+		require.NoError(t, err)
+		assert.Equal(t, i == 0, depacketizer.S == 1)
+
+		data = append(data, payload...)
+	}
+	assert.Equal(t, frame, data)
+}
+
+func TestMediaSource_H264(t *testing.T) {
+	options := workertest.VideoProducerOptions()
+	options.RtpParameters.Codecs = []*mediasoup.RtpCodecParameters{
+		{
+			MimeType:    "video/H264",
+			PayloadType: 125,
+			ClockRate:   90000,
+			Parameters: mediasoup.RtpCodecSpecificParameters{
+				RtpParameter: h264.RtpParameter{PacketizationMode: 1, ProfileLevelId: "42e01f"},
+			},
+		},
+	}
+	s := newTestSource(t, options, MediaSourceOptions{Mtu: 100})
+
+	idr := append([]byte{0x65}, bytes.Repeat([]byte{0xab}, 150)...)
+	accessUnit := []byte{0, 0, 0, 1, 0x67, 0x42, 0xe0, 0x1f, 0, 0, 0, 1, 0x68, 0xce, 0x38, 0, 0, 0, 1}
+	accessUnit = append(accessUnit, idr...)
+
+	require.NoError(t, s.WriteFrame(accessUnit, time.Second/30))
+
+	// STAP-A with SPS and PPS, then the IDR slice in two FU-A.
+	packets := s.receive(t, 3)
+
+	assert.Equal(t, byte(24), packets[0].Payload[0]&0x1f)
+	assert.Equal(t, byte(28), packets[1].Payload[0]&0x1f)
+	assert.True(t, packets[2].Marker)
+
+	depacketizer := &codecs.H264Packet{}
+	data := []byte{}
+
+	for _, packet := range packets {
+		payload, err := depacketizer.Unmarshal(packet.Payload)
+		require.NoError(t, err)
+		data = append(data, payload...)
+	}
+	assert.Equal(t, accessUnit, data)
+}
+
+func TestMediaSource_SenderReport(t *testing.T) {
+	s := newTestSource(t, workertest.AudioProducerOptions(), MediaSourceOptions{
+		SenderReportInterval: 10 * time.Millisecond,
+	})
+
+	reports := make(chan *rtcp.SenderReport, 10)
+
+	s.process.SetHandler("transport.sendRtcp", func(req workertest.Request) (interface{}, error) {
+		packets, err := rtcp.Unmarshal(req.Payload)
+		if err == nil {
+			reports <- packets[0].(*rtcp.SenderReport)
+		}
+		return nil, nil
+	})
+
+	require.NoError(t, s.WriteFrame([]byte{0xfc, 0x01, 0x02}, 20*time.Millisecond))
+	require.NoError(t, s.WriteFrame([]byte{0xfc, 0x03}, 20*time.Millisecond))
+	packets := s.receive(t, 2)
+
+	select {
+	case report := <-reports:
+		assert.EqualValues(t, 11111111, report.SSRC)
+		assert.EqualValues(t, 2, report.PacketCount)
+		assert.EqualValues(t, 5, report.OctetCount)
+		assert.True(t, report.RTPTime-packets[1].Timestamp < 48000)
+		assert.InDelta(t, time.Now().Unix()+2208988800, int64(report.NTPTime>>32), 1)
+	case <-time.After(time.Second):
+		t.Fatal("no Sender Report")
+	}
+
+	s.Producer().Close()
+
+	assert.Eventually(t, s.Closed, time.Second, 5*time.Millisecond)
+	assert.Error(t, s.WriteFrame([]byte{0xfc}, 20*time.Millisecond))
+}
+
+func TestNewMediaSource_TypeError(t *testing.T) {
+	options := workertest.AudioProducerOptions()
+	options.RtpParameters.Codecs[0].MimeType = "audio/PCMU"
+	options.RtpParameters.Codecs[0].ClockRate = 8000
+	options.RtpParameters.Codecs[0].Channels = 0
+	options.RtpParameters.Codecs[0].PayloadType = 0
+
+	router, _ := workertest.NewRouter(t, &mediasoup.RtpCodecCapability{Kind: "audio", MimeType: "audio/PCMU", ClockRate: 8000})
+
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+
+	producer, err := transport.Produce(options)
+	require.NoError(t, err)
+
+	_, err = NewMediaSource(transport, producer, MediaSourceOptions{})
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+}
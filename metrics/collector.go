@@ -0,0 +1,385 @@
+// Package metrics provides a prometheus.Collector exporting the statistics of
+// mediasoup workers and of the routers, transports, producers and consumers
+// living in them.
+//
+// Statistics are requested to the workers on every scrape, so the collector
+// should be registered once and scraped at a reasonable interval.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type CollectorOptions struct {
+	/**
+	 * Namespace of the metric names. Default "mediasoup".
+	 */
+	Namespace string
+
+	/**
+	 * AppData keys exported as labels of the transport, producer, consumer and
+	 * data consumer metrics. Only AppData of type map (such as mediasoup.H)
+	 * is read, a missing key is exported as an empty label.
+	 */
+	AppDataLabels []string
+
+	/**
+	 * Maximum number of requests sent to the workers at the same time during
+	 * a scrape. Default 8.
+	 */
+	MaxConcurrency int
+
+	/**
+	 * Timeout of a scrape. Objects whose stats are not received in time are
+	 * left out. Default 5s.
+	 */
+	Timeout time.Duration
+}
+
+/**
+ * Collector walks the live objects of the added workers and worker pools on
+ * every scrape.
+ */
+type Collector struct {
+	logger  mediasoup.Logger
+	options CollectorOptions
+	descs   descs
+	workers []*mediasoup.Worker
+	pools   []*mediasoup.WorkerPool
+	locker  sync.Mutex
+}
+
+func NewCollector(options CollectorOptions) *Collector {
+	if len(options.Namespace) == 0 {
+		options.Namespace = "mediasoup"
+	}
+	if options.MaxConcurrency <= 0 {
+		options.MaxConcurrency = 8
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 5 * time.Second
+	}
+
+	return &Collector{
+		logger:  mediasoup.NewLogger("MetricsCollector"),
+		options: options,
+		descs:   newDescs(options.Namespace, options.AppDataLabels),
+	}
+}
+
+/**
+ * AddWorker adds a worker to collect. It is forgotten once closed.
+ */
+func (c *Collector) AddWorker(worker *mediasoup.Worker) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	c.workers = append(c.workers, worker)
+}
+
+/**
+ * AddWorkerPool adds the workers of a pool to collect, including the ones
+ * added to the pool later.
+ */
+func (c *Collector) AddWorkerPool(pool *mediasoup.WorkerPool) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	c.pools = append(c.pools, pool)
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.descs.describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.options.Timeout)
+	defer cancel()
+
+	s := &scrape{
+		collector: c,
+		ctx:       ctx,
+		ch:        ch,
+		sem:       make(chan struct{}, c.options.MaxConcurrency),
+	}
+
+	for _, worker := range c.liveWorkers() {
+		s.collectWorker(worker)
+	}
+
+	s.wg.Wait()
+
+	ch <- prometheus.MustNewConstMetric(c.descs.scrapeErrors, prometheus.GaugeValue, float64(s.errors))
+}
+
+// liveWorkers returns the alive workers and forgets the closed ones.
+func (c *Collector) liveWorkers() (workers []*mediasoup.Worker) {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+
+	seen := map[*mediasoup.Worker]bool{}
+	alive := c.workers[:0]
+
+	for _, worker := range c.workers {
+		if !worker.Closed() {
+			alive = append(alive, worker)
+		}
+	}
+	c.workers = alive
+
+	alivePools := c.pools[:0]
+
+	for _, pool := range c.pools {
+		if !pool.Closed() {
+			alivePools = append(alivePools, pool)
+		}
+	}
+	c.pools = alivePools
+
+	candidates := append([]*mediasoup.Worker{}, c.workers...)
+
+	for _, pool := range c.pools {
+		candidates = append(candidates, pool.Workers()...)
+	}
+
+	for _, worker := range candidates {
+		if !worker.Closed() && !seen[worker] {
+			seen[worker] = true
+			workers = append(workers, worker)
+		}
+	}
+
+	return
+}
+
+// scrape is a single run of Collect.
+type scrape struct {
+	collector *Collector
+	ctx       context.Context
+	ch        chan<- prometheus.Metric
+	sem       chan struct{}
+	wg        sync.WaitGroup
+	errors    int
+	locker    sync.Mutex
+}
+
+// run calls fn in a goroutine once a slot is free, unless the scrape timed
+// out.
+func (s *scrape) run(fn func(ctx context.Context) error) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		select {
+		case s.sem <- struct{}{}:
+		case <-s.ctx.Done():
+			s.fail(s.ctx.Err())
+			return
+		}
+		defer func() { <-s.sem }()
+
+		if err := fn(s.ctx); err != nil {
+			s.fail(err)
+		}
+	}()
+}
+
+func (s *scrape) fail(err error) {
+	s.collector.logger.Warn("scrape failed: %s", err)
+
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	s.errors++
+}
+
+func (s *scrape) gauge(desc *prometheus.Desc, value float64, labels ...string) {
+	s.ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labels...)
+}
+
+func (s *scrape) counter(desc *prometheus.Desc, value float64, labels ...string) {
+	s.ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, labels...)
+}
+
+func (s *scrape) collectWorker(worker *mediasoup.Worker) {
+	descs := s.collector.descs
+	pid := strconv.Itoa(worker.Pid())
+
+	s.run(func(ctx context.Context) error {
+		usage, err := worker.GetResourceUsageContext(ctx)
+		if err != nil {
+			return err
+		}
+		s.counter(descs.workerCpuUser, float64(usage.RU_Utime)/1000, pid)
+		s.counter(descs.workerCpuSystem, float64(usage.RU_Stime)/1000, pid)
+
+		return nil
+	})
+
+	routers := worker.Routers()
+
+	s.gauge(descs.workerRouters, float64(len(routers)), pid)
+
+	for _, router := range routers {
+		for _, transport := range router.Transports() {
+			s.collectTransport(router, transport)
+		}
+	}
+}
+
+func (s *scrape) collectTransport(router *mediasoup.Router, transport mediasoup.ITransport) {
+	descs := s.collector.descs
+	labels := append([]string{router.Id(), transport.Id()}, s.appDataLabels(transport.AppData())...)
+
+	s.run(func(ctx context.Context) error {
+		stats, err := transport.GetStatsContext(ctx)
+		if err != nil {
+			return err
+		}
+		for _, stat := range stats {
+			s.counter(descs.transportBytesReceived, float64(stat.BytesReceived), labels...)
+			s.counter(descs.transportBytesSent, float64(stat.BytesSent), labels...)
+			s.gauge(descs.transportRecvBitrate, float64(stat.RecvBitrate), labels...)
+			s.gauge(descs.transportSendBitrate, float64(stat.SendBitrate), labels...)
+		}
+
+		return nil
+	})
+
+	for _, producer := range transport.Producers() {
+		s.collectProducer(router, transport, producer)
+	}
+	for _, consumer := range transport.Consumers() {
+		s.collectConsumer(router, transport, consumer)
+	}
+	for _, dataConsumer := range transport.DataConsumers() {
+		s.collectDataConsumer(router, transport, dataConsumer)
+	}
+}
+
+func (s *scrape) collectProducer(router *mediasoup.Router, transport mediasoup.ITransport, producer *mediasoup.Producer) {
+	descs := s.collector.descs
+	labels := append([]string{router.Id(), transport.Id(), producer.Id(), string(producer.Kind())},
+		s.appDataLabels(producer.AppData())...)
+
+	for _, score := range producer.Score() {
+		s.gauge(descs.producerScore, float64(score.Score), append(labels, fmt.Sprint(score.Ssrc))...)
+	}
+
+	s.run(func(ctx context.Context) error {
+		stats, err := producer.GetStatsContext(ctx)
+		if err != nil {
+			return err
+		}
+		s.collectRtpStreams(descs.producer, stats, labels)
+
+		return nil
+	})
+}
+
+func (s *scrape) collectConsumer(router *mediasoup.Router, transport mediasoup.ITransport, consumer *mediasoup.Consumer) {
+	descs := s.collector.descs
+	labels := append([]string{router.Id(), transport.Id(), consumer.Id(), consumer.ProducerId(), string(consumer.Kind())},
+		s.appDataLabels(consumer.AppData())...)
+
+	score := consumer.Score()
+
+	s.gauge(descs.consumerScore, float64(score.Score), labels...)
+	s.gauge(descs.consumerProducerScore, float64(score.ProducerScore), labels...)
+
+	s.run(func(ctx context.Context) error {
+		stats, err := consumer.GetStatsContext(ctx)
+		if err != nil {
+			return err
+		}
+		// Stats of the producer stream are given too.
+		outbound := []*mediasoup.ConsumerStat{}
+
+		for _, stat := range stats {
+			if stat.Type == "outbound-rtp" {
+				outbound = append(outbound, stat)
+			}
+		}
+		s.collectRtpStreams(descs.consumer, outbound, labels)
+
+		return nil
+	})
+}
+
+// collectRtpStreams exports the sums of the stats of the RTP streams of a
+// producer or consumer.
+func (s *scrape) collectRtpStreams(descs rtpStreamDescs, stats []*mediasoup.ProducerStat, labels []string) {
+	var total mediasoup.ProducerStat
+
+	for _, stat := range stats {
+		total.ByteCount += stat.ByteCount
+		total.PacketCount += stat.PacketCount
+		total.PacketsLost += stat.PacketsLost
+		total.NackCount += stat.NackCount
+		total.PliCount += stat.PliCount
+		total.FirCount += stat.FirCount
+		total.Bitrate += stat.Bitrate
+	}
+
+	s.counter(descs.bytes, float64(total.ByteCount), labels...)
+	s.counter(descs.packets, float64(total.PacketCount), labels...)
+	s.counter(descs.packetsLost, float64(total.PacketsLost), labels...)
+	s.counter(descs.nacks, float64(total.NackCount), labels...)
+	s.counter(descs.plis, float64(total.PliCount), labels...)
+	s.counter(descs.firs, float64(total.FirCount), labels...)
+	s.gauge(descs.bitrate, float64(total.Bitrate), labels...)
+}
+
+func (s *scrape) collectDataConsumer(router *mediasoup.Router, transport mediasoup.ITransport, dataConsumer *mediasoup.DataConsumer) {
+	descs := s.collector.descs
+	labels := append([]string{router.Id(), transport.Id(), dataConsumer.Id()},
+		s.appDataLabels(dataConsumer.AppData())...)
+
+	s.run(func(ctx context.Context) error {
+		bufferedAmount, err := dataConsumer.GetBufferedAmountContext(ctx)
+		if err != nil {
+			return err
+		}
+		s.gauge(descs.dataConsumerBufferedAmount, float64(bufferedAmount), labels...)
+
+		return nil
+	})
+}
+
+// appDataLabels returns the values of the selected AppData keys.
+func (s *scrape) appDataLabels(appData interface{}) []string {
+	keys := s.collector.options.AppDataLabels
+	values := make([]string, len(keys))
+
+	var data map[string]interface{}
+
+	switch v := appData.(type) {
+	case mediasoup.H:
+		data = v
+	case map[string]interface{}:
+		data = v
+	case map[string]string:
+		for i, key := range keys {
+			values[i] = v[key]
+		}
+		return values
+	}
+
+	for i, key := range keys {
+		if value, ok := data[key]; ok && value != nil {
+			values[i] = fmt.Sprint(value)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gather(t *testing.T, collector *Collector) map[string][]*dto.Metric {
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(collector))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	metrics := map[string][]*dto.Metric{}
+
+	for _, family := range families {
+		metrics[family.GetName()] = family.GetMetric()
+	}
+
+	return metrics
+}
+
+func labelsOf(metric *dto.Metric) map[string]string {
+	labels := map[string]string{}
+
+	for _, pair := range metric.GetLabel() {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+
+	return labels
+}
+
+func valueOf(metric *dto.Metric) float64 {
+	if metric.Gauge != nil {
+		return metric.Gauge.GetValue()
+	}
+	return metric.Counter.GetValue()
+}
+
+func TestCollector(t *testing.T) {
+	worker, err := workertest.NewWorker()
+	require.NoError(t, err)
+	defer worker.Close()
+
+	process := workertest.ProcessOf(worker)
+	process.SetResourceUsage(mediasoup.WorkerResourceUsage{RU_Utime: 1500, RU_Stime: 500})
+
+	router, err := worker.CreateRouter(mediasoup.RouterOptions{MediaCodecs: workertest.MediaCodecs()})
+	require.NoError(t, err)
+
+	transport, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+		AppData:   mediasoup.H{"room": "lobby", "peer": "alice"},
+	})
+	require.NoError(t, err)
+
+	producerOptions := workertest.VideoProducerOptions()
+	producerOptions.AppData = mediasoup.H{"room": "lobby"}
+	producer, err := transport.Produce(producerOptions)
+	require.NoError(t, err)
+
+	consumer, err := transport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      producer.Id(),
+		RtpCapabilities: workertest.DeviceRtpCapabilities(),
+	})
+	require.NoError(t, err)
+
+	process.SetStats(transport.Id(), []mediasoup.H{{
+		"type":          "webrtc-transport",
+		"transportId":   transport.Id(),
+		"bytesReceived": 1000,
+		"bytesSent":     2000,
+		"recvBitrate":   300,
+	}})
+	process.SetStats(producer.Id(), []mediasoup.H{
+		{"type": "inbound-rtp", "ssrc": 1, "packetsLost": 3, "nackCount": 4, "pliCount": 1, "byteCount": 100},
+		{"type": "inbound-rtp", "ssrc": 2, "packetsLost": 2, "nackCount": 1, "pliCount": 1, "byteCount": 50},
+	})
+	process.SetStats(consumer.Id(), []mediasoup.H{
+		{"type": "outbound-rtp", "ssrc": 3, "packetsLost": 1, "pliCount": 5},
+		{"type": "inbound-rtp", "ssrc": 1, "packetsLost": 3, "pliCount": 1},
+	})
+	require.NoError(t, process.Notify(producer.Id(), "score", []mediasoup.H{{"ssrc": 1, "score": 9}}))
+	require.NoError(t, process.Notify(consumer.Id(), "score", mediasoup.H{"score": 7, "producerScore": 9}))
+
+	assert.Eventually(t, func() bool {
+		return consumer.Score().Score == 7 && len(producer.Score()) == 1
+	}, time.Second, 5*time.Millisecond)
+
+	collector := NewCollector(CollectorOptions{AppDataLabels: []string{"room", "peer"}})
+	collector.AddWorker(worker)
+
+	metrics := gather(t, collector)
+
+	require.Len(t, metrics["mediasoup_worker_cpu_user_seconds_total"], 1)
+	assert.Equal(t, 1.5, valueOf(metrics["mediasoup_worker_cpu_user_seconds_total"][0]))
+	assert.Equal(t, 0.5, valueOf(metrics["mediasoup_worker_cpu_system_seconds_total"][0]))
+	assert.Equal(t, 1.0, valueOf(metrics["mediasoup_worker_routers"][0]))
+
+	require.Len(t, metrics["mediasoup_transport_bytes_received_total"], 1)
+	transportMetric := metrics["mediasoup_transport_bytes_received_total"][0]
+	assert.Equal(t, 1000.0, valueOf(transportMetric))
+	assert.Equal(t, map[string]string{
+		"router_id":    router.Id(),
+		"transport_id": transport.Id(),
+		"room":         "lobby",
+		"peer":         "alice",
+	}, labelsOf(transportMetric))
+	assert.Equal(t, 2000.0, valueOf(metrics["mediasoup_transport_bytes_sent_total"][0]))
+
+	require.Len(t, metrics["mediasoup_producer_packets_lost_total"], 1)
+	producerMetric := metrics["mediasoup_producer_packets_lost_total"][0]
+	assert.Equal(t, 5.0, valueOf(producerMetric))
+	assert.Equal(t, producer.Id(), labelsOf(producerMetric)["producer_id"])
+	assert.Equal(t, "lobby", labelsOf(producerMetric)["room"])
+	assert.Equal(t, "", labelsOf(producerMetric)["peer"])
+	assert.Equal(t, 5.0, valueOf(metrics["mediasoup_producer_nacks_total"][0]))
+	assert.Equal(t, 2.0, valueOf(metrics["mediasoup_producer_plis_total"][0]))
+	assert.Equal(t, 150.0, valueOf(metrics["mediasoup_producer_bytes_total"][0]))
+
+	require.Len(t, metrics["mediasoup_producer_score"], 1)
+	assert.Equal(t, 9.0, valueOf(metrics["mediasoup_producer_score"][0]))
+	assert.Equal(t, "1", labelsOf(metrics["mediasoup_producer_score"][0])["ssrc"])
+
+	assert.Equal(t, 1.0, valueOf(metrics["mediasoup_consumer_packets_lost_total"][0]))
+	assert.Equal(t, 5.0, valueOf(metrics["mediasoup_consumer_plis_total"][0]))
+	assert.Equal(t, 7.0, valueOf(metrics["mediasoup_consumer_score"][0]))
+	assert.Equal(t, 9.0, valueOf(metrics["mediasoup_consumer_producer_score"][0]))
+	assert.Equal(t, producer.Id(), labelsOf(metrics["mediasoup_consumer_score"][0])["producer_id"])
+
+	assert.Equal(t, 0.0, valueOf(metrics["mediasoup_scrape_errors"][0]))
+
+	// Closed objects are left out.
+	router.Close()
+
+	metrics = gather(t, collector)
+
+	assert.Equal(t, 0.0, valueOf(metrics["mediasoup_worker_routers"][0]))
+	assert.Empty(t, metrics["mediasoup_transport_bytes_received_total"])
+}
+
+func TestCollector_DataConsumer(t *testing.T) {
+	worker, err := workertest.NewWorker()
+	require.NoError(t, err)
+	defer worker.Close()
+
+	router, err := worker.CreateRouter(mediasoup.RouterOptions{MediaCodecs: workertest.MediaCodecs()})
+	require.NoError(t, err)
+
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+
+	dataProducer, err := transport.ProduceData(mediasoup.DataProducerOptions{})
+	require.NoError(t, err)
+
+	dataConsumer, err := transport.ConsumeData(mediasoup.DataConsumerOptions{DataProducerId: dataProducer.Id()})
+	require.NoError(t, err)
+
+	collector := NewCollector(CollectorOptions{Namespace: "sfu"})
+	collector.AddWorker(worker)
+
+	metrics := gather(t, collector)
+
+	require.Len(t, metrics["sfu_data_consumer_buffered_amount_bytes"], 1)
+	assert.Equal(t, dataConsumer.Id(), labelsOf(metrics["sfu_data_consumer_buffered_amount_bytes"][0])["data_consumer_id"])
+}
+
+func TestCollector_WorkerPool(t *testing.T) {
+	pool, err := mediasoup.NewWorkerPool(mediasoup.WorkerPoolOptions{
+		NumWorkers:    2,
+		WorkerOptions: []mediasoup.Option{mediasoup.WithSpawner(workertest.Spawn)},
+	})
+	require.NoError(t, err)
+	defer pool.Close()
+
+	collector := NewCollector(CollectorOptions{MaxConcurrency: 1})
+	collector.AddWorkerPool(pool)
+
+	metrics := gather(t, collector)
+	assert.Len(t, metrics["mediasoup_worker_cpu_user_seconds_total"], 2)
+
+	worker, err := pool.AddWorker()
+	require.NoError(t, err)
+
+	metrics = gather(t, collector)
+	assert.Len(t, metrics["mediasoup_worker_routers"], 3)
+
+	worker.Close()
+
+	metrics = gather(t, collector)
+	assert.Len(t, metrics["mediasoup_worker_routers"], 2)
+}
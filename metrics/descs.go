@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// rtpStreamDescs describes the summed stats of the RTP streams of a producer
+// or consumer.
+type rtpStreamDescs struct {
+	bytes       *prometheus.Desc
+	packets     *prometheus.Desc
+	packetsLost *prometheus.Desc
+	nacks       *prometheus.Desc
+	plis        *prometheus.Desc
+	firs        *prometheus.Desc
+	bitrate     *prometheus.Desc
+}
+
+type descs struct {
+	scrapeErrors *prometheus.Desc
+
+	workerCpuUser   *prometheus.Desc
+	workerCpuSystem *prometheus.Desc
+	workerRouters   *prometheus.Desc
+
+	transportBytesReceived *prometheus.Desc
+	transportBytesSent     *prometheus.Desc
+	transportRecvBitrate   *prometheus.Desc
+	transportSendBitrate   *prometheus.Desc
+
+	producer      rtpStreamDescs
+	producerScore *prometheus.Desc
+
+	consumer              rtpStreamDescs
+	consumerScore         *prometheus.Desc
+	consumerProducerScore *prometheus.Desc
+
+	dataConsumerBufferedAmount *prometheus.Desc
+}
+
+func newDescs(namespace string, appDataLabels []string) descs {
+	desc := func(subsystem, name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help, labels, nil)
+	}
+	withAppData := func(labels ...string) []string {
+		return append(labels, appDataLabels...)
+	}
+
+	transportLabels := withAppData("router_id", "transport_id")
+	producerLabels := withAppData("router_id", "transport_id", "producer_id", "kind")
+	consumerLabels := withAppData("router_id", "transport_id", "consumer_id", "producer_id", "kind")
+	dataConsumerLabels := withAppData("router_id", "transport_id", "data_consumer_id")
+
+	rtpStream := func(subsystem string, labels []string) rtpStreamDescs {
+		return rtpStreamDescs{
+			bytes:       desc(subsystem, "bytes_total", "Bytes of the RTP streams.", labels...),
+			packets:     desc(subsystem, "packets_total", "Packets of the RTP streams.", labels...),
+			packetsLost: desc(subsystem, "packets_lost_total", "Packets lost by the RTP streams.", labels...),
+			nacks:       desc(subsystem, "nacks_total", "NACK packets of the RTP streams.", labels...),
+			plis:        desc(subsystem, "plis_total", "PLI packets of the RTP streams.", labels...),
+			firs:        desc(subsystem, "firs_total", "FIR packets of the RTP streams.", labels...),
+			bitrate:     desc(subsystem, "bitrate_bps", "Bitrate of the RTP streams.", labels...),
+		}
+	}
+
+	return descs{
+		scrapeErrors: desc("", "scrape_errors", "Requests failed or timed out during the last scrape."),
+
+		workerCpuUser:   desc("worker", "cpu_user_seconds_total", "User CPU time used by the worker.", "pid"),
+		workerCpuSystem: desc("worker", "cpu_system_seconds_total", "System CPU time used by the worker.", "pid"),
+		workerRouters:   desc("worker", "routers", "Routers living in the worker.", "pid"),
+
+		transportBytesReceived: desc("transport", "bytes_received_total", "Bytes received by the transport.", transportLabels...),
+		transportBytesSent:     desc("transport", "bytes_sent_total", "Bytes sent by the transport.", transportLabels...),
+		transportRecvBitrate:   desc("transport", "recv_bitrate_bps", "Receiving bitrate of the transport.", transportLabels...),
+		transportSendBitrate:   desc("transport", "send_bitrate_bps", "Sending bitrate of the transport.", transportLabels...),
+
+		producer:      rtpStream("producer", producerLabels),
+		producerScore: desc("producer", "score", "Score of a producer RTP stream.", append(producerLabels, "ssrc")...),
+
+		consumer:              rtpStream("consumer", consumerLabels),
+		consumerScore:         desc("consumer", "score", "Score of the consumer RTP stream.", consumerLabels...),
+		consumerProducerScore: desc("consumer", "producer_score", "Score of the consumed producer RTP stream.", consumerLabels...),
+
+		dataConsumerBufferedAmount: desc("data_consumer", "buffered_amount_bytes", "Bytes buffered by the data consumer.", dataConsumerLabels...),
+	}
+}
+
+func (d descs) describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		d.scrapeErrors,
+		d.workerCpuUser, d.workerCpuSystem, d.workerRouters,
+		d.transportBytesReceived, d.transportBytesSent, d.transportRecvBitrate, d.transportSendBitrate,
+		d.producerScore, d.consumerScore, d.consumerProducerScore,
+		d.dataConsumerBufferedAmount,
+	} {
+		ch <- desc
+	}
+
+	for _, streams := range []rtpStreamDescs{d.producer, d.consumer} {
+		ch <- streams.bytes
+		ch <- streams.packets
+		ch <- streams.packetsLost
+		ch <- streams.nacks
+		ch <- streams.plis
+		ch <- streams.firs
+		ch <- streams.bitrate
+	}
+}
@@ -0,0 +1,677 @@
+// Package monitor polls the statistics of transports, producers, consumers,
+// data producers and data consumers, and publishes what changed between two
+// polls.
+//
+// GetStats returns absolute counters, which restart from zero when the worker
+// re-creates a stream (e.g. a new SSRC after the remote endpoint restarted).
+// The StatsMonitor computes per-interval deltas and handles these resets, so
+// that no negative or huge bogus delta is reported.
+package monitor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+/**
+ * Kind of a polled entity.
+ */
+type StatsKind string
+
+const (
+	StatsKind_Transport    StatsKind = "transport"
+	StatsKind_Producer               = "producer"
+	StatsKind_Consumer               = "consumer"
+	StatsKind_DataProducer           = "dataproducer"
+	StatsKind_DataConsumer           = "dataconsumer"
+)
+
+type StatsMonitorOptions struct {
+	/**
+	 * Interval between two polls of an entity. Default 1s.
+	 */
+	Interval time.Duration
+
+	/**
+	 * Maximum number of GetStats requests sent to the workers at the same
+	 * time. Default 8.
+	 */
+	MaxConcurrency int
+
+	/**
+	 * Timeout of a GetStats request. Default Interval.
+	 */
+	Timeout time.Duration
+
+	/**
+	 * Capacity of the Reports() channel. Reports are dropped while the
+	 * channel is full. Default 64.
+	 */
+	ReportsBuffer int
+
+	/**
+	 * OnReport is called with each report, in addition to the Reports()
+	 * channel. It may be called concurrently for different entities.
+	 * Optional.
+	 */
+	OnReport func(StatsReport)
+}
+
+/**
+ * StatsReport holds what changed in the statistics of an entity since its
+ * previous poll.
+ */
+type StatsReport struct {
+	Kind    StatsKind
+	Id      string
+	AppData interface{}
+
+	/**
+	 * Time of the poll.
+	 */
+	Time time.Time
+
+	/**
+	 * Time elapsed since the previous poll.
+	 */
+	Interval time.Duration
+
+	/**
+	 * Deltas of the streams of the entity. Transports, data producers and
+	 * data consumers have a single stream.
+	 */
+	Streams []StreamDelta
+}
+
+/**
+ * StreamDelta holds the counter deltas of a stream over an interval.
+ */
+type StreamDelta struct {
+	/**
+	 * Type of the stats entry, such as "inbound-rtp", "outbound-rtp",
+	 * "webrtc-transport" or "data-producer".
+	 */
+	Type string
+	Ssrc uint32
+	Rid  string
+
+	PacketsLost      int64
+	PacketCount      int64
+	NackCount        int64
+	NackPacketCount  int64
+	PliCount         int64
+	FirCount         int64
+	BytesReceived    int64
+	BytesSent        int64
+	MessagesReceived int64
+	MessagesSent     int64
+
+	/**
+	 * Average bitrates over the interval, in bits per second.
+	 */
+	RecvBitrate int64
+	SendBitrate int64
+
+	/**
+	 * Whether the counters of the stream went backwards since the previous
+	 * poll. The deltas are then counted from zero. A decrease of the packets
+	 * lost alone is no reset, their delta is zero then.
+	 */
+	Reset bool
+}
+
+/**
+ * Total sums the deltas of the streams. Bitrates are summed too, and Reset is
+ * set if any stream was reset.
+ */
+func (r StatsReport) Total() (total StreamDelta) {
+	for _, s := range r.Streams {
+		total.PacketsLost += s.PacketsLost
+		total.PacketCount += s.PacketCount
+		total.NackCount += s.NackCount
+		total.NackPacketCount += s.NackPacketCount
+		total.PliCount += s.PliCount
+		total.FirCount += s.FirCount
+		total.BytesReceived += s.BytesReceived
+		total.BytesSent += s.BytesSent
+		total.MessagesReceived += s.MessagesReceived
+		total.MessagesSent += s.MessagesSent
+		total.RecvBitrate += s.RecvBitrate
+		total.SendBitrate += s.SendBitrate
+		total.Reset = total.Reset || s.Reset
+	}
+	return
+}
+
+/**
+ * StatsMonitor polls the added entities at a fixed interval. An entity is
+ * removed once it is closed.
+ *
+ * The first poll of an entity only sets the baseline of its counters, the
+ * following ones publish a StatsReport.
+ *
+ * @emits report - (report: StatsReport)
+ * @emits close
+ */
+type StatsMonitor struct {
+	// Accessed atomically, first for the 64-bit alignment.
+	dropped uint64
+	mediasoup.IEventEmitter
+	logger   mediasoup.Logger
+	options  StatsMonitorOptions
+	entities map[string]*entity
+	sem      chan struct{}
+	reports  chan StatsReport
+	ctx      context.Context
+	cancel   context.CancelFunc
+	closed   uint32
+	wg       sync.WaitGroup
+	locker   sync.Mutex
+	// now returns the current time, replaced in tests.
+	now func() time.Time
+}
+
+// entity is a polled entity.
+type entity struct {
+	kind     StatsKind
+	id       string
+	appData  interface{}
+	closed   func() bool
+	getStats func(ctx context.Context) (map[streamKey]counters, error)
+	polling  bool
+	last     map[streamKey]counters
+	lastTime time.Time
+}
+
+// streamKey identifies a stream in the stats of an entity.
+type streamKey struct {
+	typ  string
+	ssrc uint32
+	rid  string
+}
+
+type counters struct {
+	packetsLost      int64
+	packetCount      int64
+	nackCount        int64
+	nackPacketCount  int64
+	pliCount         int64
+	firCount         int64
+	bytesReceived    int64
+	bytesSent        int64
+	messagesReceived int64
+	messagesSent     int64
+}
+
+func NewStatsMonitor(options StatsMonitorOptions) *StatsMonitor {
+	logger := mediasoup.NewLogger("StatsMonitor")
+
+	logger.Debug("constructor()")
+
+	if options.Interval <= 0 {
+		options.Interval = time.Second
+	}
+	if options.MaxConcurrency <= 0 {
+		options.MaxConcurrency = 8
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = options.Interval
+	}
+	if options.ReportsBuffer <= 0 {
+		options.ReportsBuffer = 64
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m := &StatsMonitor{
+		IEventEmitter: mediasoup.NewEventEmitter(),
+		logger:        logger,
+		options:       options,
+		entities:      make(map[string]*entity),
+		sem:           make(chan struct{}, options.MaxConcurrency),
+		reports:       make(chan StatsReport, options.ReportsBuffer),
+		ctx:           ctx,
+		cancel:        cancel,
+		now:           time.Now,
+	}
+
+	go m.pollLoop()
+
+	return m
+}
+
+/**
+ * Whether the StatsMonitor is closed.
+ */
+func (m *StatsMonitor) Closed() bool {
+	return atomic.LoadUint32(&m.closed) > 0
+}
+
+/**
+ * Close stops polling, cancels the pending requests and closes the Reports()
+ * channel.
+ */
+func (m *StatsMonitor) Close() {
+	if !atomic.CompareAndSwapUint32(&m.closed, 0, 1) {
+		return
+	}
+
+	m.logger.Debug("close()")
+
+	m.cancel()
+
+	m.locker.Lock()
+	m.entities = make(map[string]*entity)
+	m.locker.Unlock()
+
+	m.wg.Wait()
+	close(m.reports)
+
+	m.SafeEmit("close")
+}
+
+/**
+ * Reports returns the channel receiving the reports. It is closed with the
+ * StatsMonitor.
+ */
+func (m *StatsMonitor) Reports() <-chan StatsReport {
+	return m.reports
+}
+
+/**
+ * Dropped returns the number of reports dropped because the Reports()
+ * channel was full.
+ */
+func (m *StatsMonitor) Dropped() uint64 {
+	return atomic.LoadUint64(&m.dropped)
+}
+
+/**
+ * AddTransport polls the stats of a transport.
+ */
+func (m *StatsMonitor) AddTransport(transport mediasoup.ITransport) {
+	m.add(transport.Observer(), &entity{
+		kind:    StatsKind_Transport,
+		id:      transport.Id(),
+		appData: transport.AppData(),
+		closed:  transport.Closed,
+		getStats: func(ctx context.Context) (map[streamKey]counters, error) {
+			stats, err := transport.GetStatsContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			result := make(map[streamKey]counters, len(stats))
+			for _, stat := range stats {
+				result[streamKey{typ: stat.Type}] = counters{
+					bytesReceived: stat.BytesReceived,
+					bytesSent:     stat.BytesSent,
+				}
+			}
+			return result, nil
+		},
+	})
+}
+
+/**
+ * AddProducer polls the stats of a producer.
+ */
+func (m *StatsMonitor) AddProducer(producer *mediasoup.Producer) {
+	m.add(producer.Observer(), &entity{
+		kind:    StatsKind_Producer,
+		id:      producer.Id(),
+		appData: producer.AppData(),
+		closed:  producer.Closed,
+		getStats: func(ctx context.Context) (map[streamKey]counters, error) {
+			stats, err := producer.GetStatsContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return rtpCounters(stats), nil
+		},
+	})
+}
+
+/**
+ * AddConsumer polls the stats of a consumer, whose "inbound-rtp" stream is
+ * the one of its producer.
+ */
+func (m *StatsMonitor) AddConsumer(consumer *mediasoup.Consumer) {
+	m.add(consumer.Observer(), &entity{
+		kind:    StatsKind_Consumer,
+		id:      consumer.Id(),
+		appData: consumer.AppData(),
+		closed:  consumer.Closed,
+		getStats: func(ctx context.Context) (map[streamKey]counters, error) {
+			stats, err := consumer.GetStatsContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return rtpCounters(stats), nil
+		},
+	})
+}
+
+/**
+ * AddDataProducer polls the stats of a data producer.
+ */
+func (m *StatsMonitor) AddDataProducer(dataProducer *mediasoup.DataProducer) {
+	m.add(dataProducer.Observer(), &entity{
+		kind:    StatsKind_DataProducer,
+		id:      dataProducer.Id(),
+		appData: dataProducer.AppData(),
+		closed:  dataProducer.Closed,
+		getStats: func(ctx context.Context) (map[streamKey]counters, error) {
+			stats, err := dataProducer.GetStatsContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			result := make(map[streamKey]counters, len(stats))
+			for _, stat := range stats {
+				result[streamKey{typ: stat.Type}] = counters{
+					bytesReceived:    stat.BytesReceived,
+					messagesReceived: stat.MessagesReceived,
+				}
+			}
+			return result, nil
+		},
+	})
+}
+
+/**
+ * AddDataConsumer polls the stats of a data consumer.
+ */
+func (m *StatsMonitor) AddDataConsumer(dataConsumer *mediasoup.DataConsumer) {
+	m.add(dataConsumer.Observer(), &entity{
+		kind:    StatsKind_DataConsumer,
+		id:      dataConsumer.Id(),
+		appData: dataConsumer.AppData(),
+		closed:  dataConsumer.Closed,
+		getStats: func(ctx context.Context) (map[streamKey]counters, error) {
+			stats, err := dataConsumer.GetStatsContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			result := make(map[streamKey]counters, len(stats))
+			for _, stat := range stats {
+				result[streamKey{typ: stat.Type}] = counters{
+					bytesSent:    stat.BytesSent,
+					messagesSent: stat.MessagesSent,
+				}
+			}
+			return result, nil
+		},
+	})
+}
+
+/**
+ * Remove stops polling the entity with the given id and forgets its
+ * baseline.
+ */
+func (m *StatsMonitor) Remove(id string) {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+
+	if _, ok := m.entities[id]; ok {
+		m.logger.Debug("remove() [id:%s]", id)
+
+		delete(m.entities, id)
+	}
+}
+
+/**
+ * Ids returns the ids of the polled entities.
+ */
+func (m *StatsMonitor) Ids() []string {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+
+	ids := make([]string, 0, len(m.entities))
+
+	for id := range m.entities {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func (m *StatsMonitor) add(observer mediasoup.IEventEmitter, e *entity) {
+	if m.Closed() || e.closed() {
+		return
+	}
+
+	m.locker.Lock()
+	defer m.locker.Unlock()
+
+	if _, ok := m.entities[e.id]; ok {
+		return
+	}
+
+	m.logger.Debug("add() [kind:%s, id:%s]", e.kind, e.id)
+
+	m.entities[e.id] = e
+
+	observer.On("close", func() {
+		m.locker.Lock()
+		defer m.locker.Unlock()
+
+		// The entity may have been removed and added again.
+		if m.entities[e.id] == e {
+			m.logger.Debug("entity closed [kind:%s, id:%s]", e.kind, e.id)
+
+			delete(m.entities, e.id)
+		}
+	})
+}
+
+func (m *StatsMonitor) pollLoop() {
+	ticker := time.NewTicker(m.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.poll()
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+// poll requests the stats of the entities which are not already being
+// polled, at most MaxConcurrency at a time.
+func (m *StatsMonitor) poll() {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+
+	for _, e := range m.entities {
+		if e.polling {
+			continue
+		}
+		e.polling = true
+
+		m.wg.Add(1)
+
+		go func(e *entity) {
+			defer m.wg.Done()
+
+			select {
+			case m.sem <- struct{}{}:
+			case <-m.ctx.Done():
+				return
+			}
+			defer func() { <-m.sem }()
+
+			m.pollEntity(e)
+		}(e)
+	}
+}
+
+func (m *StatsMonitor) pollEntity(e *entity) {
+	ctx, cancel := context.WithTimeout(m.ctx, m.options.Timeout)
+	defer cancel()
+
+	stats, err := e.getStats(ctx)
+	now := m.now()
+
+	report, ok := m.update(e, stats, err, now)
+	if ok {
+		m.publish(report)
+	}
+}
+
+// update replaces the baseline of an entity by its new stats and returns the
+// report of the interval, if any.
+func (m *StatsMonitor) update(e *entity, stats map[streamKey]counters, err error, now time.Time) (report StatsReport, ok bool) {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+
+	e.polling = false
+
+	if m.entities[e.id] != e {
+		return
+	}
+	if err != nil {
+		if !e.closed() && !m.Closed() {
+			m.logger.Warn("getting stats failed [kind:%s, id:%s]: %s", e.kind, e.id, err)
+		}
+		return
+	}
+
+	last, lastTime := e.last, e.lastTime
+	e.last, e.lastTime = stats, now
+
+	if last == nil {
+		return
+	}
+
+	report = StatsReport{
+		Kind:     e.kind,
+		Id:       e.id,
+		AppData:  e.appData,
+		Time:     now,
+		Interval: now.Sub(lastTime),
+	}
+
+	for key, current := range stats {
+		delta := newStreamDelta(key, last[key], current, report.Interval)
+
+		// The packets lost keep their highest value as the baseline, so that
+		// they only count again once they exceed it.
+		if !delta.Reset && current.packetsLost < last[key].packetsLost {
+			current.packetsLost = last[key].packetsLost
+			stats[key] = current
+		}
+
+		report.Streams = append(report.Streams, delta)
+	}
+
+	sort.Slice(report.Streams, func(i, j int) bool {
+		a, b := report.Streams[i], report.Streams[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Ssrc != b.Ssrc {
+			return a.Ssrc < b.Ssrc
+		}
+		return a.Rid < b.Rid
+	})
+
+	return report, true
+}
+
+// publish emits the report and sends it to the Reports() channel unless the
+// channel is full. The channel is only closed once the polls are done.
+func (m *StatsMonitor) publish(report StatsReport) {
+	if m.options.OnReport != nil {
+		m.options.OnReport(report)
+	}
+
+	m.SafeEmit("report", report)
+
+	select {
+	case m.reports <- report:
+	default:
+		atomic.AddUint64(&m.dropped, 1)
+		m.logger.Debug("reports channel full, report dropped [kind:%s, id:%s]", report.Kind, report.Id)
+	}
+}
+
+// newStreamDelta computes the deltas of a stream. A stream which is new
+// since the previous poll is counted from zero, as is a stream whose counters
+// went backwards. The packets lost are not a counter: late and duplicated
+// packets make them decrease, which is not a reset but clamps their delta to
+// zero.
+func newStreamDelta(key streamKey, last, current counters, interval time.Duration) StreamDelta {
+	reset := current.packetCount < last.packetCount ||
+		current.nackCount < last.nackCount ||
+		current.nackPacketCount < last.nackPacketCount ||
+		current.pliCount < last.pliCount ||
+		current.firCount < last.firCount ||
+		current.bytesReceived < last.bytesReceived ||
+		current.bytesSent < last.bytesSent ||
+		current.messagesReceived < last.messagesReceived ||
+		current.messagesSent < last.messagesSent
+
+	if reset {
+		last = counters{}
+	}
+
+	delta := StreamDelta{
+		Type:             key.typ,
+		Ssrc:             key.ssrc,
+		Rid:              key.rid,
+		PacketsLost:      current.packetsLost - last.packetsLost,
+		PacketCount:      current.packetCount - last.packetCount,
+		NackCount:        current.nackCount - last.nackCount,
+		NackPacketCount:  current.nackPacketCount - last.nackPacketCount,
+		PliCount:         current.pliCount - last.pliCount,
+		FirCount:         current.firCount - last.firCount,
+		BytesReceived:    current.bytesReceived - last.bytesReceived,
+		BytesSent:        current.bytesSent - last.bytesSent,
+		MessagesReceived: current.messagesReceived - last.messagesReceived,
+		MessagesSent:     current.messagesSent - last.messagesSent,
+		Reset:            reset,
+	}
+
+	if delta.PacketsLost < 0 {
+		delta.PacketsLost = 0
+	}
+
+	if interval > 0 {
+		delta.RecvBitrate = delta.BytesReceived * 8 * int64(time.Second) / int64(interval)
+		delta.SendBitrate = delta.BytesSent * 8 * int64(time.Second) / int64(interval)
+	}
+
+	return delta
+}
+
+// rtpCounters reads the counters of the RTP streams of a producer or a
+// consumer. The bytes of "inbound-rtp" streams are received, the others are
+// sent.
+func rtpCounters(stats []*mediasoup.ProducerStat) map[streamKey]counters {
+	result := make(map[streamKey]counters, len(stats))
+
+	for _, stat := range stats {
+		c := counters{
+			packetsLost:     int64(stat.PacketsLost),
+			packetCount:     stat.PacketCount,
+			nackCount:       int64(stat.NackCount),
+			nackPacketCount: int64(stat.NackPacketCount),
+			pliCount:        int64(stat.PliCount),
+			firCount:        int64(stat.FirCount),
+		}
+		if stat.Type == "inbound-rtp" {
+			c.bytesReceived = stat.ByteCount
+		} else {
+			c.bytesSent = stat.ByteCount
+		}
+		result[streamKey{typ: stat.Type, ssrc: stat.Ssrc, rid: stat.Rid}] = c
+	}
+
+	return result
+}
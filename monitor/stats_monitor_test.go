@@ -0,0 +1,295 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rtpStat(typ string, ssrc uint32, packetsLost, nackCount, pliCount, byteCount int) mediasoup.H {
+	return mediasoup.H{
+		"type":        typ,
+		"ssrc":        ssrc,
+		"kind":        "video",
+		"packetsLost": packetsLost,
+		"nackCount":   nackCount,
+		"pliCount":    pliCount,
+		"firCount":    pliCount / 2,
+		"packetCount": byteCount / 1000,
+		"byteCount":   byteCount,
+	}
+}
+
+// sumReports reads the reports of the given entity until their total packets
+// lost reaches the given count, and returns the total without the bitrates.
+func sumReports(t *testing.T, monitor *StatsMonitor, id string, packetsLost int64) (total StreamDelta) {
+	timeout := time.After(time.Second)
+
+	for total.PacketsLost < packetsLost {
+		select {
+		case report := <-monitor.Reports():
+			if report.Id != id {
+				continue
+			}
+			assert.True(t, report.Interval > 0)
+			delta := report.Total()
+			delta.Reset = delta.Reset || total.Reset
+			delta.Type, delta.Ssrc = report.Streams[0].Type, report.Streams[0].Ssrc
+			delta.RecvBitrate, delta.SendBitrate = 0, 0
+			delta.PacketsLost += total.PacketsLost
+			delta.NackCount += total.NackCount
+			delta.PliCount += total.PliCount
+			delta.FirCount += total.FirCount
+			delta.PacketCount += total.PacketCount
+			delta.BytesReceived += total.BytesReceived
+			delta.BytesSent += total.BytesSent
+			total = delta
+		case <-timeout:
+			t.Fatalf("reports not received, total: %+v", total)
+		}
+	}
+
+	return
+}
+
+func TestStatsMonitor_Deltas(t *testing.T) {
+	router, process := workertest.NewRouter(t)
+
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+	producer, err := transport.Produce(workertest.VideoProducerOptions())
+	require.NoError(t, err)
+
+	process.SetStats(producer.Id(), []mediasoup.H{rtpStat("inbound-rtp", 1111, 10, 5, 2, 100000)})
+
+	monitor := NewStatsMonitor(StatsMonitorOptions{Interval: 10 * time.Millisecond})
+	defer monitor.Close()
+
+	monitor.AddProducer(producer)
+	time.Sleep(30 * time.Millisecond)
+
+	process.SetStats(producer.Id(), []mediasoup.H{rtpStat("inbound-rtp", 1111, 25, 8, 6, 150000)})
+
+	total := sumReports(t, monitor, producer.Id(), 15)
+	assert.Equal(t, StreamDelta{
+		Type:          "inbound-rtp",
+		Ssrc:          1111,
+		PacketsLost:   15,
+		NackCount:     3,
+		PliCount:      4,
+		FirCount:      2,
+		PacketCount:   50,
+		BytesReceived: 50000,
+	}, total)
+}
+
+func TestStatsMonitor_CounterReset(t *testing.T) {
+	router, process := workertest.NewRouter(t)
+
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+	producer, err := transport.Produce(workertest.VideoProducerOptions())
+	require.NoError(t, err)
+	consumer, err := transport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      producer.Id(),
+		RtpCapabilities: router.RtpCapabilities(),
+	})
+	require.NoError(t, err)
+
+	process.SetStats(consumer.Id(), []mediasoup.H{rtpStat("outbound-rtp", 2222, 100, 50, 20, 900000)})
+
+	monitor := NewStatsMonitor(StatsMonitorOptions{Interval: 10 * time.Millisecond})
+	defer monitor.Close()
+
+	monitor.AddConsumer(consumer)
+	time.Sleep(30 * time.Millisecond)
+
+	// The stream was re-created, its counters restart from zero.
+	process.SetStats(consumer.Id(), []mediasoup.H{rtpStat("outbound-rtp", 2222, 3, 1, 2, 20000)})
+
+	total := sumReports(t, monitor, consumer.Id(), 3)
+	assert.True(t, total.Reset)
+	assert.EqualValues(t, 3, total.PacketsLost)
+	assert.EqualValues(t, 1, total.NackCount)
+	assert.EqualValues(t, 20000, total.BytesSent)
+
+	// A new SSRC is counted from zero too.
+	process.SetStats(consumer.Id(), []mediasoup.H{rtpStat("outbound-rtp", 3333, 4, 0, 0, 1000)})
+
+	total = sumReports(t, monitor, consumer.Id(), 4)
+	assert.EqualValues(t, 3333, total.Ssrc)
+	assert.False(t, total.Reset)
+	assert.EqualValues(t, 4, total.PacketsLost)
+	assert.EqualValues(t, 1000, total.BytesSent)
+}
+
+func TestNewStreamDelta_PacketsLostDecrease(t *testing.T) {
+	key := streamKey{typ: "inbound-rtp", ssrc: 1111}
+	last := counters{packetsLost: 10, packetCount: 100, bytesReceived: 10000}
+
+	// Late packets were recovered, the other counters kept going.
+	delta := newStreamDelta(key, last, counters{packetsLost: 7, packetCount: 150, bytesReceived: 15000}, 0)
+	assert.False(t, delta.Reset)
+	assert.EqualValues(t, 0, delta.PacketsLost)
+	assert.EqualValues(t, 50, delta.PacketCount)
+	assert.EqualValues(t, 5000, delta.BytesReceived)
+
+	// A negative cumulative loss is not counted after a reset either.
+	delta = newStreamDelta(key, last, counters{packetsLost: -2, packetCount: 5, bytesReceived: 500}, 0)
+	assert.True(t, delta.Reset)
+	assert.EqualValues(t, 0, delta.PacketsLost)
+	assert.EqualValues(t, 5, delta.PacketCount)
+}
+
+func TestStatsMonitor_PacketsLostDecreaseThenIncrease(t *testing.T) {
+	monitor := NewStatsMonitor(StatsMonitorOptions{Interval: time.Hour})
+	defer monitor.Close()
+
+	e := &entity{kind: StatsKind_Producer, id: "producer", closed: func() bool { return false }}
+	monitor.entities[e.id] = e
+
+	key := streamKey{typ: "inbound-rtp", ssrc: 1111}
+	poll := func(packetsLost, packetCount int64) StreamDelta {
+		stats := map[streamKey]counters{key: {packetsLost: packetsLost, packetCount: packetCount}}
+		report, ok := monitor.update(e, stats, nil, time.Now())
+		if !ok {
+			return StreamDelta{}
+		}
+		require.Len(t, report.Streams, 1)
+		return report.Streams[0]
+	}
+
+	poll(10, 100)
+	assert.EqualValues(t, 0, poll(7, 150).PacketsLost)
+
+	// Only the losses beyond the previous maximum count.
+	delta := poll(12, 200)
+	assert.False(t, delta.Reset)
+	assert.EqualValues(t, 2, delta.PacketsLost)
+	assert.EqualValues(t, 50, delta.PacketCount)
+
+	assert.EqualValues(t, 3, poll(15, 250).PacketsLost)
+}
+
+func TestStatsMonitor_TransportAndDataEntities(t *testing.T) {
+	router, process := workertest.NewRouter(t)
+
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+	dataProducer, err := transport.ProduceData(mediasoup.DataProducerOptions{Label: "chat"})
+	require.NoError(t, err)
+
+	process.SetStats(transport.Id(), []mediasoup.H{
+		{"type": "direct-transport", "transportId": transport.Id(), "bytesReceived": 1000, "bytesSent": 0},
+	})
+	process.SetStats(dataProducer.Id(), []mediasoup.H{
+		{"type": "data-producer", "messagesReceived": 1, "bytesReceived": 10},
+	})
+
+	var (
+		interval = 10 * time.Millisecond
+		clock    = time.Now()
+		monitor  = NewStatsMonitor(StatsMonitorOptions{Interval: interval, MaxConcurrency: 1})
+	)
+	defer monitor.Close()
+
+	// Each poll is 1s apart.
+	monitor.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	monitor.AddTransport(transport)
+	monitor.AddDataProducer(dataProducer)
+	time.Sleep(3 * interval)
+
+	process.SetStats(transport.Id(), []mediasoup.H{
+		{"type": "direct-transport", "transportId": transport.Id(), "bytesReceived": 2000, "bytesSent": 500},
+	})
+	process.SetStats(dataProducer.Id(), []mediasoup.H{
+		{"type": "data-producer", "messagesReceived": 4, "bytesReceived": 40},
+	})
+
+	reports := make(map[StatsKind]StatsReport)
+	timeout := time.After(time.Second)
+
+	for len(reports) < 2 {
+		select {
+		case report := <-monitor.Reports():
+			if report.Total().BytesReceived > 0 {
+				reports[report.Kind] = report
+			}
+		case <-timeout:
+			t.Fatalf("reports not received: %v", reports)
+		}
+	}
+
+	report := reports[StatsKind_Transport]
+	assert.Equal(t, transport.Id(), report.Id)
+	require.Len(t, report.Streams, 1)
+	assert.Equal(t, StreamDelta{
+		Type:          "direct-transport",
+		BytesReceived: 1000,
+		BytesSent:     500,
+		RecvBitrate:   8000 * int64(time.Second) / int64(report.Interval),
+		SendBitrate:   4000 * int64(time.Second) / int64(report.Interval),
+	}, report.Streams[0])
+
+	report = reports[StatsKind_DataProducer]
+	assert.Equal(t, dataProducer.Id(), report.Id)
+	assert.EqualValues(t, 3, report.Total().MessagesReceived)
+	assert.EqualValues(t, 30, report.Total().BytesReceived)
+}
+
+func TestStatsMonitor_StopsOnClose(t *testing.T) {
+	router, _ := workertest.NewRouter(t)
+
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+	producer, err := transport.Produce(workertest.AudioProducerOptions())
+	require.NoError(t, err)
+	dataProducer, err := transport.ProduceData(mediasoup.DataProducerOptions{})
+	require.NoError(t, err)
+	dataConsumer, err := transport.ConsumeData(mediasoup.DataConsumerOptions{DataProducerId: dataProducer.Id()})
+	require.NoError(t, err)
+
+	reports := make(chan StatsReport, 100)
+
+	monitor := NewStatsMonitor(StatsMonitorOptions{
+		Interval: 10 * time.Millisecond,
+		OnReport: func(report StatsReport) { reports <- report },
+	})
+
+	monitor.AddTransport(transport)
+	monitor.AddProducer(producer)
+	monitor.AddDataProducer(dataProducer)
+	monitor.AddDataConsumer(dataConsumer)
+	assert.Len(t, monitor.Ids(), 4)
+
+	select {
+	case <-reports:
+	case <-time.After(time.Second):
+		t.Fatal("report not received")
+	}
+
+	producer.Close()
+	assert.Eventually(t, func() bool { return len(monitor.Ids()) == 3 }, time.Second, 5*time.Millisecond)
+
+	// The children are closed with the transport.
+	transport.Close()
+	assert.Eventually(t, func() bool { return len(monitor.Ids()) == 0 }, time.Second, 5*time.Millisecond)
+
+	// A closed entity is not added.
+	monitor.AddProducer(producer)
+	assert.Empty(t, monitor.Ids())
+
+	monitor.Close()
+	assert.True(t, monitor.Closed())
+
+	for range monitor.Reports() {
+	}
+}
@@ -1,12 +1,12 @@
 package mediasoup
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net"
 	"sync"
 	"sync/atomic"
-	"time"
 
 	"github.com/jiyeyuran/mediasoup-go/netstring"
 )
@@ -96,12 +96,23 @@ func (c *PayloadChannel) Notify(event string, internal interface{}, data interfa
 	return
 }
 
-func (c *PayloadChannel) Request(method string, internal interface{}, data interface{}, payload []byte) (rsp workerResponse) {
+func (c *PayloadChannel) Request(method string, internal interface{}, data interface{}, payload []byte) workerResponse {
+	return c.RequestContext(context.Background(), method, internal, data, payload)
+}
+
+// RequestContext sends a request with payload to the worker and waits for the
+// response. If ctx has no deadline, the request times out after 15s plus 0.1s
+// per pending request.
+func (c *PayloadChannel) RequestContext(ctx context.Context, method string, internal interface{}, data interface{}, payload []byte) (rsp workerResponse) {
 	if c.Closed() {
 		rsp.err = NewInvalidStateError("PayloadChannel closed")
 		return
 	}
 
+	if rsp.err = ctx.Err(); rsp.err != nil {
+		return
+	}
+
 	id := int64(1)
 
 	if atomic.LoadInt64(&c.nextId) < 4294967295 {
@@ -115,7 +126,7 @@ func (c *PayloadChannel) Request(method string, internal interface{}, data inter
 	sent := sentInfo{
 		id:     id,
 		method: method,
-		respCh: make(chan workerResponse),
+		respCh: make(chan workerResponse, 1),
 	}
 	c.sents.Store(id, sent)
 
@@ -151,15 +162,16 @@ func (c *PayloadChannel) Request(method string, internal interface{}, data inter
 		return
 	}
 
-	timeout := 1000 * (15 + (0.1 * float64(size)))
-	timer := time.NewTimer(time.Duration(timeout) * time.Millisecond)
-	defer timer.Stop()
+	timeoutCh, stop := requestTimeout(ctx, size)
+	defer stop()
 
 	select {
 	case rsp = <-sent.respCh:
 		return
-	case <-timer.C:
+	case <-timeoutCh:
 		rsp.err = errors.New("Channel request timeout")
+	case <-ctx.Done():
+		rsp.err = ctx.Err()
 	case <-c.closeCh:
 		rsp.err = NewInvalidStateError("Channel closed")
 	}
@@ -1,6 +1,7 @@
 package mediasoup
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
@@ -146,21 +147,34 @@ func (transport *PipeTransport) Observer() IEventEmitter {
 	return transport.ITransport.Observer()
 }
 
+/**
+ * OnSctpStateChange adds a listener of "sctpstatechange" and returns the function
+ * removing it.
+ */
+func (transport *PipeTransport) OnSctpStateChange(listener func(SctpState)) func() {
+	return transport.subscribe("sctpstatechange", listener)
+}
+
 /**
  * Close the PipeTransport.
  *
  * @override
  */
 func (transport *PipeTransport) Close() {
+	transport.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but with a context.
+func (transport *PipeTransport) CloseContext(ctx context.Context) error {
 	if transport.Closed() {
-		return
+		return nil
 	}
 
 	if len(transport.data.SctpState) > 0 {
 		transport.data.SctpState = SctpState_Closed
 	}
 
-	transport.ITransport.Close()
+	return transport.ITransport.CloseContext(ctx)
 }
 
 /**
@@ -185,7 +199,12 @@ func (transport *PipeTransport) routerClosed() {
  *
  * @override
  */
-func (transport *PipeTransport) Connect(options TransportConnectOptions) (err error) {
+func (transport *PipeTransport) Connect(options TransportConnectOptions) error {
+	return transport.ConnectContext(context.Background(), options)
+}
+
+// ConnectContext is like Connect but with a context.
+func (transport *PipeTransport) ConnectContext(ctx context.Context, options TransportConnectOptions) (err error) {
 	transport.logger.Debug("connect()")
 
 	reqData := TransportConnectOptions{
@@ -193,7 +212,7 @@ func (transport *PipeTransport) Connect(options TransportConnectOptions) (err er
 		Port:           options.Port,
 		SrtpParameters: options.SrtpParameters,
 	}
-	resp := transport.channel.Request("transport.connect", transport.internal, reqData)
+	resp := transport.channel.RequestContext(ctx, "transport.connect", transport.internal, reqData)
 
 	var data struct {
 		Tuple TransportTuple
@@ -213,7 +232,12 @@ func (transport *PipeTransport) Connect(options TransportConnectOptions) (err er
  *
  * @override
  */
-func (transport *PipeTransport) Consume(options ConsumerOptions) (consumer *Consumer, err error) {
+func (transport *PipeTransport) Consume(options ConsumerOptions) (*Consumer, error) {
+	return transport.ConsumeContext(context.Background(), options)
+}
+
+// ConsumeContext is like Consume but with a context.
+func (transport *PipeTransport) ConsumeContext(ctx context.Context, options ConsumerOptions) (consumer *Consumer, err error) {
 	transport.logger.Debug("consume()")
 
 	producerId := options.ProducerId
@@ -237,7 +261,7 @@ func (transport *PipeTransport) Consume(options ConsumerOptions) (consumer *Cons
 		"type":                   "pipe",
 		"consumableRtpEncodings": producer.ConsumableRtpParameters().Encodings,
 	}
-	resp := transport.channel.Request("transport.consume", internal, reqData)
+	resp := transport.channel.RequestContext(ctx, "transport.consume", internal, reqData)
 
 	var status struct {
 		Paused         bool
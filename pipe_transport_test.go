@@ -2,6 +2,7 @@ package mediasoup
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -638,3 +639,94 @@ func (suite *PipeTransportTestingSuite) TestRouterPipeRouterCalledTwiceGenenrate
 	// - PipeTransport between routerA and routerB.
 	suite.Len(dump.TransportIds, 1)
 }
+
+func (suite *PipeTransportTestingSuite) TestRouterPipeToRouterCalledTwiceReturnsTheSameResult() {
+	result1, err := suite.router1.PipeToRouter(PipeToRouterOptions{
+		ProducerId: suite.audioProducer.Id(),
+		Router:     suite.router2,
+	})
+	suite.NoError(err)
+
+	result2, err := suite.router1.PipeToRouter(PipeToRouterOptions{
+		ProducerId: suite.audioProducer.Id(),
+		Router:     suite.router2,
+	})
+	suite.NoError(err)
+	suite.Same(result1, result2)
+
+	piped := suite.router1.PipedProducers()
+	suite.Len(piped, 1)
+	suite.Equal(suite.audioProducer.Id(), piped[0].ProducerId)
+	suite.Equal(suite.router2, piped[0].Router)
+	suite.Same(result1, piped[0].Result)
+}
+
+func (suite *PipeTransportTestingSuite) TestRouterUnpipeFromRouterSucceeds() {
+	audioResult, err := suite.router1.PipeToRouter(PipeToRouterOptions{
+		ProducerId: suite.audioProducer.Id(),
+		Router:     suite.router2,
+	})
+	suite.NoError(err)
+	dataResult, err := suite.router1.PipeToRouter(PipeToRouterOptions{
+		DataProducerId: suite.dataProducer.Id(),
+		Router:         suite.router2,
+	})
+	suite.NoError(err)
+
+	err = suite.router1.UnpipeFromRouter(UnpipeFromRouterOptions{
+		ProducerId: suite.audioProducer.Id(),
+		Router:     suite.router2,
+	})
+	suite.NoError(err)
+	suite.True(audioResult.PipeConsumer.Closed())
+	suite.True(audioResult.PipeProducer.Closed())
+	suite.Len(suite.router1.PipedProducers(), 1)
+
+	// The PipeTransport pair is kept while the DataProducer is piped.
+	dump, _ := suite.router1.Dump()
+	suite.Len(dump.TransportIds, 2)
+
+	err = suite.router1.UnpipeFromRouter(UnpipeFromRouterOptions{
+		DataProducerId: suite.dataProducer.Id(),
+		Router:         suite.router2,
+	})
+	suite.NoError(err)
+	suite.True(dataResult.PipeDataConsumer.Closed())
+	suite.True(dataResult.PipeDataProducer.Closed())
+	suite.Empty(suite.router1.PipedProducers())
+
+	// The idle PipeTransport pair is closed.
+	dump, _ = suite.router1.Dump()
+	suite.Len(dump.TransportIds, 1)
+	dump, _ = suite.router2.Dump()
+	suite.Len(dump.TransportIds, 1)
+}
+
+func (suite *PipeTransportTestingSuite) TestRouterUnpipeFromRouterTypeError() {
+	err := suite.router1.UnpipeFromRouter(UnpipeFromRouterOptions{
+		ProducerId: suite.audioProducer.Id(),
+		Router:     suite.router2,
+	})
+	suite.IsType(TypeError{}, err)
+
+	err = suite.router1.UnpipeFromRouter(UnpipeFromRouterOptions{
+		ProducerId: suite.audioProducer.Id(),
+	})
+	suite.IsType(TypeError{}, err)
+}
+
+func (suite *PipeTransportTestingSuite) TestProducerCloseClosesTheIdlePipeTransportPair() {
+	_, err := suite.router1.PipeToRouter(PipeToRouterOptions{
+		ProducerId: suite.audioProducer.Id(),
+		Router:     suite.router2,
+	})
+	suite.NoError(err)
+
+	suite.audioProducer.Close()
+
+	suite.Eventually(func() bool {
+		dump, _ := suite.router1.Dump()
+		return len(dump.TransportIds) == 1
+	}, time.Second, 10*time.Millisecond)
+	suite.Empty(suite.router1.PipedProducers())
+}
@@ -1,6 +1,9 @@
 package mediasoup
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+)
 
 type PlainTransportOptions struct {
 	/**
@@ -168,21 +171,49 @@ func (transport *PlainTransport) Observer() IEventEmitter {
 	return transport.ITransport.Observer()
 }
 
+/**
+ * OnTuple adds a listener of "tuple" and returns the function removing it.
+ */
+func (transport *PlainTransport) OnTuple(listener func(TransportTuple)) func() {
+	return transport.subscribe("tuple", listener)
+}
+
+/**
+ * OnRtcpTuple adds a listener of "rtcptuple" and returns the function
+ * removing it.
+ */
+func (transport *PlainTransport) OnRtcpTuple(listener func(TransportTuple)) func() {
+	return transport.subscribe("rtcptuple", listener)
+}
+
+/**
+ * OnSctpStateChange adds a listener of "sctpstatechange" and returns the function
+ * removing it.
+ */
+func (transport *PlainTransport) OnSctpStateChange(listener func(SctpState)) func() {
+	return transport.subscribe("sctpstatechange", listener)
+}
+
 /**
  * Close the PlainTransport.
  *
  * @override
  */
 func (transport *PlainTransport) Close() {
+	transport.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but with a context.
+func (transport *PlainTransport) CloseContext(ctx context.Context) error {
 	if transport.Closed() {
-		return
+		return nil
 	}
 
 	if len(transport.data.SctpState) > 0 {
 		transport.data.SctpState = SctpState_Closed
 	}
 
-	transport.ITransport.Close()
+	return transport.ITransport.CloseContext(ctx)
 }
 
 /**
@@ -207,7 +238,12 @@ func (transport *PlainTransport) routerClosed() {
  *
  * @override
  */
-func (transport *PlainTransport) Connect(options TransportConnectOptions) (err error) {
+func (transport *PlainTransport) Connect(options TransportConnectOptions) error {
+	return transport.ConnectContext(context.Background(), options)
+}
+
+// ConnectContext is like Connect but with a context.
+func (transport *PlainTransport) ConnectContext(ctx context.Context, options TransportConnectOptions) (err error) {
 	transport.logger.Debug("connect()")
 
 	reqData := TransportConnectOptions{
@@ -216,7 +252,7 @@ func (transport *PlainTransport) Connect(options TransportConnectOptions) (err e
 		RtcpPort:       options.RtcpPort,
 		SrtpParameters: options.SrtpParameters,
 	}
-	resp := transport.channel.Request("transport.connect", transport.internal, reqData)
+	resp := transport.channel.RequestContext(ctx, "transport.connect", transport.internal, reqData)
 
 	var data struct {
 		Tuple          *TransportTuple
@@ -1,9 +1,12 @@
 package mediasoup
 
 import (
+	"context"
 	"encoding/json"
 	"sync"
 	"sync/atomic"
+
+	"github.com/pion/rtp"
 )
 
 type ProducerOptions struct {
@@ -242,6 +245,13 @@ func (producer *Producer) RtpParameters() RtpParameters {
 	return producer.data.RtpParameters
 }
 
+/**
+ * Ids of the header extensions of the RTP packets sent to the Producer.
+ */
+func (producer *Producer) HeaderExtensionIds() RtpHeaderExtensionIds {
+	return NewRtpHeaderExtensionIds(producer.data.RtpParameters.HeaderExtensions)
+}
+
 // Producer type.
 func (producer *Producer) Type() ProducerType {
 	return producer.data.Type
@@ -284,8 +294,43 @@ func (producer *Producer) Observer() IEventEmitter {
 	return producer.observer
 }
 
+/**
+ * OnTransportClose adds a listener of "transportclose" and returns the function
+ * removing it.
+ */
+func (producer *Producer) OnTransportClose(listener func()) func() {
+	return subscribe(producer.IEventEmitter, "transportclose", listener)
+}
+
+/**
+ * OnScore adds a listener of "score" and returns the function removing it.
+ */
+func (producer *Producer) OnScore(listener func([]ProducerScore)) func() {
+	return subscribe(producer.IEventEmitter, "score", listener)
+}
+
+/**
+ * OnVideoOrientationChange adds a listener of "videoorientationchange" and returns the function
+ * removing it.
+ */
+func (producer *Producer) OnVideoOrientationChange(listener func(ProducerVideoOrientation)) func() {
+	return subscribe(producer.IEventEmitter, "videoorientationchange", listener)
+}
+
+/**
+ * OnTrace adds a listener of "trace" and returns the function removing it.
+ */
+func (producer *Producer) OnTrace(listener func(ProducerTraceEventData)) func() {
+	return subscribe(producer.IEventEmitter, "trace", listener)
+}
+
 // Close the Producer.
-func (producer *Producer) Close() (err error) {
+func (producer *Producer) Close() error {
+	return producer.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but with a context.
+func (producer *Producer) CloseContext(ctx context.Context) (err error) {
 	if atomic.CompareAndSwapUint32(&producer.closed, 0, 1) {
 		producer.logger.Debug("close()")
 
@@ -293,10 +338,9 @@ func (producer *Producer) Close() (err error) {
 		producer.channel.RemoveAllListeners(producer.Id())
 		producer.payloadChannel.RemoveAllListeners(producer.Id())
 
-		response := producer.channel.Request("producer.close", producer.internal)
-
-		if err = response.Err(); err != nil {
-			return
+		// Closed even if the request fails, like the Router and the Transport.
+		if err = producer.channel.RequestContext(ctx, "producer.close", producer.internal).Err(); err != nil {
+			producer.logger.Error("close() failed: %s", err)
 		}
 
 		producer.Emit("@close")
@@ -325,27 +369,42 @@ func (producer *Producer) transportClosed() {
 }
 
 // Dump Producer.
-func (producer *Producer) Dump() (dump ProducerDump, err error) {
+func (producer *Producer) Dump() (ProducerDump, error) {
+	return producer.DumpContext(context.Background())
+}
+
+// DumpContext is like Dump but with a context.
+func (producer *Producer) DumpContext(ctx context.Context) (dump ProducerDump, err error) {
 	producer.logger.Debug("dump()")
 
-	resp := producer.channel.Request("producer.dump", producer.internal)
+	resp := producer.channel.RequestContext(ctx, "producer.dump", producer.internal)
 	err = resp.Unmarshal(&dump)
 
 	return
 }
 
 // Get Producer stats.
-func (producer *Producer) GetStats() (stats []*ProducerStat, err error) {
+func (producer *Producer) GetStats() ([]*ProducerStat, error) {
+	return producer.GetStatsContext(context.Background())
+}
+
+// GetStatsContext is like GetStats but with a context.
+func (producer *Producer) GetStatsContext(ctx context.Context) (stats []*ProducerStat, err error) {
 	producer.logger.Debug("getStats()")
 
-	resp := producer.channel.Request("producer.getStats", producer.internal)
+	resp := producer.channel.RequestContext(ctx, "producer.getStats", producer.internal)
 	err = resp.Unmarshal(&stats)
 
 	return
 }
 
 // Pause the Producer.
-func (producer *Producer) Pause() (err error) {
+func (producer *Producer) Pause() error {
+	return producer.PauseContext(context.Background())
+}
+
+// PauseContext is like Pause but with a context.
+func (producer *Producer) PauseContext(ctx context.Context) (err error) {
 	producer.locker.Lock()
 	defer producer.locker.Unlock()
 
@@ -353,7 +412,7 @@ func (producer *Producer) Pause() (err error) {
 
 	wasPaused := producer.paused
 
-	response := producer.channel.Request("producer.pause", producer.internal)
+	response := producer.channel.RequestContext(ctx, "producer.pause", producer.internal)
 
 	if err = response.Err(); err != nil {
 		return
@@ -370,7 +429,12 @@ func (producer *Producer) Pause() (err error) {
 }
 
 // Resume the Producer.
-func (producer *Producer) Resume() (err error) {
+func (producer *Producer) Resume() error {
+	return producer.ResumeContext(context.Background())
+}
+
+// ResumeContext is like Resume but with a context.
+func (producer *Producer) ResumeContext(ctx context.Context) (err error) {
 	producer.locker.Lock()
 	defer producer.locker.Unlock()
 
@@ -378,7 +442,7 @@ func (producer *Producer) Resume() (err error) {
 
 	wasPaused := producer.paused
 
-	result := producer.channel.Request("producer.resume", producer.internal)
+	result := producer.channel.RequestContext(ctx, "producer.resume", producer.internal)
 
 	if err = result.Err(); err != nil {
 		return
@@ -398,13 +462,18 @@ func (producer *Producer) Resume() (err error) {
  * Enable 'trace' event.
  */
 func (producer *Producer) EnableTraceEvent(types ...ProducerTraceEventType) error {
+	return producer.EnableTraceEventContext(context.Background(), types...)
+}
+
+// EnableTraceEventContext is like EnableTraceEvent but with a context.
+func (producer *Producer) EnableTraceEventContext(ctx context.Context, types ...ProducerTraceEventType) error {
 	producer.logger.Debug("enableTraceEvent()")
 
 	if types == nil {
 		types = []ProducerTraceEventType{}
 	}
 
-	result := producer.channel.Request("producer.enableTraceEvent", producer.internal, H{"types": types})
+	result := producer.channel.RequestContext(ctx, "producer.enableTraceEvent", producer.internal, H{"types": types})
 
 	return result.Err()
 }
@@ -413,11 +482,35 @@ func (producer *Producer) EnableTraceEvent(types ...ProducerTraceEventType) erro
  * Send RTP packet (just valid for Producers created on a DirectTransport).
  */
 func (producer *Producer) Send(rtpPacket []byte) error {
-	result := producer.payloadChannel.Request("producer.send", producer.internal, nil, rtpPacket)
+	return producer.SendContext(context.Background(), rtpPacket)
+}
+
+// SendContext is like Send but with a context.
+func (producer *Producer) SendContext(ctx context.Context, rtpPacket []byte) error {
+	result := producer.payloadChannel.RequestContext(ctx, "producer.send", producer.internal, nil, rtpPacket)
 
 	return result.Err()
 }
 
+/**
+ * Send a parsed RTP packet (just valid for Producers created on a
+ * DirectTransport). Header extensions are set with the ids of
+ * HeaderExtensionIds().
+ */
+func (producer *Producer) SendPacket(packet *rtp.Packet) error {
+	return producer.SendPacketContext(context.Background(), packet)
+}
+
+// SendPacketContext is like SendPacket but with a context.
+func (producer *Producer) SendPacketContext(ctx context.Context, packet *rtp.Packet) error {
+	rtpPacket, err := packet.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return producer.SendContext(ctx, rtpPacket)
+}
+
 func (producer *Producer) handleWorkerNotifications() {
 	producer.channel.On(producer.Id(), func(event string, data []byte) {
 		switch event {
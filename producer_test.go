@@ -1,6 +1,7 @@
 package mediasoup
 
 import (
+	"context"
 	"testing"
 
 	"github.com/jiyeyuran/mediasoup-go/h264"
@@ -513,6 +514,23 @@ func (suite *ProducerTestingSuite) TestProduceClose_Succeeds() {
 	suite.Empty(transportDump.ConsumerIds)
 }
 
+func (suite *ProducerTestingSuite) TestProducerCloseContext_Canceled() {
+	onObserverClose := NewMockFunc(suite.T())
+
+	audioProducer := suite.audioProducer()
+	audioProducer.Observer().Once("close", onObserverClose.Fn())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The Producer is closed even if the request is canceled.
+	suite.Equal(context.Canceled, audioProducer.CloseContext(ctx))
+	onObserverClose.ExpectCalledTimes(1)
+	suite.True(audioProducer.Closed())
+	_, ok := suite.router.producers.Load(audioProducer.Id())
+	suite.False(ok)
+}
+
 func (suite *ProducerTestingSuite) TestProduceMethodsRejectIfClosed() {
 	audioProducer := suite.audioProducer()
 	audioProducer.Close()
@@ -527,6 +545,30 @@ func (suite *ProducerTestingSuite) TestProduceMethodsRejectIfClosed() {
 	suite.Error(audioProducer.Resume())
 }
 
+func (suite *ProducerTestingSuite) TestProducerOnScore() {
+	videoProducer := suite.videoProducer()
+	channel := videoProducer.channel
+
+	onScore := NewMockFunc(suite.T())
+	onScoreFn := onScore.Fn()
+
+	unsubscribe := videoProducer.OnScore(func(score []ProducerScore) { onScoreFn(score) })
+
+	channel.Emit(videoProducer.Id(), "score",
+		[]byte(`[ { "ssrc": 11, "score": 10 } ]`))
+
+	onScore.ExpectCalledTimes(1)
+	onScore.ExpectCalledWith([]ProducerScore{{Ssrc: 11, Score: 10}})
+
+	unsubscribe()
+
+	channel.Emit(videoProducer.Id(), "score",
+		[]byte(`[ { "ssrc": 11, "score": 9 } ]`))
+
+	onScore.ExpectCalledTimes(1)
+	suite.Equal([]ProducerScore{{Ssrc: 11, Score: 9}}, videoProducer.Score())
+}
+
 func (suite *ProducerTestingSuite) TestProducerEmitsTransportclose() {
 	onObserverClose := NewMockFunc(suite.T())
 
@@ -0,0 +1,267 @@
+package recorder
+
+import (
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+)
+
+// frame is an encoded frame assembled from the payloads of the RTP packets
+// sharing a timestamp.
+type frame struct {
+	data      []byte
+	timestamp int64
+	keyFrame  bool
+}
+
+// depacketizer extracts the codec payload of a RTP packet.
+type depacketizer interface {
+	// depacketize returns the frame data carried by the packet.
+	depacketize(packet *rtp.Packet) ([]byte, error)
+	// isKeyFrame tells whether the first packet of a frame starts a key frame.
+	isKeyFrame(packet *rtp.Packet, data []byte) bool
+	// audio codecs carry one frame per packet.
+	audio() bool
+	// reset drops the state of a partially received frame.
+	reset()
+}
+
+func newDepacketizer(mimeType string) depacketizer {
+	switch strings.ToLower(mimeType) {
+	case "audio/opus":
+		return &opusDepacketizer{}
+	case "video/vp8":
+		return &vp8Depacketizer{}
+	case "video/vp9":
+		return &vp9Depacketizer{}
+	case "video/h264":
+		return &h264Depacketizer{}
+	}
+	return nil
+}
+
+type opusDepacketizer struct {
+	codecs.OpusPacket
+}
+
+func (d *opusDepacketizer) depacketize(packet *rtp.Packet) ([]byte, error) {
+	return d.Unmarshal(packet.Payload)
+}
+
+func (d *opusDepacketizer) isKeyFrame(packet *rtp.Packet, data []byte) bool {
+	return true
+}
+
+func (d *opusDepacketizer) reset() {}
+
+func (d *opusDepacketizer) audio() bool {
+	return true
+}
+
+type vp8Depacketizer struct {
+	codecs.VP8Packet
+}
+
+func (d *vp8Depacketizer) depacketize(packet *rtp.Packet) ([]byte, error) {
+	return d.Unmarshal(packet.Payload)
+}
+
+func (d *vp8Depacketizer) isKeyFrame(packet *rtp.Packet, data []byte) bool {
+	// The P bit of the frame tag is 0 for key frames.
+	return d.S == 1 && d.PID == 0 && len(data) > 0 && data[0]&0x01 == 0
+}
+
+func (d *vp8Depacketizer) reset() {}
+
+func (d *vp8Depacketizer) audio() bool {
+	return false
+}
+
+type vp9Depacketizer struct {
+	codecs.VP9Packet
+}
+
+func (d *vp9Depacketizer) depacketize(packet *rtp.Packet) ([]byte, error) {
+	return d.Unmarshal(packet.Payload)
+}
+
+func (d *vp9Depacketizer) isKeyFrame(packet *rtp.Packet, data []byte) bool {
+	return d.B && !d.P
+}
+
+func (d *vp9Depacketizer) reset() {}
+
+func (d *vp9Depacketizer) audio() bool {
+	return false
+}
+
+type h264Depacketizer struct {
+	codecs.H264Packet
+}
+
+func (d *h264Depacketizer) depacketize(packet *rtp.Packet) ([]byte, error) {
+	return d.Unmarshal(packet.Payload)
+}
+
+func (d *h264Depacketizer) isKeyFrame(packet *rtp.Packet, data []byte) bool {
+	payload := packet.Payload
+
+	if len(payload) == 0 {
+		return false
+	}
+
+	switch nalType := payload[0] & 0x1f; nalType {
+	case 5, 7:
+		return true
+
+	case 24: // STAP-A
+		for offset := 1; offset+2 < len(payload); {
+			size := int(payload[offset])<<8 | int(payload[offset+1])
+			if t := payload[offset+2] & 0x1f; t == 5 || t == 7 {
+				return true
+			}
+			offset += 2 + size
+		}
+
+	case 28: // FU-A
+		return len(payload) > 1 && payload[1]&0x1f == 5
+	}
+
+	return false
+}
+
+func (d *h264Depacketizer) audio() bool {
+	return false
+}
+
+func (d *h264Depacketizer) reset() {
+	d.H264Packet = codecs.H264Packet{}
+}
+
+// unwrapper extends the 16-bit sequence numbers or 32-bit timestamps of RTP
+// to 64 bits, so that they keep increasing across wraparounds.
+type unwrapper struct {
+	bits    uint
+	started bool
+	last    int64
+}
+
+func (u *unwrapper) unwrap(value uint32) int64 {
+	if !u.started {
+		u.started = true
+		u.last = int64(value)
+		return u.last
+	}
+
+	size := int64(1) << u.bits
+	mask := size - 1
+	delta := (int64(value) - u.last) & mask
+
+	// Values more than half the range behind are late packets.
+	if delta >= size/2 {
+		delta -= size
+	}
+
+	value64 := u.last + delta
+
+	if delta > 0 {
+		u.last = value64
+	}
+
+	return value64
+}
+
+// assembler gathers the packets of a stream into frames.
+type assembler struct {
+	depacketizer   depacketizer
+	sequence       unwrapper
+	timestamp      unwrapper
+	lastSequence   int64
+	firstTimestamp int64
+	started        bool
+	current        *frame
+	// Whether a key frame is needed before frames can be emitted again.
+	waitKeyFrame bool
+}
+
+func newAssembler(depacketizer depacketizer) *assembler {
+	return &assembler{
+		depacketizer: depacketizer,
+		sequence:     unwrapper{bits: 16},
+		timestamp:    unwrapper{bits: 32},
+		waitKeyFrame: !depacketizer.audio(),
+	}
+}
+
+// push adds a packet and returns the frames it completes. lost is true when
+// packets are missing, in which case video frames are dropped until the next
+// key frame.
+func (a *assembler) push(packet *rtp.Packet) (frames []*frame, lost bool) {
+	sequence := a.sequence.unwrap(uint32(packet.SequenceNumber))
+	timestamp := a.timestamp.unwrap(packet.Timestamp)
+
+	if !a.started {
+		a.started = true
+		a.firstTimestamp = timestamp
+		a.lastSequence = sequence - 1
+	}
+
+	// Duplicated or reordered packet.
+	if sequence <= a.lastSequence {
+		return
+	}
+
+	if sequence != a.lastSequence+1 {
+		lost = true
+
+		if !a.depacketizer.audio() {
+			a.depacketizer.reset()
+			a.current = nil
+			a.waitKeyFrame = true
+		}
+	}
+	a.lastSequence = sequence
+
+	// A new timestamp without the marker of the previous frame.
+	if a.current != nil && a.current.timestamp != timestamp-a.firstTimestamp {
+		frames = a.flush(frames)
+	}
+
+	data, err := a.depacketizer.depacketize(packet)
+	if err != nil {
+		a.depacketizer.reset()
+		a.current = nil
+		return
+	}
+
+	if a.current == nil {
+		keyFrame := a.depacketizer.isKeyFrame(packet, data)
+
+		if a.waitKeyFrame && !keyFrame {
+			return
+		}
+		a.waitKeyFrame = false
+		a.current = &frame{
+			timestamp: timestamp - a.firstTimestamp,
+			keyFrame:  keyFrame,
+		}
+	}
+
+	a.current.data = append(a.current.data, data...)
+
+	if a.depacketizer.audio() || packet.Marker {
+		frames = a.flush(frames)
+	}
+
+	return
+}
+
+func (a *assembler) flush(frames []*frame) []*frame {
+	if a.current != nil && len(a.current.data) > 0 {
+		frames = append(frames, a.current)
+	}
+	a.current = nil
+
+	return frames
+}
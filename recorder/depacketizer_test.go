@@ -0,0 +1,79 @@
+package recorder
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnwrapper(t *testing.T) {
+	u := unwrapper{bits: 16}
+
+	assert.EqualValues(t, 65534, u.unwrap(65534))
+	assert.EqualValues(t, 65536, u.unwrap(0))
+	// Late packet before the wraparound.
+	assert.EqualValues(t, 65535, u.unwrap(65535))
+	assert.EqualValues(t, 65537, u.unwrap(1))
+	assert.EqualValues(t, 65536+20000, u.unwrap(20000))
+	// Late packet.
+	assert.EqualValues(t, 65536+10000, u.unwrap(10000))
+}
+
+func TestAssembler_Audio(t *testing.T) {
+	a := newAssembler(newDepacketizer("audio/opus"))
+
+	push := func(sequence uint16, timestamp uint32) ([]*frame, bool) {
+		return a.push(&rtp.Packet{
+			Header:  rtp.Header{SequenceNumber: sequence, Timestamp: timestamp},
+			Payload: []byte{byte(sequence)},
+		})
+	}
+
+	frames, lost := push(10, 1000)
+	assert.False(t, lost)
+	assert.Equal(t, []*frame{{data: []byte{10}, timestamp: 0, keyFrame: true}}, frames)
+
+	// Lost audio packets don't stop the stream.
+	frames, lost = push(12, 2920)
+	assert.True(t, lost)
+	assert.Len(t, frames, 1)
+	assert.EqualValues(t, 1920, frames[0].timestamp)
+
+	// Duplicated packet.
+	frames, lost = push(12, 2920)
+	assert.False(t, lost)
+	assert.Empty(t, frames)
+}
+
+func TestAssembler_Video(t *testing.T) {
+	a := newAssembler(newDepacketizer("video/vp8"))
+
+	push := func(sequence uint16, timestamp uint32, marker bool, payload ...byte) []*frame {
+		frames, _ := a.push(&rtp.Packet{
+			Header:  rtp.Header{SequenceNumber: sequence, Timestamp: timestamp, Marker: marker},
+			Payload: payload,
+		})
+		return frames
+	}
+
+	// Delta frame before the first key frame.
+	assert.Empty(t, push(1, 0, true, 0x10, 0x01, 0x02, 0x03))
+	assert.True(t, a.waitKeyFrame)
+
+	assert.Empty(t, push(2, 3000, false, 0x10, 0x00, 0x02, 0x03))
+	frames := push(3, 3000, true, 0x00, 0x04, 0x05, 0x06)
+	assert.Equal(t, []*frame{{data: []byte{0x00, 0x02, 0x03, 0x04, 0x05, 0x06}, timestamp: 3000, keyFrame: true}}, frames)
+
+	// A frame without marker is flushed by the next timestamp.
+	assert.Empty(t, push(4, 6000, false, 0x10, 0x01, 0x02, 0x03))
+	frames = push(5, 9000, true, 0x10, 0x01, 0x04, 0x05)
+	assert.Len(t, frames, 2)
+	assert.False(t, frames[0].keyFrame)
+
+	// Packet 6 is lost, the frame is dropped.
+	assert.Empty(t, push(7, 12000, true, 0x00, 0x01, 0x02, 0x03))
+	assert.True(t, a.waitKeyFrame)
+	assert.Empty(t, push(8, 15000, true, 0x10, 0x01, 0x02, 0x03))
+	assert.Len(t, push(9, 18000, true, 0x10, 0x00, 0x02, 0x03), 1)
+}
@@ -0,0 +1,19 @@
+package recorder
+
+import "io"
+
+// h264Writer writes H264 access units in an Annex-B byte stream, the
+// depacketizer already prefixes the NAL units with start codes.
+type h264Writer struct {
+	w io.Writer
+}
+
+func (h *h264Writer) writeFrame(f *frame) error {
+	_, err := h.w.Write(f.data)
+
+	return err
+}
+
+func (h *h264Writer) close() error {
+	return nil
+}
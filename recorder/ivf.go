@@ -0,0 +1,101 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+const ivfHeaderSize = 32
+
+// ivfWriter writes VP8 or VP9 frames in an IVF file. The time base is the RTP
+// clock rate. The frame count of the header is written on close when the
+// output is seekable.
+type ivfWriter struct {
+	w          io.Writer
+	fourcc     string
+	clockRate  int
+	frameCount uint32
+	started    bool
+}
+
+func newIvfWriter(w io.Writer, fourcc string, clockRate int) *ivfWriter {
+	return &ivfWriter{
+		w:         w,
+		fourcc:    fourcc,
+		clockRate: clockRate,
+	}
+}
+
+// writeHeader writes the file header once the size of the video is known
+// from the first key frame.
+func (i *ivfWriter) writeHeader(width, height uint16) error {
+	header := make([]byte, ivfHeaderSize)
+
+	copy(header, "DKIF")
+	binary.LittleEndian.PutUint16(header[4:], 0)
+	binary.LittleEndian.PutUint16(header[6:], ivfHeaderSize)
+	copy(header[8:], i.fourcc)
+	binary.LittleEndian.PutUint16(header[12:], width)
+	binary.LittleEndian.PutUint16(header[14:], height)
+	binary.LittleEndian.PutUint32(header[16:], uint32(i.clockRate))
+	binary.LittleEndian.PutUint32(header[20:], 1)
+
+	_, err := i.w.Write(header)
+
+	return err
+}
+
+func (i *ivfWriter) writeFrame(f *frame) error {
+	if !i.started {
+		width, height := videoSize(i.fourcc, f.data)
+
+		if err := i.writeHeader(width, height); err != nil {
+			return err
+		}
+		i.started = true
+	}
+
+	header := make([]byte, 12)
+	binary.LittleEndian.PutUint32(header, uint32(len(f.data)))
+	binary.LittleEndian.PutUint64(header[4:], uint64(f.timestamp))
+
+	if _, err := i.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := i.w.Write(f.data); err != nil {
+		return err
+	}
+
+	i.frameCount++
+
+	return nil
+}
+
+func (i *ivfWriter) close() error {
+	if !i.started {
+		return i.writeHeader(0, 0)
+	}
+
+	seeker, ok := i.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil
+	}
+
+	count := make([]byte, 4)
+	binary.LittleEndian.PutUint32(count, i.frameCount)
+
+	if _, err = seeker.Seek(24, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = seeker.Write(count); err != nil {
+		return err
+	}
+	_, err = seeker.Seek(offset, io.SeekStart)
+
+	return err
+}
@@ -0,0 +1,141 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"io"
+	"math/rand"
+)
+
+const (
+	oggHeaderTypeBOS = 0x02
+	oggHeaderTypeEOS = 0x04
+)
+
+var oggCrcTable = func() (table [256]uint32) {
+	for i := range table {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+	return
+}()
+
+func oggCrc(data []byte) (crc uint32) {
+	for _, b := range data {
+		crc = crc<<8 ^ oggCrcTable[byte(crc>>24)^b]
+	}
+	return
+}
+
+// oggWriter writes Opus packets in an Ogg file (RFC 7845), one packet per
+// page. The last packet is held back so that its page can be flagged as the
+// end of the stream.
+type oggWriter struct {
+	w            io.Writer
+	serial       uint32
+	pageSequence uint32
+	channels     int
+	pending      *frame
+	duration     int64
+}
+
+func newOggWriter(w io.Writer, clockRate, channels int) (*oggWriter, error) {
+	if channels <= 0 {
+		channels = 2
+	}
+	writer := &oggWriter{
+		w:        w,
+		serial:   rand.Uint32(),
+		channels: channels,
+		duration: int64(clockRate / 50),
+	}
+
+	if err := writer.writePage(opusHead(channels, clockRate), oggHeaderTypeBOS, 0); err != nil {
+		return nil, err
+	}
+
+	tags := []byte("OpusTags")
+	tags = appendUint32(tags, uint32(len(vendor)))
+	tags = append(tags, vendor...)
+	tags = appendUint32(tags, 0)
+
+	if err := writer.writePage(tags, 0, 0); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// opusHead returns the Opus identification header.
+func opusHead(channels, sampleRate int) []byte {
+	head := []byte("OpusHead")
+	head = append(head, 1, byte(channels))
+	head = append(head, 0, 0) // pre-skip
+	head = appendUint32(head, uint32(sampleRate))
+	head = append(head, 0, 0) // output gain
+	head = append(head, 0)    // channel mapping family
+
+	return head
+}
+
+func (o *oggWriter) writeFrame(f *frame) error {
+	if o.pending != nil {
+		if f.timestamp > o.pending.timestamp {
+			o.duration = f.timestamp - o.pending.timestamp
+		}
+		if err := o.writePage(o.pending.data, 0, uint64(f.timestamp)); err != nil {
+			return err
+		}
+	}
+	o.pending = f
+
+	return nil
+}
+
+func (o *oggWriter) close() error {
+	if o.pending == nil {
+		// An empty page ends the stream.
+		return o.writePage(nil, oggHeaderTypeEOS, 0)
+	}
+
+	return o.writePage(o.pending.data, oggHeaderTypeEOS, uint64(o.pending.timestamp+o.duration))
+}
+
+// writePage writes a packet in a page. The granule position is the number of
+// 48kHz samples at the end of the packet, and the RTP clock of Opus is
+// 48kHz.
+func (o *oggWriter) writePage(packet []byte, headerType byte, granulePosition uint64) error {
+	segments := len(packet)/255 + 1
+	page := make([]byte, 27, 27+segments+len(packet))
+
+	copy(page, "OggS")
+	page[5] = headerType
+	binary.LittleEndian.PutUint64(page[6:], granulePosition)
+	binary.LittleEndian.PutUint32(page[14:], o.serial)
+	binary.LittleEndian.PutUint32(page[18:], o.pageSequence)
+	page[26] = byte(segments)
+
+	for i := 0; i < segments-1; i++ {
+		page = append(page, 255)
+	}
+	page = append(page, byte(len(packet)%255))
+	page = append(page, packet...)
+
+	binary.LittleEndian.PutUint32(page[22:], oggCrc(page))
+
+	o.pageSequence++
+
+	_, err := o.w.Write(page)
+
+	return err
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
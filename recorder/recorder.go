@@ -0,0 +1,425 @@
+// Package recorder writes the media of a Producer to a file without external
+// tools. The Producer is consumed through a DirectTransport, whose consumer
+// emits the RTP packets, or through a PlainTransport sending to a local UDP
+// socket. Opus is written as Ogg or WebM, VP8 and VP9 as IVF or WebM, and
+// H264 as an Annex-B byte stream.
+package recorder
+
+import (
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/pion/rtp"
+)
+
+const vendor = "mediasoup-go"
+
+type Format string
+
+const (
+	Format_Ogg  Format = "ogg"
+	Format_Ivf         = "ivf"
+	Format_WebM        = "webm"
+	Format_H264        = "h264"
+)
+
+type RecorderTransport string
+
+const (
+	// The consumer lives in a DirectTransport and emits the RTP packets.
+	RecorderTransport_Direct RecorderTransport = "direct"
+	// The consumer lives in a PlainTransport sending to a local UDP socket.
+	RecorderTransport_Plain = "plain"
+)
+
+type RecorderOptions struct {
+	/**
+	 * The id of the Producer to record.
+	 */
+	ProducerId string
+
+	/**
+	 * Where the file is written. If it is an io.WriteSeeker, headers which
+	 * need the whole recording (such as the IVF frame count) are completed on
+	 * close.
+	 */
+	Output io.Writer
+
+	/**
+	 * Path of the file created when Output is nil.
+	 */
+	Path string
+
+	/**
+	 * Container format. Default "ogg" for Opus, "ivf" for VP8 and VP9, and
+	 * "h264" for H264.
+	 */
+	Format Format
+
+	/**
+	 * How the Producer is consumed. Default "direct".
+	 */
+	Transport RecorderTransport
+
+	/**
+	 * Local IP of the PlainTransport and of the UDP socket. Default
+	 * "127.0.0.1".
+	 */
+	ListenIp string
+
+	/**
+	 * Minimum delay between two key frame requests. Default 1s.
+	 */
+	KeyFrameRequestInterval time.Duration
+}
+
+// frameWriter writes frames to a container.
+type frameWriter interface {
+	writeFrame(f *frame) error
+	close() error
+}
+
+/**
+ * Recorder consumes a Producer and writes its frames to a file. Frames are
+ * written from the first key frame, and when packets are lost video frames
+ * are dropped and a key frame is requested until the stream can be decoded
+ * again.
+ *
+ * @emits close
+ */
+type Recorder struct {
+	mediasoup.IEventEmitter
+	logger                  mediasoup.Logger
+	transport               mediasoup.ITransport
+	consumer                *mediasoup.Consumer
+	conn                    *net.UDPConn
+	file                    *os.File
+	assembler               *assembler
+	writer                  frameWriter
+	keyFrameRequestInterval time.Duration
+	lastKeyFrameRequest     time.Time
+	started                 bool
+	baseTimestamp           int64
+	frames                  uint32
+	closed                  uint32
+	locker                  sync.Mutex
+}
+
+/**
+ * NewRecorder starts recording a Producer of the given router.
+ */
+func NewRecorder(router *mediasoup.Router, options RecorderOptions) (recorder *Recorder, err error) {
+	logger := mediasoup.NewLogger("Recorder")
+
+	logger.Debug("constructor()")
+
+	if options.Output == nil && len(options.Path) == 0 {
+		return nil, mediasoup.NewTypeError("missing output")
+	}
+	if len(options.Transport) == 0 {
+		options.Transport = RecorderTransport_Direct
+	}
+	if len(options.ListenIp) == 0 {
+		options.ListenIp = "127.0.0.1"
+	}
+	if options.KeyFrameRequestInterval <= 0 {
+		options.KeyFrameRequestInterval = time.Second
+	}
+
+	recorder = &Recorder{
+		IEventEmitter:           mediasoup.NewEventEmitter(),
+		logger:                  logger,
+		keyFrameRequestInterval: options.KeyFrameRequestInterval,
+	}
+
+	defer func() {
+		if err != nil {
+			recorder.release()
+			recorder = nil
+		}
+	}()
+
+	switch options.Transport {
+	case RecorderTransport_Direct:
+		recorder.transport, err = router.CreateDirectTransport()
+
+	case RecorderTransport_Plain:
+		err = recorder.listen(router, options.ListenIp)
+
+	default:
+		err = mediasoup.NewTypeError("invalid transport: %s", options.Transport)
+	}
+	if err != nil {
+		return
+	}
+
+	recorder.consumer, err = recorder.transport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      options.ProducerId,
+		RtpCapabilities: router.RtpCapabilities(),
+	})
+	if err != nil {
+		return
+	}
+
+	codec := recorder.consumer.RtpParameters().Codecs[0]
+
+	depacketizer := newDepacketizer(codec.MimeType)
+	if depacketizer == nil {
+		err = mediasoup.NewTypeError("unsupported codec: %s", codec.MimeType)
+		return
+	}
+	recorder.assembler = newAssembler(depacketizer)
+
+	format, err := checkFormat(options.Format, codec)
+	if err != nil {
+		return
+	}
+
+	output := options.Output
+
+	if output == nil {
+		if recorder.file, err = os.Create(options.Path); err != nil {
+			return
+		}
+		output = recorder.file
+	}
+
+	if recorder.writer, err = newFrameWriter(output, format, codec); err != nil {
+		return
+	}
+
+	recorder.consumer.OnProducerClose(func() { recorder.Close() })
+	recorder.consumer.OnTransportClose(func() { recorder.Close() })
+
+	if recorder.conn != nil {
+		go recorder.readLoop()
+	} else {
+		recorder.consumer.On("rtp", recorder.handleRtp)
+	}
+
+	return
+}
+
+// listen creates a PlainTransport sending to a local UDP socket.
+func (r *Recorder) listen(router *mediasoup.Router, listenIp string) (err error) {
+	r.conn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(listenIp)})
+	if err != nil {
+		return
+	}
+
+	transport, err := router.CreatePlainTransport(mediasoup.PlainTransportOptions{
+		ListenIp: mediasoup.TransportListenIp{Ip: listenIp},
+		RtcpMux:  mediasoup.Bool(true),
+	})
+	if err != nil {
+		return
+	}
+	r.transport = transport
+
+	return transport.Connect(mediasoup.TransportConnectOptions{
+		Ip:   listenIp,
+		Port: uint16(r.conn.LocalAddr().(*net.UDPAddr).Port),
+	})
+}
+
+/**
+ * Consumer of the Producer.
+ */
+func (r *Recorder) Consumer() *mediasoup.Consumer {
+	return r.consumer
+}
+
+/**
+ * Transport of the Consumer, a *DirectTransport or a *PlainTransport.
+ */
+func (r *Recorder) Transport() mediasoup.ITransport {
+	return r.transport
+}
+
+/**
+ * Number of frames written.
+ */
+func (r *Recorder) Frames() int {
+	return int(atomic.LoadUint32(&r.frames))
+}
+
+/**
+ * Whether the Recorder is closed.
+ */
+func (r *Recorder) Closed() bool {
+	return atomic.LoadUint32(&r.closed) > 0
+}
+
+/**
+ * Close stops recording and completes the file. It is called when the
+ * Producer or its router is closed.
+ */
+func (r *Recorder) Close() (err error) {
+	if !atomic.CompareAndSwapUint32(&r.closed, 0, 1) {
+		return
+	}
+
+	r.logger.Debug("close()")
+
+	err = r.release()
+
+	r.SafeEmit("close")
+
+	return
+}
+
+// release closes the transport and completes the file.
+func (r *Recorder) release() (err error) {
+	if r.transport != nil {
+		r.transport.Close()
+	}
+	if r.conn != nil {
+		r.conn.Close()
+	}
+
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	if r.writer != nil {
+		err = r.writer.close()
+	}
+	if r.file != nil {
+		if closeErr := r.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return
+}
+
+func (r *Recorder) readLoop() {
+	buf := make([]byte, 65536)
+
+	for {
+		n, _, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !r.Closed() {
+				r.logger.Error("read failed: %s", err)
+			}
+			return
+		}
+
+		// RTCP is multiplexed.
+		if n >= 2 && buf[1] >= 192 && buf[1] <= 223 {
+			continue
+		}
+
+		r.handleRtp(buf[:n])
+	}
+}
+
+func (r *Recorder) handleRtp(data []byte) {
+	packet := &rtp.Packet{}
+
+	if err := packet.Unmarshal(data); err != nil {
+		r.logger.Warn("invalid RTP packet: %s", err)
+		return
+	}
+
+	r.locker.Lock()
+	defer r.locker.Unlock()
+
+	if r.Closed() {
+		return
+	}
+
+	frames, lost := r.assembler.push(packet)
+
+	if lost || r.assembler.waitKeyFrame {
+		r.requestKeyFrame()
+	}
+
+	for _, f := range frames {
+		if !r.started {
+			r.started = true
+			r.baseTimestamp = f.timestamp
+		}
+		f.timestamp -= r.baseTimestamp
+
+		if err := r.writer.writeFrame(f); err != nil {
+			r.logger.Error("write failed: %s", err)
+			go r.Close()
+			return
+		}
+		atomic.AddUint32(&r.frames, 1)
+	}
+}
+
+// requestKeyFrame requests a key frame unless one was requested recently.
+// r.locker must be held.
+func (r *Recorder) requestKeyFrame() {
+	if r.consumer.Kind() != mediasoup.MediaKind_Video {
+		return
+	}
+
+	now := time.Now()
+
+	if now.Sub(r.lastKeyFrameRequest) < r.keyFrameRequestInterval {
+		return
+	}
+	r.lastKeyFrameRequest = now
+
+	go func() {
+		if err := r.consumer.RequestKeyFrame(); err != nil && !r.Closed() {
+			r.logger.Warn("requestKeyFrame() failed: %s", err)
+		}
+	}()
+}
+
+// formats lists the formats each codec can be written in, the first one is
+// the default.
+var formats = map[string][]Format{
+	"audio/opus": {Format_Ogg, Format_WebM},
+	"video/vp8":  {Format_Ivf, Format_WebM},
+	"video/vp9":  {Format_Ivf, Format_WebM},
+	"video/h264": {Format_H264},
+}
+
+// checkFormat returns the format to write the codec in.
+func checkFormat(format Format, codec *mediasoup.RtpCodecParameters) (Format, error) {
+	supported := formats[strings.ToLower(codec.MimeType)]
+
+	if len(format) == 0 && len(supported) > 0 {
+		return supported[0], nil
+	}
+	for _, f := range supported {
+		if f == format {
+			return format, nil
+		}
+	}
+
+	return "", mediasoup.NewTypeError("cannot write %s in %s", codec.MimeType, format)
+}
+
+func newFrameWriter(w io.Writer, format Format, codec *mediasoup.RtpCodecParameters) (frameWriter, error) {
+	mimeType := strings.ToLower(codec.MimeType)
+	fourcc := map[string]string{"video/vp8": "VP80", "video/vp9": "VP90"}[mimeType]
+
+	switch format {
+	case Format_Ogg:
+		return newOggWriter(w, codec.ClockRate, codec.Channels)
+
+	case Format_Ivf:
+		return newIvfWriter(w, fourcc, codec.ClockRate), nil
+
+	case Format_WebM:
+		if mimeType == "audio/opus" {
+			return newWebmWriter(w, "A_OPUS", "", true, codec.ClockRate, codec.Channels), nil
+		}
+		return newWebmWriter(w, "V_"+fourcc[:3], fourcc, false, codec.ClockRate, 0), nil
+
+	default:
+		return &h264Writer{w: w}, nil
+	}
+}
@@ -0,0 +1,422 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/h264"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testStream struct {
+	t        *testing.T
+	router   *mediasoup.Router
+	process  *workertest.Process
+	producer *mediasoup.Producer
+	sequence uint16
+}
+
+func newTestStream(t *testing.T, options mediasoup.ProducerOptions) *testStream {
+	mediaCodecs := append(workertest.MediaCodecs(), &mediasoup.RtpCodecCapability{
+		Kind:      "video",
+		MimeType:  "video/H264",
+		ClockRate: 90000,
+		Parameters: mediasoup.RtpCodecSpecificParameters{
+			RtpParameter: h264.RtpParameter{
+				PacketizationMode: 1,
+				ProfileLevelId:    "42e01f",
+			},
+		},
+	})
+
+	router, process := workertest.NewRouter(t, mediaCodecs...)
+
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+
+	options.RtpParameters.HeaderExtensions = nil
+	options.RtpParameters.Encodings = []mediasoup.RtpEncodingParameters{{Ssrc: 1234}}
+
+	producer, err := transport.Produce(options)
+	require.NoError(t, err)
+
+	return &testStream{
+		t:        t,
+		router:   router,
+		process:  process,
+		producer: producer,
+	}
+}
+
+// send sends a packet with the next sequence number.
+func (s *testStream) send(timestamp uint32, marker bool, payload []byte) {
+	packet := &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			PayloadType:    100,
+			SequenceNumber: s.sequence,
+			Timestamp:      timestamp,
+			SSRC:           1234,
+			Marker:         marker,
+		},
+		Payload: payload,
+	}
+	s.sequence++
+
+	data, err := packet.Marshal()
+	require.NoError(s.t, err)
+	require.NoError(s.t, s.producer.Send(data))
+}
+
+func (s *testStream) record(options RecorderOptions) *Recorder {
+	options.ProducerId = s.producer.Id()
+
+	recorder, err := NewRecorder(s.router, options)
+	require.NoError(s.t, err)
+
+	return recorder
+}
+
+func waitFrames(t *testing.T, recorder *Recorder, frames int) {
+	assert.Eventually(t, func() bool {
+		return recorder.Frames() == frames
+	}, time.Second, 5*time.Millisecond)
+}
+
+func vp8KeyFrame() []byte {
+	frame := []byte{0x00, 0x00, 0x00, 0x9d, 0x01, 0x2a, 0, 0, 0, 0, 0xaa, 0xbb}
+	binary.LittleEndian.PutUint16(frame[6:], 640)
+	binary.LittleEndian.PutUint16(frame[8:], 480)
+	return frame
+}
+
+// sendVp8 sends a VP8 frame in two packets.
+func (s *testStream) sendVp8(timestamp uint32, frame []byte) {
+	half := len(frame) / 2
+	s.send(timestamp, false, append([]byte{0x10}, frame[:half]...))
+	s.send(timestamp, true, append([]byte{0x00}, frame[half:]...))
+}
+
+type oggPage struct {
+	headerType      byte
+	granulePosition uint64
+	packet          []byte
+}
+
+func parseOgg(t *testing.T, data []byte) (pages []oggPage) {
+	for len(data) > 0 {
+		require.True(t, len(data) >= 27)
+		require.Equal(t, "OggS", string(data[:4]))
+
+		segments := int(data[26])
+		size := 0
+		for _, lacing := range data[27 : 27+segments] {
+			size += int(lacing)
+		}
+		pageSize := 27 + segments + size
+		page := append([]byte{}, data[:pageSize]...)
+
+		crc := binary.LittleEndian.Uint32(page[22:])
+		binary.LittleEndian.PutUint32(page[22:], 0)
+		require.Equal(t, oggCrc(page), crc)
+
+		pages = append(pages, oggPage{
+			headerType:      data[5],
+			granulePosition: binary.LittleEndian.Uint64(data[6:]),
+			packet:          data[27+segments : pageSize],
+		})
+		data = data[pageSize:]
+	}
+	return
+}
+
+func TestRecorder_Ogg(t *testing.T) {
+	s := newTestStream(t, workertest.AudioProducerOptions())
+	// Sequence numbers and timestamps wrap around.
+	s.sequence = 65530
+
+	output := &bytes.Buffer{}
+	recorder := s.record(RecorderOptions{Output: output})
+
+	onClose := make(chan struct{}, 1)
+	recorder.On("close", func() { onClose <- struct{}{} })
+
+	timestamp := uint32(4294967000)
+	for i := 0; i < 20; i++ {
+		s.send(timestamp, false, []byte{0xfc, byte(i)})
+		timestamp += 960
+	}
+	waitFrames(t, recorder, 20)
+
+	require.NoError(t, recorder.Close())
+	<-onClose
+	assert.True(t, recorder.Closed())
+	assert.True(t, recorder.Consumer().Closed())
+
+	pages := parseOgg(t, output.Bytes())
+	require.Len(t, pages, 22)
+
+	assert.Equal(t, byte(oggHeaderTypeBOS), pages[0].headerType)
+	assert.Equal(t, "OpusHead", string(pages[0].packet[:8]))
+	assert.Equal(t, byte(2), pages[0].packet[9])
+	assert.Equal(t, "OpusTags", string(pages[1].packet[:8]))
+
+	for i, page := range pages[2:] {
+		assert.Equal(t, []byte{0xfc, byte(i)}, page.packet)
+		assert.EqualValues(t, (i+1)*960, page.granulePosition)
+	}
+	assert.Equal(t, byte(oggHeaderTypeEOS), pages[21].headerType)
+}
+
+func TestRecorder_IvfWaitsForKeyFrame(t *testing.T) {
+	s := newTestStream(t, workertest.VideoProducerOptions())
+
+	var keyFrameRequests uint32
+	s.process.SetHandler("consumer.requestKeyFrame", func(req workertest.Request) (interface{}, error) {
+		atomic.AddUint32(&keyFrameRequests, 1)
+		return nil, nil
+	})
+
+	path := filepath.Join(t.TempDir(), "video.ivf")
+	recorder := s.record(RecorderOptions{Path: path, KeyFrameRequestInterval: time.Millisecond})
+
+	// Delta frames before the first key frame are dropped.
+	s.sendVp8(0, []byte{0x01, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77})
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint32(&keyFrameRequests) >= 1
+	}, time.Second, 5*time.Millisecond)
+
+	s.sendVp8(3000, vp8KeyFrame())
+	s.sendVp8(6000, []byte{0x01, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa})
+	waitFrames(t, recorder, 2)
+
+	// A lost packet drops the frames until the next key frame.
+	time.Sleep(2 * time.Millisecond)
+	s.sequence++
+	s.send(9000, true, []byte{0x00, 0x77, 0x88, 0x99})
+	s.sendVp8(12000, []byte{0x01, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee})
+
+	assert.Eventually(t, func() bool {
+		return atomic.LoadUint32(&keyFrameRequests) >= 2
+	}, time.Second, 5*time.Millisecond)
+
+	s.sendVp8(15000, vp8KeyFrame())
+	waitFrames(t, recorder, 3)
+
+	require.NoError(t, recorder.Close())
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	require.True(t, len(data) > ivfHeaderSize)
+	assert.Equal(t, "DKIF", string(data[:4]))
+	assert.Equal(t, "VP80", string(data[8:12]))
+	assert.EqualValues(t, 640, binary.LittleEndian.Uint16(data[12:]))
+	assert.EqualValues(t, 480, binary.LittleEndian.Uint16(data[14:]))
+	assert.EqualValues(t, 90000, binary.LittleEndian.Uint32(data[16:]))
+	assert.EqualValues(t, 3, binary.LittleEndian.Uint32(data[24:]))
+
+	frames := data[ivfHeaderSize:]
+	timestamps := []uint64{}
+
+	for len(frames) > 0 {
+		size := binary.LittleEndian.Uint32(frames)
+		timestamps = append(timestamps, binary.LittleEndian.Uint64(frames[4:]))
+		frames = frames[12+size:]
+	}
+	assert.Equal(t, []uint64{0, 3000, 12000}, timestamps)
+}
+
+// ebmlElements returns the ids of the elements in data, descending into the
+// master elements.
+func ebmlElements(t *testing.T, data []byte) (ids []uint32) {
+	masters := map[uint32]bool{ebmlId: true, segmentId: true, tracksId: true, trackEntryId: true, clusterId: true, videoId: true}
+
+	for len(data) > 0 {
+		idLength := 1
+		for data[0]&(0x80>>uint(idLength-1)) == 0 {
+			idLength++
+		}
+		id := uint32(0)
+		for _, b := range data[:idLength] {
+			id = id<<8 | uint32(b)
+		}
+		data = data[idLength:]
+
+		require.Equal(t, byte(0x01), data[0])
+		size := binary.BigEndian.Uint64(data[:8]) & 0x00ffffffffffffff
+		data = data[8:]
+
+		ids = append(ids, id)
+
+		if size == 0x00ffffffffffffff {
+			// Unknown size, the element spans the rest of the data.
+			size = uint64(len(data))
+		}
+		if masters[id] {
+			ids = append(ids, ebmlElements(t, data[:size])...)
+		}
+		data = data[size:]
+	}
+	return
+}
+
+func TestRecorder_WebM(t *testing.T) {
+	s := newTestStream(t, workertest.VideoProducerOptions())
+
+	output := &bytes.Buffer{}
+	recorder := s.record(RecorderOptions{Output: output, Format: Format_WebM})
+
+	s.sendVp8(0, vp8KeyFrame())
+	s.sendVp8(3000, []byte{0x01, 0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77})
+	s.sendVp8(6000, vp8KeyFrame())
+	waitFrames(t, recorder, 3)
+
+	require.NoError(t, recorder.Close())
+
+	data := output.Bytes()
+	assert.Contains(t, string(data), "webm")
+	assert.Contains(t, string(data), "V_VP8")
+
+	count := map[uint32]int{}
+	for _, id := range ebmlElements(t, data) {
+		count[id]++
+	}
+	assert.Equal(t, 1, count[segmentId])
+	assert.Equal(t, 1, count[pixelWidthId])
+	// A cluster starts at every key frame.
+	assert.Equal(t, 2, count[clusterId])
+	assert.Equal(t, 3, count[simpleBlockId])
+}
+
+func TestRecorder_H264(t *testing.T) {
+	options := workertest.VideoProducerOptions()
+	options.RtpParameters.Codecs = []*mediasoup.RtpCodecParameters{
+		{
+			MimeType:    "video/H264",
+			PayloadType: 125,
+			ClockRate:   90000,
+			Parameters: mediasoup.RtpCodecSpecificParameters{
+				RtpParameter: h264.RtpParameter{
+					PacketizationMode: 1,
+					ProfileLevelId:    "42e01f",
+				},
+			},
+		},
+	}
+	s := newTestStream(t, options)
+
+	output := &bytes.Buffer{}
+	recorder := s.record(RecorderOptions{Output: output})
+
+	sps := []byte{0x67, 0x42, 0xe0, 0x1f}
+	pps := []byte{0x68, 0xce, 0x38}
+
+	// STAP-A with SPS and PPS, then an IDR slice in FU-A.
+	stapA := []byte{0x18, 0x00, byte(len(sps))}
+	stapA = append(stapA, sps...)
+	stapA = append(stapA, 0x00, byte(len(pps)))
+	stapA = append(stapA, pps...)
+
+	s.send(0, false, stapA)
+	s.send(0, false, []byte{0x7c, 0x85, 0x01, 0x02})
+	s.send(0, true, []byte{0x7c, 0x45, 0x03, 0x04})
+	s.send(3000, true, []byte{0x41, 0x9a, 0x05})
+	waitFrames(t, recorder, 2)
+
+	require.NoError(t, recorder.Close())
+
+	expected := []byte{0, 0, 0, 1}
+	expected = append(expected, sps...)
+	expected = append(expected, 0, 0, 0, 1)
+	expected = append(expected, pps...)
+	expected = append(expected, 0, 0, 0, 1, 0x65, 0x01, 0x02, 0x03, 0x04)
+	expected = append(expected, 0, 0, 0, 1, 0x41, 0x9a, 0x05)
+
+	assert.Equal(t, expected, output.Bytes())
+}
+
+func TestRecorder_PlainTransport(t *testing.T) {
+	s := newTestStream(t, workertest.AudioProducerOptions())
+
+	output := &bytes.Buffer{}
+	recorder := s.record(RecorderOptions{Output: output, Transport: RecorderTransport_Plain})
+
+	transport, ok := recorder.Transport().(*mediasoup.PlainTransport)
+	require.True(t, ok)
+
+	tuple := transport.Tuple()
+	require.NotNil(t, tuple)
+
+	conn, err := net.Dial("udp", net.JoinHostPort(tuple.RemoteIp, strconv.Itoa(int(tuple.RemotePort))))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// RTCP is ignored.
+	conn.Write([]byte{0x80, 200, 0, 0})
+
+	for i := 0; i < 3; i++ {
+		packet := &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				SequenceNumber: uint16(i),
+				Timestamp:      uint32(i * 960),
+			},
+			Payload: []byte{0xfc},
+		}
+		data, _ := packet.Marshal()
+		conn.Write(data)
+	}
+	waitFrames(t, recorder, 3)
+
+	require.NoError(t, recorder.Close())
+	assert.True(t, transport.Closed())
+}
+
+func TestRecorder_ClosedWithProducer(t *testing.T) {
+	s := newTestStream(t, workertest.AudioProducerOptions())
+
+	path := filepath.Join(t.TempDir(), "audio.ogg")
+	recorder := s.record(RecorderOptions{Path: path})
+
+	s.producer.Close()
+
+	assert.Eventually(t, recorder.Closed, time.Second, 5*time.Millisecond)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size())
+}
+
+func TestRecorder_TypeError(t *testing.T) {
+	s := newTestStream(t, workertest.VideoProducerOptions())
+
+	_, err := NewRecorder(s.router, RecorderOptions{ProducerId: s.producer.Id()})
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+
+	_, err = NewRecorder(s.router, RecorderOptions{
+		ProducerId: s.producer.Id(),
+		Output:     &bytes.Buffer{},
+		Format:     Format_Ogg,
+	})
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+
+	_, err = NewRecorder(s.router, RecorderOptions{
+		ProducerId: "unknown",
+		Output:     &bytes.Buffer{},
+	})
+	assert.Error(t, err)
+}
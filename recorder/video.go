@@ -0,0 +1,101 @@
+package recorder
+
+import "encoding/binary"
+
+// videoSize returns the size of the video read from a key frame, or zeros if
+// it cannot be found.
+func videoSize(fourcc string, keyFrame []byte) (width, height uint16) {
+	switch fourcc {
+	case "VP80":
+		return vp8Size(keyFrame)
+	case "VP90":
+		return vp9Size(keyFrame)
+	}
+	return
+}
+
+// vp8Size reads the size of a VP8 key frame (RFC 6386, section 9.1).
+func vp8Size(frame []byte) (width, height uint16) {
+	if len(frame) < 10 || frame[0]&0x01 != 0 ||
+		frame[3] != 0x9d || frame[4] != 0x01 || frame[5] != 0x2a {
+		return
+	}
+
+	width = binary.LittleEndian.Uint16(frame[6:]) & 0x3fff
+	height = binary.LittleEndian.Uint16(frame[8:]) & 0x3fff
+
+	return
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) (value uint32, ok bool) {
+	for i := 0; i < n; i++ {
+		if r.pos >= len(r.data)*8 {
+			return 0, false
+		}
+		bit := r.data[r.pos/8] >> (7 - uint(r.pos%8)) & 0x01
+		value = value<<1 | uint32(bit)
+		r.pos++
+	}
+	return value, true
+}
+
+// vp9Size reads the size in the uncompressed header of a VP9 key frame.
+func vp9Size(frame []byte) (width, height uint16) {
+	r := &bitReader{data: frame}
+
+	if marker, _ := r.read(2); marker != 2 {
+		return
+	}
+	low, _ := r.read(1)
+	high, _ := r.read(1)
+	profile := high<<1 | low
+
+	if profile == 3 {
+		r.read(1)
+	}
+	// show_existing_frame
+	if existing, _ := r.read(1); existing == 1 {
+		return
+	}
+	// frame_type, 0 is a key frame.
+	if frameType, _ := r.read(1); frameType != 0 {
+		return
+	}
+	// show_frame, error_resilient_mode
+	r.read(2)
+
+	if syncCode, _ := r.read(24); syncCode != 0x498342 {
+		return
+	}
+
+	// color_config
+	if profile >= 2 {
+		r.read(1)
+	}
+	colorSpace, _ := r.read(3)
+
+	if colorSpace != 7 {
+		r.read(1)
+		if profile == 1 || profile == 3 {
+			r.read(3)
+		}
+	} else if profile == 1 || profile == 3 {
+		r.read(1)
+	}
+
+	w, ok := r.read(16)
+	if !ok {
+		return
+	}
+	h, ok := r.read(16)
+	if !ok {
+		return
+	}
+
+	return uint16(w + 1), uint16(h + 1)
+}
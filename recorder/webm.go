@@ -0,0 +1,255 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand"
+)
+
+// EBML element ids used by the WebM writer.
+const (
+	ebmlId               = 0x1a45dfa3
+	ebmlVersionId        = 0x4286
+	ebmlReadVersionId    = 0x42f7
+	ebmlMaxIdLengthId    = 0x42f2
+	ebmlMaxSizeLengthId  = 0x42f3
+	docTypeId            = 0x4282
+	docTypeVersionId     = 0x4287
+	docTypeReadVersionId = 0x4285
+	segmentId            = 0x18538067
+	infoId               = 0x1549a966
+	timecodeScaleId      = 0x2ad7b1
+	muxingAppId          = 0x4d80
+	writingAppId         = 0x5741
+	tracksId             = 0x1654ae6b
+	trackEntryId         = 0xae
+	trackNumberId        = 0xd7
+	trackUidId           = 0x73c5
+	trackTypeId          = 0x83
+	codecIdId            = 0x86
+	codecPrivateId       = 0x63a2
+	seekPreRollId        = 0x56bb
+	videoId              = 0xe0
+	pixelWidthId         = 0xb0
+	pixelHeightId        = 0xba
+	audioId              = 0xe1
+	samplingFrequencyId  = 0xb5
+	channelsId           = 0x9f
+	clusterId            = 0x1f43b675
+	timecodeId           = 0xe7
+	simpleBlockId        = 0xa3
+)
+
+const (
+	webmMaxClusterDuration = 5000
+	webmMaxClusterSize     = 5 << 20
+)
+
+func ebmlElement(id uint32, payload ...[]byte) []byte {
+	size := 0
+	for _, p := range payload {
+		size += len(p)
+	}
+
+	b := ebmlIdBytes(id)
+	b = append(b, ebmlSize(uint64(size))...)
+
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+
+	return b
+}
+
+func ebmlIdBytes(id uint32) []byte {
+	switch {
+	case id > 0xffffff:
+		return []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xffff:
+		return []byte{byte(id >> 16), byte(id >> 8), byte(id)}
+	case id > 0xff:
+		return []byte{byte(id >> 8), byte(id)}
+	}
+	return []byte{byte(id)}
+}
+
+// ebmlSize encodes a size as an 8 bytes variable size integer.
+func ebmlSize(size uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, size|0x01<<56)
+	return b
+}
+
+func ebmlUint(id uint32, value uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, value)
+
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+
+	return ebmlElement(id, b)
+}
+
+func ebmlFloat(id uint32, value float64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, math.Float64bits(value))
+
+	return ebmlElement(id, b)
+}
+
+func ebmlString(id uint32, value string) []byte {
+	return ebmlElement(id, []byte(value))
+}
+
+// webmWriter writes a single track WebM file. The segment has an unknown size
+// so that the file can be written as a stream, clusters are buffered and
+// written with their size.
+type webmWriter struct {
+	w         io.Writer
+	codecId   string
+	fourcc    string
+	audio     bool
+	clockRate int
+	channels  int
+	started   bool
+	cluster   [][]byte
+	// Cluster timecode in milliseconds.
+	clusterTimecode int64
+	clusterSize     int
+}
+
+func newWebmWriter(w io.Writer, codecId, fourcc string, audio bool, clockRate, channels int) *webmWriter {
+	if channels <= 0 {
+		channels = 2
+	}
+	return &webmWriter{
+		w:         w,
+		codecId:   codecId,
+		fourcc:    fourcc,
+		audio:     audio,
+		clockRate: clockRate,
+		channels:  channels,
+	}
+}
+
+func (m *webmWriter) writeHeader(keyFrame []byte) error {
+	header := ebmlElement(ebmlId,
+		ebmlUint(ebmlVersionId, 1),
+		ebmlUint(ebmlReadVersionId, 1),
+		ebmlUint(ebmlMaxIdLengthId, 4),
+		ebmlUint(ebmlMaxSizeLengthId, 8),
+		ebmlString(docTypeId, "webm"),
+		ebmlUint(docTypeVersionId, 4),
+		ebmlUint(docTypeReadVersionId, 2),
+	)
+
+	// Segment of unknown size.
+	header = append(header, ebmlIdBytes(segmentId)...)
+	header = append(header, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+
+	header = append(header, ebmlElement(infoId,
+		ebmlUint(timecodeScaleId, 1000000),
+		ebmlString(muxingAppId, vendor),
+		ebmlString(writingAppId, vendor),
+	)...)
+
+	track := [][]byte{
+		ebmlUint(trackNumberId, 1),
+		ebmlUint(trackUidId, uint64(rand.Uint32())+1),
+		ebmlString(codecIdId, m.codecId),
+	}
+
+	if m.audio {
+		track = append(track,
+			ebmlUint(trackTypeId, 2),
+			ebmlElement(codecPrivateId, opusHead(m.channels, m.clockRate)),
+			ebmlUint(seekPreRollId, 80000000),
+			ebmlElement(audioId,
+				ebmlFloat(samplingFrequencyId, float64(m.clockRate)),
+				ebmlUint(channelsId, uint64(m.channels)),
+			),
+		)
+	} else {
+		width, height := videoSize(m.fourcc, keyFrame)
+
+		track = append(track,
+			ebmlUint(trackTypeId, 1),
+			ebmlElement(videoId,
+				ebmlUint(pixelWidthId, uint64(width)),
+				ebmlUint(pixelHeightId, uint64(height)),
+			),
+		)
+	}
+
+	header = append(header, ebmlElement(tracksId, ebmlElement(trackEntryId, track...))...)
+
+	_, err := m.w.Write(header)
+
+	return err
+}
+
+func (m *webmWriter) writeFrame(f *frame) error {
+	if !m.started {
+		if err := m.writeHeader(f.data); err != nil {
+			return err
+		}
+		m.started = true
+	}
+
+	timecode := f.timestamp * 1000 / int64(m.clockRate)
+
+	if len(m.cluster) > 0 &&
+		((!m.audio && f.keyFrame) ||
+			timecode-m.clusterTimecode >= webmMaxClusterDuration ||
+			timecode < m.clusterTimecode ||
+			m.clusterSize >= webmMaxClusterSize) {
+		if err := m.flushCluster(); err != nil {
+			return err
+		}
+	}
+
+	if len(m.cluster) == 0 {
+		m.clusterTimecode = timecode
+		m.cluster = append(m.cluster, ebmlUint(timecodeId, uint64(timecode)))
+	}
+
+	block := []byte{0x81, 0, 0, 0}
+	binary.BigEndian.PutUint16(block[1:], uint16(int16(timecode-m.clusterTimecode)))
+
+	if f.keyFrame {
+		block[3] = 0x80
+	}
+	block = append(block, f.data...)
+	block = ebmlElement(simpleBlockId, block)
+
+	m.cluster = append(m.cluster, block)
+	m.clusterSize += len(block)
+
+	return nil
+}
+
+func (m *webmWriter) flushCluster() error {
+	cluster := ebmlElement(clusterId, m.cluster...)
+
+	m.cluster = nil
+	m.clusterSize = 0
+
+	_, err := m.w.Write(cluster)
+
+	return err
+}
+
+func (m *webmWriter) close() error {
+	if !m.started {
+		if err := m.writeHeader(nil); err != nil {
+			return err
+		}
+	}
+	if len(m.cluster) == 0 {
+		return nil
+	}
+
+	return m.flushCluster()
+}
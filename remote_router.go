@@ -0,0 +1,290 @@
+package mediasoup
+
+import (
+	"context"
+)
+
+/**
+ * IRemoteRouter is a Router living in another process or host, which
+ * PipeToRouter pipes producers and data producers to. The remoterouter
+ * package provides an implementation over HTTP.
+ *
+ * The methods act on the remote Router and on the objects created in it by
+ * these methods.
+ */
+type IRemoteRouter interface {
+	/**
+	 * Router id.
+	 */
+	Id() string
+
+	/**
+	 * Create a PipeTransport in the remote Router. It listens on an IP
+	 * chosen by the remote side, options.ListenIp being left out.
+	 */
+	CreatePipeTransport(ctx context.Context, options PipeTransportOptions) (RemotePipeTransport, error)
+
+	/**
+	 * Connect a remote PipeTransport to the given local tuple.
+	 */
+	ConnectPipeTransport(ctx context.Context, transportId string, options TransportConnectOptions) error
+
+	/**
+	 * Close a remote PipeTransport.
+	 */
+	ClosePipeTransport(ctx context.Context, transportId string) error
+
+	/**
+	 * Create a pipe Producer in a remote PipeTransport.
+	 */
+	Produce(ctx context.Context, transportId string, options ProducerOptions) error
+
+	/**
+	 * Create a pipe DataProducer in a remote PipeTransport.
+	 */
+	ProduceData(ctx context.Context, transportId string, options DataProducerOptions) error
+
+	/**
+	 * Pause a remote pipe Producer.
+	 */
+	PauseProducer(ctx context.Context, producerId string) error
+
+	/**
+	 * Resume a remote pipe Producer.
+	 */
+	ResumeProducer(ctx context.Context, producerId string) error
+
+	/**
+	 * Close a remote pipe Producer.
+	 */
+	CloseProducer(ctx context.Context, producerId string) error
+
+	/**
+	 * Close a remote pipe DataProducer.
+	 */
+	CloseDataProducer(ctx context.Context, dataProducerId string) error
+
+	/**
+	 * Observer emits the closing of the remote objects, whatever the cause
+	 * (e.g. the remote Router was closed or the connection to it was lost).
+	 *
+	 * @emits transportclose - (transportId string)
+	 * @emits producerclose - (producerId string)
+	 * @emits dataproducerclose - (dataProducerId string)
+	 */
+	Observer() IEventEmitter
+}
+
+/**
+ * RemotePipeTransport describes a PipeTransport created in a remote Router.
+ */
+type RemotePipeTransport struct {
+	Id             string          `json:"id"`
+	Tuple          TransportTuple  `json:"tuple"`
+	SrtpParameters *SrtpParameters `json:"srtpParameters,omitempty"`
+}
+
+// createRemotePipeTransportPair creates and connects a local and a remote
+// PipeTransport, which are closed together. router.locker must be held.
+func (router *Router) createRemotePipeTransportPair(ctx context.Context, options *PipeToRouterOptions) (pair *pipeTransportPair, err error) {
+	remoteRouter := options.RemoteRouter
+
+	var localPipeTransport *PipeTransport
+	var remotePipeTransport RemotePipeTransport
+
+	defer func() {
+		if err != nil {
+			router.logger.Error("pipeToRouter() | error creating remote PipeTransport pair:%s", err)
+
+			if localPipeTransport != nil {
+				localPipeTransport.Close()
+			}
+			if len(remotePipeTransport.Id) > 0 {
+				remoteRouter.ClosePipeTransport(context.Background(), remotePipeTransport.Id)
+			}
+		}
+	}()
+
+	option := PipeTransportOptions{
+		ListenIp:       options.ListenIp,
+		EnableSctp:     options.EnableSctp,
+		NumSctpStreams: options.NumSctpStreams,
+		EnableRtx:      options.EnableRtx,
+		EnableSrtp:     options.EnableSrtp,
+	}
+	localPipeTransport, err = router.CreatePipeTransportContext(ctx, option)
+	if err != nil {
+		return
+	}
+	// The local IP is meaningless to the remote host.
+	option.ListenIp = TransportListenIp{}
+
+	remotePipeTransport, err = remoteRouter.CreatePipeTransport(ctx, option)
+	if err != nil {
+		return
+	}
+
+	err = localPipeTransport.ConnectContext(ctx, TransportConnectOptions{
+		Ip:             remotePipeTransport.Tuple.LocalIp,
+		Port:           remotePipeTransport.Tuple.LocalPort,
+		SrtpParameters: remotePipeTransport.SrtpParameters,
+	})
+	if err != nil {
+		return
+	}
+	err = remoteRouter.ConnectPipeTransport(ctx, remotePipeTransport.Id, TransportConnectOptions{
+		Ip:             localPipeTransport.Tuple().LocalIp,
+		Port:           localPipeTransport.Tuple().LocalPort,
+		SrtpParameters: localPipeTransport.SrtpParameters(),
+	})
+	if err != nil {
+		return
+	}
+
+	pair = newPipeTransportPair(localPipeTransport)
+	pair.remoteRouter = remoteRouter
+	pair.remoteRouterPipeTransport = remotePipeTransport
+
+	unsubscribe := subscribe(remoteRouter.Observer(), "transportclose", func(transportId string) {
+		if transportId == remotePipeTransport.Id {
+			localPipeTransport.Close()
+		}
+	})
+
+	localPipeTransport.Observer().On("close", func() {
+		unsubscribe()
+		router.deletePipeTransportPair(pair)
+
+		if err := remoteRouter.ClosePipeTransport(context.Background(), remotePipeTransport.Id); err != nil {
+			router.logger.Debug("pipeToRouter() | closing remote PipeTransport failed: %s", err)
+		}
+	})
+
+	router.mapRouterPipeTransports.Store(remoteRouter, pair)
+
+	return
+}
+
+func (router *Router) pipeProducerToRemoteRouter(ctx context.Context, remoteRouter IRemoteRouter,
+	pair *pipeTransportPair, producer *Producer) (result *PipeToRouterResult, err error) {
+	pipeConsumer, err := pair.localPipeTransport.ConsumeContext(ctx, ConsumerOptions{
+		ProducerId: producer.Id(),
+	})
+	if err != nil {
+		router.logger.Error("pipeToRouter() | error creating pipe Consumer:%s", err)
+		return
+	}
+
+	// Subscribe before producing, the remote pipe Producer may be closed
+	// before Produce returns.
+	unsubscribe := subscribe(remoteRouter.Observer(), "producerclose", func(producerId string) {
+		if producerId == producer.Id() {
+			pipeConsumer.Close()
+		}
+	})
+
+	defer func() {
+		if err != nil {
+			router.logger.Error("pipeToRouter() | error creating remote pipe Producer:%s", err)
+
+			unsubscribe()
+			pipeConsumer.Close()
+		}
+	}()
+
+	err = remoteRouter.Produce(ctx, pair.remoteRouterPipeTransport.Id, ProducerOptions{
+		Id:            producer.Id(),
+		Kind:          pipeConsumer.Kind(),
+		RtpParameters: pipeConsumer.RtpParameters(),
+		Paused:        pipeConsumer.ProducerPaused(),
+		AppData:       producer.AppData(),
+	})
+	if err != nil {
+		return
+	}
+
+	// Pipe events from the pipe Consumer to the remote pipe Producer.
+	pipeConsumer.Observer().On("close", func() {
+		unsubscribe()
+
+		// The remote pipe Producer is closed with its PipeTransport.
+		if pair.localPipeTransport.Closed() {
+			return
+		}
+		if err := remoteRouter.CloseProducer(context.Background(), producer.Id()); err != nil {
+			router.logger.Debug("pipeToRouter() | closing remote pipe Producer failed: %s", err)
+		}
+	})
+	pipeConsumer.Observer().On("pause", func() {
+		if err := remoteRouter.PauseProducer(context.Background(), producer.Id()); err != nil {
+			router.logger.Warn("pipeToRouter() | pausing remote pipe Producer failed: %s", err)
+		}
+	})
+	pipeConsumer.Observer().On("resume", func() {
+		if err := remoteRouter.ResumeProducer(context.Background(), producer.Id()); err != nil {
+			router.logger.Warn("pipeToRouter() | resuming remote pipe Producer failed: %s", err)
+		}
+	})
+
+	result = &PipeToRouterResult{
+		PipeConsumer: pipeConsumer,
+	}
+
+	return
+}
+
+func (router *Router) pipeDataProducerToRemoteRouter(ctx context.Context, remoteRouter IRemoteRouter,
+	pair *pipeTransportPair, dataProducer *DataProducer) (result *PipeToRouterResult, err error) {
+	pipeDataConsumer, err := pair.localPipeTransport.ConsumeDataContext(ctx, DataConsumerOptions{
+		DataProducerId: dataProducer.Id(),
+	})
+	if err != nil {
+		router.logger.Error("pipeToRouter() | error creating pipe DataConsumer:%s", err)
+		return
+	}
+
+	unsubscribe := subscribe(remoteRouter.Observer(), "dataproducerclose", func(dataProducerId string) {
+		if dataProducerId == dataProducer.Id() {
+			pipeDataConsumer.Close()
+		}
+	})
+
+	defer func() {
+		if err != nil {
+			router.logger.Error("pipeToRouter() | error creating remote pipe DataProducer:%s", err)
+
+			unsubscribe()
+			pipeDataConsumer.Close()
+		}
+	}()
+
+	err = remoteRouter.ProduceData(ctx, pair.remoteRouterPipeTransport.Id, DataProducerOptions{
+		Id:                   dataProducer.Id(),
+		SctpStreamParameters: pipeDataConsumer.SctpStreamParameters(),
+		Label:                pipeDataConsumer.Label(),
+		Protocol:             pipeDataConsumer.Protocol(),
+		AppData:              dataProducer.AppData(),
+	})
+	if err != nil {
+		return
+	}
+
+	// Pipe events from the pipe DataConsumer to the remote pipe DataProducer.
+	pipeDataConsumer.Observer().On("close", func() {
+		unsubscribe()
+
+		// The remote pipe DataProducer is closed with its PipeTransport.
+		if pair.localPipeTransport.Closed() {
+			return
+		}
+		if err := remoteRouter.CloseDataProducer(context.Background(), dataProducer.Id()); err != nil {
+			router.logger.Debug("pipeToRouter() | closing remote pipe DataProducer failed: %s", err)
+		}
+	})
+
+	result = &PipeToRouterResult{
+		PipeDataConsumer: pipeDataConsumer,
+	}
+
+	return
+}
@@ -0,0 +1,359 @@
+package remoterouter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+type ClientOptions struct {
+	/**
+	 * HTTP client sending the requests. It must not have a Timeout, which
+	 * would end the event stream, the contexts given to the methods bound
+	 * the requests instead. Default http.DefaultClient.
+	 */
+	HttpClient *http.Client
+
+	/**
+	 * Header added to every HTTP request, e.g. the credentials checked by
+	 * ServerOptions.Authorize.
+	 */
+	Header http.Header
+}
+
+/**
+ * Client is a mediasoup.IRemoteRouter talking to a Server.
+ *
+ * The Client is closed when the event stream of the Server ends, because the
+ * remote Router was closed or the connection was lost. Its remote
+ * PipeTransports are then closed by the Server, and so are the local
+ * PipeTransports piping to them.
+ *
+ * @emits close
+ */
+type Client struct {
+	mediasoup.IEventEmitter
+	logger     mediasoup.Logger
+	url        string
+	id         string
+	sessionId  string
+	httpClient *http.Client
+	header     http.Header
+	observer   mediasoup.IEventEmitter
+	transports map[string]struct{}
+	cancel     context.CancelFunc
+	closed     uint32
+	locker     sync.Mutex
+}
+
+/**
+ * Dial connects to the Server at the given URL and opens its session, the
+ * event stream.
+ */
+func Dial(ctx context.Context, url string, options ClientOptions) (client *Client, err error) {
+	if options.HttpClient == nil {
+		options.HttpClient = http.DefaultClient
+	}
+
+	client = &Client{
+		IEventEmitter: mediasoup.NewEventEmitter(),
+		logger:        mediasoup.NewLogger("RemoteRouterClient"),
+		url:           strings.TrimSuffix(url, "/"),
+		httpClient:    options.HttpClient,
+		header:        options.Header,
+		observer:      mediasoup.NewEventEmitter(),
+		transports:    make(map[string]struct{}),
+	}
+
+	// The event stream outlives ctx.
+	streamCtx, cancel := context.WithCancel(context.Background())
+	client.cancel = cancel
+
+	rsp, decoder, err := client.openEvents(ctx, streamCtx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	var info routerInfo
+
+	if err = client.request(ctx, "router.getInfo", internal{}, nil, &info); err != nil {
+		rsp.Body.Close()
+		cancel()
+		return nil, err
+	}
+	client.id = info.Id
+
+	client.logger.Debug("dial() [url:%s, routerId:%s, sessionId:%s]", client.url, client.id, client.sessionId)
+
+	go client.readEvents(rsp, decoder)
+
+	return client, nil
+}
+
+// openEvents opens the event stream within streamCtx and reads its session
+// object, waiting for ctx at most.
+func (c *Client) openEvents(ctx, streamCtx context.Context) (*http.Response, *json.Decoder, error) {
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, c.url+"/events", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.setHeader(req)
+
+	type result struct {
+		rsp     *http.Response
+		decoder *json.Decoder
+		err     error
+	}
+	resultCh := make(chan result, 1)
+
+	go func() {
+		rsp, err := c.httpClient.Do(req)
+		if err != nil {
+			resultCh <- result{err: err}
+			return
+		}
+		if rsp.StatusCode != http.StatusOK {
+			rsp.Body.Close()
+			resultCh <- result{err: fmt.Errorf("opening event stream failed: %s", rsp.Status)}
+			return
+		}
+
+		decoder := json.NewDecoder(rsp.Body)
+
+		var session notification
+
+		if err = decoder.Decode(&session); err == nil && session.Event != "session" {
+			err = fmt.Errorf("unexpected event %q opening event stream", session.Event)
+		}
+		if err != nil {
+			rsp.Body.Close()
+			resultCh <- result{err: err}
+			return
+		}
+		c.sessionId = session.TargetId
+
+		resultCh <- result{rsp, decoder, nil}
+	}()
+
+	select {
+	case result := <-resultCh:
+		return result.rsp, result.decoder, result.err
+
+	case <-ctx.Done():
+		go func() {
+			if result := <-resultCh; result.rsp != nil {
+				result.rsp.Body.Close()
+			}
+		}()
+
+		return nil, nil, ctx.Err()
+	}
+}
+
+func (c *Client) setHeader(req *http.Request) {
+	for key, values := range c.header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if len(c.sessionId) > 0 {
+		req.Header.Set(sessionHeader, c.sessionId)
+	}
+}
+
+/**
+ * Id of the remote Router.
+ */
+func (c *Client) Id() string {
+	return c.id
+}
+
+/**
+ * Observer.
+ *
+ * @emits transportclose - (transportId string)
+ * @emits producerclose - (producerId string)
+ * @emits dataproducerclose - (dataProducerId string)
+ */
+func (c *Client) Observer() mediasoup.IEventEmitter {
+	return c.observer
+}
+
+/**
+ * Whether the Client is closed.
+ */
+func (c *Client) Closed() bool {
+	return atomic.LoadUint32(&c.closed) > 0
+}
+
+/**
+ * Close ends the event stream, which closes the remote PipeTransports and the
+ * objects created on them.
+ */
+func (c *Client) Close() {
+	if !atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		return
+	}
+
+	c.logger.Debug("close()")
+
+	c.cancel()
+
+	c.locker.Lock()
+	transports := c.transports
+	c.transports = make(map[string]struct{})
+	c.locker.Unlock()
+
+	for transportId := range transports {
+		c.observer.SafeEmit("transportclose", transportId)
+	}
+
+	c.SafeEmit("close")
+}
+
+func (c *Client) CreatePipeTransport(ctx context.Context, options mediasoup.PipeTransportOptions) (transport mediasoup.RemotePipeTransport, err error) {
+	if err = c.request(ctx, "router.createPipeTransport", internal{}, options, &transport); err != nil {
+		return
+	}
+
+	c.locker.Lock()
+	c.transports[transport.Id] = struct{}{}
+	c.locker.Unlock()
+
+	return
+}
+
+func (c *Client) ConnectPipeTransport(ctx context.Context, transportId string, options mediasoup.TransportConnectOptions) error {
+	return c.request(ctx, "transport.connect", internal{TransportId: transportId}, options, nil)
+}
+
+func (c *Client) ClosePipeTransport(ctx context.Context, transportId string) error {
+	return c.request(ctx, "transport.close", internal{TransportId: transportId}, nil, nil)
+}
+
+func (c *Client) Produce(ctx context.Context, transportId string, options mediasoup.ProducerOptions) error {
+	return c.request(ctx, "transport.produce", internal{TransportId: transportId}, options, nil)
+}
+
+func (c *Client) ProduceData(ctx context.Context, transportId string, options mediasoup.DataProducerOptions) error {
+	return c.request(ctx, "transport.produceData", internal{TransportId: transportId}, options, nil)
+}
+
+func (c *Client) PauseProducer(ctx context.Context, producerId string) error {
+	return c.request(ctx, "producer.pause", internal{ProducerId: producerId}, nil, nil)
+}
+
+func (c *Client) ResumeProducer(ctx context.Context, producerId string) error {
+	return c.request(ctx, "producer.resume", internal{ProducerId: producerId}, nil, nil)
+}
+
+func (c *Client) CloseProducer(ctx context.Context, producerId string) error {
+	return c.request(ctx, "producer.close", internal{ProducerId: producerId}, nil, nil)
+}
+
+func (c *Client) CloseDataProducer(ctx context.Context, dataProducerId string) error {
+	return c.request(ctx, "dataProducer.close", internal{DataProducerId: dataProducerId}, nil, nil)
+}
+
+// request sends a request to the Server and decodes the data of its answer
+// into result, if not nil.
+func (c *Client) request(ctx context.Context, method string, internal internal, data, result interface{}) error {
+	if c.Closed() {
+		return mediasoup.NewInvalidStateError("Client closed")
+	}
+
+	c.logger.Debug("request() [method:%s]", method)
+
+	req := struct {
+		Method   string      `json:"method"`
+		Internal interface{} `json:"internal"`
+		Data     interface{} `json:"data,omitempty"`
+	}{method, internal, data}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/request", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	c.setHeader(httpReq)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpRsp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpRsp.Body.Close()
+
+	if httpRsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed [method:%s]: %s", method, httpRsp.Status)
+	}
+
+	var rsp struct {
+		Accepted bool            `json:"accepted"`
+		Data     json.RawMessage `json:"data"`
+		Error    string          `json:"error"`
+		Reason   string          `json:"reason"`
+	}
+	if err = json.NewDecoder(httpRsp.Body).Decode(&rsp); err != nil {
+		return err
+	}
+
+	if !rsp.Accepted {
+		c.logger.Warn("request failed [method:%s]: %s", method, rsp.Reason)
+
+		if rsp.Error == "TypeError" {
+			return mediasoup.NewTypeError("%s", rsp.Reason)
+		}
+		return errors.New(rsp.Reason)
+	}
+
+	if result != nil && len(rsp.Data) > 0 {
+		return json.Unmarshal(rsp.Data, result)
+	}
+
+	return nil
+}
+
+func (c *Client) readEvents(rsp *http.Response, decoder *json.Decoder) {
+	defer rsp.Body.Close()
+
+	for {
+		var notification notification
+
+		if err := decoder.Decode(&notification); err != nil {
+			if !c.Closed() {
+				c.logger.Warn("event stream ended: %s", err)
+				c.Close()
+			}
+			return
+		}
+
+		switch notification.Event {
+		case "transportclose":
+			c.locker.Lock()
+			delete(c.transports, notification.TargetId)
+			c.locker.Unlock()
+
+			c.observer.SafeEmit("transportclose", notification.TargetId)
+
+		case "producerclose", "dataproducerclose":
+			c.observer.SafeEmit(notification.Event, notification.TargetId)
+
+		default:
+			c.logger.Warn("ignoring unknown event %q", notification.Event)
+		}
+	}
+}
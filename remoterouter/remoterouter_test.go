@@ -0,0 +1,506 @@
+package remoterouter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The remote peer is the test binary re-executed with this variable set, so
+// that the Server lives in another process.
+const testPeerEnv = "REMOTEROUTER_TEST_PEER"
+
+const testToken = "Bearer secret"
+
+// testPeerIp is the IP announced by the PipeTransports of the remote peer.
+const testPeerIp = "9.9.9.2"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testPeerEnv) == "1" {
+		// Stdout carries the URL.
+		mediasoup.NewLoggerWriter = func() io.Writer {
+			return zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}
+		}
+
+		if err := runTestPeer(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+type testPeerState struct {
+	RouterId      string                      `json:"routerId"`
+	Transports    int                         `json:"transports"`
+	Producers     map[string]testPeerProducer `json:"producers"`
+	DataProducers map[string]string           `json:"dataProducers"`
+}
+
+type testPeerProducer struct {
+	Kind   mediasoup.MediaKind `json:"kind"`
+	Paused bool                `json:"paused"`
+}
+
+// runTestPeer serves a Router under "/router" and the control of the test
+// under "/test", prints the URL and exits when stdin is closed.
+func runTestPeer() error {
+	worker, err := workertest.NewWorker()
+	if err != nil {
+		return err
+	}
+	defer worker.Close()
+
+	router, err := worker.CreateRouter(mediasoup.RouterOptions{MediaCodecs: workertest.MediaCodecs()})
+	if err != nil {
+		return err
+	}
+
+	server := NewServer(router, ServerOptions{
+		ListenIp: mediasoup.TransportListenIp{Ip: "127.0.0.1", AnnouncedIp: testPeerIp},
+		Authorize: func(r *http.Request) error {
+			if r.Header.Get("Authorization") != testToken {
+				return errors.New("invalid token")
+			}
+			return nil
+		},
+	})
+
+	mux := http.NewServeMux()
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	mux.Handle("/router/", http.StripPrefix("/router", server))
+
+	mux.HandleFunc("/test/state", func(w http.ResponseWriter, r *http.Request) {
+		state := testPeerState{
+			RouterId:      router.Id(),
+			Producers:     map[string]testPeerProducer{},
+			DataProducers: map[string]string{},
+		}
+
+		server.locker.Lock()
+		state.Transports = len(server.transports)
+		for id, producer := range server.producers {
+			state.Producers[id] = testPeerProducer{Kind: producer.Kind(), Paused: producer.Paused()}
+		}
+		for id, dataProducer := range server.dataProducers {
+			state.DataProducers[id] = dataProducer.Label()
+		}
+		server.locker.Unlock()
+
+		json.NewEncoder(w).Encode(state)
+	})
+
+	mux.HandleFunc("/test/closeRouter", func(w http.ResponseWriter, r *http.Request) {
+		router.Close()
+	})
+
+	mux.HandleFunc("/test/closeProducer", func(w http.ResponseWriter, r *http.Request) {
+		producer, err := server.producer(r.URL.Query().Get("id"))
+		if err == nil {
+			err = producer.Close()
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	mux.HandleFunc("/test/consume", func(w http.ResponseWriter, r *http.Request) {
+		transport, err := router.CreateDirectTransport()
+		if err == nil {
+			_, err = transport.Consume(mediasoup.ConsumerOptions{
+				ProducerId:      r.URL.Query().Get("producerId"),
+				RtpCapabilities: router.RtpCapabilities(),
+			})
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+
+	mux.HandleFunc("/test/closeConnections", func(w http.ResponseWriter, r *http.Request) {
+		// Answer first, this connection is closed too.
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		httpServer.CloseClientConnections()
+	})
+
+	fmt.Println(httpServer.URL)
+
+	ioutil.ReadAll(os.Stdin)
+
+	return nil
+}
+
+type testPeers struct {
+	localRouter  *mediasoup.Router
+	url          string
+	client       *Client
+	producer     *mediasoup.Producer
+	dataProducer *mediasoup.DataProducer
+}
+
+func newTestRouter(t *testing.T) *mediasoup.Router {
+	router, _ := workertest.NewRouter(t)
+
+	return router
+}
+
+// startTestPeer runs the remote peer and returns its URL.
+func startTestPeer(t *testing.T) string {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), testPeerEnv+"=1")
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		stdin.Close()
+		cmd.Wait()
+	})
+
+	url, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+
+	return strings.TrimSpace(url)
+}
+
+// newTestPeers creates a local router and dials the router of a remote peer,
+// served over HTTP on localhost by another process.
+func newTestPeers(t *testing.T) *testPeers {
+	peers := &testPeers{
+		localRouter: newTestRouter(t),
+		url:         startTestPeer(t),
+	}
+
+	client, err := Dial(context.Background(), peers.url+"/router", ClientOptions{
+		Header: http.Header{"Authorization": {testToken}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+	peers.client = client
+
+	transport, err := peers.localRouter.CreateDirectTransport()
+	require.NoError(t, err)
+
+	peers.producer, err = transport.Produce(workertest.AudioProducerOptions())
+	require.NoError(t, err)
+
+	peers.dataProducer, err = transport.ProduceData(mediasoup.DataProducerOptions{Label: "chat"})
+	require.NoError(t, err)
+
+	return peers
+}
+
+// control sends a request to the control of the remote peer.
+func (p *testPeers) control(t *testing.T, path string) {
+	rsp, err := http.Post(p.url+"/test/"+path, "", nil)
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+
+	body, _ := ioutil.ReadAll(rsp.Body)
+	require.Equal(t, http.StatusOK, rsp.StatusCode, string(body))
+}
+
+// remoteState returns the state of the remote peer. It doesn't stop the test
+// on failure, so that it can be polled by assert.Eventually.
+func (p *testPeers) remoteState(t *testing.T) (state testPeerState) {
+	rsp, err := http.Get(p.url + "/test/state")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer rsp.Body.Close()
+
+	assert.NoError(t, json.NewDecoder(rsp.Body).Decode(&state))
+
+	return
+}
+
+func (p *testPeers) remoteProducer(t *testing.T) (producer testPeerProducer, ok bool) {
+	producer, ok = p.remoteState(t).Producers[p.producer.Id()]
+	return
+}
+
+func (p *testPeers) remoteTransports(t *testing.T) int {
+	return p.remoteState(t).Transports
+}
+
+func TestDial(t *testing.T) {
+	peers := newTestPeers(t)
+
+	assert.Equal(t, peers.remoteState(t).RouterId, peers.client.Id())
+
+	_, err := Dial(context.Background(), peers.url+"/router/missing", ClientOptions{
+		Header: http.Header{"Authorization": {testToken}},
+	})
+	assert.Error(t, err)
+}
+
+func TestAuthorize(t *testing.T) {
+	peers := newTestPeers(t)
+
+	_, err := Dial(context.Background(), peers.url+"/router", ClientOptions{})
+	assert.EqualError(t, err, "opening event stream failed: 403 Forbidden")
+
+	_, err = Dial(context.Background(), peers.url+"/router", ClientOptions{
+		Header: http.Header{"Authorization": {"Bearer wrong"}},
+	})
+	assert.Error(t, err)
+}
+
+func TestServerRequiresAuthorize(t *testing.T) {
+	server := NewServer(newTestRouter(t), ServerOptions{
+		ListenIp: mediasoup.TransportListenIp{Ip: "127.0.0.1"},
+	})
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	_, err := Dial(context.Background(), httpServer.URL, ClientOptions{})
+	assert.EqualError(t, err, "opening event stream failed: 403 Forbidden")
+}
+
+func TestServerRequiresListenIp(t *testing.T) {
+	router := newTestRouter(t)
+	server := NewServer(router, ServerOptions{
+		Authorize: func(r *http.Request) error { return nil },
+	})
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client, err := Dial(context.Background(), httpServer.URL, ClientOptions{})
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.CreatePipeTransport(context.Background(), mediasoup.PipeTransportOptions{
+		ListenIp: mediasoup.TransportListenIp{Ip: "127.0.0.1"},
+	})
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+	assert.Empty(t, router.Transports())
+}
+
+func TestPipeProducer(t *testing.T) {
+	peers := newTestPeers(t)
+
+	result, err := peers.localRouter.PipeToRouter(mediasoup.PipeToRouterOptions{
+		ProducerId:   peers.producer.Id(),
+		RemoteRouter: peers.client,
+		ListenIp:     mediasoup.TransportListenIp{Ip: "127.0.0.1", AnnouncedIp: "9.9.9.1"},
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result.PipeConsumer)
+	assert.Nil(t, result.PipeProducer)
+
+	// The remote PipeTransport listens on the IP of the Server, not on the
+	// local one.
+	var pipeTransport *mediasoup.PipeTransport
+	for _, transport := range peers.localRouter.Transports() {
+		if transport, ok := transport.(*mediasoup.PipeTransport); ok {
+			pipeTransport = transport
+		}
+	}
+	require.NotNil(t, pipeTransport)
+	assert.Equal(t, "9.9.9.1", pipeTransport.Tuple().LocalIp)
+	assert.Equal(t, testPeerIp, pipeTransport.Tuple().RemoteIp)
+	assert.Equal(t, peers.producer.Id(), result.PipeConsumer.ProducerId())
+	assert.Equal(t, mediasoup.ConsumerType("pipe"), result.PipeConsumer.Type())
+
+	remoteProducer, ok := peers.remoteProducer(t)
+	require.True(t, ok)
+	assert.Equal(t, peers.producer.Kind(), remoteProducer.Kind)
+	assert.False(t, remoteProducer.Paused)
+
+	// The remote pipe Producer can be consumed.
+	peers.control(t, "consume?producerId="+peers.producer.Id())
+
+	// The PipeTransport pair is reused.
+	_, err = peers.localRouter.PipeToRouter(mediasoup.PipeToRouterOptions{
+		DataProducerId: peers.dataProducer.Id(),
+		RemoteRouter:   peers.client,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, peers.remoteTransports(t))
+	assert.Equal(t, "chat", peers.remoteState(t).DataProducers[peers.dataProducer.Id()])
+
+	require.NoError(t, peers.producer.Pause())
+	assert.Eventually(t, func() bool {
+		producer, _ := peers.remoteProducer(t)
+		return producer.Paused
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, peers.producer.Resume())
+	assert.Eventually(t, func() bool {
+		producer, _ := peers.remoteProducer(t)
+		return !producer.Paused
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, peers.producer.Close())
+	assert.Eventually(t, func() bool {
+		_, ok := peers.remoteProducer(t)
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, peers.dataProducer.Close())
+	assert.Eventually(t, func() bool {
+		return len(peers.remoteState(t).DataProducers) == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestRemoteClose(t *testing.T) {
+	peers := newTestPeers(t)
+
+	result, err := peers.localRouter.PipeToRouter(mediasoup.PipeToRouterOptions{
+		ProducerId:   peers.producer.Id(),
+		RemoteRouter: peers.client,
+	})
+	require.NoError(t, err)
+
+	// Closing the remote pipe Producer closes the pipe Consumer.
+	peers.control(t, "closeProducer?id="+peers.producer.Id())
+	assert.Eventually(t, result.PipeConsumer.Closed, time.Second, 5*time.Millisecond)
+
+	result, err = peers.localRouter.PipeToRouter(mediasoup.PipeToRouterOptions{
+		ProducerId:   peers.producer.Id(),
+		RemoteRouter: peers.client,
+	})
+	require.NoError(t, err)
+
+	// Closing the remote Router closes the local PipeTransport.
+	peers.control(t, "closeRouter")
+	assert.Eventually(t, result.PipeConsumer.Closed, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, peers.client.Closed, time.Second, 5*time.Millisecond)
+
+	_, err = peers.localRouter.PipeToRouter(mediasoup.PipeToRouterOptions{
+		ProducerId:   peers.producer.Id(),
+		RemoteRouter: peers.client,
+	})
+	assert.EqualError(t, err, "InvalidStateError:Client closed")
+}
+
+func TestLocalClose(t *testing.T) {
+	peers := newTestPeers(t)
+
+	_, err := peers.localRouter.PipeToRouter(mediasoup.PipeToRouterOptions{
+		ProducerId:   peers.producer.Id(),
+		RemoteRouter: peers.client,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, peers.remoteTransports(t))
+
+	// Closing the local Router closes the remote PipeTransport.
+	peers.localRouter.Close()
+	assert.Eventually(t, func() bool { return peers.remoteTransports(t) == 0 }, time.Second, 5*time.Millisecond)
+	assert.False(t, peers.client.Closed())
+}
+
+func TestClientClose(t *testing.T) {
+	peers := newTestPeers(t)
+
+	result, err := peers.localRouter.PipeToRouter(mediasoup.PipeToRouterOptions{
+		ProducerId:   peers.producer.Id(),
+		RemoteRouter: peers.client,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, peers.remoteTransports(t))
+
+	// Ending the event stream closes the remote PipeTransport and its
+	// Producer.
+	peers.client.Close()
+	assert.Eventually(t, func() bool { return peers.remoteTransports(t) == 0 }, time.Second, 5*time.Millisecond)
+	assert.Empty(t, peers.remoteState(t).Producers)
+	assert.Eventually(t, result.PipeConsumer.Closed, time.Second, 5*time.Millisecond)
+}
+
+func TestConnectionLost(t *testing.T) {
+	peers := newTestPeers(t)
+
+	result, err := peers.localRouter.PipeToRouter(mediasoup.PipeToRouterOptions{
+		ProducerId:   peers.producer.Id(),
+		RemoteRouter: peers.client,
+	})
+	require.NoError(t, err)
+
+	peers.control(t, "closeConnections")
+
+	assert.Eventually(t, peers.client.Closed, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, result.PipeConsumer.Closed, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, func() bool { return peers.remoteTransports(t) == 0 }, time.Second, 5*time.Millisecond)
+}
+
+func TestPipeToRouterOptions(t *testing.T) {
+	peers := newTestPeers(t)
+
+	_, err := peers.localRouter.PipeToRouter(mediasoup.PipeToRouterOptions{
+		ProducerId:   peers.producer.Id(),
+		Router:       newTestRouter(t),
+		RemoteRouter: peers.client,
+	})
+	assert.IsType(t, mediasoup.TypeError{}, err)
+
+	// Errors of the remote Router are returned.
+	transport, err := peers.client.CreatePipeTransport(context.Background(), mediasoup.PipeTransportOptions{})
+	require.NoError(t, err)
+	err = peers.client.Produce(context.Background(), transport.Id, mediasoup.ProducerOptions{})
+	assert.IsType(t, mediasoup.TypeError{}, err)
+
+	err = peers.client.PauseProducer(context.Background(), "missing")
+	assert.EqualError(t, err, "Producer not found")
+}
+
+func TestUnpipe(t *testing.T) {
+	peers := newTestPeers(t)
+
+	options := mediasoup.PipeToRouterOptions{
+		ProducerId:   peers.producer.Id(),
+		RemoteRouter: peers.client,
+	}
+
+	result, err := peers.localRouter.PipeToRouter(options)
+	require.NoError(t, err)
+
+	again, err := peers.localRouter.PipeToRouter(options)
+	require.NoError(t, err)
+	assert.Same(t, result, again)
+
+	piped := peers.localRouter.PipedProducers()
+	require.Len(t, piped, 1)
+	assert.Equal(t, peers.client, piped[0].RemoteRouter)
+
+	require.NoError(t, peers.localRouter.UnpipeFromRouter(mediasoup.UnpipeFromRouterOptions{
+		ProducerId:   peers.producer.Id(),
+		RemoteRouter: peers.client,
+	}))
+	assert.True(t, result.PipeConsumer.Closed())
+	assert.Empty(t, peers.localRouter.PipedProducers())
+
+	// The idle PipeTransport pair is closed.
+	assert.Eventually(t, func() bool { return peers.remoteTransports(t) == 0 }, time.Second, 5*time.Millisecond)
+	assert.Eventually(t, func() bool {
+		_, ok := peers.remoteProducer(t)
+		return !ok
+	}, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,510 @@
+// Package remoterouter lets Router.PipeToRouter pipe producers to a Router
+// living in another process or host, over a simple HTTP/JSON protocol.
+//
+// The process owning the target Router serves it with a Server. The process
+// piping its producers dials the Server with Dial, and passes the Client as
+// PipeToRouterOptions.RemoteRouter:
+//
+//	http.Handle("/router/", http.StripPrefix("/router", remoterouter.NewServer(router, remoterouter.ServerOptions{
+//		ListenIp:  mediasoup.TransportListenIp{Ip: "10.0.0.2"},
+//		Authorize: authorize,
+//	})))
+//
+//	client, err := remoterouter.Dial(ctx, "http://10.0.0.2:8080/router", remoterouter.ClientOptions{})
+//	result, err := localRouter.PipeToRouter(mediasoup.PipeToRouterOptions{
+//		ProducerId:   producer.Id(),
+//		RemoteRouter: client,
+//		ListenIp:     mediasoup.TransportListenIp{Ip: "10.0.0.1"},
+//	})
+//
+// Requests are POSTed to "/request" as {method, internal, data} objects and
+// answered with {accepted, data} or {error, reason}, like the requests sent to
+// the workers. The closing of the objects created by the Server is streamed
+// from "/events" as newline-delimited {targetId, event} objects.
+//
+// Each side listens on its own IP: the local PipeTransport on
+// PipeToRouterOptions.ListenIp, the remote one on ServerOptions.ListenIp.
+//
+// The event stream is the session of a Client. It starts with a
+// {targetId, event: "session"} object, whose targetId the Client sends in the
+// X-Session-Id header of its requests. The PipeTransports created in a session
+// are closed when its event stream ends.
+package remoterouter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	maxBodySize   = 1 << 20
+	sessionHeader = "X-Session-Id"
+)
+
+var errNoAuthorize = errors.New("missing ServerOptions.Authorize")
+
+type request struct {
+	Method   string          `json:"method"`
+	Internal internal        `json:"internal"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+type internal struct {
+	TransportId    string `json:"transportId,omitempty"`
+	ProducerId     string `json:"producerId,omitempty"`
+	DataProducerId string `json:"dataProducerId,omitempty"`
+}
+
+type response struct {
+	Accepted bool        `json:"accepted,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Reason   string      `json:"reason,omitempty"`
+}
+
+type notification struct {
+	TargetId string `json:"targetId"`
+	Event    string `json:"event"`
+}
+
+type routerInfo struct {
+	Id string `json:"id"`
+}
+
+type ServerOptions struct {
+	/**
+	 * IP the PipeTransports created by the Clients listen on, which must be
+	 * reachable from them. The ListenIp sent by the Clients is ignored.
+	 * Required.
+	 */
+	ListenIp mediasoup.TransportListenIp
+
+	/**
+	 * Authorize is called with every HTTP request before it is served. An
+	 * error rejects the request with 403 Forbidden, its text being the body
+	 * of the response. Required, all requests are rejected without it.
+	 */
+	Authorize func(r *http.Request) error
+}
+
+// session is the event stream of a Client and the PipeTransports it created.
+type session struct {
+	ch         chan notification
+	transports map[string]*mediasoup.PipeTransport
+}
+
+/**
+ * Server is an http.Handler exposing a Router to the Clients dialing it. The
+ * objects created by a Client live as long as its event stream, unless they
+ * are closed by the Client.
+ */
+type Server struct {
+	logger        mediasoup.Logger
+	options       ServerOptions
+	router        *mediasoup.Router
+	mux           *http.ServeMux
+	transports    map[string]*mediasoup.PipeTransport
+	producers     map[string]*mediasoup.Producer
+	dataProducers map[string]*mediasoup.DataProducer
+	sessions      map[string]*session
+	routerClosed  bool
+	locker        sync.Mutex
+}
+
+func NewServer(router *mediasoup.Router, options ServerOptions) *Server {
+	s := &Server{
+		logger:        mediasoup.NewLogger("RemoteRouterServer"),
+		options:       options,
+		router:        router,
+		mux:           http.NewServeMux(),
+		transports:    make(map[string]*mediasoup.PipeTransport),
+		producers:     make(map[string]*mediasoup.Producer),
+		dataProducers: make(map[string]*mediasoup.DataProducer),
+		sessions:      make(map[string]*session),
+	}
+
+	s.mux.HandleFunc("/request", s.handleRequest)
+	s.mux.HandleFunc("/events", s.handleEvents)
+
+	// The event streams are ended with the Router, which tells the Clients
+	// that all its objects are gone.
+	router.Observer().On("close", func() {
+		s.locker.Lock()
+		defer s.locker.Unlock()
+
+		s.routerClosed = true
+
+		for id, session := range s.sessions {
+			close(session.ch)
+			delete(s.sessions, id)
+		}
+	})
+
+	return s
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	err := errNoAuthorize
+
+	if s.options.Authorize != nil {
+		err = s.options.Authorize(r)
+	}
+	if err != nil {
+		s.logger.Warn("request not authorized [remoteAddr:%s]: %s", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req request
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.logger.Debug("request() [method:%s]", req.Method)
+
+	var rsp response
+
+	data, err := s.handle(r, req)
+	if err != nil {
+		s.logger.Warn("request failed [method:%s]: %s", req.Method, err)
+
+		rsp.Error, rsp.Reason = "Error", err.Error()
+
+		if _, ok := err.(mediasoup.TypeError); ok {
+			rsp.Error = "TypeError"
+		}
+	} else {
+		rsp.Accepted, rsp.Data = true, data
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rsp)
+}
+
+func (s *Server) handle(r *http.Request, req request) (interface{}, error) {
+	ctx := r.Context()
+
+	switch req.Method {
+	case "router.getInfo":
+		return routerInfo{Id: s.router.Id()}, nil
+
+	case "router.createPipeTransport":
+		var options mediasoup.PipeTransportOptions
+
+		if err := unmarshal(req.Data, &options); err != nil {
+			return nil, err
+		}
+		if len(s.options.ListenIp.Ip) == 0 {
+			return nil, mediasoup.NewTypeError("missing ServerOptions.ListenIp")
+		}
+		options.ListenIp = s.options.ListenIp
+
+		sessionId := r.Header.Get(sessionHeader)
+		if !s.hasSession(sessionId) {
+			return nil, errors.New("session not found")
+		}
+		transport, err := s.router.CreatePipeTransportContext(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		if err = s.addTransport(sessionId, transport); err != nil {
+			return nil, err
+		}
+
+		return mediasoup.RemotePipeTransport{
+			Id:             transport.Id(),
+			Tuple:          transport.Tuple(),
+			SrtpParameters: transport.SrtpParameters(),
+		}, nil
+
+	case "transport.connect":
+		var options mediasoup.TransportConnectOptions
+
+		if err := unmarshal(req.Data, &options); err != nil {
+			return nil, err
+		}
+		transport, err := s.transport(req.Internal.TransportId)
+		if err != nil {
+			return nil, err
+		}
+		return nil, transport.ConnectContext(ctx, options)
+
+	case "transport.close":
+		transport, err := s.transport(req.Internal.TransportId)
+		if err != nil {
+			return nil, err
+		}
+		transport.Close()
+
+		return nil, nil
+
+	case "transport.produce":
+		var options mediasoup.ProducerOptions
+
+		if err := unmarshal(req.Data, &options); err != nil {
+			return nil, err
+		}
+		transport, err := s.transport(req.Internal.TransportId)
+		if err != nil {
+			return nil, err
+		}
+		producer, err := transport.ProduceContext(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		s.addProducer(producer)
+
+		return nil, nil
+
+	case "transport.produceData":
+		var options mediasoup.DataProducerOptions
+
+		if err := unmarshal(req.Data, &options); err != nil {
+			return nil, err
+		}
+		transport, err := s.transport(req.Internal.TransportId)
+		if err != nil {
+			return nil, err
+		}
+		dataProducer, err := transport.ProduceDataContext(ctx, options)
+		if err != nil {
+			return nil, err
+		}
+		s.addDataProducer(dataProducer)
+
+		return nil, nil
+
+	case "producer.pause", "producer.resume", "producer.close":
+		producer, err := s.producer(req.Internal.ProducerId)
+		if err != nil {
+			return nil, err
+		}
+		switch req.Method {
+		case "producer.pause":
+			return nil, producer.PauseContext(ctx)
+		case "producer.resume":
+			return nil, producer.ResumeContext(ctx)
+		default:
+			return nil, producer.Close()
+		}
+
+	case "dataProducer.close":
+		dataProducer, err := s.dataProducer(req.Internal.DataProducerId)
+		if err != nil {
+			return nil, err
+		}
+		return nil, dataProducer.Close()
+	}
+
+	return nil, mediasoup.NewTypeError("unknown method '%s'", req.Method)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sessionId := uuid.NewV4().String()
+	session := &session{
+		ch:         make(chan notification, 64),
+		transports: make(map[string]*mediasoup.PipeTransport),
+	}
+
+	s.locker.Lock()
+	if s.routerClosed {
+		s.locker.Unlock()
+		http.Error(w, "Router closed", http.StatusGone)
+		return
+	}
+	s.sessions[sessionId] = session
+	s.locker.Unlock()
+
+	defer s.endSession(sessionId, session)
+
+	s.logger.Debug("events stream opened [remoteAddr:%s, sessionId:%s]", r.RemoteAddr, sessionId)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+
+	if err := encoder.Encode(notification{TargetId: sessionId, Event: "session"}); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case notification, ok := <-session.ch:
+			if !ok {
+				return
+			}
+			if err := encoder.Encode(notification); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// endSession closes the PipeTransports of a session once its event stream has
+// ended, which the Client then considers closed.
+func (s *Server) endSession(sessionId string, session *session) {
+	s.locker.Lock()
+	if s.sessions[sessionId] == session {
+		delete(s.sessions, sessionId)
+	}
+	transports := session.transports
+	session.transports = nil
+	s.locker.Unlock()
+
+	s.logger.Debug("events stream ended [sessionId:%s]", sessionId)
+
+	for _, transport := range transports {
+		transport.Close()
+	}
+}
+
+func (s *Server) hasSession(sessionId string) bool {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	_, ok := s.sessions[sessionId]
+	return ok
+}
+
+// notify sends a notification to the event streams. A stream which doesn't
+// keep up is ended, which closes the PipeTransports of its Client.
+func (s *Server) notify(targetId, event string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	for id, session := range s.sessions {
+		select {
+		case session.ch <- notification{TargetId: targetId, Event: event}:
+		default:
+			s.logger.Warn("events stream full, ending it [sessionId:%s]", id)
+			close(session.ch)
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// addTransport adds a PipeTransport to its session. The PipeTransport is closed
+// if the session has ended meanwhile.
+func (s *Server) addTransport(sessionId string, transport *mediasoup.PipeTransport) error {
+	s.locker.Lock()
+	session, ok := s.sessions[sessionId]
+	if ok {
+		s.transports[transport.Id()] = transport
+		session.transports[transport.Id()] = transport
+	}
+	s.locker.Unlock()
+
+	if !ok {
+		transport.Close()
+		return errors.New("session not found")
+	}
+
+	transport.Observer().On("close", func() {
+		s.locker.Lock()
+		delete(s.transports, transport.Id())
+		delete(session.transports, transport.Id())
+		s.locker.Unlock()
+
+		s.notify(transport.Id(), "transportclose")
+	})
+
+	return nil
+}
+
+func (s *Server) addProducer(producer *mediasoup.Producer) {
+	s.locker.Lock()
+	s.producers[producer.Id()] = producer
+	s.locker.Unlock()
+
+	producer.Observer().On("close", func() {
+		s.locker.Lock()
+		delete(s.producers, producer.Id())
+		s.locker.Unlock()
+
+		s.notify(producer.Id(), "producerclose")
+	})
+}
+
+func (s *Server) addDataProducer(dataProducer *mediasoup.DataProducer) {
+	s.locker.Lock()
+	s.dataProducers[dataProducer.Id()] = dataProducer
+	s.locker.Unlock()
+
+	dataProducer.Observer().On("close", func() {
+		s.locker.Lock()
+		delete(s.dataProducers, dataProducer.Id())
+		s.locker.Unlock()
+
+		s.notify(dataProducer.Id(), "dataproducerclose")
+	})
+}
+
+func (s *Server) transport(id string) (*mediasoup.PipeTransport, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if transport, ok := s.transports[id]; ok {
+		return transport, nil
+	}
+	return nil, errors.New("PipeTransport not found")
+}
+
+func (s *Server) producer(id string) (*mediasoup.Producer, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if producer, ok := s.producers[id]; ok {
+		return producer, nil
+	}
+	return nil, errors.New("Producer not found")
+}
+
+func (s *Server) dataProducer(id string) (*mediasoup.DataProducer, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if dataProducer, ok := s.dataProducers[id]; ok {
+		return dataProducer, nil
+	}
+	return nil, errors.New("DataProducer not found")
+}
+
+func unmarshal(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return mediasoup.NewTypeError("missing data")
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,292 @@
+package room
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+type PeerOptions struct {
+	/**
+	 * RTP capabilities of the peer device. The peer does not consume until
+	 * they are set.
+	 */
+	RtpCapabilities mediasoup.RtpCapabilities
+
+	/**
+	 * Custom application data.
+	 */
+	AppData interface{}
+}
+
+/**
+ * Peer is a participant of a Room.
+ *
+ * @emits close
+ */
+type Peer struct {
+	mediasoup.IEventEmitter
+	id              string
+	room            *Room
+	appData         interface{}
+	rtpCapabilities mediasoup.RtpCapabilities
+	transports      []mediasoup.ITransport
+	recvTransport   mediasoup.ITransport
+	producers       []*mediasoup.Producer
+	consumers       []*mediasoup.Consumer
+	consuming       map[string]bool
+	closed          uint32
+	locker          sync.Mutex
+}
+
+func newPeer(room *Room, id string, options PeerOptions) *Peer {
+	return &Peer{
+		IEventEmitter:   mediasoup.NewEventEmitter(),
+		id:              id,
+		room:            room,
+		appData:         options.AppData,
+		rtpCapabilities: options.RtpCapabilities,
+		consuming:       make(map[string]bool),
+	}
+}
+
+// Peer id
+func (peer *Peer) Id() string {
+	return peer.id
+}
+
+// Room
+func (peer *Peer) Room() *Room {
+	return peer.room
+}
+
+// App custom data.
+func (peer *Peer) AppData() interface{} {
+	return peer.appData
+}
+
+/**
+ * Whether the Peer is closed.
+ */
+func (peer *Peer) Closed() bool {
+	return atomic.LoadUint32(&peer.closed) > 0
+}
+
+// RTP capabilities of the peer device.
+func (peer *Peer) RtpCapabilities() mediasoup.RtpCapabilities {
+	peer.locker.Lock()
+	defer peer.locker.Unlock()
+
+	return peer.rtpCapabilities
+}
+
+/**
+ * SetRtpCapabilities sets the RTP capabilities of the peer device and consumes
+ * the producers of the other peers which can now be consumed.
+ */
+func (peer *Peer) SetRtpCapabilities(rtpCapabilities mediasoup.RtpCapabilities) {
+	peer.locker.Lock()
+	peer.rtpCapabilities = rtpCapabilities
+	peer.locker.Unlock()
+
+	peer.room.consumeAll(peer)
+}
+
+// Transports of the peer.
+func (peer *Peer) Transports() []mediasoup.ITransport {
+	peer.locker.Lock()
+	defer peer.locker.Unlock()
+
+	return append([]mediasoup.ITransport{}, peer.transports...)
+}
+
+// Transport on which the peer consumes.
+func (peer *Peer) RecvTransport() mediasoup.ITransport {
+	peer.locker.Lock()
+	defer peer.locker.Unlock()
+
+	return peer.recvTransport
+}
+
+// Producers of the peer.
+func (peer *Peer) Producers() []*mediasoup.Producer {
+	peer.locker.Lock()
+	defer peer.locker.Unlock()
+
+	return append([]*mediasoup.Producer{}, peer.producers...)
+}
+
+// Consumers of the peer.
+func (peer *Peer) Consumers() []*mediasoup.Consumer {
+	peer.locker.Lock()
+	defer peer.locker.Unlock()
+
+	return append([]*mediasoup.Consumer{}, peer.consumers...)
+}
+
+/**
+ * AddTransport adds a transport of the peer. Producers created on it with
+ * Transport.Produce() are consumed by the other peers.
+ */
+func (peer *Peer) AddTransport(transport mediasoup.ITransport) (err error) {
+	if peer.Closed() {
+		return mediasoup.NewInvalidStateError("Peer closed")
+	}
+
+	peer.locker.Lock()
+
+	for _, t := range peer.transports {
+		if t == transport {
+			peer.locker.Unlock()
+			return
+		}
+	}
+	peer.transports = append(peer.transports, transport)
+
+	peer.locker.Unlock()
+
+	transport.Observer().On("newproducer", func(producer *mediasoup.Producer) {
+		peer.addProducer(producer)
+	})
+	transport.Observer().On("close", func() {
+		peer.removeTransport(transport)
+	})
+
+	return
+}
+
+/**
+ * SetRecvTransport adds a transport of the peer and uses it to consume the
+ * producers of the other peers.
+ */
+func (peer *Peer) SetRecvTransport(transport mediasoup.ITransport) (err error) {
+	if err = peer.AddTransport(transport); err != nil {
+		return
+	}
+
+	peer.locker.Lock()
+	peer.recvTransport = transport
+	peer.locker.Unlock()
+
+	peer.room.consumeAll(peer)
+
+	return
+}
+
+func (peer *Peer) addProducer(producer *mediasoup.Producer) {
+	peer.locker.Lock()
+	peer.producers = append(peer.producers, producer)
+	peer.locker.Unlock()
+
+	producer.Observer().On("close", func() {
+		peer.locker.Lock()
+		for i, p := range peer.producers {
+			if p == producer {
+				peer.producers = append(peer.producers[:i], peer.producers[i+1:]...)
+				break
+			}
+		}
+		peer.locker.Unlock()
+
+		peer.room.SafeEmit("producerclosed", peer, producer)
+	})
+
+	peer.room.SafeEmit("newproducer", peer, producer)
+
+	peer.room.fanOut(peer, producer)
+}
+
+// consume creates a consumer of the given producer on the receive transport,
+// if the peer device can consume it.
+func (peer *Peer) consume(producerPeer *Peer, producer *mediasoup.Producer) {
+	producerId := producer.Id()
+
+	peer.locker.Lock()
+
+	transport, rtpCapabilities := peer.recvTransport, peer.rtpCapabilities
+
+	if peer.Closed() || producer.Closed() || transport == nil ||
+		len(rtpCapabilities.Codecs) == 0 || peer.consuming[producerId] {
+		peer.locker.Unlock()
+		return
+	}
+
+	peer.consuming[producerId] = true
+
+	peer.locker.Unlock()
+
+	logger := peer.room.logger
+
+	if !peer.room.router.CanConsume(producerId, rtpCapabilities) {
+		logger.Debug("peer cannot consume producer [peerId:%s, producerId:%s]", peer.id, producerId)
+
+		peer.locker.Lock()
+		delete(peer.consuming, producerId)
+		peer.locker.Unlock()
+
+		return
+	}
+
+	consumer, err := transport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      producerId,
+		RtpCapabilities: rtpCapabilities,
+		Paused:          peer.room.options.ConsumerPaused,
+		AppData:         mediasoup.H{"peerId": producerPeer.id},
+	})
+	if err != nil {
+		logger.Warn("consume() failed [peerId:%s, producerId:%s]: %s", peer.id, producerId, err)
+
+		peer.locker.Lock()
+		delete(peer.consuming, producerId)
+		peer.locker.Unlock()
+
+		return
+	}
+
+	peer.locker.Lock()
+	peer.consumers = append(peer.consumers, consumer)
+	peer.locker.Unlock()
+
+	consumer.Observer().On("close", func() {
+		peer.locker.Lock()
+		defer peer.locker.Unlock()
+
+		for i, c := range peer.consumers {
+			if c == consumer {
+				peer.consumers = append(peer.consumers[:i], peer.consumers[i+1:]...)
+				break
+			}
+		}
+		delete(peer.consuming, producerId)
+	})
+
+	peer.room.SafeEmit("consumercreated", peer, consumer)
+}
+
+func (peer *Peer) removeTransport(transport mediasoup.ITransport) {
+	peer.locker.Lock()
+	defer peer.locker.Unlock()
+
+	for i, t := range peer.transports {
+		if t == transport {
+			peer.transports = append(peer.transports[:i], peer.transports[i+1:]...)
+			break
+		}
+	}
+	if peer.recvTransport == transport {
+		peer.recvTransport = nil
+	}
+}
+
+func (peer *Peer) close() {
+	if !atomic.CompareAndSwapUint32(&peer.closed, 0, 1) {
+		return
+	}
+
+	for _, transport := range peer.Transports() {
+		transport.Close()
+	}
+
+	peer.SafeEmit("close")
+}
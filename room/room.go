@@ -0,0 +1,196 @@
+// Package room provides a higher level abstraction over mediasoup.Router. A
+// Room keeps track of its peers, their transports, producers and consumers,
+// and makes every peer consume the producers of the other peers.
+package room
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+type RoomOptions struct {
+	/**
+	 * Whether consumers are created in paused mode. Default false.
+	 */
+	ConsumerPaused bool
+}
+
+/**
+ * Room is a set of peers sharing a router.
+ *
+ * @emits peerjoined - (peer: *Peer)
+ * @emits peerleft - (peer: *Peer)
+ * @emits newproducer - (peer: *Peer, producer: *mediasoup.Producer)
+ * @emits producerclosed - (peer: *Peer, producer: *mediasoup.Producer)
+ * @emits consumercreated - (peer: *Peer, consumer: *mediasoup.Consumer)
+ * @emits close
+ */
+type Room struct {
+	mediasoup.IEventEmitter
+	logger  mediasoup.Logger
+	router  *mediasoup.Router
+	options RoomOptions
+	peers   []*Peer
+	closed  uint32
+	locker  sync.Mutex
+}
+
+/**
+ * NewRoom creates a Room on the given router. The Room is closed when the
+ * router is closed, but closing the Room does not close the router.
+ */
+func NewRoom(router *mediasoup.Router, options RoomOptions) *Room {
+	logger := mediasoup.NewLogger("Room")
+
+	logger.Debug("constructor()")
+
+	room := &Room{
+		IEventEmitter: mediasoup.NewEventEmitter(),
+		logger:        logger,
+		router:        router,
+		options:       options,
+	}
+
+	router.Observer().On("close", room.Close)
+
+	return room
+}
+
+// Router
+func (room *Room) Router() *mediasoup.Router {
+	return room.router
+}
+
+/**
+ * Whether the Room is closed.
+ */
+func (room *Room) Closed() bool {
+	return atomic.LoadUint32(&room.closed) > 0
+}
+
+/**
+ * Peers returns the peers in the Room, in joining order.
+ */
+func (room *Room) Peers() []*Peer {
+	room.locker.Lock()
+	defer room.locker.Unlock()
+
+	return append([]*Peer{}, room.peers...)
+}
+
+/**
+ * Peer returns the peer with the given id, or nil.
+ */
+func (room *Room) Peer(peerId string) *Peer {
+	room.locker.Lock()
+	defer room.locker.Unlock()
+
+	for _, peer := range room.peers {
+		if peer.id == peerId {
+			return peer
+		}
+	}
+
+	return nil
+}
+
+/**
+ * Join adds a peer to the Room.
+ */
+func (room *Room) Join(peerId string, options PeerOptions) (peer *Peer, err error) {
+	room.logger.Debug("join() [peerId:%s]", peerId)
+
+	if room.Closed() {
+		err = mediasoup.NewInvalidStateError("Room closed")
+		return
+	}
+
+	room.locker.Lock()
+
+	for _, p := range room.peers {
+		if p.id == peerId {
+			room.locker.Unlock()
+			err = mediasoup.NewTypeError(`peer with id "%s" already joined`, peerId)
+			return
+		}
+	}
+
+	peer = newPeer(room, peerId, options)
+	room.peers = append(room.peers, peer)
+
+	room.locker.Unlock()
+
+	room.SafeEmit("peerjoined", peer)
+
+	return
+}
+
+/**
+ * Leave removes the peer with the given id from the Room and closes its
+ * transports, which closes its producers and the consumers of them.
+ */
+func (room *Room) Leave(peerId string) {
+	room.logger.Debug("leave() [peerId:%s]", peerId)
+
+	room.locker.Lock()
+
+	var peer *Peer
+
+	for i, p := range room.peers {
+		if p.id == peerId {
+			peer = p
+			room.peers = append(room.peers[:i], room.peers[i+1:]...)
+			break
+		}
+	}
+
+	room.locker.Unlock()
+
+	if peer == nil {
+		return
+	}
+
+	peer.close()
+
+	room.SafeEmit("peerleft", peer)
+}
+
+/**
+ * Close the Room, all its peers leave.
+ */
+func (room *Room) Close() {
+	if !atomic.CompareAndSwapUint32(&room.closed, 0, 1) {
+		return
+	}
+
+	room.logger.Debug("close()")
+
+	for _, peer := range room.Peers() {
+		room.Leave(peer.id)
+	}
+
+	room.SafeEmit("close")
+}
+
+// fanOut makes every other peer consume the producer of the given peer.
+func (room *Room) fanOut(producerPeer *Peer, producer *mediasoup.Producer) {
+	for _, peer := range room.Peers() {
+		if peer != producerPeer {
+			peer.consume(producerPeer, producer)
+		}
+	}
+}
+
+// consumeAll makes the given peer consume the producers of every other peer.
+func (room *Room) consumeAll(peer *Peer) {
+	for _, producerPeer := range room.Peers() {
+		if producerPeer == peer {
+			continue
+		}
+		for _, producer := range producerPeer.Producers() {
+			peer.consume(producerPeer, producer)
+		}
+	}
+}
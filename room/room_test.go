@@ -0,0 +1,169 @@
+package room
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createRoom(t *testing.T) *Room {
+	router, _ := workertest.NewRouter(t)
+
+	return NewRoom(router, RoomOptions{})
+}
+
+func createTransport(t *testing.T, room *Room) mediasoup.ITransport {
+	transport, err := room.Router().CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+	})
+	require.NoError(t, err)
+
+	return transport
+}
+
+func joinPeer(t *testing.T, room *Room, peerId string, rtpCapabilities mediasoup.RtpCapabilities) *Peer {
+	peer, err := room.Join(peerId, PeerOptions{RtpCapabilities: rtpCapabilities})
+	require.NoError(t, err)
+	require.NoError(t, peer.AddTransport(createTransport(t, room)))
+	require.NoError(t, peer.SetRecvTransport(createTransport(t, room)))
+
+	return peer
+}
+
+func waitConsumers(t *testing.T, peer *Peer, n int) []*mediasoup.Consumer {
+	assert.Eventually(t, func() bool {
+		return len(peer.Consumers()) == n
+	}, time.Second, 5*time.Millisecond)
+
+	return peer.Consumers()
+}
+
+func TestJoinAndLeave(t *testing.T) {
+	room := createRoom(t)
+
+	joinedCh := make(chan *Peer, 1)
+	room.On("peerjoined", func(peer *Peer) { joinedCh <- peer })
+	leftCh := make(chan *Peer, 1)
+	room.On("peerleft", func(peer *Peer) { leftCh <- peer })
+
+	peer, err := room.Join("alice", PeerOptions{AppData: mediasoup.H{"foo": "bar"}})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", peer.Id())
+	assert.Equal(t, mediasoup.H{"foo": "bar"}, peer.AppData())
+	assert.Equal(t, peer, <-joinedCh)
+	assert.Equal(t, peer, room.Peer("alice"))
+	assert.Equal(t, []*Peer{peer}, room.Peers())
+
+	_, err = room.Join("alice", PeerOptions{})
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+
+	transport := createTransport(t, room)
+	require.NoError(t, peer.AddTransport(transport))
+	assert.Len(t, peer.Transports(), 1)
+
+	room.Leave("alice")
+	assert.Equal(t, peer, <-leftCh)
+	assert.True(t, peer.Closed())
+	assert.True(t, transport.Closed())
+	assert.Nil(t, room.Peer("alice"))
+	assert.Empty(t, room.Peers())
+	assert.IsType(t, mediasoup.NewInvalidStateError(""), peer.AddTransport(createTransport(t, room)))
+}
+
+func TestProducerIsConsumedByOtherPeers(t *testing.T) {
+	room := createRoom(t)
+
+	alice := joinPeer(t, room, "alice", workertest.DeviceRtpCapabilities())
+	bob := joinPeer(t, room, "bob", workertest.DeviceRtpCapabilities())
+	// carol cannot consume anything.
+	carol := joinPeer(t, room, "carol", mediasoup.RtpCapabilities{
+		Codecs: []*mediasoup.RtpCodecCapability{
+			{Kind: "audio", MimeType: "audio/PCMU", PreferredPayloadType: 0, ClockRate: 8000},
+		},
+	})
+
+	newProducerCh := make(chan *mediasoup.Producer, 1)
+	room.On("newproducer", func(peer *Peer, producer *mediasoup.Producer) { newProducerCh <- producer })
+
+	producer, err := alice.Transports()[0].Produce(workertest.AudioProducerOptions())
+	require.NoError(t, err)
+	assert.Equal(t, producer, <-newProducerCh)
+	assert.Equal(t, []*mediasoup.Producer{producer}, alice.Producers())
+
+	consumers := waitConsumers(t, bob, 1)
+	assert.Equal(t, producer.Id(), consumers[0].ProducerId())
+	assert.Equal(t, mediasoup.H{"peerId": "alice"}, consumers[0].AppData())
+	assert.Empty(t, alice.Consumers())
+	assert.Empty(t, carol.Consumers())
+
+	// The producer is closed, so are its consumers.
+	producer.Close()
+
+	waitConsumers(t, bob, 0)
+	assert.True(t, consumers[0].Closed())
+	assert.Empty(t, alice.Producers())
+}
+
+func TestLateJoinerConsumesExistingProducers(t *testing.T) {
+	room := createRoom(t)
+
+	alice := joinPeer(t, room, "alice", workertest.DeviceRtpCapabilities())
+
+	audioProducer, err := alice.Transports()[0].Produce(workertest.AudioProducerOptions())
+	require.NoError(t, err)
+	videoProducer, err := alice.Transports()[0].Produce(workertest.VideoProducerOptions())
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return len(alice.Producers()) == 2 }, time.Second, 5*time.Millisecond)
+
+	createdCh := make(chan *mediasoup.Consumer, 2)
+	room.On("consumercreated", func(peer *Peer, consumer *mediasoup.Consumer) { createdCh <- consumer })
+
+	// bob has no RTP capabilities yet.
+	bob, err := room.Join("bob", PeerOptions{})
+	require.NoError(t, err)
+	require.NoError(t, bob.SetRecvTransport(createTransport(t, room)))
+	assert.Empty(t, bob.Consumers())
+
+	bob.SetRtpCapabilities(workertest.DeviceRtpCapabilities())
+
+	producerIds := []string{(<-createdCh).ProducerId(), (<-createdCh).ProducerId()}
+	assert.ElementsMatch(t, []string{audioProducer.Id(), videoProducer.Id()}, producerIds)
+	assert.Len(t, bob.Consumers(), 2)
+
+	// Setting the capabilities again does not consume twice.
+	bob.SetRtpCapabilities(workertest.DeviceRtpCapabilities())
+	assert.Len(t, bob.Consumers(), 2)
+
+	// alice leaves, her producers are closed and so are bob consumers.
+	room.Leave("alice")
+
+	waitConsumers(t, bob, 0)
+	assert.True(t, audioProducer.Closed())
+}
+
+func TestRoomClosedWithRouter(t *testing.T) {
+	room := createRoom(t)
+
+	peer := joinPeer(t, room, "alice", workertest.DeviceRtpCapabilities())
+
+	onClose := make(chan struct{}, 1)
+	room.On("close", func() { onClose <- struct{}{} })
+
+	room.Router().Close()
+
+	select {
+	case <-onClose:
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	assert.True(t, room.Closed())
+	assert.True(t, peer.Closed())
+
+	_, err := room.Join("bob", PeerOptions{})
+	assert.IsType(t, mediasoup.NewInvalidStateError(""), err)
+}
@@ -1,6 +1,7 @@
 package mediasoup
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"sync/atomic"
@@ -37,7 +38,15 @@ type PipeToRouterOptions struct {
 	Router *Router `json:"router,omitempty"`
 
 	/**
-	 * IP used in the PipeTransport pair. Default '127.0.0.1'.
+	 * Target Router living in another process or host, instead of Router. The
+	 * tuple of the local PipeTransport must be reachable from it, see
+	 * ListenIp.
+	 */
+	RemoteRouter IRemoteRouter `json:"-"`
+
+	/**
+	 * IP used in the PipeTransport pair. Default '127.0.0.1'. With a
+	 * RemoteRouter, only the local PipeTransport listens on it.
 	 */
 	ListenIp TransportListenIp `json:"listenIp,omitempty"`
 
@@ -69,7 +78,8 @@ type PipeToRouterResult struct {
 	PipeConsumer *Consumer
 
 	/**
-	 * The Producer created in the target Router.
+	 * The Producer created in the target Router, nil if the target Router is
+	 * remote.
 	 */
 	PipeProducer *Producer
 
@@ -79,11 +89,146 @@ type PipeToRouterResult struct {
 	PipeDataConsumer *DataConsumer
 
 	/**
-	 * The DataProducer created in the target Router.
+	 * The DataProducer created in the target Router, nil if the target Router
+	 * is remote.
 	 */
 	PipeDataProducer *DataProducer
 }
 
+// closed tells whether the pipe Consumer or DataConsumer is closed.
+func (result *PipeToRouterResult) closed() bool {
+	if result.PipeConsumer != nil {
+		return result.PipeConsumer.Closed()
+	}
+	return result.PipeDataConsumer.Closed()
+}
+
+// close closes the pipe Consumer/Producer or DataConsumer/DataProducer pair.
+func (result *PipeToRouterResult) close() {
+	if result.PipeConsumer != nil {
+		result.PipeConsumer.Close()
+	}
+	if result.PipeProducer != nil {
+		result.PipeProducer.Close()
+	}
+	if result.PipeDataConsumer != nil {
+		result.PipeDataConsumer.Close()
+	}
+	if result.PipeDataProducer != nil {
+		result.PipeDataProducer.Close()
+	}
+}
+
+type UnpipeFromRouterOptions struct {
+	/**
+	 * The id of the piped Producer.
+	 */
+	ProducerId string
+
+	/**
+	 * The id of the piped DataProducer.
+	 */
+	DataProducerId string
+
+	/**
+	 * Target Router instance.
+	 */
+	Router *Router
+
+	/**
+	 * Target remote Router, instead of Router.
+	 */
+	RemoteRouter IRemoteRouter
+}
+
+/**
+ * PipedProducer is a Producer or DataProducer piped into another Router.
+ */
+type PipedProducer struct {
+	/**
+	 * The id of the Producer, empty for a DataProducer.
+	 */
+	ProducerId string
+
+	/**
+	 * The id of the DataProducer, empty for a Producer.
+	 */
+	DataProducerId string
+
+	/**
+	 * Target Router, nil if the target Router is remote.
+	 */
+	Router *Router
+
+	/**
+	 * Target remote Router, nil if the target Router is in this process.
+	 */
+	RemoteRouter IRemoteRouter
+
+	/**
+	 * Result of PipeToRouter.
+	 */
+	Result *PipeToRouterResult
+}
+
+// pipeTransportPair connects the Router to a target Router. It is the value
+// of mapRouterPipeTransports, and is closed once nothing is piped through it.
+type pipeTransportPair struct {
+	router       *Router
+	remoteRouter IRemoteRouter
+	// PipeTransport in the Router.
+	localPipeTransport *PipeTransport
+	// PipeTransport in the target Router, nil if it is remote.
+	remotePipeTransport *PipeTransport
+	// PipeTransport in the remote target Router.
+	remoteRouterPipeTransport RemotePipeTransport
+	// PipeToRouter results by piped Producer and DataProducer id.
+	producers     map[string]*PipeToRouterResult
+	dataProducers map[string]*PipeToRouterResult
+}
+
+func newPipeTransportPair(localPipeTransport *PipeTransport) *pipeTransportPair {
+	return &pipeTransportPair{
+		localPipeTransport: localPipeTransport,
+		producers:          make(map[string]*PipeToRouterResult),
+		dataProducers:      make(map[string]*PipeToRouterResult),
+	}
+}
+
+// destination returns the key of the pair in mapRouterPipeTransports.
+func (pair *pipeTransportPair) destination() interface{} {
+	return pipeDestination(pair.router, pair.remoteRouter)
+}
+
+// idle tells whether nothing is piped through the pair.
+func (pair *pipeTransportPair) idle() bool {
+	return len(pair.producers) == 0 && len(pair.dataProducers) == 0
+}
+
+// closed tells whether a PipeTransport of the pair is closed.
+func (pair *pipeTransportPair) closed() bool {
+	return pair.localPipeTransport.Closed() ||
+		(pair.remotePipeTransport != nil && pair.remotePipeTransport.Closed())
+}
+
+// result returns the result of piping the given Producer or DataProducer
+// through the pair, nil if none.
+func (pair *pipeTransportPair) result(producerId, dataProducerId string) *PipeToRouterResult {
+	if len(producerId) > 0 {
+		return pair.producers[producerId]
+	}
+	return pair.dataProducers[dataProducerId]
+}
+
+// remove forgets the result of piping the given Producer or DataProducer.
+func (pair *pipeTransportPair) remove(producerId, dataProducerId string) {
+	if len(producerId) > 0 {
+		delete(pair.producers, producerId)
+	} else {
+		delete(pair.dataProducers, dataProducerId)
+	}
+}
+
 type routerData struct {
 	RtpCapabilities RtpCapabilities `json:"rtpCapabilities,omitempty"`
 }
@@ -157,32 +302,58 @@ func (router *Router) Observer() IEventEmitter {
 	return router.observer
 }
 
+/**
+ * OnWorkerClose adds a listener of "workerclose" and returns the function
+ * removing it.
+ */
+func (router *Router) OnWorkerClose(listener func()) func() {
+	return subscribe(router.IEventEmitter, "workerclose", listener)
+}
+
+/**
+ * Transports returns the alive transports of the router.
+ */
+func (router *Router) Transports() (transports []ITransport) {
+	router.transports.Range(func(key, value interface{}) bool {
+		transports = append(transports, value.(ITransport))
+		return true
+	})
+
+	return
+}
+
 // Close the Router.
 func (router *Router) Close() {
+	router.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but with a context. The Router is closed even if
+// the request fails.
+func (router *Router) CloseContext(ctx context.Context) (err error) {
 	if atomic.CompareAndSwapUint32(&router.closed, 0, 1) {
 		router.logger.Debug("close()")
 
-		router.channel.Request("router.close", router.internal)
+		err = router.channel.RequestContext(ctx, "router.close", router.internal).Err()
 
 		// Close every Transport.
 		router.transports.Range(func(key, value interface{}) bool {
 			value.(ITransport).routerClosed()
 			return true
 		})
-		router.transports = sync.Map{}
+		syncMapClear(&router.transports)
 
 		// Clear the Producers map.
-		router.producers = sync.Map{}
+		syncMapClear(&router.producers)
 
 		// Close every RtpObserver.
 		router.rtpObservers.Range(func(key, value interface{}) bool {
 			value.(IRtpObserver).routerClosed()
 			return true
 		})
-		router.rtpObservers = sync.Map{}
+		syncMapClear(&router.rtpObservers)
 
 		// Clear map of Router/PipeTransports.
-		router.mapRouterPipeTransports = sync.Map{}
+		syncMapClear(&router.mapRouterPipeTransports)
 
 		router.Emit("@close")
 
@@ -202,20 +373,20 @@ func (router *Router) workerClosed() {
 			value.(ITransport).routerClosed()
 			return true
 		})
-		router.transports = sync.Map{}
+		syncMapClear(&router.transports)
 
 		// Clear the Producers map.
-		router.producers = sync.Map{}
+		syncMapClear(&router.producers)
 
 		// Close every RtpObserver.
 		router.rtpObservers.Range(func(key, value interface{}) bool {
 			value.(IRtpObserver).routerClosed()
 			return true
 		})
-		router.rtpObservers = sync.Map{}
+		syncMapClear(&router.rtpObservers)
 
 		// Clear map of Router/PipeTransports.
-		router.mapRouterPipeTransports = sync.Map{}
+		syncMapClear(&router.mapRouterPipeTransports)
 
 		router.Emit("workerclose")
 
@@ -227,10 +398,15 @@ func (router *Router) workerClosed() {
 }
 
 // Dump Router.
-func (router *Router) Dump() (data *RouterDump, err error) {
+func (router *Router) Dump() (*RouterDump, error) {
+	return router.DumpContext(context.Background())
+}
+
+// DumpContext is like Dump but with a context.
+func (router *Router) DumpContext(ctx context.Context) (data *RouterDump, err error) {
 	router.logger.Debug("dump()")
 
-	resp := router.channel.Request("router.dump", router.internal)
+	resp := router.channel.RequestContext(ctx, "router.dump", router.internal)
 	err = resp.Unmarshal(&data)
 
 	return
@@ -239,7 +415,12 @@ func (router *Router) Dump() (data *RouterDump, err error) {
 /**
  * Create a WebRtcTransport.
  */
-func (router *Router) CreateWebRtcTransport(option WebRtcTransportOptions) (transport *WebRtcTransport, err error) {
+func (router *Router) CreateWebRtcTransport(option WebRtcTransportOptions) (*WebRtcTransport, error) {
+	return router.CreateWebRtcTransportContext(context.Background(), option)
+}
+
+// CreateWebRtcTransportContext is like CreateWebRtcTransport but with a context.
+func (router *Router) CreateWebRtcTransportContext(ctx context.Context, option WebRtcTransportOptions) (transport *WebRtcTransport, err error) {
 	options := &WebRtcTransportOptions{
 		EnableUdp:                       Bool(true),
 		InitialAvailableOutgoingBitrate: 600000,
@@ -253,10 +434,18 @@ func (router *Router) CreateWebRtcTransport(option WebRtcTransportOptions) (tran
 
 	router.logger.Debug("createWebRtcTransport()")
 
+	if options.WebRtcServer == nil && len(options.ListenIps) == 0 {
+		err = NewTypeError("missing webRtcServer and listenIps (one of them is mandatory)")
+		return
+	}
+	if options.WebRtcServer != nil && options.WebRtcServer.Closed() {
+		err = NewInvalidStateError("WebRtcServer closed")
+		return
+	}
+
 	internal := router.internal
 	internal.TransportId = uuid.NewV4().String()
 	reqData := H{
-		"listenIps":                       options.ListenIps,
 		"enableUdp":                       options.EnableUdp,
 		"enableTcp":                       options.EnableTcp,
 		"preferUdp":                       options.PreferUdp,
@@ -268,23 +457,40 @@ func (router *Router) CreateWebRtcTransport(option WebRtcTransportOptions) (tran
 		"sctpSendBufferSize":              options.SctpSendBufferSize,
 		"isDataChannel":                   true,
 	}
+	method := "router.createWebRtcTransport"
 
-	resp := router.channel.Request("router.createWebRtcTransport", internal, reqData)
+	if options.WebRtcServer != nil {
+		method = "router.createWebRtcTransportWithServer"
+		reqData["webRtcServerId"] = options.WebRtcServer.Id()
+	} else {
+		reqData["listenIps"] = options.ListenIps
+	}
+
+	resp := router.channel.RequestContext(ctx, method, internal, reqData)
 
 	var data webrtcTransportData
 	if err = resp.Unmarshal(&data); err != nil {
 		return
 	}
 
-	iTransport := router.createTransport(internal, data, options.AppData)
+	transport = router.createTransport(internal, data, options.AppData).(*WebRtcTransport)
 
-	return iTransport.(*WebRtcTransport), nil
+	if options.WebRtcServer != nil {
+		options.WebRtcServer.handleWebRtcTransport(transport)
+	}
+
+	return
 }
 
 /**
  * Create a PlainTransport.
  */
-func (router *Router) CreatePlainTransport(option PlainTransportOptions) (transport *PlainTransport, err error) {
+func (router *Router) CreatePlainTransport(option PlainTransportOptions) (*PlainTransport, error) {
+	return router.CreatePlainTransportContext(context.Background(), option)
+}
+
+// CreatePlainTransportContext is like CreatePlainTransport but with a context.
+func (router *Router) CreatePlainTransportContext(ctx context.Context, option PlainTransportOptions) (transport *PlainTransport, err error) {
 	options := &PlainTransportOptions{
 		RtcpMux:            Bool(true),
 		NumSctpStreams:     NumSctpStreams{OS: 1024, MIS: 1024},
@@ -313,7 +519,7 @@ func (router *Router) CreatePlainTransport(option PlainTransportOptions) (transp
 		"srtpCryptoSuite":    options.SrtpCryptoSuite,
 	}
 
-	resp := router.channel.Request("router.createPlainTransport", internal, reqData)
+	resp := router.channel.RequestContext(ctx, "router.createPlainTransport", internal, reqData)
 
 	var data plainTransportData
 	if err = resp.Unmarshal(&data); err != nil {
@@ -328,7 +534,12 @@ func (router *Router) CreatePlainTransport(option PlainTransportOptions) (transp
 /**
  * Create a PipeTransport.
  */
-func (router *Router) CreatePipeTransport(option PipeTransportOptions) (transport *PipeTransport, err error) {
+func (router *Router) CreatePipeTransport(option PipeTransportOptions) (*PipeTransport, error) {
+	return router.CreatePipeTransportContext(context.Background(), option)
+}
+
+// CreatePipeTransportContext is like CreatePipeTransport but with a context.
+func (router *Router) CreatePipeTransportContext(ctx context.Context, option PipeTransportOptions) (transport *PipeTransport, err error) {
 	options := &PipeTransportOptions{
 		NumSctpStreams:     NumSctpStreams{OS: 1024, MIS: 1024},
 		MaxSctpMessageSize: 268435456,
@@ -353,7 +564,7 @@ func (router *Router) CreatePipeTransport(option PipeTransportOptions) (transpor
 		"enableSrtp":         options.EnableSrtp,
 	}
 
-	resp := router.channel.Request("router.createPipeTransport", internal, reqData)
+	resp := router.channel.RequestContext(ctx, "router.createPipeTransport", internal, reqData)
 
 	var data pipeTransortData
 	if err = resp.Unmarshal(&data); err != nil {
@@ -368,7 +579,12 @@ func (router *Router) CreatePipeTransport(option PipeTransportOptions) (transpor
 /**
  * Create a DirectTransport.
  */
-func (router *Router) CreateDirectTransport(params ...DirectTransportOptions) (transport *DirectTransport, err error) {
+func (router *Router) CreateDirectTransport(params ...DirectTransportOptions) (*DirectTransport, error) {
+	return router.CreateDirectTransportContext(context.Background(), params...)
+}
+
+// CreateDirectTransportContext is like CreateDirectTransport but with a context.
+func (router *Router) CreateDirectTransportContext(ctx context.Context, params ...DirectTransportOptions) (transport *DirectTransport, err error) {
 	options := &DirectTransportOptions{
 		MaxMessageSize: 262144,
 	}
@@ -384,7 +600,7 @@ func (router *Router) CreateDirectTransport(params ...DirectTransportOptions) (t
 	internal.TransportId = uuid.NewV4().String()
 	reqData := H{"direct": true, "maxMessageSize": options.MaxMessageSize}
 
-	resp := router.channel.Request("router.createDirectTransport", internal, reqData)
+	resp := router.channel.RequestContext(ctx, "router.createDirectTransport", internal, reqData)
 
 	var data directTransportData
 	if err = resp.Unmarshal(&data); err != nil {
@@ -397,9 +613,15 @@ func (router *Router) CreateDirectTransport(params ...DirectTransportOptions) (t
 }
 
 /**
- * Pipes the given Producer or DataProducer into another Router in same host.
+ * Pipes the given Producer or DataProducer into another Router in same host,
+ * or into a remote Router. Piping twice returns the existing result.
  */
-func (router *Router) PipeToRouter(option PipeToRouterOptions) (result *PipeToRouterResult, err error) {
+func (router *Router) PipeToRouter(option PipeToRouterOptions) (*PipeToRouterResult, error) {
+	return router.PipeToRouterContext(context.Background(), option)
+}
+
+// PipeToRouterContext is like PipeToRouter but with a context.
+func (router *Router) PipeToRouterContext(ctx context.Context, option PipeToRouterOptions) (result *PipeToRouterResult, err error) {
 	options := &PipeToRouterOptions{
 		ListenIp: TransportListenIp{
 			Ip: "127.0.0.1",
@@ -419,10 +641,14 @@ func (router *Router) PipeToRouter(option PipeToRouterOptions) (result *PipeToRo
 		err = NewTypeError("just producerId or dataProducerId can be given")
 		return
 	}
-	if options.Router == nil {
+	if options.Router == nil && options.RemoteRouter == nil {
 		err = NewTypeError("Router not found")
 		return
 	}
+	if options.Router != nil && options.RemoteRouter != nil {
+		err = NewTypeError("just Router or RemoteRouter can be given")
+		return
+	}
 	if options.Router == router {
 		err = NewTypeError("cannot use this Router as destination'")
 		return
@@ -459,182 +685,399 @@ func (router *Router) PipeToRouter(option PipeToRouterOptions) (result *PipeToRo
 	router.locker.Lock()
 	defer router.locker.Unlock()
 
-	var localPipeTransport, remotePipeTransport *PipeTransport
+	pair := router.loadPipeTransportPair(pipeDestination(options.Router, options.RemoteRouter))
 
-	value, ok := router.mapRouterPipeTransports.Load(options.Router)
+	// The teardown of a closed pair may be pending.
+	if pair != nil && pair.closed() {
+		router.closePipeTransportPair(pair)
+		pair = nil
+	}
 
-	if ok {
-		pipeTransportPair := value.([]*PipeTransport)
-		localPipeTransport = pipeTransportPair[0]
-		remotePipeTransport = pipeTransportPair[1]
-	} else {
-		defer func() {
-			if err != nil {
-				router.logger.Error("pipeToRouter() | error creating PipeTransport pair:%s", err)
-
-				if localPipeTransport != nil {
-					localPipeTransport.Close()
-				}
-				if remotePipeTransport != nil {
-					remotePipeTransport.Close()
-				}
+	if pair != nil {
+		// Piping twice returns the existing pipe Consumer/Producer pair. The
+		// teardown of a closed one may be pending, finish it first.
+		if result = pair.result(options.ProducerId, options.DataProducerId); result != nil {
+			if !result.closed() {
+				return
 			}
-		}()
-
-		option := PipeTransportOptions{
-			ListenIp:       options.ListenIp,
-			EnableSctp:     options.EnableSctp,
-			NumSctpStreams: options.NumSctpStreams,
-			EnableRtx:      options.EnableRtx,
-			EnableSrtp:     options.EnableSrtp,
+			result.close()
+			pair.remove(options.ProducerId, options.DataProducerId)
+			result = nil
 		}
-		localPipeTransport, err = router.CreatePipeTransport(option)
-		if err != nil {
-			return
+	} else {
+		if options.RemoteRouter != nil {
+			pair, err = router.createRemotePipeTransportPair(ctx, options)
+		} else {
+			pair, err = router.createPipeTransportPair(ctx, options)
 		}
-		remotePipeTransport, err = options.Router.CreatePipeTransport(option)
 		if err != nil {
 			return
 		}
+	}
 
-		err = localPipeTransport.Connect(TransportConnectOptions{
-			Ip:             remotePipeTransport.Tuple().LocalIp,
-			Port:           remotePipeTransport.Tuple().LocalPort,
-			SrtpParameters: remotePipeTransport.SrtpParameters(),
-		})
-		if err != nil {
-			return
+	// Don't keep a new pair which ends up unused.
+	defer func() {
+		if err != nil && pair.idle() {
+			router.closePipeTransportPair(pair)
 		}
-		err = remotePipeTransport.Connect(TransportConnectOptions{
-			Ip:             localPipeTransport.Tuple().LocalIp,
-			Port:           localPipeTransport.Tuple().LocalPort,
-			SrtpParameters: localPipeTransport.SrtpParameters(),
-		})
-		if err != nil {
-			return
+	}()
+
+	switch {
+	case producer != nil && options.RemoteRouter != nil:
+		result, err = router.pipeProducerToRemoteRouter(ctx, options.RemoteRouter, pair, producer)
+	case producer != nil:
+		result, err = router.pipeProducer(ctx, pair, producer)
+	case dataProducer != nil && options.RemoteRouter != nil:
+		result, err = router.pipeDataProducerToRemoteRouter(ctx, options.RemoteRouter, pair, dataProducer)
+	case dataProducer != nil:
+		result, err = router.pipeDataProducer(ctx, pair, dataProducer)
+	default:
+		err = errors.New("internal error")
+	}
+	if err != nil {
+		return
+	}
+
+	router.addPipedProducer(pair, options.ProducerId, options.DataProducerId, result)
+
+	return
+}
+
+/**
+ * Stops piping the given Producer or DataProducer into another Router. The
+ * PipeTransport pair is closed once nothing is piped through it anymore.
+ */
+func (router *Router) UnpipeFromRouter(options UnpipeFromRouterOptions) error {
+	if len(options.ProducerId) == 0 && len(options.DataProducerId) == 0 {
+		return NewTypeError("missing producerId")
+	}
+	if len(options.ProducerId) > 0 && len(options.DataProducerId) > 0 {
+		return NewTypeError("just producerId or dataProducerId can be given")
+	}
+	if options.Router == nil && options.RemoteRouter == nil {
+		return NewTypeError("Router not found")
+	}
+	if options.Router != nil && options.RemoteRouter != nil {
+		return NewTypeError("just Router or RemoteRouter can be given")
+	}
+
+	router.logger.Debug("unpipeFromRouter()")
+
+	router.locker.Lock()
+	defer router.locker.Unlock()
+
+	pair := router.loadPipeTransportPair(pipeDestination(options.Router, options.RemoteRouter))
+
+	var result *PipeToRouterResult
+
+	if pair != nil {
+		result = pair.result(options.ProducerId, options.DataProducerId)
+	}
+	if result == nil {
+		if len(options.ProducerId) > 0 {
+			return NewTypeError("Producer not piped to this Router")
 		}
+		return NewTypeError("DataProducer not piped to this Router")
+	}
 
-		localPipeTransport.Observer().On("close", func() {
-			remotePipeTransport.Close()
-			router.mapRouterPipeTransports.Delete(options.Router)
-		})
+	pair.remove(options.ProducerId, options.DataProducerId)
 
-		remotePipeTransport.Observer().On("close", func() {
-			localPipeTransport.Close()
-			router.mapRouterPipeTransports.Delete(options.Router)
-		})
+	if pair.idle() {
+		router.closePipeTransportPair(pair)
+	} else {
+		result.close()
+	}
+
+	return nil
+}
+
+/**
+ * PipedProducers returns the Producers and DataProducers piped into other
+ * Routers.
+ */
+func (router *Router) PipedProducers() (pipedProducers []PipedProducer) {
+	router.locker.Lock()
+	defer router.locker.Unlock()
+
+	router.mapRouterPipeTransports.Range(func(key, value interface{}) bool {
+		pair := value.(*pipeTransportPair)
+
+		for producerId, result := range pair.producers {
+			pipedProducers = append(pipedProducers, PipedProducer{
+				ProducerId:   producerId,
+				Router:       pair.router,
+				RemoteRouter: pair.remoteRouter,
+				Result:       result,
+			})
+		}
+		for dataProducerId, result := range pair.dataProducers {
+			pipedProducers = append(pipedProducers, PipedProducer{
+				DataProducerId: dataProducerId,
+				Router:         pair.router,
+				RemoteRouter:   pair.remoteRouter,
+				Result:         result,
+			})
+		}
 
-		router.mapRouterPipeTransports.Store(options.Router, []*PipeTransport{localPipeTransport, remotePipeTransport})
+		return true
+	})
+
+	return
+}
+
+// pipeDestination returns the key of mapRouterPipeTransports for the given
+// destination Router.
+func pipeDestination(router *Router, remoteRouter IRemoteRouter) interface{} {
+	if remoteRouter != nil {
+		return remoteRouter
 	}
+	return router
+}
 
-	if producer != nil {
-		var pipeConsumer *Consumer
-		var pipeProducer *Producer
+// loadPipeTransportPair returns the PipeTransport pair connected to the given
+// destination, nil if none. router.locker must be held.
+func (router *Router) loadPipeTransportPair(destination interface{}) *pipeTransportPair {
+	if value, ok := router.mapRouterPipeTransports.Load(destination); ok {
+		return value.(*pipeTransportPair)
+	}
+	return nil
+}
 
-		defer func() {
-			if err != nil {
-				router.logger.Error("pipeToRouter() | error creating pipe Consumer/Producer pair:%s", err)
+// deletePipeTransportPair forgets the given PipeTransport pair, unless it was
+// replaced already.
+func (router *Router) deletePipeTransportPair(pair *pipeTransportPair) {
+	router.locker.Lock()
+	defer router.locker.Unlock()
 
-				if pipeConsumer != nil {
-					pipeConsumer.Close()
-				}
-				if pipeProducer != nil {
-					pipeProducer.Close()
-				}
-			}
-		}()
+	if router.loadPipeTransportPair(pair.destination()) == pair {
+		router.mapRouterPipeTransports.Delete(pair.destination())
+	}
+}
 
-		pipeConsumer, err = localPipeTransport.Consume(ConsumerOptions{
-			ProducerId: options.ProducerId,
-		})
-		if err != nil {
+// closePipeTransportPair forgets and closes the given PipeTransport pair,
+// which closes all that is piped through it. router.locker must be held.
+func (router *Router) closePipeTransportPair(pair *pipeTransportPair) {
+	router.logger.Debug("pipeToRouter() | closing PipeTransport pair")
+
+	if router.loadPipeTransportPair(pair.destination()) == pair {
+		router.mapRouterPipeTransports.Delete(pair.destination())
+	}
+
+	pair.localPipeTransport.Close()
+
+	if pair.remotePipeTransport != nil {
+		pair.remotePipeTransport.Close()
+	}
+}
+
+// addPipedProducer adds a reference to the given PipeTransport pair, which is
+// released when the pipe Consumer or DataConsumer is closed. router.locker
+// must be held.
+func (router *Router) addPipedProducer(pair *pipeTransportPair, producerId, dataProducerId string, result *PipeToRouterResult) {
+	release := func() {
+		router.locker.Lock()
+		defer router.locker.Unlock()
+
+		if pair.result(producerId, dataProducerId) != result {
 			return
 		}
 
-		pipeProducer, err = remotePipeTransport.Produce(ProducerOptions{
-			Id:            producer.Id(),
-			Kind:          pipeConsumer.Kind(),
-			RtpParameters: pipeConsumer.RtpParameters(),
-			Paused:        pipeConsumer.ProducerPaused(),
-			AppData:       producer.AppData(),
-		})
-		if err != nil {
-			return
+		pair.remove(producerId, dataProducerId)
+
+		if pair.idle() {
+			router.closePipeTransportPair(pair)
 		}
+	}
 
-		// Pipe events from the pipe Consumer to the pipe Producer.
-		pipeConsumer.Observer().On("close", func() { pipeProducer.Close() })
-		pipeConsumer.Observer().On("pause", func() { pipeProducer.Pause() })
-		pipeConsumer.Observer().On("resume", func() { pipeProducer.Resume() })
+	if len(producerId) > 0 {
+		pair.producers[producerId] = result
+		result.PipeConsumer.Observer().On("close", release)
+	} else {
+		pair.dataProducers[dataProducerId] = result
+		result.PipeDataConsumer.Observer().On("close", release)
+	}
+}
 
-		// Pipe events from the pipe Producer to the pipe Consumer.
-		pipeProducer.Observer().On("close", func() { pipeConsumer.Close() })
+// createPipeTransportPair creates and connects a PipeTransport in the Router
+// and one in the destination Router, which are closed together.
+// router.locker must be held.
+func (router *Router) createPipeTransportPair(ctx context.Context, options *PipeToRouterOptions) (pair *pipeTransportPair, err error) {
+	var localPipeTransport, remotePipeTransport *PipeTransport
 
-		result = &PipeToRouterResult{
-			PipeConsumer: pipeConsumer,
-			PipeProducer: pipeProducer,
+	defer func() {
+		if err != nil {
+			router.logger.Error("pipeToRouter() | error creating PipeTransport pair:%s", err)
+
+			if localPipeTransport != nil {
+				localPipeTransport.Close()
+			}
+			if remotePipeTransport != nil {
+				remotePipeTransport.Close()
+			}
 		}
+	}()
+
+	option := PipeTransportOptions{
+		ListenIp:       options.ListenIp,
+		EnableSctp:     options.EnableSctp,
+		NumSctpStreams: options.NumSctpStreams,
+		EnableRtx:      options.EnableRtx,
+		EnableSrtp:     options.EnableSrtp,
+	}
+	localPipeTransport, err = router.CreatePipeTransportContext(ctx, option)
+	if err != nil {
+		return
+	}
+	remotePipeTransport, err = options.Router.CreatePipeTransportContext(ctx, option)
+	if err != nil {
+		return
+	}
 
+	err = localPipeTransport.ConnectContext(ctx, TransportConnectOptions{
+		Ip:             remotePipeTransport.Tuple().LocalIp,
+		Port:           remotePipeTransport.Tuple().LocalPort,
+		SrtpParameters: remotePipeTransport.SrtpParameters(),
+	})
+	if err != nil {
+		return
+	}
+	err = remotePipeTransport.ConnectContext(ctx, TransportConnectOptions{
+		Ip:             localPipeTransport.Tuple().LocalIp,
+		Port:           localPipeTransport.Tuple().LocalPort,
+		SrtpParameters: localPipeTransport.SrtpParameters(),
+	})
+	if err != nil {
 		return
 	}
 
-	if dataProducer != nil {
-		var pipeDataConsumer *DataConsumer
-		var pipeDataProducer *DataProducer
+	pair = newPipeTransportPair(localPipeTransport)
+	pair.router = options.Router
+	pair.remotePipeTransport = remotePipeTransport
 
-		defer func() {
-			if err != nil {
-				router.logger.Error("pipeToRouter() | error creating pipe DataConsumer/DataProducer pair:%s", err)
+	localPipeTransport.Observer().On("close", func() {
+		remotePipeTransport.Close()
+		router.deletePipeTransportPair(pair)
+	})
 
-				if pipeDataConsumer != nil {
-					pipeDataConsumer.Close()
-				}
-				if pipeDataProducer != nil {
-					pipeDataProducer.Close()
-				}
-			}
-		}()
+	remotePipeTransport.Observer().On("close", func() {
+		localPipeTransport.Close()
+		router.deletePipeTransportPair(pair)
+	})
 
-		pipeDataConsumer, err = localPipeTransport.ConsumeData(DataConsumerOptions{
-			DataProducerId: options.DataProducerId,
-		})
-		if err != nil {
-			return
-		}
+	router.mapRouterPipeTransports.Store(options.Router, pair)
 
-		pipeDataProducer, err = remotePipeTransport.ProduceData(DataProducerOptions{
-			Id:                   dataProducer.Id(),
-			SctpStreamParameters: pipeDataConsumer.SctpStreamParameters(),
-			Label:                pipeDataConsumer.Label(),
-			Protocol:             pipeDataConsumer.Protocol(),
-			AppData:              dataProducer.AppData(),
-		})
+	return
+}
+
+func (router *Router) pipeProducer(ctx context.Context, pair *pipeTransportPair, producer *Producer) (result *PipeToRouterResult, err error) {
+	var pipeConsumer *Consumer
+	var pipeProducer *Producer
+
+	defer func() {
 		if err != nil {
-			return
+			router.logger.Error("pipeToRouter() | error creating pipe Consumer/Producer pair:%s", err)
+
+			if pipeConsumer != nil {
+				pipeConsumer.Close()
+			}
+			if pipeProducer != nil {
+				pipeProducer.Close()
+			}
 		}
+	}()
 
-		// Pipe events from the pipe DataConsumer to the pipe DataProducer.
-		pipeDataConsumer.Observer().On("close", func() { pipeDataProducer.Close() })
+	pipeConsumer, err = pair.localPipeTransport.ConsumeContext(ctx, ConsumerOptions{
+		ProducerId: producer.Id(),
+	})
+	if err != nil {
+		return
+	}
 
-		// Pipe events from the pipe DataProducer to the pipe DataConsumer.
-		pipeDataProducer.Observer().On("close", func() { pipeDataConsumer.Close() })
+	pipeProducer, err = pair.remotePipeTransport.ProduceContext(ctx, ProducerOptions{
+		Id:            producer.Id(),
+		Kind:          pipeConsumer.Kind(),
+		RtpParameters: pipeConsumer.RtpParameters(),
+		Paused:        pipeConsumer.ProducerPaused(),
+		AppData:       producer.AppData(),
+	})
+	if err != nil {
+		return
+	}
 
-		result = &PipeToRouterResult{
-			PipeDataConsumer: pipeDataConsumer,
-			PipeDataProducer: pipeDataProducer,
+	// Pipe events from the pipe Consumer to the pipe Producer.
+	pipeConsumer.Observer().On("close", func() { pipeProducer.Close() })
+	pipeConsumer.Observer().On("pause", func() { pipeProducer.Pause() })
+	pipeConsumer.Observer().On("resume", func() { pipeProducer.Resume() })
+
+	// Pipe events from the pipe Producer to the pipe Consumer.
+	pipeProducer.Observer().On("close", func() { pipeConsumer.Close() })
+
+	result = &PipeToRouterResult{
+		PipeConsumer: pipeConsumer,
+		PipeProducer: pipeProducer,
+	}
+
+	return
+}
+
+func (router *Router) pipeDataProducer(ctx context.Context, pair *pipeTransportPair, dataProducer *DataProducer) (result *PipeToRouterResult, err error) {
+	var pipeDataConsumer *DataConsumer
+	var pipeDataProducer *DataProducer
+
+	defer func() {
+		if err != nil {
+			router.logger.Error("pipeToRouter() | error creating pipe DataConsumer/DataProducer pair:%s", err)
+
+			if pipeDataConsumer != nil {
+				pipeDataConsumer.Close()
+			}
+			if pipeDataProducer != nil {
+				pipeDataProducer.Close()
+			}
 		}
+	}()
 
+	pipeDataConsumer, err = pair.localPipeTransport.ConsumeDataContext(ctx, DataConsumerOptions{
+		DataProducerId: dataProducer.Id(),
+	})
+	if err != nil {
+		return
+	}
+
+	pipeDataProducer, err = pair.remotePipeTransport.ProduceDataContext(ctx, DataProducerOptions{
+		Id:                   dataProducer.Id(),
+		SctpStreamParameters: pipeDataConsumer.SctpStreamParameters(),
+		Label:                pipeDataConsumer.Label(),
+		Protocol:             pipeDataConsumer.Protocol(),
+		AppData:              dataProducer.AppData(),
+	})
+	if err != nil {
 		return
 	}
 
-	err = errors.New("internal error")
+	// Pipe events from the pipe DataConsumer to the pipe DataProducer.
+	pipeDataConsumer.Observer().On("close", func() { pipeDataProducer.Close() })
+
+	// Pipe events from the pipe DataProducer to the pipe DataConsumer.
+	pipeDataProducer.Observer().On("close", func() { pipeDataConsumer.Close() })
+
+	result = &PipeToRouterResult{
+		PipeDataConsumer: pipeDataConsumer,
+		PipeDataProducer: pipeDataProducer,
+	}
+
 	return
 }
 
 /**
  * Create an AudioLevelObserver.
  */
-func (router *Router) CreateAudioLevelObserver(options ...func(o *AudioLevelObserverOptions)) (rtpObserver IRtpObserver, err error) {
+func (router *Router) CreateAudioLevelObserver(options ...func(o *AudioLevelObserverOptions)) (IRtpObserver, error) {
+	return router.CreateAudioLevelObserverContext(context.Background(), options...)
+}
+
+// CreateAudioLevelObserverContext is like CreateAudioLevelObserver but with a context.
+func (router *Router) CreateAudioLevelObserverContext(ctx context.Context, options ...func(o *AudioLevelObserverOptions)) (rtpObserver IRtpObserver, err error) {
 	router.logger.Debug("createAudioLevelObserver()")
 
 	defaultOptions := NewAudioLevelObserverOptions()
@@ -646,7 +1089,7 @@ func (router *Router) CreateAudioLevelObserver(options ...func(o *AudioLevelObse
 	internal := router.internal
 	internal.RtpObserverId = uuid.NewV4().String()
 
-	resp := router.channel.Request("router.createAudioLevelObserver", internal, defaultOptions)
+	resp := router.channel.RequestContext(ctx, "router.createAudioLevelObserver", internal, defaultOptions)
 
 	if err = resp.Err(); err != nil {
 		return
@@ -673,6 +1116,54 @@ func (router *Router) CreateAudioLevelObserver(options ...func(o *AudioLevelObse
 	return
 }
 
+/**
+ * Create an ActiveSpeakerObserver. It requires mediasoup-worker 3.8.0 or later,
+ * and fails with an UnsupportedError on older workers.
+ */
+func (router *Router) CreateActiveSpeakerObserver(options ...func(o *ActiveSpeakerObserverOptions)) (IRtpObserver, error) {
+	return router.CreateActiveSpeakerObserverContext(context.Background(), options...)
+}
+
+// CreateActiveSpeakerObserverContext is like CreateActiveSpeakerObserver but with a context.
+func (router *Router) CreateActiveSpeakerObserverContext(ctx context.Context, options ...func(o *ActiveSpeakerObserverOptions)) (rtpObserver IRtpObserver, err error) {
+	router.logger.Debug("createActiveSpeakerObserver()")
+
+	defaultOptions := NewActiveSpeakerObserverOptions()
+
+	for _, option := range options {
+		option(&defaultOptions)
+	}
+
+	internal := router.internal
+	internal.RtpObserverId = uuid.NewV4().String()
+
+	resp := router.channel.RequestContext(ctx, "router.createActiveSpeakerObserver", internal, defaultOptions)
+
+	if err = requireWorkerVersion(resp.Err(), "ActiveSpeakerObserver", "3.8.0"); err != nil {
+		return
+	}
+
+	rtpObserver = newActiveSpeakerObserver(rtpObserverParams{
+		internal:       internal,
+		channel:        router.channel,
+		payloadChannel: router.payloadChannel,
+		appData:        defaultOptions.AppData,
+		getProducerById: func(producerId string) *Producer {
+			if value, ok := router.producers.Load(producerId); ok {
+				return value.(*Producer)
+			}
+			return nil
+		},
+	})
+
+	router.rtpObservers.Store(rtpObserver.Id(), rtpObserver)
+	rtpObserver.On("@close", func() {
+		router.rtpObservers.Delete(rtpObserver.Id())
+	})
+
+	return
+}
+
 /**
  * Check whether the given RTP capabilities can consume the given Producer.
  */
@@ -747,6 +1238,9 @@ func (router *Router) createTransport(internal internalData, data, appData inter
 	transport.On("@close", func() {
 		router.transports.Delete(transport.Id())
 	})
+	transport.On("@listenserverclose", func() {
+		router.transports.Delete(transport.Id())
+	})
 	transport.On("@newproducer", func(producer *Producer) {
 		router.producers.Store(producer.Id(), producer)
 	})
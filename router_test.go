@@ -1,6 +1,7 @@
 package mediasoup
 
 import (
+	"context"
 	"testing"
 
 	"github.com/jiyeyuran/mediasoup-go/h264"
@@ -99,6 +100,31 @@ func TestRouterClose_Succeeds(t *testing.T) {
 	assert.True(t, router.Closed())
 }
 
+func TestRouterCloseContext_Canceled(t *testing.T) {
+	worker := CreateTestWorker()
+	defer worker.Close()
+
+	router, _ := worker.CreateRouter(RouterOptions{
+		MediaCodecs: testRouterMediaCodecs,
+	})
+	transport, _ := router.CreateWebRtcTransport(WebRtcTransportOptions{
+		ListenIps: []TransportListenIp{{Ip: "127.0.0.1"}},
+	})
+
+	onObserverClose := NewMockFunc(t)
+	router.Observer().Once("close", onObserverClose.Fn())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The Router is closed even if the request is canceled.
+	assert.Equal(t, context.Canceled, router.CloseContext(ctx))
+	onObserverClose.ExpectCalled()
+	assert.True(t, router.Closed())
+	assert.True(t, transport.Closed())
+	assert.NoError(t, router.CloseContext(context.Background()))
+}
+
 func TestRouterEmitsWorkCloseIfWorkerIsClosed(t *testing.T) {
 	worker := CreateTestWorker()
 	onObserverClose := NewMockFunc(t)
@@ -110,3 +136,21 @@ func TestRouterEmitsWorkCloseIfWorkerIsClosed(t *testing.T) {
 	onObserverClose.ExpectCalled()
 	assert.True(t, router.Closed())
 }
+
+func TestRouterTransports(t *testing.T) {
+	worker := CreateTestWorker()
+	router, _ := worker.CreateRouter(RouterOptions{
+		MediaCodecs: testRouterMediaCodecs,
+	})
+	assert.Equal(t, []*Router{router}, worker.Routers())
+	assert.Empty(t, router.Transports())
+
+	transport, _ := router.CreateDirectTransport()
+	assert.Equal(t, []ITransport{transport}, router.Transports())
+
+	transport.Close()
+	assert.Empty(t, router.Transports())
+
+	router.Close()
+	assert.Empty(t, worker.Routers())
+}
@@ -1,6 +1,7 @@
 package mediasoup
 
 import (
+	"context"
 	"sync"
 )
 
@@ -12,11 +13,18 @@ type IRtpObserver interface {
 	Paused() bool
 	Observer() IEventEmitter
 	Close()
+	CloseContext(ctx context.Context) error
 	routerClosed()
+	subscribe(evt string, listener interface{}) func()
+	OnRouterClose(listener func()) func()
 	Pause()
+	PauseContext(ctx context.Context) error
 	Resume()
+	ResumeContext(ctx context.Context) error
 	AddProducer(producerId string)
+	AddProducerContext(ctx context.Context, producerId string) error
 	RemoveProducer(producerId string)
+	RemoveProducerContext(ctx context.Context, producerId string) error
 }
 
 /**
@@ -113,10 +121,28 @@ func (o *RtpObserver) Observer() IEventEmitter {
 	return o.observer
 }
 
+func (o *RtpObserver) subscribe(evt string, listener interface{}) func() {
+	return subscribe(o.IEventEmitter, evt, listener)
+}
+
+/**
+ * OnRouterClose adds a listener of "routerclose" and returns the function
+ * removing it.
+ */
+func (o *RtpObserver) OnRouterClose(listener func()) func() {
+	return o.subscribe("routerclose", listener)
+}
+
 /**
  * Close the RtpObserver.
  */
 func (o *RtpObserver) Close() {
+	o.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but with a context. The RtpObserver is closed even
+// if the request fails.
+func (o *RtpObserver) CloseContext(ctx context.Context) (err error) {
 	o.locker.Lock()
 	defer o.locker.Unlock()
 
@@ -132,12 +158,14 @@ func (o *RtpObserver) Close() {
 	o.channel.RemoveAllListeners(o.internal.RtpObserverId)
 	o.payloadChannel.RemoveAllListeners(o.internal.RtpObserverId)
 
-	o.channel.Request("rtpObserver.close", o.internal)
+	err = o.channel.RequestContext(ctx, "rtpObserver.close", o.internal).Err()
 
 	o.Emit("@close")
 
 	// Emit observer event.
 	o.observer.SafeEmit("close")
+
+	return
 }
 
 /**
@@ -168,6 +196,11 @@ func (o *RtpObserver) routerClosed() {
  * Pause the RtpObserver.
  */
 func (o *RtpObserver) Pause() {
+	o.PauseContext(context.Background())
+}
+
+// PauseContext is like Pause but with a context.
+func (o *RtpObserver) PauseContext(ctx context.Context) error {
 	o.locker.Lock()
 	defer o.locker.Unlock()
 
@@ -175,7 +208,9 @@ func (o *RtpObserver) Pause() {
 
 	wasPaused := o.paused
 
-	o.channel.Request("rtpObserver.pause", o.internal)
+	if err := o.channel.RequestContext(ctx, "rtpObserver.pause", o.internal).Err(); err != nil {
+		return err
+	}
 
 	o.paused = true
 
@@ -183,12 +218,19 @@ func (o *RtpObserver) Pause() {
 	if !wasPaused {
 		o.observer.SafeEmit("pause")
 	}
+
+	return nil
 }
 
 /**
  * Resume the RtpObserver.
  */
 func (o *RtpObserver) Resume() {
+	o.ResumeContext(context.Background())
+}
+
+// ResumeContext is like Resume but with a context.
+func (o *RtpObserver) ResumeContext(ctx context.Context) error {
 	o.locker.Lock()
 	defer o.locker.Unlock()
 
@@ -196,7 +238,9 @@ func (o *RtpObserver) Resume() {
 
 	wasPaused := o.paused
 
-	o.channel.Request("rtpObserver.resume", o.internal)
+	if err := o.channel.RequestContext(ctx, "rtpObserver.resume", o.internal).Err(); err != nil {
+		return err
+	}
 
 	o.paused = false
 
@@ -204,12 +248,19 @@ func (o *RtpObserver) Resume() {
 	if wasPaused {
 		o.observer.SafeEmit("resume")
 	}
+
+	return nil
 }
 
 /**
  * Add a Producer to the RtpObserver.
  */
 func (o *RtpObserver) AddProducer(producerId string) {
+	o.AddProducerContext(context.Background(), producerId)
+}
+
+// AddProducerContext is like AddProducer but with a context.
+func (o *RtpObserver) AddProducerContext(ctx context.Context, producerId string) error {
 	o.locker.Lock()
 	defer o.locker.Unlock()
 
@@ -219,16 +270,25 @@ func (o *RtpObserver) AddProducer(producerId string) {
 	internal := o.internal
 	internal.ProducerId = producerId
 
-	o.channel.Request("rtpObserver.addProducer", internal)
+	if err := o.channel.RequestContext(ctx, "rtpObserver.addProducer", internal).Err(); err != nil {
+		return err
+	}
 
 	// Emit observer event.
 	o.observer.SafeEmit("addproducer", producer)
+
+	return nil
 }
 
 /**
  * Remove a Producer from the RtpObserver.
  */
 func (o *RtpObserver) RemoveProducer(producerId string) {
+	o.RemoveProducerContext(context.Background(), producerId)
+}
+
+// RemoveProducerContext is like RemoveProducer but with a context.
+func (o *RtpObserver) RemoveProducerContext(ctx context.Context, producerId string) error {
 	o.locker.Lock()
 	defer o.locker.Unlock()
 
@@ -238,8 +298,12 @@ func (o *RtpObserver) RemoveProducer(producerId string) {
 	internal := o.internal
 	internal.ProducerId = producerId
 
-	o.channel.Request("rtpObserver.removeProducer", internal)
+	if err := o.channel.RequestContext(ctx, "rtpObserver.removeProducer", internal).Err(); err != nil {
+		return err
+	}
 
 	// Emit observer event.
 	o.observer.SafeEmit("removeproducer", producer)
+
+	return nil
 }
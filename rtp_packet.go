@@ -0,0 +1,99 @@
+package mediasoup
+
+import (
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// RTP header extension URIs supported by mediasoup.
+const (
+	RtpHeaderExtensionUri_Mid                 = "urn:ietf:params:rtp-hdrext:sdes:mid"
+	RtpHeaderExtensionUri_RtpStreamId         = "urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id"
+	RtpHeaderExtensionUri_RepairedRtpStreamId = "urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id"
+	RtpHeaderExtensionUri_AbsSendTime         = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+	RtpHeaderExtensionUri_TransportWideCc     = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+	RtpHeaderExtensionUri_FrameMarking        = "urn:ietf:params:rtp-hdrext:framemarking"
+	RtpHeaderExtensionUri_FrameMarking07      = "http://tools.ietf.org/html/draft-ietf-avtext-framemarking-07"
+	RtpHeaderExtensionUri_AudioLevel          = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+	RtpHeaderExtensionUri_VideoOrientation    = "urn:3gpp:video-orientation"
+	RtpHeaderExtensionUri_TimeOffset          = "urn:ietf:params:rtp-hdrext:toffset"
+)
+
+/**
+ * RtpHeaderExtensionIds maps the URIs of the header extensions of some RTP
+ * parameters to the ids they have in the RTP packets.
+ */
+type RtpHeaderExtensionIds map[string]uint8
+
+/**
+ * NewRtpHeaderExtensionIds returns the ids of the given header extensions.
+ */
+func NewRtpHeaderExtensionIds(headerExtensions []RtpHeaderExtensionParameters) RtpHeaderExtensionIds {
+	ids := RtpHeaderExtensionIds{}
+
+	for _, ext := range headerExtensions {
+		ids[ext.Uri] = uint8(ext.Id)
+	}
+
+	return ids
+}
+
+/**
+ * Get returns the payload of the header extension, or nil if the extension
+ * is not negotiated or not present in the packet.
+ */
+func (ids RtpHeaderExtensionIds) Get(packet *rtp.Packet, uri string) []byte {
+	id, ok := ids[uri]
+	if !ok || !packet.Extension {
+		return nil
+	}
+
+	return packet.GetExtension(id)
+}
+
+/**
+ * Set adds or replaces the header extension in the packet.
+ */
+func (ids RtpHeaderExtensionIds) Set(packet *rtp.Packet, uri string, payload []byte) error {
+	id, ok := ids[uri]
+	if !ok {
+		return NewTypeError("header extension not negotiated: %s", uri)
+	}
+
+	return packet.SetExtension(id, payload)
+}
+
+/**
+ * Del removes the header extension from the packet.
+ */
+func (ids RtpHeaderExtensionIds) Del(packet *rtp.Packet, uri string) error {
+	id, ok := ids[uri]
+	if !ok {
+		return NewTypeError("header extension not negotiated: %s", uri)
+	}
+
+	return packet.DelExtension(id)
+}
+
+// parseRtcp parses a compound RTCP packet.
+func parseRtcp(logger Logger, data []byte) ([]rtcp.Packet, bool) {
+	packets, err := rtcp.Unmarshal(data)
+	if err != nil {
+		logger.Warn("invalid RTCP packet: %s", err)
+		return nil, false
+	}
+
+	return packets, true
+}
+
+// parseRtp parses a RTP packet.
+func parseRtp(logger Logger, data []byte) (*rtp.Packet, bool) {
+	packet := &rtp.Packet{}
+
+	if err := packet.Unmarshal(data); err != nil {
+		logger.Warn("invalid RTP packet: %s", err)
+		return nil, false
+	}
+
+	return packet, true
+}
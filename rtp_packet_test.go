@@ -0,0 +1,36 @@
+package mediasoup
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRtpHeaderExtensionIds(t *testing.T) {
+	ids := NewRtpHeaderExtensionIds([]RtpHeaderExtensionParameters{
+		{Uri: RtpHeaderExtensionUri_Mid, Id: 1},
+		{Uri: RtpHeaderExtensionUri_AudioLevel, Id: 10},
+	})
+	assert.Equal(t, RtpHeaderExtensionIds{RtpHeaderExtensionUri_Mid: 1, RtpHeaderExtensionUri_AudioLevel: 10}, ids)
+
+	packet := &rtp.Packet{Header: rtp.Header{Version: 2}}
+
+	assert.Nil(t, ids.Get(packet, RtpHeaderExtensionUri_Mid))
+	assert.NoError(t, ids.Set(packet, RtpHeaderExtensionUri_Mid, []byte("0")))
+	assert.NoError(t, ids.Set(packet, RtpHeaderExtensionUri_AudioLevel, []byte{0x9e}))
+	assert.IsType(t, NewTypeError(""), ids.Set(packet, RtpHeaderExtensionUri_AbsSendTime, []byte{1, 2, 3}))
+
+	data, err := packet.Marshal()
+	assert.NoError(t, err)
+
+	parsed := &rtp.Packet{}
+	assert.NoError(t, parsed.Unmarshal(data))
+	assert.Equal(t, []uint8{1, 10}, parsed.GetExtensionIDs())
+	assert.Equal(t, []byte("0"), ids.Get(parsed, RtpHeaderExtensionUri_Mid))
+	assert.Equal(t, []byte{0x9e}, ids.Get(parsed, RtpHeaderExtensionUri_AudioLevel))
+	assert.Nil(t, ids.Get(parsed, RtpHeaderExtensionUri_AbsSendTime))
+
+	assert.NoError(t, ids.Del(parsed, RtpHeaderExtensionUri_Mid))
+	assert.Nil(t, ids.Get(parsed, RtpHeaderExtensionUri_Mid))
+}
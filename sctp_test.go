@@ -27,6 +27,12 @@ type SctpTestingSuite struct {
 	stcpStream   *sctp.Stream
 }
 
+func (suite *SctpTestingSuite) SetupSuite() {
+	if fakeWorker {
+		suite.T().Skip("the fake worker does not speak SCTP")
+	}
+}
+
 func (suite *SctpTestingSuite) SetupTest() {
 	var err error
 	suite.worker = CreateTestWorker()
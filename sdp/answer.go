@@ -0,0 +1,393 @@
+package sdp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+const midExtensionUri = "urn:ietf:params:rtp-hdrext:sdes:mid"
+
+type AnswerOptions struct {
+	/**
+	 * ICE parameters of the WebRtcTransport.
+	 */
+	IceParameters mediasoup.IceParameters
+
+	/**
+	 * ICE candidates of the WebRtcTransport.
+	 */
+	IceCandidates []mediasoup.IceCandidate
+
+	/**
+	 * DTLS parameters of the WebRtcTransport.
+	 */
+	DtlsParameters mediasoup.DtlsParameters
+
+	/**
+	 * Producers created with the options returned by Offer.ProducerOptions().
+	 * They are matched to the offer media sections by mid.
+	 */
+	Producers []*mediasoup.Producer
+
+	/**
+	 * Consumers to send in the media sections the remote receives. They are
+	 * matched by mid, or else to the first free section of the same kind, so
+	 * they should be created with ConsumerOptions.Mid set to the offered mid.
+	 * The mid header extension of a Consumer sending another mid is left
+	 * out. Their codecs and header extensions are answered with the offered
+	 * payload types and ids.
+	 */
+	Consumers []*mediasoup.Consumer
+}
+
+/**
+ * NewAnswerOptions returns AnswerOptions with the ICE and DTLS parameters of
+ * the given transport.
+ */
+func NewAnswerOptions(transport *mediasoup.WebRtcTransport) AnswerOptions {
+	return AnswerOptions{
+		IceParameters:  transport.IceParameters(),
+		IceCandidates:  transport.IceCandidates(),
+		DtlsParameters: transport.DtlsParameters(),
+	}
+}
+
+/**
+ * Answer generates the answer SDP. Every offered media section is answered
+ * in order, sections without a matching producer or consumer are rejected.
+ * Accepted sections are bundled.
+ */
+func (o *Offer) Answer(options AnswerOptions) (string, error) {
+	connectOptions, err := o.ConnectOptions()
+	if err != nil {
+		return "", err
+	}
+
+	answer := &SessionDescription{
+		Origin:      fmt.Sprintf("- %d 2 IN IP4 127.0.0.1", time.Now().UnixNano()),
+		SessionName: "-",
+		Timing:      "0 0",
+	}
+
+	setup := "passive"
+	if connectOptions.DtlsParameters.Role == mediasoup.DtlsRole_Server {
+		setup = "active"
+	}
+
+	fingerprint, err := selectFingerprint(options.DtlsParameters)
+	if err != nil {
+		return "", err
+	}
+
+	bundle := []string{}
+	usedConsumers := map[*mediasoup.Consumer]bool{}
+
+	for _, offered := range o.Media {
+		mid := offered.Mid()
+
+		m := &MediaDescription{
+			Kind:     offered.Kind,
+			Protocol: offered.Protocol,
+		}
+		answer.Media = append(answer.Media, m)
+
+		var (
+			rtpParameters mediasoup.RtpParameters
+			direction     string
+			consumer      *mediasoup.Consumer
+		)
+
+		if isRtp(offered) {
+			switch offered.Direction() {
+			case "sendonly", "sendrecv":
+				for _, producer := range options.Producers {
+					if producer.RtpParameters().Mid == mid {
+						rtpParameters, direction = producer.RtpParameters(), "recvonly"
+						break
+					}
+				}
+			case "recvonly":
+				if consumer = selectConsumer(options.Consumers, usedConsumers, offered); consumer != nil {
+					usedConsumers[consumer] = true
+					rtpParameters = consumer.RtpParameters()
+					rtpParameters.Codecs = remapCodecs(rtpParameters.Codecs, parseCodecs(offered))
+					rtpParameters.HeaderExtensions = remapExtensions(rtpParameters.HeaderExtensions, parseExtensions(offered))
+					if len(rtpParameters.Codecs) > 0 {
+						direction = "sendonly"
+					}
+				}
+			}
+		}
+
+		if len(direction) == 0 {
+			// Reject the media section.
+			m.Formats = offered.Formats
+			m.Connection = "IN IP4 0.0.0.0"
+			m.Add("mid", mid)
+			m.Add("inactive", "")
+			continue
+		}
+
+		bundle = append(bundle, mid)
+
+		m.Port = 7
+		m.Connection = "IN IP4 127.0.0.1"
+		for _, codec := range rtpParameters.Codecs {
+			m.Formats = append(m.Formats, fmt.Sprint(codec.PayloadType))
+		}
+
+		m.Add("ice-ufrag", options.IceParameters.UsernameFragment)
+		m.Add("ice-pwd", options.IceParameters.Password)
+		m.Add("fingerprint", fingerprint)
+		m.Add("setup", setup)
+		m.Add("mid", mid)
+		m.Add(direction, "")
+
+		for _, candidate := range options.IceCandidates {
+			m.Add("candidate", FormatCandidate(candidate))
+		}
+		m.Add("end-of-candidates", "")
+		m.Add("rtcp-mux", "")
+		m.Add("rtcp-rsize", "")
+
+		addCodecs(m, rtpParameters.Codecs)
+
+		for _, ext := range rtpParameters.HeaderExtensions {
+			// The consumer sends its own mid, which differs from the offered one.
+			if ext.Uri == midExtensionUri && rtpParameters.Mid != mid {
+				continue
+			}
+			m.Add("extmap", fmt.Sprintf("%d %s", ext.Id, ext.Uri))
+		}
+
+		if consumer != nil {
+			addSsrcs(m, consumer)
+		} else {
+			addRids(m, rtpParameters.Encodings)
+		}
+	}
+
+	if len(bundle) == 0 {
+		return "", errors.New("sdp: no media section accepted")
+	}
+
+	if options.IceParameters.IceLite {
+		answer.Add("ice-lite", "")
+	}
+	answer.Add("group", "BUNDLE "+strings.Join(bundle, " "))
+	answer.Add("msid-semantic", " WMS *")
+
+	return answer.String(), nil
+}
+
+func selectConsumer(consumers []*mediasoup.Consumer, used map[*mediasoup.Consumer]bool, m *MediaDescription) *mediasoup.Consumer {
+	for _, consumer := range consumers {
+		if !used[consumer] && string(consumer.Kind()) == m.Kind && consumer.RtpParameters().Mid == m.Mid() {
+			return consumer
+		}
+	}
+	for _, consumer := range consumers {
+		if !used[consumer] && string(consumer.Kind()) == m.Kind {
+			return consumer
+		}
+	}
+	return nil
+}
+
+// remapCodecs returns the codecs with the payload types of the matching offered
+// codecs, which are the ones the remote receives. The apt of RTX codecs is
+// remapped too, and codecs without an offered match are dropped.
+func remapCodecs(codecs []*mediasoup.RtpCodecParameters, offered []*codec) (remapped []*mediasoup.RtpCodecParameters) {
+	payloadTypes := map[byte]byte{}
+	used := map[byte]bool{}
+
+	for _, c := range codecs {
+		if isRtxCodec(c) {
+			continue
+		}
+		capability := &mediasoup.RtpCodecCapability{
+			MimeType:   c.MimeType,
+			ClockRate:  c.ClockRate,
+			Channels:   c.Channels,
+			Parameters: c.Parameters,
+		}
+		for _, o := range offered {
+			if !o.isRtx() && !used[o.payloadType] && matchCodec(o, capability) {
+				payloadTypes[c.PayloadType] = o.payloadType
+				used[o.payloadType] = true
+				break
+			}
+		}
+	}
+
+	for _, c := range codecs {
+		answered := *c
+
+		if isRtxCodec(c) {
+			apt, ok := payloadTypes[c.Parameters.Apt]
+			if !ok {
+				continue
+			}
+			answered.PayloadType, answered.Parameters.Apt = 0, apt
+			for _, o := range offered {
+				if o.isRtx() && o.parameters.Apt == apt {
+					answered.PayloadType = o.payloadType
+					break
+				}
+			}
+			if answered.PayloadType == 0 {
+				continue
+			}
+		} else {
+			pt, ok := payloadTypes[c.PayloadType]
+			if !ok {
+				continue
+			}
+			answered.PayloadType = pt
+		}
+
+		remapped = append(remapped, &answered)
+	}
+
+	return
+}
+
+// remapExtensions returns the header extensions with the ids of the offered
+// ones of the same URI, extensions which are not offered are dropped.
+func remapExtensions(exts []mediasoup.RtpHeaderExtensionParameters, offered []mediasoup.RtpHeaderExtensionParameters) (remapped []mediasoup.RtpHeaderExtensionParameters) {
+	for _, ext := range exts {
+		for _, o := range offered {
+			if o.Uri == ext.Uri {
+				answered := ext
+				answered.Id = o.Id
+				remapped = append(remapped, answered)
+				break
+			}
+		}
+	}
+	return
+}
+
+func isRtxCodec(c *mediasoup.RtpCodecParameters) bool {
+	return strings.HasSuffix(strings.ToLower(c.MimeType), "/rtx")
+}
+
+func selectFingerprint(dtlsParameters mediasoup.DtlsParameters) (string, error) {
+	if len(dtlsParameters.Fingerprints) == 0 {
+		return "", errors.New("sdp: no DTLS fingerprint")
+	}
+
+	fingerprint := dtlsParameters.Fingerprints[0]
+
+	for _, fp := range dtlsParameters.Fingerprints {
+		if fp.Algorithm == "sha-256" {
+			fingerprint = fp
+			break
+		}
+	}
+
+	return fingerprint.Algorithm + " " + fingerprint.Value, nil
+}
+
+// FormatCandidate returns the value of the "a=candidate" attribute of an ICE
+// candidate.
+func FormatCandidate(candidate mediasoup.IceCandidate) string {
+	typ := candidate.Type
+	if len(typ) == 0 {
+		typ = "host"
+	}
+
+	value := fmt.Sprintf("%s 1 %s %d %s %d typ %s",
+		candidate.Foundation, candidate.Protocol, candidate.Priority, candidate.Ip, candidate.Port, typ)
+
+	if len(candidate.TcpType) > 0 {
+		value += " tcptype " + candidate.TcpType
+	}
+
+	return value
+}
+
+func addCodecs(m *MediaDescription, codecs []*mediasoup.RtpCodecParameters) {
+	for _, codec := range codecs {
+		name := codec.MimeType[strings.Index(codec.MimeType, "/")+1:]
+		rtpmap := fmt.Sprintf("%d %s/%d", codec.PayloadType, name, codec.ClockRate)
+
+		if codec.Channels > 1 {
+			rtpmap += fmt.Sprintf("/%d", codec.Channels)
+		}
+		m.Add("rtpmap", rtpmap)
+
+		if fmtp := formatFmtp(codec.Parameters); len(fmtp) > 0 {
+			m.Add("fmtp", fmt.Sprintf("%d %s", codec.PayloadType, fmtp))
+		}
+
+		for _, fb := range codec.RtcpFeedback {
+			value := fmt.Sprintf("%d %s", codec.PayloadType, fb.Type)
+			if len(fb.Parameter) > 0 {
+				value += " " + fb.Parameter
+			}
+			m.Add("rtcp-fb", value)
+		}
+	}
+}
+
+// addRids adds the rid/simulcast attributes receiving the simulcast streams
+// of a producer.
+func addRids(m *MediaDescription, encodings []mediasoup.RtpEncodingParameters) {
+	rids := []string{}
+
+	for _, encoding := range encodings {
+		if len(encoding.Rid) > 0 {
+			rids = append(rids, encoding.Rid)
+			m.Add("rid", encoding.Rid+" recv")
+		}
+	}
+
+	if len(rids) > 0 {
+		m.Add("simulcast", "recv "+strings.Join(rids, ";"))
+	}
+}
+
+// addSsrcs adds the msid and ssrc attributes of a consumer.
+func addSsrcs(m *MediaDescription, consumer *mediasoup.Consumer) {
+	rtpParameters := consumer.RtpParameters()
+
+	if len(rtpParameters.Encodings) == 0 {
+		return
+	}
+
+	encoding := rtpParameters.Encodings[0]
+	cname := rtpParameters.Rtcp.Cname
+	streamId := cname
+	if len(streamId) == 0 {
+		streamId = "-"
+	}
+	msid := streamId + " " + consumer.Id()
+
+	m.Add("msid", msid)
+
+	if encoding.Rtx != nil && encoding.Rtx.Ssrc != 0 {
+		m.Add("ssrc-group", fmt.Sprintf("FID %d %d", encoding.Ssrc, encoding.Rtx.Ssrc))
+	}
+
+	for _, ssrc := range []uint32{encoding.Ssrc, rtxSsrc(encoding)} {
+		if ssrc == 0 {
+			continue
+		}
+		if len(cname) > 0 {
+			m.Add("ssrc", fmt.Sprintf("%d cname:%s", ssrc, cname))
+		}
+		m.Add("ssrc", fmt.Sprintf("%d msid:%s", ssrc, msid))
+	}
+}
+
+func rtxSsrc(encoding mediasoup.RtpEncodingParameters) uint32 {
+	if encoding.Rtx == nil {
+		return 0
+	}
+	return encoding.Rtx.Ssrc
+}
@@ -0,0 +1,205 @@
+package sdp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/h264"
+)
+
+// codec is a payload type of a media section.
+type codec struct {
+	payloadType  byte
+	mimeType     string
+	clockRate    int
+	channels     int
+	parameters   mediasoup.RtpCodecSpecificParameters
+	rtcpFeedback []mediasoup.RtcpFeedback
+}
+
+func (c *codec) isRtx() bool {
+	return strings.HasSuffix(strings.ToLower(c.mimeType), "/rtx")
+}
+
+// parseCodecs returns the codecs of the media section in the order of the
+// "m=" line formats.
+func parseCodecs(m *MediaDescription) (codecs []*codec) {
+	byPayloadType := map[byte]*codec{}
+
+	for _, value := range m.Values("rtpmap") {
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			continue
+		}
+		pt, err := strconv.ParseUint(fields[0], 10, 8)
+		if err != nil {
+			continue
+		}
+		encoding := strings.Split(fields[1], "/")
+		if len(encoding) < 2 {
+			continue
+		}
+		c := &codec{
+			payloadType: byte(pt),
+			mimeType:    m.Kind + "/" + encoding[0],
+		}
+		c.clockRate, _ = strconv.Atoi(encoding[1])
+		if len(encoding) > 2 {
+			c.channels, _ = strconv.Atoi(encoding[2])
+		}
+		byPayloadType[c.payloadType] = c
+	}
+
+	for _, value := range m.Values("fmtp") {
+		fields := strings.SplitN(value, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		if pt, err := strconv.ParseUint(fields[0], 10, 8); err == nil && byPayloadType[byte(pt)] != nil {
+			byPayloadType[byte(pt)].parameters = parseFmtp(fields[1])
+		}
+	}
+
+	for _, value := range m.Values("rtcp-fb") {
+		fields := strings.Fields(value)
+		if len(fields) < 2 {
+			continue
+		}
+		fb := mediasoup.RtcpFeedback{Type: fields[1]}
+		if len(fields) > 2 {
+			fb.Parameter = fields[2]
+		}
+		for pt, c := range byPayloadType {
+			if fields[0] == "*" || fields[0] == strconv.Itoa(int(pt)) {
+				c.rtcpFeedback = append(c.rtcpFeedback, fb)
+			}
+		}
+	}
+
+	for _, format := range m.Formats {
+		if pt, err := strconv.ParseUint(format, 10, 8); err == nil && byPayloadType[byte(pt)] != nil {
+			codecs = append(codecs, byPayloadType[byte(pt)])
+		}
+	}
+
+	return
+}
+
+func parseFmtp(value string) (params mediasoup.RtpCodecSpecificParameters) {
+	for _, item := range strings.Split(value, ";") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := kv[0], kv[1]
+		n, _ := strconv.ParseUint(value, 10, 32)
+
+		switch key {
+		case "packetization-mode":
+			params.PacketizationMode = int(n)
+		case "profile-level-id":
+			params.ProfileLevelId = value
+		case "level-asymmetry-allowed":
+			params.LevelAsymmetryAllowed = int(n)
+		case "profile-id":
+			params.ProfileId = value
+		case "apt":
+			params.Apt = byte(n)
+		case "sprop-stereo":
+			params.SpropStereo = uint8(n)
+		case "useinbandfec":
+			params.Useinbandfec = uint8(n)
+		case "usedtx":
+			params.Usedtx = uint8(n)
+		case "maxplaybackrate":
+			params.Maxplaybackrate = uint32(n)
+		case "x-google-min-bitrate":
+			params.XGoogleMinBitrate = uint32(n)
+		case "x-google-max-bitrate":
+			params.XGoogleMaxBitrate = uint32(n)
+		case "x-google-start-bitrate":
+			params.XGoogleStartBitrate = uint32(n)
+		}
+	}
+
+	return
+}
+
+func formatFmtp(params mediasoup.RtpCodecSpecificParameters) string {
+	items := []string{}
+
+	add := func(key string, value interface{}) {
+		if s := fmt.Sprint(value); s != "0" && len(s) > 0 {
+			items = append(items, key+"="+s)
+		}
+	}
+
+	add("level-asymmetry-allowed", params.LevelAsymmetryAllowed)
+	add("packetization-mode", params.PacketizationMode)
+	add("profile-level-id", params.ProfileLevelId)
+	add("profile-id", params.ProfileId)
+	add("apt", params.Apt)
+	add("sprop-stereo", params.SpropStereo)
+	add("useinbandfec", params.Useinbandfec)
+	add("usedtx", params.Usedtx)
+	add("maxplaybackrate", params.Maxplaybackrate)
+	add("x-google-min-bitrate", params.XGoogleMinBitrate)
+	add("x-google-max-bitrate", params.XGoogleMaxBitrate)
+	add("x-google-start-bitrate", params.XGoogleStartBitrate)
+
+	return strings.Join(items, ";")
+}
+
+// matchCodec tells whether the offered codec is supported by the router
+// codec capability.
+func matchCodec(c *codec, capability *mediasoup.RtpCodecCapability) bool {
+	if !strings.EqualFold(c.mimeType, capability.MimeType) || c.clockRate != capability.ClockRate {
+		return false
+	}
+	if c.channels > 0 && capability.Channels > 0 && c.channels != capability.Channels {
+		return false
+	}
+
+	switch strings.ToLower(c.mimeType) {
+	case "video/h264":
+		return c.parameters.PacketizationMode == capability.Parameters.PacketizationMode &&
+			h264.IsSameProfile(c.parameters.ProfileLevelId, capability.Parameters.ProfileLevelId)
+
+	case "video/vp9":
+		return profileId(c.parameters.ProfileId) == profileId(capability.Parameters.ProfileId)
+	}
+
+	return true
+}
+
+func profileId(id string) string {
+	if len(id) == 0 {
+		return "0"
+	}
+	return id
+}
+
+func (c *codec) rtpCodecParameters() *mediasoup.RtpCodecParameters {
+	return &mediasoup.RtpCodecParameters{
+		MimeType:     c.mimeType,
+		PayloadType:  c.payloadType,
+		ClockRate:    c.clockRate,
+		Channels:     c.channels,
+		Parameters:   c.parameters,
+		RtcpFeedback: c.rtcpFeedback,
+	}
+}
+
+func (c *codec) rtpCodecCapability(kind mediasoup.MediaKind) *mediasoup.RtpCodecCapability {
+	return &mediasoup.RtpCodecCapability{
+		Kind:                 kind,
+		MimeType:             c.mimeType,
+		PreferredPayloadType: c.payloadType,
+		ClockRate:            c.clockRate,
+		Channels:             c.channels,
+		Parameters:           c.parameters,
+		RtcpFeedback:         c.rtcpFeedback,
+	}
+}
@@ -0,0 +1,325 @@
+package sdp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+// Offer is a remote SDP offer to be answered by a WebRtcTransport.
+type Offer struct {
+	*SessionDescription
+}
+
+// ParseOffer parses a remote SDP offer.
+func ParseOffer(sdp string) (*Offer, error) {
+	s, err := Parse(sdp)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.Media) == 0 {
+		return nil, errors.New("sdp: offer without media")
+	}
+	return &Offer{SessionDescription: s}, nil
+}
+
+// attribute returns the value of the attribute from the media section, or
+// from the session if the media section does not have it.
+func (o *Offer) attribute(m *MediaDescription, key string) (string, bool) {
+	if value, ok := m.Attribute(key); ok {
+		return value, true
+	}
+	return o.Attribute(key)
+}
+
+/**
+ * ConnectOptions returns the options to call WebRtcTransport.Connect() with,
+ * taken from the DTLS fingerprint and setup role of the offer.
+ */
+func (o *Offer) ConnectOptions() (options mediasoup.TransportConnectOptions, err error) {
+	m := o.Media[0]
+
+	fingerprint, ok := o.attribute(m, "fingerprint")
+	if !ok {
+		err = errors.New(`sdp: missing "a=fingerprint"`)
+		return
+	}
+	fields := strings.Fields(fingerprint)
+	if len(fields) != 2 {
+		err = fmt.Errorf("sdp: invalid fingerprint %q", fingerprint)
+		return
+	}
+
+	setup, _ := o.attribute(m, "setup")
+
+	options.DtlsParameters = &mediasoup.DtlsParameters{
+		Role: remoteDtlsRole(setup),
+		Fingerprints: []mediasoup.DtlsFingerprint{
+			{Algorithm: strings.ToLower(fields[0]), Value: fields[1]},
+		},
+	}
+
+	return
+}
+
+// remoteDtlsRole returns the DTLS role of the offerer. An "actpass" offerer is
+// answered with "passive", so it takes the client role.
+func remoteDtlsRole(setup string) mediasoup.DtlsRole {
+	if setup == "passive" {
+		return mediasoup.DtlsRole_Server
+	}
+	return mediasoup.DtlsRole_Client
+}
+
+// isRtp tells whether the media section carries audio or video.
+func isRtp(m *MediaDescription) bool {
+	return m.Port != 0 && (m.Kind == "audio" || m.Kind == "video")
+}
+
+/**
+ * ProducerOptions returns the options to call Transport.Produce() with, one
+ * for every audio or video section the remote sends. Each section is reduced
+ * to the first codec (and its RTX codec) supported by the given router
+ * capabilities, and to the supported header extensions.
+ */
+func (o *Offer) ProducerOptions(routerRtpCapabilities mediasoup.RtpCapabilities) (options []mediasoup.ProducerOptions, err error) {
+	for _, m := range o.Media {
+		if !isRtp(m) || (m.Direction() != "sendonly" && m.Direction() != "sendrecv") {
+			continue
+		}
+
+		rtpParameters, err := o.producerRtpParameters(m, routerRtpCapabilities)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, mediasoup.ProducerOptions{
+			Kind:          mediasoup.MediaKind(m.Kind),
+			RtpParameters: rtpParameters,
+		})
+	}
+
+	return
+}
+
+func (o *Offer) producerRtpParameters(m *MediaDescription, caps mediasoup.RtpCapabilities) (params mediasoup.RtpParameters, err error) {
+	params.Mid = m.Mid()
+
+	if len(params.Mid) == 0 {
+		err = errors.New(`sdp: missing "a=mid"`)
+		return
+	}
+
+	codecs := parseCodecs(m)
+
+	var selected *codec
+
+	for _, c := range codecs {
+		if c.isRtx() {
+			continue
+		}
+		for _, capability := range caps.Codecs {
+			if capability.Kind == mediasoup.MediaKind(m.Kind) && matchCodec(c, capability) {
+				selected = c
+				break
+			}
+		}
+		if selected != nil {
+			break
+		}
+	}
+
+	if selected == nil {
+		err = fmt.Errorf("sdp: no supported codec in media section %q", params.Mid)
+		return
+	}
+
+	params.Codecs = append(params.Codecs, selected.rtpCodecParameters())
+
+	for _, c := range codecs {
+		if c.isRtx() && c.parameters.Apt == selected.payloadType {
+			params.Codecs = append(params.Codecs, c.rtpCodecParameters())
+			break
+		}
+	}
+
+	for _, ext := range parseExtensions(m) {
+		for _, capability := range caps.HeaderExtensions {
+			if capability.Kind == mediasoup.MediaKind(m.Kind) && capability.Uri == ext.Uri {
+				params.HeaderExtensions = append(params.HeaderExtensions, ext)
+				break
+			}
+		}
+	}
+
+	params.Encodings = parseEncodings(m)
+
+	for _, value := range m.Values("ssrc") {
+		if fields := strings.SplitN(value, " ", 2); len(fields) == 2 && strings.HasPrefix(fields[1], "cname:") {
+			params.Rtcp.Cname = strings.TrimPrefix(fields[1], "cname:")
+			break
+		}
+	}
+
+	if _, ok := m.Attribute("rtcp-rsize"); ok {
+		reducedSize := true
+		params.Rtcp.ReducedSize = &reducedSize
+	}
+
+	return
+}
+
+func parseExtensions(m *MediaDescription) (exts []mediasoup.RtpHeaderExtensionParameters) {
+	for _, value := range m.Values("extmap") {
+		fields := strings.Fields(value)
+		if len(fields) < 2 {
+			continue
+		}
+		// "id/direction uri"
+		id, err := strconv.Atoi(strings.SplitN(fields[0], "/", 2)[0])
+		if err != nil {
+			continue
+		}
+		exts = append(exts, mediasoup.RtpHeaderExtensionParameters{Uri: fields[1], Id: id})
+	}
+	return
+}
+
+// parseEncodings returns the encodings from "a=simulcast" and "a=rid", or else
+// from the "a=ssrc" and "a=ssrc-group" attributes.
+func parseEncodings(m *MediaDescription) (encodings []mediasoup.RtpEncodingParameters) {
+	if rids := sendRids(m); len(rids) > 0 {
+		for _, rid := range rids {
+			encodings = append(encodings, mediasoup.RtpEncodingParameters{Rid: rid})
+		}
+		return
+	}
+
+	ssrcs := []uint32{}
+	rtxSsrcs := map[uint32]uint32{}
+
+	for _, value := range m.Values("ssrc-group") {
+		fields := strings.Fields(value)
+		if len(fields) < 3 {
+			continue
+		}
+		switch fields[0] {
+		case "SIM":
+			ssrcs = ssrcs[:0]
+			for _, field := range fields[1:] {
+				ssrcs = append(ssrcs, parseSsrc(field))
+			}
+		case "FID":
+			rtxSsrcs[parseSsrc(fields[1])] = parseSsrc(fields[2])
+		}
+	}
+
+	if len(ssrcs) == 0 {
+		for _, value := range m.Values("ssrc") {
+			ssrc := parseSsrc(strings.SplitN(value, " ", 2)[0])
+			isRtx := false
+			for _, rtxSsrc := range rtxSsrcs {
+				isRtx = isRtx || rtxSsrc == ssrc
+			}
+			if ssrc != 0 && !isRtx {
+				ssrcs = append(ssrcs, ssrc)
+				break
+			}
+		}
+	}
+
+	for _, ssrc := range ssrcs {
+		encoding := mediasoup.RtpEncodingParameters{Ssrc: ssrc}
+		if rtxSsrc, ok := rtxSsrcs[ssrc]; ok {
+			encoding.Rtx = &mediasoup.RtpEncodingRtx{Ssrc: rtxSsrc}
+		}
+		encodings = append(encodings, encoding)
+	}
+
+	return
+}
+
+// sendRids returns the rids of "a=simulcast:send", without paused ("~") and
+// alternative streams.
+func sendRids(m *MediaDescription) (rids []string) {
+	simulcast, ok := m.Attribute("simulcast")
+	if !ok {
+		return
+	}
+
+	fields := strings.Fields(simulcast)
+
+	for i := 0; i+1 < len(fields); i += 2 {
+		if fields[i] != "send" {
+			continue
+		}
+		for _, stream := range strings.Split(fields[i+1], ";") {
+			rid := strings.TrimPrefix(strings.Split(stream, ",")[0], "~")
+			if len(rid) > 0 {
+				rids = append(rids, rid)
+			}
+		}
+	}
+
+	return
+}
+
+func parseSsrc(value string) uint32 {
+	ssrc, _ := strconv.ParseUint(value, 10, 32)
+	return uint32(ssrc)
+}
+
+/**
+ * RtpCapabilities returns the receiving RTP capabilities of the remote, taken
+ * from the audio and video sections it receives. They are used to call
+ * Transport.Consume(). The header extensions take the ids of the router,
+ * which the Consumers need, and Answer maps them back to the offered ids.
+ */
+func (o *Offer) RtpCapabilities(routerRtpCapabilities mediasoup.RtpCapabilities) (caps mediasoup.RtpCapabilities) {
+	codecs := map[string]bool{}
+	exts := map[string]bool{}
+
+	for _, m := range o.Media {
+		if !isRtp(m) || (m.Direction() != "recvonly" && m.Direction() != "sendrecv") {
+			continue
+		}
+
+		kind := mediasoup.MediaKind(m.Kind)
+
+		for _, c := range parseCodecs(m) {
+			key := fmt.Sprintf("%s %d", kind, c.payloadType)
+			if !codecs[key] {
+				codecs[key] = true
+				caps.Codecs = append(caps.Codecs, c.rtpCodecCapability(kind))
+			}
+		}
+
+		for _, ext := range parseExtensions(m) {
+			key := fmt.Sprintf("%s %s", kind, ext.Uri)
+			if !exts[key] {
+				exts[key] = true
+				caps.HeaderExtensions = append(caps.HeaderExtensions, &mediasoup.RtpHeaderExtension{
+					Kind:        kind,
+					Uri:         ext.Uri,
+					PreferredId: routerExtensionId(routerRtpCapabilities, kind, ext),
+				})
+			}
+		}
+	}
+
+	return
+}
+
+// routerExtensionId returns the id of the router for an offered header
+// extension, or the offered id if the router doesn't support it.
+func routerExtensionId(caps mediasoup.RtpCapabilities, kind mediasoup.MediaKind, ext mediasoup.RtpHeaderExtensionParameters) int {
+	for _, capExt := range caps.HeaderExtensions {
+		if (len(capExt.Kind) == 0 || capExt.Kind == kind) && capExt.Uri == ext.Uri {
+			return capExt.PreferredId
+		}
+	}
+	return ext.Id
+}
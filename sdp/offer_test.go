@@ -0,0 +1,295 @@
+package sdp
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const playOffer = `v=0
+o=- 1 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0 1
+a=fingerprint:sha-256 D1:2C:BE:AD:C4:F6:64:5C:25:16:11:9C:AF:E7:0F:73:79:36:4E:9C:1E:15:54:39:0C:06:8B:ED:96:86:00:39
+a=setup:passive
+m=audio 9 UDP/TLS/RTP/SAVPF 111
+c=IN IP4 0.0.0.0
+a=mid:0
+a=recvonly
+a=rtcp-mux
+a=rtpmap:111 opus/48000/2
+a=fmtp:111 useinbandfec=1
+m=video 9 UDP/TLS/RTP/SAVPF 96 97
+c=IN IP4 0.0.0.0
+a=mid:1
+a=recvonly
+a=rtcp-mux
+a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid
+a=rtpmap:96 VP8/90000
+a=rtcp-fb:96 nack
+a=rtcp-fb:96 nack pli
+a=rtcp-fb:96 ccm fir
+a=rtpmap:97 rtx/90000
+a=fmtp:97 apt=96
+`
+
+func createTransport(t *testing.T) (*mediasoup.Router, *mediasoup.WebRtcTransport) {
+	router, _ := workertest.NewRouter(t)
+
+	transport, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+		EnableTcp: true,
+	})
+	require.NoError(t, err)
+
+	return router, transport
+}
+
+func TestOfferConnectOptions(t *testing.T) {
+	offer, err := ParseOffer(publishOffer)
+	require.NoError(t, err)
+
+	options, err := offer.ConnectOptions()
+	require.NoError(t, err)
+	assert.Equal(t, &mediasoup.DtlsParameters{
+		Role: mediasoup.DtlsRole_Client,
+		Fingerprints: []mediasoup.DtlsFingerprint{
+			{
+				Algorithm: "sha-256",
+				Value:     "D1:2C:BE:AD:C4:F6:64:5C:25:16:11:9C:AF:E7:0F:73:79:36:4E:9C:1E:15:54:39:0C:06:8B:ED:96:86:00:39",
+			},
+		},
+	}, options.DtlsParameters)
+
+	// Session level attributes.
+	offer, err = ParseOffer(playOffer)
+	require.NoError(t, err)
+
+	options, err = offer.ConnectOptions()
+	require.NoError(t, err)
+	assert.Equal(t, mediasoup.DtlsRole(mediasoup.DtlsRole_Server), options.DtlsParameters.Role)
+	assert.Len(t, options.DtlsParameters.Fingerprints, 1)
+
+	offer, err = ParseOffer("v=0\r\nm=audio 9 UDP/TLS/RTP/SAVPF 111\r\n")
+	require.NoError(t, err)
+
+	_, err = offer.ConnectOptions()
+	assert.Error(t, err)
+}
+
+func TestOfferProducerOptions(t *testing.T) {
+	router, _ := createTransport(t)
+
+	offer, err := ParseOffer(publishOffer)
+	require.NoError(t, err)
+
+	options, err := offer.ProducerOptions(router.RtpCapabilities())
+	require.NoError(t, err)
+	require.Len(t, options, 2)
+
+	audio := options[0]
+	assert.Equal(t, mediasoup.MediaKind_Audio, audio.Kind)
+	assert.Equal(t, "0", audio.RtpParameters.Mid)
+	require.Len(t, audio.RtpParameters.Codecs, 1)
+	assert.Equal(t, "audio/opus", audio.RtpParameters.Codecs[0].MimeType)
+	assert.EqualValues(t, 111, audio.RtpParameters.Codecs[0].PayloadType)
+	assert.Equal(t, 2, audio.RtpParameters.Codecs[0].Channels)
+	assert.EqualValues(t, 1, audio.RtpParameters.Codecs[0].Parameters.Useinbandfec)
+	assert.Equal(t, []mediasoup.RtcpFeedback{{Type: "transport-cc"}}, audio.RtpParameters.Codecs[0].RtcpFeedback)
+	assert.Equal(t, []mediasoup.RtpEncodingParameters{{Ssrc: 1001}}, audio.RtpParameters.Encodings)
+	assert.Equal(t, "publisher", audio.RtpParameters.Rtcp.Cname)
+	assert.Nil(t, audio.RtpParameters.Rtcp.ReducedSize)
+
+	video := options[1]
+	assert.Equal(t, mediasoup.MediaKind(mediasoup.MediaKind_Video), video.Kind)
+	assert.Equal(t, "1", video.RtpParameters.Mid)
+	require.Len(t, video.RtpParameters.Codecs, 2)
+	assert.Equal(t, "video/VP8", video.RtpParameters.Codecs[0].MimeType)
+	assert.Equal(t, "video/rtx", video.RtpParameters.Codecs[1].MimeType)
+	assert.EqualValues(t, 96, video.RtpParameters.Codecs[1].Parameters.Apt)
+	assert.Equal(t, []mediasoup.RtpEncodingParameters{{Rid: "q"}, {Rid: "h"}, {Rid: "f"}}, video.RtpParameters.Encodings)
+	assert.True(t, *video.RtpParameters.Rtcp.ReducedSize)
+
+	uris := []string{}
+	for _, ext := range video.RtpParameters.HeaderExtensions {
+		uris = append(uris, ext.Uri)
+	}
+	assert.NotContains(t, uris, "urn:ietf:params:rtp-hdrext:unknown")
+	assert.Contains(t, uris, "urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id")
+
+	// No supported codec.
+	_, err = offer.ProducerOptions(mediasoup.RtpCapabilities{})
+	assert.Error(t, err)
+}
+
+func TestOfferAnswer_Publish(t *testing.T) {
+	router, transport := createTransport(t)
+
+	offer, err := ParseOffer(publishOffer)
+	require.NoError(t, err)
+
+	connectOptions, err := offer.ConnectOptions()
+	require.NoError(t, err)
+	require.NoError(t, transport.Connect(connectOptions))
+
+	producerOptions, err := offer.ProducerOptions(router.RtpCapabilities())
+	require.NoError(t, err)
+
+	answerOptions := NewAnswerOptions(transport)
+
+	for _, options := range producerOptions {
+		producer, err := transport.Produce(options)
+		require.NoError(t, err)
+		answerOptions.Producers = append(answerOptions.Producers, producer)
+	}
+
+	answerSdp, err := offer.Answer(answerOptions)
+	require.NoError(t, err)
+
+	answer, err := Parse(answerSdp)
+	require.NoError(t, err)
+	require.Len(t, answer.Media, 3)
+
+	group, _ := answer.Attribute("group")
+	assert.Equal(t, "BUNDLE 0 1", group)
+
+	audio := answer.Media[0]
+	assert.Equal(t, []string{"111"}, audio.Formats)
+	assert.Equal(t, "recvonly", audio.Direction())
+	assert.Equal(t, "0", audio.Mid())
+	assert.Equal(t, []string{"111 opus/48000/2"}, audio.Values("rtpmap"))
+
+	ufrag, _ := audio.Attribute("ice-ufrag")
+	assert.Equal(t, transport.IceParameters().UsernameFragment, ufrag)
+	setup, _ := audio.Attribute("setup")
+	assert.Equal(t, "passive", setup)
+	assert.Len(t, audio.Values("candidate"), len(transport.IceCandidates()))
+
+	video := answer.Media[1]
+	assert.Equal(t, []string{"96", "97"}, video.Formats)
+	assert.Equal(t, []string{"96 VP8/90000", "97 rtx/90000"}, video.Values("rtpmap"))
+	assert.Equal(t, []string{"97 apt=96"}, video.Values("fmtp"))
+	assert.Equal(t, []string{"q recv", "h recv", "f recv"}, video.Values("rid"))
+	assert.Equal(t, []string{"recv q;h;f"}, video.Values("simulcast"))
+
+	// The data channel is rejected.
+	assert.Equal(t, 0, answer.Media[2].Port)
+	assert.Equal(t, "2", answer.Media[2].Mid())
+}
+
+func TestOfferAnswer_Play(t *testing.T) {
+	router, transport := createTransport(t)
+
+	producerTransport, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+	})
+	require.NoError(t, err)
+
+	videoProducer, err := producerTransport.Produce(workertest.VideoProducerOptions())
+	require.NoError(t, err)
+
+	offer, err := ParseOffer(playOffer)
+	require.NoError(t, err)
+
+	rtpCapabilities := offer.RtpCapabilities(router.RtpCapabilities())
+	require.Len(t, rtpCapabilities.Codecs, 3)
+	assert.Equal(t, "audio/opus", rtpCapabilities.Codecs[0].MimeType)
+	assert.EqualValues(t, 96, rtpCapabilities.Codecs[1].PreferredPayloadType)
+
+	// The id of the router, the offered one is answered.
+	assert.Equal(t, 1, rtpCapabilities.HeaderExtensions[0].PreferredId)
+
+	consumer, err := transport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      videoProducer.Id(),
+		RtpCapabilities: rtpCapabilities,
+		Mid:             "1",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1", consumer.RtpParameters().Mid)
+
+	answerOptions := NewAnswerOptions(transport)
+	answerOptions.Consumers = []*mediasoup.Consumer{consumer}
+
+	answerSdp, err := offer.Answer(answerOptions)
+	require.NoError(t, err)
+
+	answer, err := Parse(answerSdp)
+	require.NoError(t, err)
+
+	group, _ := answer.Attribute("group")
+	assert.Equal(t, "BUNDLE 1", group)
+
+	// No audio consumer.
+	assert.Equal(t, 0, answer.Media[0].Port)
+
+	video := answer.Media[1]
+	assert.Equal(t, "sendonly", video.Direction())
+
+	// Payload types are the offered ones, whichever the router uses.
+	assert.Equal(t, []string{"96", "97"}, video.Formats)
+	assert.Equal(t, []string{"96 VP8/90000", "97 rtx/90000"}, video.Values("rtpmap"))
+	assert.Equal(t, []string{"97 apt=96"}, video.Values("fmtp"))
+	assert.Contains(t, video.Values("rtcp-fb"), "96 nack pli")
+
+	setup, _ := video.Attribute("setup")
+	assert.Equal(t, "active", setup)
+
+	encoding := consumer.RtpParameters().Encodings[0]
+	require.NotNil(t, encoding.Rtx)
+
+	fid, _ := video.Attribute("ssrc-group")
+	assert.Equal(t, "FID "+itoa(encoding.Ssrc)+" "+itoa(encoding.Rtx.Ssrc), fid)
+	assert.Contains(t, video.Values("ssrc"), itoa(encoding.Ssrc)+" msid:"+consumer.RtpParameters().Rtcp.Cname+" "+consumer.Id())
+
+	assert.Equal(t, []string{"4 urn:ietf:params:rtp-hdrext:sdes:mid"}, video.Values("extmap"))
+
+	// Nothing to answer.
+	_, err = offer.Answer(NewAnswerOptions(transport))
+	assert.Error(t, err)
+}
+
+func TestOfferAnswer_PlayWithoutMid(t *testing.T) {
+	router, transport := createTransport(t)
+
+	producerTransport, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+	})
+	require.NoError(t, err)
+
+	videoProducer, err := producerTransport.Produce(workertest.VideoProducerOptions())
+	require.NoError(t, err)
+
+	offer, err := ParseOffer(playOffer)
+	require.NoError(t, err)
+
+	consumer, err := transport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      videoProducer.Id(),
+		RtpCapabilities: offer.RtpCapabilities(router.RtpCapabilities()),
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, "1", consumer.RtpParameters().Mid)
+
+	answerOptions := NewAnswerOptions(transport)
+	answerOptions.Consumers = []*mediasoup.Consumer{consumer}
+
+	answerSdp, err := offer.Answer(answerOptions)
+	require.NoError(t, err)
+
+	answer, err := Parse(answerSdp)
+	require.NoError(t, err)
+
+	// The consumer is answered in the video section, but without the mid
+	// extension as it sends another mid.
+	video := answer.Media[1]
+	assert.Equal(t, "sendonly", video.Direction())
+	assert.Empty(t, video.Values("extmap"))
+}
+
+func itoa(n uint32) string {
+	return strconv.FormatUint(uint64(n), 10)
+}
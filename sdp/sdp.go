@@ -0,0 +1,221 @@
+// Package sdp bridges SDP offer/answer and the ORTC structures of mediasoup, so
+// that a WebRtcTransport can be negotiated with a plain RTCPeerConnection or a
+// WHIP/WHEP client.
+package sdp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Attribute is an "a=" line. Value is empty for property attributes such as
+// "a=rtcp-mux".
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+func (a Attribute) String() string {
+	if len(a.Value) == 0 {
+		return a.Key
+	}
+	return a.Key + ":" + a.Value
+}
+
+// MediaDescription is a media section, starting with a "m=" line.
+type MediaDescription struct {
+	Kind       string
+	Port       int
+	Protocol   string
+	Formats    []string
+	Connection string
+	Attributes []Attribute
+}
+
+// Attribute returns the value of the first attribute with the given key.
+func (m *MediaDescription) Attribute(key string) (string, bool) {
+	return findAttribute(m.Attributes, key)
+}
+
+// Values returns the values of all the attributes with the given key.
+func (m *MediaDescription) Values(key string) []string {
+	return findValues(m.Attributes, key)
+}
+
+// Add appends an attribute.
+func (m *MediaDescription) Add(key, value string) {
+	m.Attributes = append(m.Attributes, Attribute{Key: key, Value: value})
+}
+
+// Mid returns the value of the "a=mid" attribute.
+func (m *MediaDescription) Mid() string {
+	mid, _ := m.Attribute("mid")
+	return mid
+}
+
+// Direction returns "sendrecv", "sendonly", "recvonly" or "inactive". Default
+// "sendrecv".
+func (m *MediaDescription) Direction() string {
+	for _, attr := range m.Attributes {
+		switch attr.Key {
+		case "sendrecv", "sendonly", "recvonly", "inactive":
+			return attr.Key
+		}
+	}
+	return "sendrecv"
+}
+
+// SessionDescription is a parsed SDP.
+type SessionDescription struct {
+	Origin      string
+	SessionName string
+	Timing      string
+	Attributes  []Attribute
+	Media       []*MediaDescription
+}
+
+// Attribute returns the value of the first session level attribute with the
+// given key.
+func (s *SessionDescription) Attribute(key string) (string, bool) {
+	return findAttribute(s.Attributes, key)
+}
+
+// Add appends a session level attribute.
+func (s *SessionDescription) Add(key, value string) {
+	s.Attributes = append(s.Attributes, Attribute{Key: key, Value: value})
+}
+
+// Parse parses a SDP. Lines other than "v=", "o=", "s=", "t=", "c=", "m=" and
+// "a=" are ignored.
+func Parse(sdp string) (s *SessionDescription, err error) {
+	lines := strings.Split(strings.TrimSpace(sdp), "\n")
+
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "v=0" {
+		return nil, errors.New(`sdp: missing "v=0"`)
+	}
+
+	s = &SessionDescription{}
+
+	var media *MediaDescription
+
+	for i, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+
+		if len(line) == 0 {
+			continue
+		}
+		if len(line) < 2 || line[1] != '=' {
+			return nil, fmt.Errorf("sdp: invalid line %d: %q", i+2, line)
+		}
+
+		value := line[2:]
+
+		switch line[0] {
+		case 'o':
+			s.Origin = value
+		case 's':
+			s.SessionName = value
+		case 't':
+			s.Timing = value
+		case 'c':
+			if media != nil {
+				media.Connection = value
+			}
+		case 'm':
+			if media, err = parseMedia(value); err != nil {
+				return nil, fmt.Errorf("sdp: invalid line %d: %s", i+2, err)
+			}
+			s.Media = append(s.Media, media)
+		case 'a':
+			attr := parseAttribute(value)
+			if media != nil {
+				media.Attributes = append(media.Attributes, attr)
+			} else {
+				s.Attributes = append(s.Attributes, attr)
+			}
+		}
+	}
+
+	return
+}
+
+// String returns the SDP with CRLF line endings.
+func (s *SessionDescription) String() string {
+	b := &strings.Builder{}
+
+	writeLine := func(typ byte, value string) {
+		b.WriteByte(typ)
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteString("\r\n")
+	}
+
+	writeLine('v', "0")
+	writeLine('o', s.Origin)
+	writeLine('s', s.SessionName)
+	writeLine('t', s.Timing)
+
+	for _, attr := range s.Attributes {
+		writeLine('a', attr.String())
+	}
+
+	for _, m := range s.Media {
+		writeLine('m', fmt.Sprintf("%s %d %s %s", m.Kind, m.Port, m.Protocol, strings.Join(m.Formats, " ")))
+
+		if len(m.Connection) > 0 {
+			writeLine('c', m.Connection)
+		}
+		for _, attr := range m.Attributes {
+			writeLine('a', attr.String())
+		}
+	}
+
+	return b.String()
+}
+
+func parseMedia(value string) (*MediaDescription, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return nil, errors.New("too few fields")
+	}
+
+	port, err := strconv.Atoi(strings.SplitN(fields[1], "/", 2)[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &MediaDescription{
+		Kind:     fields[0],
+		Port:     port,
+		Protocol: fields[2],
+		Formats:  fields[3:],
+	}, nil
+}
+
+func parseAttribute(value string) Attribute {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) == 1 {
+		return Attribute{Key: parts[0]}
+	}
+	return Attribute{Key: parts[0], Value: parts[1]}
+}
+
+func findAttribute(attrs []Attribute, key string) (string, bool) {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+func findValues(attrs []Attribute, key string) (values []string) {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			values = append(values, attr.Value)
+		}
+	}
+	return
+}
@@ -0,0 +1,136 @@
+package sdp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const publishOffer = `v=0
+o=- 4611731400430051336 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0 1 2
+a=msid-semantic: WMS stream
+m=audio 9 UDP/TLS/RTP/SAVPF 111 0
+c=IN IP4 0.0.0.0
+a=ice-ufrag:EsAw
+a=ice-pwd:P2uYro0UCOQ4zxjKXaWCBui1
+a=fingerprint:sha-256 D1:2C:BE:AD:C4:F6:64:5C:25:16:11:9C:AF:E7:0F:73:79:36:4E:9C:1E:15:54:39:0C:06:8B:ED:96:86:00:39
+a=setup:actpass
+a=mid:0
+a=extmap:1 urn:ietf:params:rtp-hdrext:ssrc-audio-level
+a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid
+a=sendonly
+a=msid:stream audio
+a=rtcp-mux
+a=rtpmap:111 opus/48000/2
+a=rtcp-fb:111 transport-cc
+a=fmtp:111 minptime=10;useinbandfec=1
+a=rtpmap:0 PCMU/8000
+a=ssrc:1001 cname:publisher
+a=ssrc:1001 msid:stream audio
+m=video 9 UDP/TLS/RTP/SAVPF 96 97 102 103
+c=IN IP4 0.0.0.0
+a=ice-ufrag:EsAw
+a=ice-pwd:P2uYro0UCOQ4zxjKXaWCBui1
+a=fingerprint:sha-256 D1:2C:BE:AD:C4:F6:64:5C:25:16:11:9C:AF:E7:0F:73:79:36:4E:9C:1E:15:54:39:0C:06:8B:ED:96:86:00:39
+a=setup:actpass
+a=mid:1
+a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid
+a=extmap:10 urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id
+a=extmap:11 urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id
+a=extmap:13 urn:3gpp:video-orientation
+a=extmap:14 urn:ietf:params:rtp-hdrext:unknown
+a=sendonly
+a=msid:stream video
+a=rtcp-mux
+a=rtcp-rsize
+a=rtpmap:96 VP8/90000
+a=rtcp-fb:96 goog-remb
+a=rtcp-fb:96 ccm fir
+a=rtcp-fb:96 nack
+a=rtcp-fb:96 nack pli
+a=rtpmap:97 rtx/90000
+a=fmtp:97 apt=96
+a=rtpmap:102 H264/90000
+a=rtcp-fb:102 nack
+a=fmtp:102 level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f
+a=rtpmap:103 rtx/90000
+a=fmtp:103 apt=102
+a=rid:q send
+a=rid:h send
+a=rid:f send
+a=simulcast:send q;h;~f
+m=application 9 UDP/DTLS/SCTP webrtc-datachannel
+c=IN IP4 0.0.0.0
+a=mid:2
+a=sctp-port:5000
+`
+
+func TestParse(t *testing.T) {
+	s, err := Parse(publishOffer)
+	require.NoError(t, err)
+
+	assert.Equal(t, "-", s.SessionName)
+	assert.Equal(t, "0 0", s.Timing)
+
+	group, ok := s.Attribute("group")
+	assert.True(t, ok)
+	assert.Equal(t, "BUNDLE 0 1 2", group)
+
+	require.Len(t, s.Media, 3)
+
+	audio := s.Media[0]
+	assert.Equal(t, "audio", audio.Kind)
+	assert.Equal(t, 9, audio.Port)
+	assert.Equal(t, "UDP/TLS/RTP/SAVPF", audio.Protocol)
+	assert.Equal(t, []string{"111", "0"}, audio.Formats)
+	assert.Equal(t, "IN IP4 0.0.0.0", audio.Connection)
+	assert.Equal(t, "0", audio.Mid())
+	assert.Equal(t, "sendonly", audio.Direction())
+	assert.Equal(t, []string{"1001 cname:publisher", "1001 msid:stream audio"}, audio.Values("ssrc"))
+
+	_, ok = audio.Attribute("rtcp-mux")
+	assert.True(t, ok)
+
+	assert.Equal(t, "sendrecv", s.Media[2].Direction())
+
+	// String() is parsed back to the same description.
+	again, err := Parse(s.String())
+	require.NoError(t, err)
+	assert.Equal(t, s, again)
+	assert.True(t, strings.HasSuffix(s.String(), "a=sctp-port:5000\r\n"))
+}
+
+func TestParse_Invalid(t *testing.T) {
+	_, err := Parse("")
+	assert.Error(t, err)
+
+	_, err = Parse("o=- 1 2 IN IP4 127.0.0.1")
+	assert.Error(t, err)
+
+	_, err = Parse("v=0\r\nm=audio\r\n")
+	assert.Error(t, err)
+
+	_, err = Parse("v=0\r\ngarbage\r\n")
+	assert.Error(t, err)
+
+	_, err = ParseOffer("v=0\r\ns=-\r\n")
+	assert.Error(t, err)
+}
+
+func TestFmtp(t *testing.T) {
+	params := parseFmtp("level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f")
+
+	assert.Equal(t, 1, params.LevelAsymmetryAllowed)
+	assert.Equal(t, 1, params.PacketizationMode)
+	assert.Equal(t, "42001f", params.ProfileLevelId)
+	assert.Equal(t, "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f", formatFmtp(params))
+
+	params = parseFmtp("minptime=10; useinbandfec=1")
+	assert.EqualValues(t, 1, params.Useinbandfec)
+	assert.Equal(t, "useinbandfec=1", formatFmtp(params))
+}
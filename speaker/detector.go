@@ -0,0 +1,357 @@
+// Package speaker detects the dominant speaker of a room from the volumes
+// reported by an AudioLevelObserver, without relying on the worker's
+// ActiveSpeakerObserver. Volumes are smoothed per producer, and a producer
+// must be louder than the current speaker by a margin for a minimum duration
+// before taking over, so that short noises don't switch the speaker.
+package speaker
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+// Volume of the producers missing from a "volumes" event.
+const silenceVolume = -127
+
+type DetectorOptions struct {
+	/**
+	 * Minimum smoothed volume (in dBvo from -127 to 0) of a speaker.
+	 * Default -60.
+	 */
+	Threshold *int
+
+	/**
+	 * Margin (in dB) by which the smoothed volume of a producer must exceed
+	 * the one of the current speaker to take over, 0 switching to any louder
+	 * producer. Default 6.
+	 */
+	Hysteresis *int
+
+	/**
+	 * Duration a producer must stay the loudest before becoming the speaker.
+	 * Default 500ms.
+	 */
+	MinSpeakingDuration time.Duration
+
+	/**
+	 * Weight of a new volume in the smoothed volume of a producer, from 0
+	 * (exclusive) to 1. Default 0.5.
+	 */
+	Smoothing float64
+
+	/**
+	 * Number of recent speakers reported by "lastnchanged". Default 3.
+	 */
+	LastN int
+}
+
+/**
+ * Int returns a pointer to the given int, to set Threshold or Hysteresis.
+ */
+func Int(i int) *int {
+	return &i
+}
+
+/**
+ * Detector tracks the dominant speaker and the last N distinct speakers.
+ *
+ * @emits speakerchanged - (producer: *mediasoup.Producer), nil when the
+ *   speaker's producer was closed
+ * @emits lastnchanged - (producers: []*mediasoup.Producer), most recent first
+ * @emits close
+ */
+type Detector struct {
+	mediasoup.IEventEmitter
+	logger  mediasoup.Logger
+	options DetectorOptions
+	volumes map[*mediasoup.Producer]float64
+	speaker *mediasoup.Producer
+	lastN   []*mediasoup.Producer
+	// Producer louder than the speaker and the time it became so.
+	challenger      *mediasoup.Producer
+	challengerSince time.Time
+	unsubscribes    []func()
+	now             func() time.Time
+	closed          uint32
+	locker          sync.Mutex
+}
+
+/**
+ * NewDetector returns a Detector, which is fed by Observe or by calling
+ * Update and Silence.
+ */
+func NewDetector(options DetectorOptions) *Detector {
+	logger := mediasoup.NewLogger("SpeakerDetector")
+
+	logger.Debug("constructor()")
+
+	if options.Threshold == nil {
+		options.Threshold = Int(-60)
+	}
+	if options.Hysteresis == nil {
+		options.Hysteresis = Int(6)
+	}
+	if options.MinSpeakingDuration == 0 {
+		options.MinSpeakingDuration = 500 * time.Millisecond
+	}
+	if options.Smoothing <= 0 || options.Smoothing > 1 {
+		options.Smoothing = 0.5
+	}
+	if options.LastN <= 0 {
+		options.LastN = 3
+	}
+
+	return &Detector{
+		IEventEmitter: mediasoup.NewEventEmitter(),
+		logger:        logger,
+		options:       options,
+		volumes:       make(map[*mediasoup.Producer]float64),
+		now:           time.Now,
+	}
+}
+
+/**
+ * Observe feeds the Detector with the events of the given observer until the
+ * returned function is called or the Detector is closed.
+ */
+func (d *Detector) Observe(observer *mediasoup.AudioLevelObserver) func() {
+	removeVolumes := observer.OnVolumes(d.Update)
+	removeSilence := observer.OnSilence(d.Silence)
+
+	var once sync.Once
+
+	unsubscribe := func() {
+		once.Do(func() {
+			removeVolumes()
+			removeSilence()
+		})
+	}
+
+	d.locker.Lock()
+	d.unsubscribes = append(d.unsubscribes, unsubscribe)
+	d.locker.Unlock()
+
+	if d.Closed() {
+		unsubscribe()
+	}
+
+	return unsubscribe
+}
+
+/**
+ * Speaker returns the dominant speaker, or nil.
+ */
+func (d *Detector) Speaker() *mediasoup.Producer {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	return d.speaker
+}
+
+/**
+ * LastN returns the last distinct speakers, most recent first.
+ */
+func (d *Detector) LastN() []*mediasoup.Producer {
+	d.locker.Lock()
+	defer d.locker.Unlock()
+
+	return append([]*mediasoup.Producer{}, d.lastN...)
+}
+
+/**
+ * Whether the Detector is closed.
+ */
+func (d *Detector) Closed() bool {
+	return atomic.LoadUint32(&d.closed) > 0
+}
+
+/**
+ * Close stops observing.
+ */
+func (d *Detector) Close() {
+	if !atomic.CompareAndSwapUint32(&d.closed, 0, 1) {
+		return
+	}
+
+	d.logger.Debug("close()")
+
+	d.locker.Lock()
+	unsubscribes := d.unsubscribes
+	d.unsubscribes = nil
+	d.locker.Unlock()
+
+	for _, unsubscribe := range unsubscribes {
+		unsubscribe()
+	}
+
+	d.SafeEmit("close")
+}
+
+/**
+ * Update handles the volumes of an interval, producers missing from them are
+ * considered silent.
+ */
+func (d *Detector) Update(volumes []mediasoup.AudioLevelObserverVolume) {
+	if d.Closed() {
+		return
+	}
+
+	d.locker.Lock()
+
+	now := d.now()
+	samples := make(map[*mediasoup.Producer]int, len(volumes))
+
+	for _, volume := range volumes {
+		samples[volume.Producer] = volume.Volume
+
+		if _, ok := d.volumes[volume.Producer]; !ok {
+			d.volumes[volume.Producer] = silenceVolume
+		}
+	}
+
+	for producer, smoothed := range d.volumes {
+		if producer.Closed() {
+			delete(d.volumes, producer)
+			continue
+		}
+
+		sample, ok := samples[producer]
+		if !ok {
+			sample = silenceVolume
+		}
+		smoothed += d.options.Smoothing * (float64(sample) - smoothed)
+
+		// Silent producers are forgotten until they are heard again.
+		if !ok && smoothed < silenceVolume+1 {
+			delete(d.volumes, producer)
+			continue
+		}
+		d.volumes[producer] = smoothed
+	}
+
+	speakerChanged, lastNChanged := d.removeClosed()
+
+	if d.elect(now) {
+		speakerChanged = true
+		lastNChanged = d.pushLastN(d.speaker) || lastNChanged
+	}
+
+	speaker := d.speaker
+	lastN := append([]*mediasoup.Producer{}, d.lastN...)
+
+	d.locker.Unlock()
+
+	if speakerChanged {
+		d.logger.Debug("speaker changed [producerId:%s]", producerId(speaker))
+
+		d.SafeEmit("speakerchanged", speaker)
+	}
+	if lastNChanged {
+		d.SafeEmit("lastnchanged", lastN)
+	}
+}
+
+/**
+ * Silence handles an interval in which no producer was heard.
+ */
+func (d *Detector) Silence() {
+	d.Update(nil)
+}
+
+// removeClosed removes the closed producers from the speaker and the last
+// speakers. d.locker must be held.
+func (d *Detector) removeClosed() (speakerChanged, lastNChanged bool) {
+	if d.speaker != nil && d.speaker.Closed() {
+		d.speaker = nil
+		speakerChanged = true
+	}
+
+	lastN := d.lastN[:0]
+
+	for _, producer := range d.lastN {
+		if producer.Closed() {
+			lastNChanged = true
+		} else {
+			lastN = append(lastN, producer)
+		}
+	}
+
+	d.lastN = lastN
+
+	return
+}
+
+// elect makes the loudest producer the speaker once it has exceeded the
+// current speaker by the hysteresis for the minimum speaking duration.
+// d.locker must be held.
+func (d *Detector) elect(now time.Time) bool {
+	var (
+		loudest *mediasoup.Producer
+		max     float64
+	)
+
+	for producer, smoothed := range d.volumes {
+		if smoothed < float64(*d.options.Threshold) {
+			continue
+		}
+		if loudest == nil || smoothed > max {
+			loudest, max = producer, smoothed
+		}
+	}
+
+	if loudest == nil || loudest == d.speaker {
+		d.challenger = nil
+		return false
+	}
+
+	if d.speaker != nil {
+		if speakerVolume, ok := d.volumes[d.speaker]; ok && max < speakerVolume+float64(*d.options.Hysteresis) {
+			d.challenger = nil
+			return false
+		}
+	}
+
+	if loudest != d.challenger {
+		d.challenger = loudest
+		d.challengerSince = now
+	}
+
+	if now.Sub(d.challengerSince) < d.options.MinSpeakingDuration {
+		return false
+	}
+
+	d.speaker = loudest
+	d.challenger = nil
+
+	return true
+}
+
+// pushLastN moves the given producer to the front of the last speakers.
+// d.locker must be held.
+func (d *Detector) pushLastN(producer *mediasoup.Producer) bool {
+	if len(d.lastN) > 0 && d.lastN[0] == producer {
+		return false
+	}
+
+	lastN := []*mediasoup.Producer{producer}
+
+	for _, p := range d.lastN {
+		if p != producer && len(lastN) < d.options.LastN {
+			lastN = append(lastN, p)
+		}
+	}
+
+	d.lastN = lastN
+
+	return true
+}
+
+func producerId(producer *mediasoup.Producer) string {
+	if producer == nil {
+		return ""
+	}
+	return producer.Id()
+}
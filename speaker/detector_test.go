@@ -0,0 +1,228 @@
+package speaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testDetector struct {
+	*Detector
+	clock    time.Time
+	speakers chan *mediasoup.Producer
+	lastNs   chan []*mediasoup.Producer
+}
+
+func newTestDetector(options DetectorOptions) *testDetector {
+	d := &testDetector{
+		Detector: NewDetector(options),
+		clock:    time.Now(),
+		speakers: make(chan *mediasoup.Producer, 10),
+		lastNs:   make(chan []*mediasoup.Producer, 10),
+	}
+	d.now = func() time.Time { return d.clock }
+	d.On("speakerchanged", func(producer *mediasoup.Producer) { d.speakers <- producer })
+	d.On("lastnchanged", func(producers []*mediasoup.Producer) { d.lastNs <- producers })
+
+	return d
+}
+
+// feed calls Update with the given volumes every 100ms for the given duration.
+func (d *testDetector) feed(duration time.Duration, volumes ...mediasoup.AudioLevelObserverVolume) {
+	for elapsed := time.Duration(0); elapsed < duration; elapsed += 100 * time.Millisecond {
+		d.clock = d.clock.Add(100 * time.Millisecond)
+		d.Update(volumes)
+	}
+}
+
+func (d *testDetector) nextSpeaker(t *testing.T) *mediasoup.Producer {
+	select {
+	case producer := <-d.speakers:
+		return producer
+	case <-time.After(time.Second):
+		t.Fatal("speakerchanged not emitted")
+		return nil
+	}
+}
+
+func (d *testDetector) nextLastN(t *testing.T) []*mediasoup.Producer {
+	select {
+	case producers := <-d.lastNs:
+		return producers
+	case <-time.After(time.Second):
+		t.Fatal("lastnchanged not emitted")
+		return nil
+	}
+}
+
+func (d *testDetector) assertNoEvent(t *testing.T) {
+	select {
+	case producer := <-d.speakers:
+		t.Fatalf("unexpected speakerchanged: %p", producer)
+	case producers := <-d.lastNs:
+		t.Fatalf("unexpected lastnchanged: %v", producers)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func volume(producer *mediasoup.Producer, v int) mediasoup.AudioLevelObserverVolume {
+	return mediasoup.AudioLevelObserverVolume{Producer: producer, Volume: v}
+}
+
+func TestDetector_MinSpeakingDuration(t *testing.T) {
+	d := newTestDetector(DetectorOptions{Smoothing: 1, MinSpeakingDuration: 300 * time.Millisecond})
+	a := &mediasoup.Producer{}
+
+	// A single loud interval is not enough.
+	d.feed(100*time.Millisecond, volume(a, -20))
+	d.Silence()
+	d.feed(100*time.Millisecond, volume(a, -20))
+	assert.Nil(t, d.Speaker())
+
+	d.feed(300*time.Millisecond, volume(a, -20))
+	assert.Equal(t, a, d.nextSpeaker(t))
+	assert.Equal(t, []*mediasoup.Producer{a}, d.nextLastN(t))
+	assert.Equal(t, a, d.Speaker())
+}
+
+func TestDetector_Threshold(t *testing.T) {
+	d := newTestDetector(DetectorOptions{Threshold: Int(-50), MinSpeakingDuration: time.Millisecond})
+	a := &mediasoup.Producer{}
+
+	d.feed(time.Second, volume(a, -55))
+	assert.Nil(t, d.Speaker())
+	d.assertNoEvent(t)
+}
+
+func TestDetector_Hysteresis(t *testing.T) {
+	d := newTestDetector(DetectorOptions{Hysteresis: Int(10), MinSpeakingDuration: 200 * time.Millisecond})
+	a, b := &mediasoup.Producer{}, &mediasoup.Producer{}
+
+	d.feed(time.Second, volume(a, -30))
+	assert.Equal(t, a, d.nextSpeaker(t))
+	d.nextLastN(t)
+
+	// Louder but within the hysteresis.
+	d.feed(time.Second, volume(a, -30), volume(b, -25))
+	assert.Equal(t, a, d.Speaker())
+	d.assertNoEvent(t)
+
+	d.feed(time.Second, volume(a, -30), volume(b, -15))
+	assert.Equal(t, b, d.nextSpeaker(t))
+	assert.Equal(t, []*mediasoup.Producer{b, a}, d.nextLastN(t))
+}
+
+func TestDetector_ZeroThresholdAndHysteresis(t *testing.T) {
+	d := newTestDetector(DetectorOptions{
+		Threshold:           Int(0),
+		Hysteresis:          Int(0),
+		Smoothing:           1,
+		MinSpeakingDuration: 100 * time.Millisecond,
+	})
+	a, b := &mediasoup.Producer{}, &mediasoup.Producer{}
+
+	// Below the default threshold of -60 but a threshold of 0 is no default.
+	d.feed(time.Second, volume(a, -1))
+	assert.Nil(t, d.Speaker())
+	d.assertNoEvent(t)
+
+	d.feed(time.Second, volume(a, 0))
+	assert.Equal(t, a, d.nextSpeaker(t))
+	d.nextLastN(t)
+
+	// Louder by less than the default hysteresis of 6.
+	d.feed(time.Second, volume(a, -1), volume(b, 0))
+	assert.Equal(t, b, d.nextSpeaker(t))
+	assert.Equal(t, []*mediasoup.Producer{b, a}, d.nextLastN(t))
+}
+
+func TestDetector_Smoothing(t *testing.T) {
+	d := newTestDetector(DetectorOptions{Smoothing: 0.2, MinSpeakingDuration: 100 * time.Millisecond})
+	a, b := &mediasoup.Producer{}, &mediasoup.Producer{}
+
+	d.feed(2*time.Second, volume(a, -30))
+	assert.Equal(t, a, d.nextSpeaker(t))
+	d.nextLastN(t)
+
+	// Spikes of b alternating with silence don't raise its smoothed volume
+	// above the one of a.
+	for i := 0; i < 10; i++ {
+		d.feed(100*time.Millisecond, volume(a, -30), volume(b, 0))
+		d.feed(100*time.Millisecond, volume(a, -30))
+	}
+	assert.Equal(t, a, d.Speaker())
+	d.assertNoEvent(t)
+}
+
+func TestDetector_LastN(t *testing.T) {
+	d := newTestDetector(DetectorOptions{LastN: 2, MinSpeakingDuration: 100 * time.Millisecond})
+	a, b, c := &mediasoup.Producer{}, &mediasoup.Producer{}, &mediasoup.Producer{}
+
+	for _, producer := range []*mediasoup.Producer{a, b, c, a} {
+		d.Silence()
+		d.feed(time.Second, volume(producer, -10))
+		assert.Equal(t, producer, d.nextSpeaker(t))
+	}
+
+	assert.Equal(t, []*mediasoup.Producer{a}, d.nextLastN(t))
+	assert.Equal(t, []*mediasoup.Producer{b, a}, d.nextLastN(t))
+	assert.Equal(t, []*mediasoup.Producer{c, b}, d.nextLastN(t))
+	assert.Equal(t, []*mediasoup.Producer{a, c}, d.nextLastN(t))
+	assert.Equal(t, []*mediasoup.Producer{a, c}, d.LastN())
+}
+
+func TestDetector_SilenceKeepsSpeaker(t *testing.T) {
+	d := newTestDetector(DetectorOptions{MinSpeakingDuration: 100 * time.Millisecond})
+	a := &mediasoup.Producer{}
+
+	d.feed(time.Second, volume(a, -20))
+	assert.Equal(t, a, d.nextSpeaker(t))
+	d.nextLastN(t)
+
+	for i := 0; i < 50; i++ {
+		d.Silence()
+	}
+	assert.Equal(t, a, d.Speaker())
+	d.assertNoEvent(t)
+}
+
+func TestDetector_Observe(t *testing.T) {
+	router, process := workertest.NewRouter(t)
+
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+
+	producer, err := transport.Produce(workertest.AudioProducerOptions())
+	require.NoError(t, err)
+
+	rtpObserver, err := router.CreateAudioLevelObserver()
+	require.NoError(t, err)
+
+	d := newTestDetector(DetectorOptions{MinSpeakingDuration: time.Millisecond})
+	d.now = time.Now
+	d.Observe(rtpObserver.(*mediasoup.AudioLevelObserver))
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, process.Notify(rtpObserver.Id(), "volumes", []mediasoup.H{
+			{"producerId": producer.Id(), "volume": -20},
+		}))
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	assert.Equal(t, producer, d.nextSpeaker(t))
+	assert.Equal(t, []*mediasoup.Producer{producer}, d.nextLastN(t))
+
+	// The closed speaker is removed at the next interval.
+	producer.Close()
+	require.NoError(t, process.Notify(rtpObserver.Id(), "silence", nil))
+
+	assert.Nil(t, d.nextSpeaker(t))
+	assert.Empty(t, d.nextLastN(t))
+
+	d.Close()
+	assert.True(t, d.Closed())
+}
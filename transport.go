@@ -1,6 +1,7 @@
 package mediasoup
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
@@ -16,16 +17,35 @@ type ITransport interface {
 	AppData() interface{}
 	Observer() IEventEmitter
 	Close()
+	CloseContext(ctx context.Context) error
 	routerClosed()
+	listenServerClosed()
+	subscribe(evt string, listener interface{}) func()
+	OnRouterClose(listener func()) func()
+	OnTrace(listener func(TransportTraceEventData)) func()
+	Producers() []*Producer
+	Consumers() []*Consumer
+	DataProducers() []*DataProducer
+	DataConsumers() []*DataConsumer
 	Dump() (*TransportDump, error)
+	DumpContext(ctx context.Context) (*TransportDump, error)
 	GetStats() ([]*TransportStat, error)
+	GetStatsContext(ctx context.Context) ([]*TransportStat, error)
 	Connect(TransportConnectOptions) error
+	ConnectContext(context.Context, TransportConnectOptions) error
 	SetMaxIncomingBitrate(bitrate int) error
+	SetMaxIncomingBitrateContext(ctx context.Context, bitrate int) error
 	Produce(ProducerOptions) (*Producer, error)
+	ProduceContext(context.Context, ProducerOptions) (*Producer, error)
 	Consume(ConsumerOptions) (*Consumer, error)
+	ConsumeContext(context.Context, ConsumerOptions) (*Consumer, error)
 	ProduceData(DataProducerOptions) (*DataProducer, error)
+	ProduceDataContext(context.Context, DataProducerOptions) (*DataProducer, error)
 	ConsumeData(DataConsumerOptions) (*DataConsumer, error)
+	ConsumeDataContext(context.Context, DataConsumerOptions) (*DataConsumer, error)
+	SctpStreamUsage() SctpStreamUsage
 	EnableTraceEvent(types ...TransportTraceEventType) error
+	EnableTraceEventContext(ctx context.Context, types ...TransportTraceEventType) error
 }
 
 type TransportListenIp struct {
@@ -95,6 +115,30 @@ const (
 	SctpState_Closed     = "closed"
 )
 
+/**
+ * SctpStreamUsage describes the SCTP stream ids taken by the DataConsumers of
+ * a transport.
+ */
+type SctpStreamUsage struct {
+	/**
+	 * Number of stream ids, the MIS of the SCTP parameters. 0 if SCTP is not
+	 * enabled.
+	 */
+	NumStreams int
+
+	/**
+	 * Stream ids in use, in ascending order.
+	 */
+	StreamIds []uint16
+}
+
+/**
+ * Available returns the number of stream ids left.
+ */
+func (u SctpStreamUsage) Available() int {
+	return u.NumStreams - len(u.StreamIds)
+}
+
 type TransportStat struct {
 	// Common to all Transports.
 	Type                     string    `json:"type"`
@@ -170,7 +214,9 @@ type transportParams struct {
 /**
  * Transport
  * @emits routerclose
+ * @emits listenserverclose
  * @emits @close
+ * @emits @listenserverclose
  * @emits @newproducer - (producer: Producer)
  * @emits @producerclose - (producer: Producer)
  * @emits @newdataproducer - (dataProducer: DataProducer)
@@ -209,9 +255,9 @@ type Transport struct {
 	cnameForProducers string
 	// Next MID for Consumers. It's converted into string when used.
 	nextMidForConsumers uint32
-	// Buffer with available SCTP stream ids.
+	// Buffer with available SCTP stream ids, guarded by locker.
 	sctpStreamIds []byte
-	// Next SCTP stream id.
+	// Next SCTP stream id, guarded by locker.
 	nextSctpStreamId int
 	// Observer instance.
 	observer IEventEmitter
@@ -267,50 +313,91 @@ func (transport *Transport) Observer() IEventEmitter {
 	return transport.observer
 }
 
-// Close the Transport.
-func (transport *Transport) Close() {
-	if atomic.CompareAndSwapUint32(&transport.closed, 0, 1) {
-		transport.logger.Debug("close()")
+func (transport *Transport) subscribe(evt string, listener interface{}) func() {
+	return subscribe(transport.IEventEmitter, evt, listener)
+}
 
-		// Remove notification subscriptions.
-		transport.channel.RemoveAllListeners(transport.Id())
-		transport.payloadChannel.RemoveAllListeners(transport.Id())
+/**
+ * OnRouterClose adds a listener of "routerclose" and returns the function
+ * removing it.
+ */
+func (transport *Transport) OnRouterClose(listener func()) func() {
+	return transport.subscribe("routerclose", listener)
+}
+
+/**
+ * OnTrace adds a listener of "trace" and returns the function removing it.
+ */
+func (transport *Transport) OnTrace(listener func(TransportTraceEventData)) func() {
+	return transport.subscribe("trace", listener)
+}
+
+/**
+ * Producers returns the alive producers of the transport.
+ */
+func (transport *Transport) Producers() (producers []*Producer) {
+	transport.producers.Range(func(key, value interface{}) bool {
+		producers = append(producers, value.(*Producer))
+		return true
+	})
 
-		transport.channel.Request("transport.close", transport.internal)
+	return
+}
+
+/**
+ * Consumers returns the alive consumers of the transport.
+ */
+func (transport *Transport) Consumers() (consumers []*Consumer) {
+	transport.consumers.Range(func(key, value interface{}) bool {
+		consumers = append(consumers, value.(*Consumer))
+		return true
+	})
 
-		transport.producers.Range(func(key, value interface{}) bool {
-			producer := value.(*Producer)
+	return
+}
 
-			producer.transportClosed()
-			transport.Emit("@producerclose", producer)
+/**
+ * DataProducers returns the alive data producers of the transport.
+ */
+func (transport *Transport) DataProducers() (dataProducers []*DataProducer) {
+	transport.dataProducers.Range(func(key, value interface{}) bool {
+		dataProducers = append(dataProducers, value.(*DataProducer))
+		return true
+	})
 
-			return true
-		})
-		transport.producers = sync.Map{}
+	return
+}
 
-		transport.consumers.Range(func(key, value interface{}) bool {
-			value.(*Consumer).transportClosed()
+/**
+ * DataConsumers returns the alive data consumers of the transport.
+ */
+func (transport *Transport) DataConsumers() (dataConsumers []*DataConsumer) {
+	transport.dataConsumers.Range(func(key, value interface{}) bool {
+		dataConsumers = append(dataConsumers, value.(*DataConsumer))
+		return true
+	})
 
-			return true
-		})
-		transport.consumers = sync.Map{}
+	return
+}
 
-		transport.dataProducers.Range(func(key, value interface{}) bool {
-			producer := value.(*DataProducer)
+// Close the Transport.
+func (transport *Transport) Close() {
+	transport.CloseContext(context.Background())
+}
 
-			producer.transportClosed()
-			transport.Emit("@dataproducerclose", producer)
+// CloseContext is like Close but with a context. The Transport is closed even
+// if the request fails.
+func (transport *Transport) CloseContext(ctx context.Context) (err error) {
+	if atomic.CompareAndSwapUint32(&transport.closed, 0, 1) {
+		transport.logger.Debug("close()")
 
-			return true
-		})
-		transport.dataProducers = sync.Map{}
+		// Remove notification subscriptions.
+		transport.channel.RemoveAllListeners(transport.Id())
+		transport.payloadChannel.RemoveAllListeners(transport.Id())
 
-		transport.dataConsumers.Range(func(key, value interface{}) bool {
-			value.(*DataConsumer).transportClosed()
+		err = transport.channel.RequestContext(ctx, "transport.close", transport.internal).Err()
 
-			return true
-		})
-		transport.dataConsumers = sync.Map{}
+		transport.closeChildren()
 
 		transport.Emit("@close")
 
@@ -333,62 +420,105 @@ func (transport *Transport) routerClosed() {
 		transport.channel.RemoveAllListeners(transport.Id())
 		transport.payloadChannel.RemoveAllListeners(transport.Id())
 
-		transport.producers.Range(func(key, value interface{}) bool {
-			producer := value.(*Producer)
-
-			producer.transportClosed()
-			transport.Emit("@producerclose", producer)
-
-			return true
-		})
-		transport.producers = sync.Map{}
+		transport.closeChildren()
 
-		transport.consumers.Range(func(key, value interface{}) bool {
-			value.(*Consumer).transportClosed()
-
-			return true
-		})
-		transport.consumers = sync.Map{}
+		transport.SafeEmit("routerclose")
 
-		transport.dataProducers.Range(func(key, value interface{}) bool {
-			producer := value.(*DataProducer)
+		// Emit observer event.
+		transport.observer.SafeEmit("close")
+	}
+}
 
-			producer.transportClosed()
-			transport.Emit("@dataproducerclose", producer)
+/**
+ * The WebRtcServer the transport listens with was closed.
+ *
+ * @virtual
+ */
+func (transport *Transport) listenServerClosed() {
+	if atomic.CompareAndSwapUint32(&transport.closed, 0, 1) {
+		transport.logger.Debug("listenServerClosed()")
 
-			return true
-		})
-		transport.dataProducers = sync.Map{}
+		// Remove notification subscriptions.
+		transport.channel.RemoveAllListeners(transport.Id())
+		transport.payloadChannel.RemoveAllListeners(transport.Id())
 
-		transport.dataConsumers.Range(func(key, value interface{}) bool {
-			value.(*DataConsumer).transportClosed()
+		transport.closeChildren()
 
-			return true
-		})
-		transport.dataConsumers = sync.Map{}
+		// Let the Router forget the transport, closed in the worker with the
+		// WebRtcServer.
+		transport.Emit("@listenserverclose")
 
-		transport.SafeEmit("routerclose")
+		transport.SafeEmit("listenserverclose")
 
 		// Emit observer event.
 		transport.observer.SafeEmit("close")
 	}
 }
 
+// closeChildren closes the producers, consumers, data producers and data
+// consumers of the closed transport.
+func (transport *Transport) closeChildren() {
+	transport.producers.Range(func(key, value interface{}) bool {
+		producer := value.(*Producer)
+
+		producer.transportClosed()
+		transport.Emit("@producerclose", producer)
+
+		return true
+	})
+	syncMapClear(&transport.producers)
+
+	transport.consumers.Range(func(key, value interface{}) bool {
+		value.(*Consumer).transportClosed()
+
+		return true
+	})
+	syncMapClear(&transport.consumers)
+
+	transport.dataProducers.Range(func(key, value interface{}) bool {
+		producer := value.(*DataProducer)
+
+		producer.transportClosed()
+		transport.Emit("@dataproducerclose", producer)
+
+		return true
+	})
+	syncMapClear(&transport.dataProducers)
+
+	transport.dataConsumers.Range(func(key, value interface{}) bool {
+		value.(*DataConsumer).transportClosed()
+
+		return true
+	})
+	syncMapClear(&transport.dataConsumers)
+	transport.releaseSctpStreamIds()
+}
+
 // Dump Transport.
-func (transport *Transport) Dump() (data *TransportDump, err error) {
+func (transport *Transport) Dump() (*TransportDump, error) {
+	return transport.DumpContext(context.Background())
+}
+
+// DumpContext is like Dump but with a context.
+func (transport *Transport) DumpContext(ctx context.Context) (data *TransportDump, err error) {
 	transport.logger.Debug("dump()")
 
-	resp := transport.channel.Request("transport.dump", transport.internal)
+	resp := transport.channel.RequestContext(ctx, "transport.dump", transport.internal)
 	err = resp.Unmarshal(&data)
 
 	return
 }
 
 // Get Transport stats.
-func (transport *Transport) GetStats() (stat []*TransportStat, err error) {
+func (transport *Transport) GetStats() ([]*TransportStat, error) {
+	return transport.GetStatsContext(context.Background())
+}
+
+// GetStatsContext is like GetStats but with a context.
+func (transport *Transport) GetStatsContext(ctx context.Context) (stat []*TransportStat, err error) {
 	transport.logger.Debug("getStats()")
 
-	resp := transport.channel.Request("transport.getStats", transport.internal)
+	resp := transport.channel.RequestContext(ctx, "transport.getStats", transport.internal)
 	err = resp.Unmarshal(&stat)
 
 	return
@@ -397,7 +527,12 @@ func (transport *Transport) GetStats() (stat []*TransportStat, err error) {
 /**
  * Provide the Transport remote parameters.
  */
-func (transport *Transport) Connect(TransportConnectOptions) error {
+func (transport *Transport) Connect(options TransportConnectOptions) error {
+	return transport.ConnectContext(context.Background(), options)
+}
+
+// ConnectContext is like Connect but with a context.
+func (transport *Transport) ConnectContext(context.Context, TransportConnectOptions) error {
 	return errors.New("method not implemented in the subclass")
 }
 
@@ -405,9 +540,14 @@ func (transport *Transport) Connect(TransportConnectOptions) error {
  * Set maximum incoming bitrate for receiving media.
  */
 func (transport *Transport) SetMaxIncomingBitrate(bitrate int) error {
+	return transport.SetMaxIncomingBitrateContext(context.Background(), bitrate)
+}
+
+// SetMaxIncomingBitrateContext is like SetMaxIncomingBitrate but with a context.
+func (transport *Transport) SetMaxIncomingBitrateContext(ctx context.Context, bitrate int) error {
 	transport.logger.Debug("SetMaxIncomingBitrate() [bitrate:%d]", bitrate)
 
-	resp := transport.channel.Request(
+	resp := transport.channel.RequestContext(ctx,
 		"transport.setMaxIncomingBitrate", transport.internal, H{"bitrate": bitrate})
 
 	return resp.Err()
@@ -416,7 +556,12 @@ func (transport *Transport) SetMaxIncomingBitrate(bitrate int) error {
 /**
  * Create a Producer.
  */
-func (transport *Transport) Produce(options ProducerOptions) (producer *Producer, err error) {
+func (transport *Transport) Produce(options ProducerOptions) (*Producer, error) {
+	return transport.ProduceContext(context.Background(), options)
+}
+
+// ProduceContext is like Produce but with a context.
+func (transport *Transport) ProduceContext(ctx context.Context, options ProducerOptions) (producer *Producer, err error) {
 	transport.logger.Debug("produce()")
 
 	id := options.Id
@@ -485,7 +630,7 @@ func (transport *Transport) Produce(options ProducerOptions) (producer *Producer
 		"keyFrameRequestDelay": keyFrameRequestDelay,
 		"paused":               paused,
 	}
-	resp := transport.channel.Request("transport.produce", internal, reqData)
+	resp := transport.channel.RequestContext(ctx, "transport.produce", internal, reqData)
 
 	var status struct {
 		Type ProducerType
@@ -528,7 +673,12 @@ func (transport *Transport) Produce(options ProducerOptions) (producer *Producer
 /**
  * Create a Consumer.
  */
-func (transport *Transport) Consume(options ConsumerOptions) (consumer *Consumer, err error) {
+func (transport *Transport) Consume(options ConsumerOptions) (*Consumer, error) {
+	return transport.ConsumeContext(context.Background(), options)
+}
+
+// ConsumeContext is like Consume but with a context.
+func (transport *Transport) ConsumeContext(ctx context.Context, options ConsumerOptions) (consumer *Consumer, err error) {
 	transport.logger.Debug("consume()")
 
 	producerId := options.ProducerId
@@ -553,9 +703,13 @@ func (transport *Transport) Consume(options ConsumerOptions) (consumer *Consumer
 		transport.locker.Lock()
 
 		// Set MID.
-		rtpParameters.Mid = fmt.Sprintf("%d", transport.nextMidForConsumers)
+		if len(options.Mid) > 0 {
+			rtpParameters.Mid = options.Mid
+		} else {
+			rtpParameters.Mid = fmt.Sprintf("%d", transport.nextMidForConsumers)
 
-		transport.nextMidForConsumers++
+			transport.nextMidForConsumers++
+		}
 
 		// We use up to 8 bytes for MID (string).
 		if maxMid := uint32(100000000); transport.nextMidForConsumers == maxMid {
@@ -585,7 +739,7 @@ func (transport *Transport) Consume(options ConsumerOptions) (consumer *Consumer
 		"paused":                 paused,
 		"preferredLayers":        preferredLayers,
 	}
-	resp := transport.channel.Request("transport.consume", internal, reqData)
+	resp := transport.channel.RequestContext(ctx, "transport.consume", internal, reqData)
 
 	var status struct {
 		Paused         bool
@@ -630,7 +784,12 @@ func (transport *Transport) Consume(options ConsumerOptions) (consumer *Consumer
 /**
  * Create a DataProducer.
  */
-func (transport *Transport) ProduceData(options DataProducerOptions) (dataProducer *DataProducer, err error) {
+func (transport *Transport) ProduceData(options DataProducerOptions) (*DataProducer, error) {
+	return transport.ProduceDataContext(context.Background(), options)
+}
+
+// ProduceDataContext is like ProduceData but with a context.
+func (transport *Transport) ProduceDataContext(ctx context.Context, options DataProducerOptions) (dataProducer *DataProducer, err error) {
 	transport.logger.Debug("produceData()")
 
 	id := options.Id
@@ -676,7 +835,7 @@ func (transport *Transport) ProduceData(options DataProducerOptions) (dataProduc
 	if sctpStreamParameters != nil {
 		reqData["sctpStreamParameters"] = sctpStreamParameters
 	}
-	resp := transport.channel.Request("transport.produceData", internal, reqData)
+	resp := transport.channel.RequestContext(ctx, "transport.produceData", internal, reqData)
 
 	var data dataProducerData
 	if err = resp.Unmarshal(&data); err != nil {
@@ -708,7 +867,12 @@ func (transport *Transport) ProduceData(options DataProducerOptions) (dataProduc
 /**
  * Create a DataConsumer.
  */
-func (transport *Transport) ConsumeData(options DataConsumerOptions) (dataConsumer *DataConsumer, err error) {
+func (transport *Transport) ConsumeData(options DataConsumerOptions) (*DataConsumer, error) {
+	return transport.ConsumeDataContext(context.Background(), options)
+}
+
+// ConsumeDataContext is like ConsumeData but with a context.
+func (transport *Transport) ConsumeDataContext(ctx context.Context, options DataConsumerOptions) (dataConsumer *DataConsumer, err error) {
 	transport.logger.Debug("consumeData()")
 
 	dataProducerId := options.DataProducerId
@@ -735,6 +899,10 @@ func (transport *Transport) ConsumeData(options DataConsumerOptions) (dataConsum
 			transport.logger.Warn(
 				"consumeData() | ordered, maxPacketLifeTime and maxRetransmits are ignored when consuming data on a DirectTransport")
 		}
+		if options.SctpStreamId != nil {
+			transport.logger.Warn(
+				"consumeData() | sctpStreamId is ignored when consuming data on a DirectTransport")
+		}
 	} else {
 		typ = DataProducerType_Sctp
 
@@ -750,15 +918,10 @@ func (transport *Transport) ConsumeData(options DataConsumerOptions) (dataConsum
 			sctpStreamParameters.MaxRetransmits = maxRetransmits
 		}
 
-		transport.locker.Lock()
-
-		if sctpStreamId, err = transport.getNextSctpStreamId(); err != nil {
+		if sctpStreamId, err = transport.reserveSctpStreamId(options.SctpStreamId); err != nil {
 			return
 		}
-		transport.sctpStreamIds[sctpStreamId] = 1
 		sctpStreamParameters.StreamId = uint16(sctpStreamId)
-
-		transport.locker.Unlock()
 	}
 
 	internal := transport.internal
@@ -771,10 +934,17 @@ func (transport *Transport) ConsumeData(options DataConsumerOptions) (dataConsum
 		"label":                dataProducer.Label(),
 		"protocol":             dataProducer.Protocol(),
 	}
-	resp := transport.channel.Request("transport.consumeData", internal, reqData)
+	resp := transport.channel.RequestContext(ctx, "transport.consumeData", internal, reqData)
 
 	var data dataConsumerData
 	if err = resp.Unmarshal(&data); err != nil {
+		// Unless the worker rejected it, the DataConsumer may exist and
+		// hold the stream id.
+		if resp.Err() == nil || resp.unanswered {
+			transport.closeUnknownDataConsumer(internal, sctpStreamId)
+		} else {
+			transport.releaseSctpStreamId(sctpStreamId)
+		}
 		return
 	}
 
@@ -789,21 +959,11 @@ func (transport *Transport) ConsumeData(options DataConsumerOptions) (dataConsum
 	transport.dataConsumers.Store(dataConsumer.Id(), dataConsumer)
 	dataConsumer.On("@close", func() {
 		transport.dataConsumers.Delete(dataConsumer.Id())
-
-		transport.locker.Lock()
-		if sctpStreamId >= 0 {
-			transport.sctpStreamIds[sctpStreamId] = 0
-		}
-		transport.locker.Unlock()
+		transport.releaseSctpStreamId(sctpStreamId)
 	})
 	dataConsumer.On("@dataproducerclose", func() {
 		transport.dataConsumers.Delete(dataConsumer.Id())
-
-		transport.locker.Lock()
-		if sctpStreamId >= 0 {
-			transport.sctpStreamIds[sctpStreamId] = 0
-		}
-		transport.locker.Unlock()
+		transport.releaseSctpStreamId(sctpStreamId)
 	})
 
 	// Emit observer event.
@@ -816,33 +976,83 @@ func (transport *Transport) ConsumeData(options DataConsumerOptions) (dataConsum
  * Enable 'trace' event.
  */
 func (transport *Transport) EnableTraceEvent(types ...TransportTraceEventType) error {
+	return transport.EnableTraceEventContext(context.Background(), types...)
+}
+
+// EnableTraceEventContext is like EnableTraceEvent but with a context.
+func (transport *Transport) EnableTraceEventContext(ctx context.Context, types ...TransportTraceEventType) error {
 	transport.logger.Debug("pause()")
 
 	if types == nil {
 		types = []TransportTraceEventType{}
 	}
 
-	resp := transport.channel.Request("transport.enableTraceEvent", transport.internal, H{"types": types})
+	resp := transport.channel.RequestContext(ctx, "transport.enableTraceEvent", transport.internal, H{"types": types})
 
 	return resp.Err()
 }
 
-func (transport *Transport) getNextSctpStreamId() (sctpStreamId int, err error) {
+/**
+ * SctpStreamUsage returns the SCTP stream ids taken by the DataConsumers.
+ */
+func (transport *Transport) SctpStreamUsage() (usage SctpStreamUsage) {
+	transport.locker.Lock()
+	defer transport.locker.Unlock()
+
+	if transport.data.sctpParameters.MIS == 0 {
+		return
+	}
+
+	usage.NumStreams = int(transport.data.sctpParameters.MIS)
+	usage.StreamIds = []uint16{}
+
+	for sctpStreamId, used := range transport.sctpStreamIds {
+		if used != 0 {
+			usage.StreamIds = append(usage.StreamIds, uint16(sctpStreamId))
+		}
+	}
+
+	return
+}
+
+// reserveSctpStreamId takes the requested SCTP stream id, or the next
+// available one if nil.
+func (transport *Transport) reserveSctpStreamId(requested *uint16) (sctpStreamId int, err error) {
+	transport.locker.Lock()
+	defer transport.locker.Unlock()
+
 	if transport.data.sctpParameters.MIS == 0 {
 		err = NewTypeError("missing data.sctpParameters.MIS")
 		return
 	}
 
-	numStreams := transport.data.sctpParameters.MIS
+	numStreams := int(transport.data.sctpParameters.MIS)
 
 	if len(transport.sctpStreamIds) == 0 {
 		transport.sctpStreamIds = make([]byte, numStreams)
 	}
 
-	for idx := 0; idx < len(transport.sctpStreamIds); idx++ {
-		sctpStreamId = (transport.nextSctpStreamId + idx) % len(transport.sctpStreamIds)
+	if requested != nil {
+		sctpStreamId = int(*requested)
+
+		if sctpStreamId >= numStreams {
+			err = NewTypeError("sctpStreamId %d out of range [0, %d)", sctpStreamId, numStreams)
+			return
+		}
+		if transport.sctpStreamIds[sctpStreamId] != 0 {
+			err = NewTypeError("sctpStreamId %d already in use", sctpStreamId)
+			return
+		}
+		transport.sctpStreamIds[sctpStreamId] = 1
+
+		return
+	}
+
+	for idx := 0; idx < numStreams; idx++ {
+		sctpStreamId = (transport.nextSctpStreamId + idx) % numStreams
 
 		if transport.sctpStreamIds[sctpStreamId] == 0 {
+			transport.sctpStreamIds[sctpStreamId] = 1
 			transport.nextSctpStreamId = sctpStreamId + 1
 			return
 		}
@@ -852,3 +1062,41 @@ func (transport *Transport) getNextSctpStreamId() (sctpStreamId int, err error)
 
 	return
 }
+
+// closeUnknownDataConsumer closes a DataConsumer the worker may have created
+// although ConsumeData failed, then releases its SCTP stream id. The stream
+// id stays taken if the closing is not answered either.
+func (transport *Transport) closeUnknownDataConsumer(internal internalData, sctpStreamId int) {
+	go func() {
+		resp := transport.channel.Request("dataConsumer.close", internal)
+
+		if resp.unanswered {
+			transport.logger.Warn("consumeData() | closing DataConsumer failed, keeping sctpStreamId %d: %s",
+				sctpStreamId, resp.Err())
+			return
+		}
+
+		transport.releaseSctpStreamId(sctpStreamId)
+	}()
+}
+
+// releaseSctpStreamId makes a SCTP stream id available again, it does nothing
+// given -1 or once the transport is closed.
+func (transport *Transport) releaseSctpStreamId(sctpStreamId int) {
+	transport.locker.Lock()
+	defer transport.locker.Unlock()
+
+	if sctpStreamId >= 0 && sctpStreamId < len(transport.sctpStreamIds) {
+		transport.sctpStreamIds[sctpStreamId] = 0
+	}
+}
+
+// releaseSctpStreamIds makes all the SCTP stream ids available again, when the
+// DataConsumers are closed with the transport.
+func (transport *Transport) releaseSctpStreamIds() {
+	transport.locker.Lock()
+	defer transport.locker.Unlock()
+
+	transport.sctpStreamIds = nil
+	transport.nextSctpStreamId = 0
+}
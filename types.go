@@ -12,8 +12,9 @@ func Bool(b bool) *bool {
 }
 
 type WorkerDump struct {
-	Pid       int      `json:"pid,omitempty"`
-	RouterIds []string `json:"routerIds,omitempty"`
+	Pid             int      `json:"pid,omitempty"`
+	RouterIds       []string `json:"routerIds,omitempty"`
+	WebRtcServerIds []string `json:"webRtcServerIds,omitempty"`
 }
 
 type RouterDump struct {
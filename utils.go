@@ -60,3 +60,12 @@ func syncMapLen(m *sync.Map) (len int) {
 	})
 	return
 }
+
+// syncMapClear removes the entries of m. Unlike assigning an empty map, it is
+// safe while other goroutines read m.
+func syncMapClear(m *sync.Map) {
+	m.Range(func(key, val interface{}) bool {
+		m.Delete(key)
+		return true
+	})
+}
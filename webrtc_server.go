@@ -0,0 +1,247 @@
+package mediasoup
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+type WebRtcServerListenInfo struct {
+	/**
+	 * Network protocol.
+	 */
+	Protocol TransportProtocol `json:"protocol"`
+
+	/**
+	 * Listening IPv4 or IPv6.
+	 */
+	Ip string `json:"ip"`
+
+	/**
+	 * Announced IPv4 or IPv6 (useful when running mediasoup behind NAT with
+	 * private IP).
+	 */
+	AnnouncedIp string `json:"announcedIp,omitempty"`
+
+	/**
+	 * Listening port.
+	 */
+	Port uint16 `json:"port"`
+}
+
+type WebRtcServerOptions struct {
+	/**
+	 * Listen infos.
+	 */
+	ListenInfos []WebRtcServerListenInfo `json:"listenInfos,omitempty"`
+
+	/**
+	 * Custom application data.
+	 */
+	AppData interface{} `json:"appData,omitempty"`
+}
+
+type IpPort struct {
+	Ip   string `json:"ip"`
+	Port uint16 `json:"port"`
+}
+
+type IceUserNameFragment struct {
+	LocalIceUsernameFragment string `json:"localIceUsernameFragment"`
+	WebRtcTransportId        string `json:"webRtcTransportId"`
+}
+
+type TupleHash struct {
+	TupleHash         uint64 `json:"tupleHash"`
+	WebRtcTransportId string `json:"webRtcTransportId"`
+}
+
+type WebRtcServerDump struct {
+	Id                        string                `json:"id"`
+	UdpSockets                []IpPort              `json:"udpSockets,omitempty"`
+	TcpServers                []IpPort              `json:"tcpServers,omitempty"`
+	WebRtcTransportIds        []string              `json:"webRtcTransportIds,omitempty"`
+	LocalIceUsernameFragments []IceUserNameFragment `json:"localIceUsernameFragments,omitempty"`
+	TupleHashes               []TupleHash           `json:"tupleHashes,omitempty"`
+}
+
+/**
+ * WebRtcServer listens on a few ports shared by the WebRtcTransports created
+ * with it, instead of each WebRtcTransport binding its own ports. It requires
+ * mediasoup-worker 3.10.0 or later.
+ *
+ * @emits workerclose
+ * @emits @close
+ */
+type WebRtcServer struct {
+	IEventEmitter
+	logger           Logger
+	internal         internalData
+	channel          *Channel
+	closed           uint32
+	appData          interface{}
+	webRtcTransports sync.Map
+	observer         IEventEmitter
+}
+
+type webRtcServerParams struct {
+	internal internalData
+	channel  *Channel
+	appData  interface{}
+}
+
+func newWebRtcServer(params webRtcServerParams) *WebRtcServer {
+	logger := NewLogger("WebRtcServer")
+
+	logger.Debug("constructor()")
+
+	return &WebRtcServer{
+		IEventEmitter: NewEventEmitter(),
+		logger:        logger,
+		internal:      params.internal,
+		channel:       params.channel,
+		appData:       params.appData,
+		observer:      NewEventEmitter(),
+	}
+}
+
+// WebRtcServer id
+func (s *WebRtcServer) Id() string {
+	return s.internal.WebRtcServerId
+}
+
+// Whether the WebRtcServer is closed.
+func (s *WebRtcServer) Closed() bool {
+	return atomic.LoadUint32(&s.closed) > 0
+}
+
+// App custom data.
+func (s *WebRtcServer) AppData() interface{} {
+	return s.appData
+}
+
+/**
+ * Observer.
+ *
+ * @emits close
+ * @emits webrtctransporthandled - (webRtcTransport: *WebRtcTransport)
+ * @emits webrtctransportunhandled - (webRtcTransport: *WebRtcTransport)
+ */
+func (s *WebRtcServer) Observer() IEventEmitter {
+	return s.observer
+}
+
+/**
+ * OnWorkerClose adds a listener of "workerclose" and returns the function
+ * removing it.
+ */
+func (s *WebRtcServer) OnWorkerClose(listener func()) func() {
+	return subscribe(s.IEventEmitter, "workerclose", listener)
+}
+
+/**
+ * WebRtcTransports returns the alive WebRtcTransports created with the
+ * WebRtcServer.
+ */
+func (s *WebRtcServer) WebRtcTransports() (transports []*WebRtcTransport) {
+	s.webRtcTransports.Range(func(key, value interface{}) bool {
+		transports = append(transports, value.(*WebRtcTransport))
+		return true
+	})
+
+	return
+}
+
+/**
+ * Close the WebRtcServer, which closes its WebRtcTransports.
+ */
+func (s *WebRtcServer) Close() {
+	s.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but with a context. The WebRtcServer is closed
+// even if the request fails.
+func (s *WebRtcServer) CloseContext(ctx context.Context) (err error) {
+	if !atomic.CompareAndSwapUint32(&s.closed, 0, 1) {
+		return
+	}
+
+	s.logger.Debug("close()")
+
+	err = s.channel.RequestContext(ctx, "worker.closeWebRtcServer", s.internal).Err()
+
+	// Close every WebRtcTransport.
+	s.webRtcTransports.Range(func(key, value interface{}) bool {
+		// The WebRtcTransport may be closing meanwhile.
+		if _, ok := s.webRtcTransports.LoadAndDelete(key); !ok {
+			return true
+		}
+		transport := value.(*WebRtcTransport)
+
+		transport.listenServerClosed()
+
+		// Emit observer event.
+		s.observer.SafeEmit("webrtctransportunhandled", transport)
+
+		return true
+	})
+
+	s.Emit("@close")
+
+	// Emit observer event.
+	s.observer.SafeEmit("close")
+
+	return
+}
+
+/**
+ * Worker was closed.
+ */
+func (s *WebRtcServer) workerClosed() {
+	if !atomic.CompareAndSwapUint32(&s.closed, 0, 1) {
+		return
+	}
+
+	s.logger.Debug("workerClosed()")
+
+	// NOTE: The WebRtcTransports are closed with their routers.
+	syncMapClear(&s.webRtcTransports)
+
+	s.SafeEmit("workerclose")
+
+	// Emit observer event.
+	s.observer.SafeEmit("close")
+}
+
+// Dump WebRtcServer.
+func (s *WebRtcServer) Dump() (WebRtcServerDump, error) {
+	return s.DumpContext(context.Background())
+}
+
+// DumpContext is like Dump but with a context.
+func (s *WebRtcServer) DumpContext(ctx context.Context) (dump WebRtcServerDump, err error) {
+	s.logger.Debug("dump()")
+
+	resp := s.channel.RequestContext(ctx, "webRtcServer.dump", s.internal)
+	err = resp.Unmarshal(&dump)
+
+	return
+}
+
+// handleWebRtcTransport tracks a WebRtcTransport created with the
+// WebRtcServer until it is closed.
+func (s *WebRtcServer) handleWebRtcTransport(transport *WebRtcTransport) {
+	s.webRtcTransports.Store(transport.Id(), transport)
+
+	// Emit observer event.
+	s.observer.SafeEmit("webrtctransporthandled", transport)
+
+	transport.Observer().On("close", func() {
+		if _, ok := s.webRtcTransports.LoadAndDelete(transport.Id()); !ok {
+			return
+		}
+
+		// Emit observer event.
+		s.observer.SafeEmit("webrtctransportunhandled", transport)
+	})
+}
@@ -0,0 +1,241 @@
+package mediasoup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+func TestWebRtcServerTestingSuite(t *testing.T) {
+	suite.Run(t, new(WebRtcServerTestingSuite))
+}
+
+type WebRtcServerTestingSuite struct {
+	TestingSuite
+	worker       *Worker
+	router       *Router
+	webRtcServer *WebRtcServer
+}
+
+func (suite *WebRtcServerTestingSuite) SetupTest() {
+	var err error
+
+	suite.worker = CreateTestWorker()
+	suite.router = CreateRouter(suite.worker)
+	suite.webRtcServer, err = suite.worker.CreateWebRtcServer(WebRtcServerOptions{
+		ListenInfos: []WebRtcServerListenInfo{
+			{Protocol: TransportProtocol_Udp, Ip: "127.0.0.1", AnnouncedIp: "9.9.9.1", Port: 44544},
+			{Protocol: TransportProtocol_Tcp, Ip: "127.0.0.1", AnnouncedIp: "9.9.9.1", Port: 44544},
+		},
+		AppData: H{"foo": "bar"},
+	})
+	suite.Require().NoError(err)
+}
+
+func (suite *WebRtcServerTestingSuite) TearDownTest() {
+	suite.worker.Close()
+}
+
+func (suite *WebRtcServerTestingSuite) TestCreateWebRtcServer_Succeeds() {
+	onObserverNewWebRtcServer := NewMockFunc(suite.T())
+	suite.worker.Observer().Once("newwebrtcserver", onObserverNewWebRtcServer.Fn())
+
+	webRtcServer, err := suite.worker.CreateWebRtcServer(WebRtcServerOptions{
+		ListenInfos: []WebRtcServerListenInfo{
+			{Protocol: TransportProtocol_Udp, Ip: "127.0.0.1", Port: 44545},
+		},
+	})
+	suite.NoError(err)
+
+	onObserverNewWebRtcServer.ExpectCalledTimes(1)
+	onObserverNewWebRtcServer.ExpectCalledWith(webRtcServer)
+	suite.False(webRtcServer.Closed())
+	suite.Equal(H{"foo": "bar"}, suite.webRtcServer.AppData())
+	suite.ElementsMatch([]*WebRtcServer{suite.webRtcServer, webRtcServer}, suite.worker.WebRtcServers())
+
+	dump, err := suite.worker.Dump()
+	suite.NoError(err)
+	suite.ElementsMatch([]string{suite.webRtcServer.Id(), webRtcServer.Id()}, dump.WebRtcServerIds)
+}
+
+func (suite *WebRtcServerTestingSuite) TestCreateWebRtcServer_TypeError() {
+	_, err := suite.worker.CreateWebRtcServer(WebRtcServerOptions{})
+	suite.IsType(NewTypeError(""), err)
+
+	_, err = suite.worker.CreateWebRtcServer(WebRtcServerOptions{
+		ListenInfos: []WebRtcServerListenInfo{
+			{Protocol: "foo", Ip: "127.0.0.1", Port: 44545},
+		},
+	})
+	suite.IsType(NewTypeError(""), err)
+}
+
+func (suite *WebRtcServerTestingSuite) TestDump_Succeeds() {
+	transport, err := suite.router.CreateWebRtcTransport(WebRtcTransportOptions{
+		WebRtcServer: suite.webRtcServer,
+		EnableTcp:    true,
+	})
+	suite.NoError(err)
+
+	dump, err := suite.webRtcServer.Dump()
+	suite.NoError(err)
+	suite.Equal(suite.webRtcServer.Id(), dump.Id)
+	suite.Equal([]IpPort{{Ip: "127.0.0.1", Port: 44544}}, dump.UdpSockets)
+	suite.Equal([]IpPort{{Ip: "127.0.0.1", Port: 44544}}, dump.TcpServers)
+	suite.Equal([]string{transport.Id()}, dump.WebRtcTransportIds)
+	suite.Equal([]IceUserNameFragment{
+		{
+			LocalIceUsernameFragment: transport.IceParameters().UsernameFragment,
+			WebRtcTransportId:        transport.Id(),
+		},
+	}, dump.LocalIceUsernameFragments)
+	suite.Empty(dump.TupleHashes)
+
+	iceCandidates := transport.IceCandidates()
+	suite.Len(iceCandidates, 2)
+	suite.Equal("9.9.9.1", iceCandidates[0].Ip)
+	suite.EqualValues(44544, iceCandidates[0].Port)
+	suite.EqualValues("udp", iceCandidates[0].Protocol)
+	suite.Equal("9.9.9.1", iceCandidates[1].Ip)
+	suite.EqualValues(44544, iceCandidates[1].Port)
+	suite.EqualValues("tcp", iceCandidates[1].Protocol)
+}
+
+func (suite *WebRtcServerTestingSuite) TestObserverEvents_Succeeds() {
+	onObserverHandled := NewMockFunc(suite.T())
+	suite.webRtcServer.Observer().On("webrtctransporthandled", onObserverHandled.Fn())
+
+	onObserverUnhandled := NewMockFunc(suite.T())
+	suite.webRtcServer.Observer().On("webrtctransportunhandled", onObserverUnhandled.Fn())
+
+	transport, err := suite.router.CreateWebRtcTransport(WebRtcTransportOptions{
+		WebRtcServer: suite.webRtcServer,
+	})
+	suite.NoError(err)
+
+	onObserverHandled.ExpectCalledTimes(1)
+	onObserverHandled.ExpectCalledWith(transport)
+	suite.Equal([]*WebRtcTransport{transport}, suite.webRtcServer.WebRtcTransports())
+
+	transport.Close()
+
+	onObserverUnhandled.ExpectCalledTimes(1)
+	onObserverUnhandled.ExpectCalledWith(transport)
+	suite.Empty(suite.webRtcServer.WebRtcTransports())
+
+	dump, err := suite.webRtcServer.Dump()
+	suite.NoError(err)
+	suite.Empty(dump.WebRtcTransportIds)
+}
+
+func (suite *WebRtcServerTestingSuite) TestClose_Succeeds() {
+	transport, err := suite.router.CreateWebRtcTransport(WebRtcTransportOptions{
+		WebRtcServer: suite.webRtcServer,
+	})
+	suite.NoError(err)
+
+	onObserverClose := NewMockFunc(suite.T())
+	suite.webRtcServer.Observer().Once("close", onObserverClose.Fn())
+
+	onObserverUnhandled := NewMockFunc(suite.T())
+	suite.webRtcServer.Observer().On("webrtctransportunhandled", onObserverUnhandled.Fn())
+
+	suite.webRtcServer.Close()
+
+	onObserverClose.ExpectCalledTimes(1)
+	onObserverUnhandled.ExpectCalledTimes(1)
+	onObserverUnhandled.ExpectCalledWith(transport)
+	suite.True(suite.webRtcServer.Closed())
+	suite.Empty(suite.worker.WebRtcServers())
+
+	dump, err := suite.worker.Dump()
+	suite.NoError(err)
+	suite.Empty(dump.WebRtcServerIds)
+
+	_, err = suite.webRtcServer.Dump()
+	suite.Error(err)
+
+	_, err = suite.router.CreateWebRtcTransport(WebRtcTransportOptions{
+		WebRtcServer: suite.webRtcServer,
+	})
+	suite.Error(err)
+}
+
+func (suite *WebRtcServerTestingSuite) TestCloseContext_Canceled() {
+	transport, err := suite.router.CreateWebRtcTransport(WebRtcTransportOptions{
+		WebRtcServer: suite.webRtcServer,
+	})
+	suite.NoError(err)
+
+	onObserverClose := NewMockFunc(suite.T())
+	suite.webRtcServer.Observer().Once("close", onObserverClose.Fn())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The WebRtcServer is closed even if the request is canceled.
+	suite.Equal(context.Canceled, suite.webRtcServer.CloseContext(ctx))
+	onObserverClose.ExpectCalledTimes(1)
+	suite.True(suite.webRtcServer.Closed())
+	suite.True(transport.Closed())
+	suite.Empty(suite.worker.WebRtcServers())
+	suite.NoError(suite.webRtcServer.CloseContext(context.Background()))
+}
+
+func (suite *WebRtcServerTestingSuite) TestEmitsListenservercloseIfWebRtcServerIsClosed() {
+	transport, err := suite.router.CreateWebRtcTransport(WebRtcTransportOptions{
+		WebRtcServer: suite.webRtcServer,
+		EnableSctp:   true,
+	})
+	suite.NoError(err)
+
+	onListenServerClose := NewMockFunc(suite.T())
+	listenServerCloseFn := onListenServerClose.Fn()
+	transport.OnListenServerClose(func() { listenServerCloseFn() })
+
+	onObserverClose := NewMockFunc(suite.T())
+	transport.Observer().Once("close", onObserverClose.Fn())
+
+	suite.webRtcServer.Close()
+
+	onListenServerClose.ExpectCalledTimes(1)
+	onObserverClose.ExpectCalledTimes(1)
+	suite.True(transport.Closed())
+	suite.EqualValues("closed", transport.IceState())
+	suite.Nil(transport.IceSelectedTuple())
+	suite.EqualValues("closed", transport.DtlsState())
+	suite.EqualValues("closed", transport.SctpState())
+	suite.Empty(suite.router.Transports())
+
+	dump, err := suite.router.Dump()
+	suite.NoError(err)
+	suite.Empty(dump.TransportIds)
+}
+
+func (suite *WebRtcServerTestingSuite) TestEmitsWorkercloseIfWorkerIsClosed() {
+	transport, err := suite.router.CreateWebRtcTransport(WebRtcTransportOptions{
+		WebRtcServer: suite.webRtcServer,
+	})
+	suite.NoError(err)
+
+	onWorkerClose := NewMockFunc(suite.T())
+	workerCloseFn := onWorkerClose.Fn()
+	suite.webRtcServer.OnWorkerClose(func() { workerCloseFn() })
+
+	onObserverClose := NewMockFunc(suite.T())
+	suite.webRtcServer.Observer().Once("close", onObserverClose.Fn())
+
+	onObserverUnhandled := NewMockFunc(suite.T())
+	suite.webRtcServer.Observer().On("webrtctransportunhandled", onObserverUnhandled.Fn())
+
+	suite.worker.Close()
+
+	onWorkerClose.ExpectCalledTimes(1)
+	onObserverClose.ExpectCalledTimes(1)
+	onObserverUnhandled.ExpectCalledTimes(0)
+	suite.True(suite.webRtcServer.Closed())
+	suite.True(transport.Closed())
+	suite.Empty(suite.webRtcServer.WebRtcTransports())
+	suite.Empty(suite.worker.WebRtcServers())
+}
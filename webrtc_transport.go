@@ -1,11 +1,14 @@
 package mediasoup
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+)
 
 type WebRtcTransportOptions struct {
 	/**
 	 * Listening IP address or addresses in order of preference (first one is the
-	 * preferred one).
+	 * preferred one). Mandatory unless WebRtcServer is given.
 	 */
 	ListenIps []TransportListenIp `json:"listenIps,omitempty"`
 
@@ -56,6 +59,12 @@ type WebRtcTransportOptions struct {
 	 */
 	SctpSendBufferSize int `json:"sctpSendBufferSize,omitempty"`
 
+	/**
+	 * WebRtcServer whose ports the transport listens on, instead of its own
+	 * ones. ListenIps is ignored if given.
+	 */
+	WebRtcServer *WebRtcServer `json:"-"`
+
 	/**
 	 * Custom application data.
 	 */
@@ -147,6 +156,7 @@ type webrtcTransportData struct {
 
 /**
  * WebRtcTransport
+ * @emits listenserverclose
  * @emits icestatechange - (iceState: IceState)
  * @emits iceselectedtuplechange - (iceSelectedTuple: TransportTuple)
  * @emits dtlsstatechange - (dtlsState: DtlsState)
@@ -274,14 +284,60 @@ func (transport *WebRtcTransport) Observer() IEventEmitter {
 	return transport.ITransport.Observer()
 }
 
+/**
+ * OnListenServerClose adds a listener of "listenserverclose", emitted when the
+ * WebRtcServer of the transport is closed, and returns the function removing
+ * it.
+ */
+func (transport *WebRtcTransport) OnListenServerClose(listener func()) func() {
+	return transport.subscribe("listenserverclose", listener)
+}
+
+/**
+ * OnIceStateChange adds a listener of "icestatechange" and returns the function
+ * removing it.
+ */
+func (transport *WebRtcTransport) OnIceStateChange(listener func(IceState)) func() {
+	return transport.subscribe("icestatechange", listener)
+}
+
+/**
+ * OnIceSelectedTupleChange adds a listener of "iceselectedtuplechange" and returns the function
+ * removing it.
+ */
+func (transport *WebRtcTransport) OnIceSelectedTupleChange(listener func(TransportTuple)) func() {
+	return transport.subscribe("iceselectedtuplechange", listener)
+}
+
+/**
+ * OnDtlsStateChange adds a listener of "dtlsstatechange" and returns the function
+ * removing it.
+ */
+func (transport *WebRtcTransport) OnDtlsStateChange(listener func(DtlsState)) func() {
+	return transport.subscribe("dtlsstatechange", listener)
+}
+
+/**
+ * OnSctpStateChange adds a listener of "sctpstatechange" and returns the function
+ * removing it.
+ */
+func (transport *WebRtcTransport) OnSctpStateChange(listener func(SctpState)) func() {
+	return transport.subscribe("sctpstatechange", listener)
+}
+
 /**
  * Close the WebRtcTransport.
  *
  * @override
  */
 func (transport *WebRtcTransport) Close() {
+	transport.CloseContext(context.Background())
+}
+
+// CloseContext is like Close but with a context.
+func (transport *WebRtcTransport) CloseContext(ctx context.Context) error {
 	if transport.Closed() {
-		return
+		return nil
 	}
 
 	transport.data.IceSelectedTuple = nil
@@ -292,7 +348,7 @@ func (transport *WebRtcTransport) Close() {
 		transport.data.SctpState = SctpState_Closed
 	}
 
-	transport.ITransport.Close()
+	return transport.ITransport.CloseContext(ctx)
 }
 
 /**
@@ -316,16 +372,42 @@ func (transport *WebRtcTransport) routerClosed() {
 	transport.ITransport.routerClosed()
 }
 
+/**
+ * The WebRtcServer was closed.
+ *
+ * @override
+ */
+func (transport *WebRtcTransport) listenServerClosed() {
+	if transport.Closed() {
+		return
+	}
+
+	transport.data.IceSelectedTuple = nil
+	transport.data.IceState = IceState_Closed
+	transport.data.DtlsState = DtlsState_Closed
+
+	if len(transport.data.SctpState) > 0 {
+		transport.data.SctpState = SctpState_Closed
+	}
+
+	transport.ITransport.listenServerClosed()
+}
+
 /**
  * Provide the PlainTransport remote parameters.
  *
  * @override
  */
-func (transport *WebRtcTransport) Connect(options TransportConnectOptions) (err error) {
+func (transport *WebRtcTransport) Connect(options TransportConnectOptions) error {
+	return transport.ConnectContext(context.Background(), options)
+}
+
+// ConnectContext is like Connect but with a context.
+func (transport *WebRtcTransport) ConnectContext(ctx context.Context, options TransportConnectOptions) (err error) {
 	transport.logger.Debug("connect()")
 
 	reqData := TransportConnectOptions{DtlsParameters: options.DtlsParameters}
-	resp := transport.channel.Request("transport.connect", transport.internal, reqData)
+	resp := transport.channel.RequestContext(ctx, "transport.connect", transport.internal, reqData)
 
 	var data struct {
 		DtlsLocalRole DtlsRole
@@ -343,10 +425,15 @@ func (transport *WebRtcTransport) Connect(options TransportConnectOptions) (err
 /**
  * Restart ICE.
  */
-func (transport *WebRtcTransport) RestartIce() (iceParameters IceParameters, err error) {
+func (transport *WebRtcTransport) RestartIce() (IceParameters, error) {
+	return transport.RestartIceContext(context.Background())
+}
+
+// RestartIceContext is like RestartIce but with a context.
+func (transport *WebRtcTransport) RestartIceContext(ctx context.Context) (iceParameters IceParameters, err error) {
 	transport.logger.Debug("restartIce()")
 
-	resp := transport.channel.Request("transport.restartIce", transport.internal)
+	resp := transport.channel.RequestContext(ctx, "transport.restartIce", transport.internal)
 
 	var data struct {
 		IceParameters IceParameters
@@ -352,6 +352,47 @@ func (suite *WebRtcTransportTestingSuite) TestEvents_Succeeds() {
 	suite.Equal("ABCD", transport.DtlsRemoteCert())
 }
 
+func (suite *WebRtcTransportTestingSuite) TestTypedEvents_Succeeds() {
+	transport := suite.transport
+
+	// Private API.
+	channel := transport.channel
+	onIceStateChange1 := NewMockFunc(suite.T())
+	onIceStateChange1Fn := onIceStateChange1.Fn()
+	onIceStateChange2 := NewMockFunc(suite.T())
+	onIceStateChange2Fn := onIceStateChange2.Fn()
+
+	unsubscribe := transport.OnIceStateChange(func(iceState IceState) { onIceStateChange1Fn(iceState) })
+	transport.OnIceStateChange(func(iceState IceState) { onIceStateChange2Fn(iceState) })
+
+	data, _ := json.Marshal(H{"iceState": "completed"})
+	channel.Emit(transport.Id(), "icestatechange", data)
+
+	onIceStateChange1.ExpectCalledTimes(1)
+	onIceStateChange1.ExpectCalledWith(IceState("completed"))
+	onIceStateChange2.ExpectCalledTimes(1)
+
+	unsubscribe()
+	unsubscribe()
+
+	data, _ = json.Marshal(H{"iceState": "disconnected"})
+	channel.Emit(transport.Id(), "icestatechange", data)
+
+	onIceStateChange1.ExpectCalledTimes(1)
+	onIceStateChange2.ExpectCalledTimes(2)
+	onIceStateChange2.ExpectCalledWith(IceState("disconnected"))
+
+	onDtlsStateChange := NewMockFunc(suite.T())
+	onDtlsStateChangeFn := onDtlsStateChange.Fn()
+	transport.OnDtlsStateChange(func(dtlsState DtlsState) { onDtlsStateChangeFn(dtlsState) })
+
+	data, _ = json.Marshal(H{"dtlsState": "connecting"})
+	channel.Emit(transport.Id(), "dtlsstatechange", data)
+
+	onDtlsStateChange.ExpectCalledTimes(1)
+	onDtlsStateChange.ExpectCalledWith(DtlsState("connecting"))
+}
+
 func (suite *WebRtcTransportTestingSuite) TestMethodsRejectIfClosed() {
 	transport := suite.transport
 	onObserverClose := NewMockFunc(suite.T())
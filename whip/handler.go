@@ -0,0 +1,352 @@
+// Package whip provides http.Handler implementations of the WHIP (WebRTC-HTTP
+// ingestion protocol) and WHEP (WebRTC-HTTP egress protocol) drafts, so that
+// publishers such as OBS or GStreamer, and players, can be connected to a
+// mediasoup router without writing signaling.
+//
+// A handler is mounted on a path prefix. POST on any path below it creates a
+// session whose resource URL is the request path followed by the session id.
+// PATCH on the resource URL handles trickle ICE and ICE restarts, and DELETE
+// tears the session down.
+package whip
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/sdp"
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	sdpContentType     = "application/sdp"
+	sdpFragContentType = "application/trickle-ice-sdpfrag"
+
+	maxBodySize = 1 << 20
+)
+
+/**
+ * Session is a WHIP or WHEP resource, backed by a WebRtcTransport.
+ *
+ * @emits close
+ */
+type Session struct {
+	mediasoup.IEventEmitter
+	id             string
+	handler        *handler
+	transport      *mediasoup.WebRtcTransport
+	offer          *sdp.Offer
+	remoteIceUfrag string
+	producers      []*mediasoup.Producer
+	consumers      []*mediasoup.Consumer
+	closed         uint32
+	locker         sync.Mutex
+}
+
+// Session id, the last segment of the resource URL.
+func (s *Session) Id() string {
+	return s.id
+}
+
+// Transport of the session.
+func (s *Session) Transport() *mediasoup.WebRtcTransport {
+	return s.transport
+}
+
+// Producers created from a WHIP offer.
+func (s *Session) Producers() []*mediasoup.Producer {
+	return s.producers
+}
+
+// Consumers created for a WHEP offer.
+func (s *Session) Consumers() []*mediasoup.Consumer {
+	return s.consumers
+}
+
+/**
+ * Whether the Session is closed.
+ */
+func (s *Session) Closed() bool {
+	return atomic.LoadUint32(&s.closed) > 0
+}
+
+/**
+ * Close the Session and its transport.
+ */
+func (s *Session) Close() {
+	if !atomic.CompareAndSwapUint32(&s.closed, 0, 1) {
+		return
+	}
+
+	s.handler.logger.Debug("session close() [id:%s]", s.id)
+
+	s.transport.Close()
+	s.handler.remove(s)
+
+	s.SafeEmit("close")
+}
+
+// setupFunc creates the producers or consumers of a new session and adds them
+// to the answer options.
+type setupFunc func(r *http.Request, router *mediasoup.Router, session *Session, answerOptions *sdp.AnswerOptions) error
+
+// handler serves the resources shared by WHIP and WHEP.
+type handler struct {
+	mediasoup.IEventEmitter
+	logger           mediasoup.Logger
+	router           func(r *http.Request) (*mediasoup.Router, error)
+	transportOptions mediasoup.WebRtcTransportOptions
+	setup            setupFunc
+	sessions         map[string]*Session
+	locker           sync.Mutex
+}
+
+func newHandler(name string, router func(r *http.Request) (*mediasoup.Router, error),
+	transportOptions mediasoup.WebRtcTransportOptions, setup setupFunc) *handler {
+	return &handler{
+		IEventEmitter:    mediasoup.NewEventEmitter(),
+		logger:           mediasoup.NewLogger(name),
+		router:           router,
+		transportOptions: transportOptions,
+		setup:            setup,
+		sessions:         make(map[string]*Session),
+	}
+}
+
+/**
+ * Session returns the session with the given id, or nil.
+ */
+func (h *handler) Session(id string) *Session {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+
+	return h.sessions[id]
+}
+
+/**
+ * Sessions returns the alive sessions.
+ */
+func (h *handler) Sessions() (sessions []*Session) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+
+	for _, session := range h.sessions {
+		sessions = append(sessions, session)
+	}
+
+	return
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.post(w, r)
+
+	case http.MethodPatch:
+		h.patch(w, r)
+
+	case http.MethodDelete:
+		h.delete(w, r)
+
+	default:
+		w.Header().Set("Allow", "POST, PATCH, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *handler) post(w http.ResponseWriter, r *http.Request) {
+	body, ok := readBody(w, r, sdpContentType)
+	if !ok {
+		return
+	}
+
+	offer, err := sdp.ParseOffer(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	connectOptions, err := offer.ConnectOptions()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	router, err := h.router(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	transport, err := router.CreateWebRtcTransport(h.transportOptions)
+	if err != nil {
+		h.logger.Error("createWebRtcTransport() failed: %s", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	session := &Session{
+		IEventEmitter: mediasoup.NewEventEmitter(),
+		id:            uuid.NewV4().String(),
+		handler:       h,
+		transport:     transport,
+		offer:         offer,
+	}
+	session.remoteIceUfrag = remoteIceUfrag(offer)
+
+	answer, err := h.answer(r, router, session, connectOptions)
+	if err != nil {
+		transport.Close()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.locker.Lock()
+	h.sessions[session.id] = session
+	h.locker.Unlock()
+
+	transport.Observer().On("close", session.Close)
+
+	w.Header().Set("Content-Type", sdpContentType)
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+session.id)
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(answer))
+
+	h.SafeEmit("newsession", session)
+}
+
+func (h *handler) answer(r *http.Request, router *mediasoup.Router, session *Session,
+	connectOptions mediasoup.TransportConnectOptions) (answer string, err error) {
+	if err = session.transport.Connect(connectOptions); err != nil {
+		return
+	}
+
+	answerOptions := sdp.NewAnswerOptions(session.transport)
+
+	if err = h.setup(r, router, session, &answerOptions); err != nil {
+		return
+	}
+
+	return session.offer.Answer(answerOptions)
+}
+
+func (h *handler) patch(w http.ResponseWriter, r *http.Request) {
+	session := h.Session(path.Base(r.URL.Path))
+	if session == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	body, ok := readBody(w, r, sdpFragContentType)
+	if !ok {
+		return
+	}
+
+	ufrag, _ := fragAttribute(body, "ice-ufrag")
+
+	session.locker.Lock()
+	defer session.locker.Unlock()
+
+	// mediasoup is ICE lite, remote candidates are not needed.
+	if len(ufrag) == 0 || ufrag == session.remoteIceUfrag {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.logger.Debug("restarting ICE [id:%s]", session.id)
+
+	iceParameters, err := session.transport.RestartIce()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	session.remoteIceUfrag = ufrag
+
+	w.Header().Set("Content-Type", sdpFragContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(iceFragment(session, iceParameters)))
+}
+
+func (h *handler) delete(w http.ResponseWriter, r *http.Request) {
+	session := h.Session(path.Base(r.URL.Path))
+	if session == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	session.Close()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *handler) remove(session *Session) {
+	h.locker.Lock()
+	defer h.locker.Unlock()
+
+	delete(h.sessions, session.id)
+}
+
+func readBody(w http.ResponseWriter, r *http.Request, contentType string) (string, bool) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), contentType) {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return "", false
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+
+	return string(body), true
+}
+
+func remoteIceUfrag(offer *sdp.Offer) string {
+	if ufrag, ok := offer.Media[0].Attribute("ice-ufrag"); ok {
+		return ufrag
+	}
+	ufrag, _ := offer.Attribute("ice-ufrag")
+	return ufrag
+}
+
+// fragAttribute returns the value of the first attribute of a SDP fragment.
+func fragAttribute(frag, key string) (string, bool) {
+	for _, line := range strings.Split(frag, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "a="+key+":") {
+			return strings.TrimPrefix(line, "a="+key+":"), true
+		}
+	}
+	return "", false
+}
+
+// iceFragment returns the SDP fragment answering an ICE restart.
+func iceFragment(session *Session, iceParameters mediasoup.IceParameters) string {
+	b := &strings.Builder{}
+
+	if iceParameters.IceLite {
+		b.WriteString("a=ice-lite\r\n")
+	}
+	b.WriteString("a=ice-ufrag:" + iceParameters.UsernameFragment + "\r\n")
+	b.WriteString("a=ice-pwd:" + iceParameters.Password + "\r\n")
+
+	for _, m := range session.offer.Media {
+		if m.Port == 0 || (m.Kind != "audio" && m.Kind != "video") {
+			continue
+		}
+		b.WriteString("m=" + m.Kind + " 9 " + m.Protocol + " " + strings.Join(m.Formats, " ") + "\r\n")
+		b.WriteString("a=mid:" + m.Mid() + "\r\n")
+
+		for _, candidate := range session.transport.IceCandidates() {
+			b.WriteString("a=candidate:" + sdp.FormatCandidate(candidate) + "\r\n")
+		}
+		b.WriteString("a=end-of-candidates\r\n")
+	}
+
+	return b.String()
+}
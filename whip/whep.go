@@ -0,0 +1,90 @@
+package whip
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/sdp"
+)
+
+type WhepHandlerOptions struct {
+	/**
+	 * Router returns the router to play from for the request. An error is
+	 * answered with 404 Not Found.
+	 */
+	Router func(r *http.Request) (*mediasoup.Router, error)
+
+	/**
+	 * Producers returns the producers of the router to play for the request.
+	 */
+	Producers func(r *http.Request, router *mediasoup.Router) ([]*mediasoup.Producer, error)
+
+	/**
+	 * Options of the WebRtcTransport created for every session.
+	 */
+	WebRtcTransportOptions mediasoup.WebRtcTransportOptions
+}
+
+/**
+ * WhepHandler serves WHEP playback. Every producer the player can consume is
+ * sent in the first free section of its kind it receives, in the order of the
+ * offer. Producers left without a section are not played.
+ *
+ * @emits newsession - (session: *Session)
+ */
+type WhepHandler struct {
+	*handler
+}
+
+func NewWhepHandler(options WhepHandlerOptions) *WhepHandler {
+	setup := func(r *http.Request, router *mediasoup.Router, session *Session, answerOptions *sdp.AnswerOptions) error {
+		producers, err := options.Producers(r, router)
+		if err != nil {
+			return err
+		}
+
+		rtpCapabilities := session.offer.RtpCapabilities(router.RtpCapabilities())
+		usedMids := map[string]bool{}
+
+		for _, producer := range producers {
+			mid := receivingMid(session.offer, producer.Kind(), usedMids)
+			if len(mid) == 0 || !router.CanConsume(producer.Id(), rtpCapabilities) {
+				continue
+			}
+			consumer, err := session.transport.Consume(mediasoup.ConsumerOptions{
+				ProducerId:      producer.Id(),
+				RtpCapabilities: rtpCapabilities,
+				Mid:             mid,
+			})
+			if err != nil {
+				return err
+			}
+			usedMids[mid] = true
+			session.consumers = append(session.consumers, consumer)
+		}
+
+		if len(session.consumers) == 0 {
+			return errors.New("no media to play")
+		}
+
+		answerOptions.Consumers = session.consumers
+
+		return nil
+	}
+
+	return &WhepHandler{
+		handler: newHandler("WhepHandler", options.Router, options.WebRtcTransportOptions, setup),
+	}
+}
+
+// receivingMid returns the mid of the first section of the offer receiving the
+// given kind which is not used yet, or "".
+func receivingMid(offer *sdp.Offer, kind mediasoup.MediaKind, used map[string]bool) string {
+	for _, m := range offer.Media {
+		if m.Kind == string(kind) && m.Direction() == "recvonly" && !used[m.Mid()] {
+			return m.Mid()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package whip
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/sdp"
+)
+
+type WhipHandlerOptions struct {
+	/**
+	 * Router returns the router to publish into for the request. An error is
+	 * answered with 404 Not Found.
+	 */
+	Router func(r *http.Request) (*mediasoup.Router, error)
+
+	/**
+	 * Options of the WebRtcTransport created for every session.
+	 */
+	WebRtcTransportOptions mediasoup.WebRtcTransportOptions
+}
+
+/**
+ * WhipHandler serves WHIP ingest. Every audio and video section sent by the
+ * publisher becomes a Producer.
+ *
+ * @emits newsession - (session: *Session)
+ */
+type WhipHandler struct {
+	*handler
+}
+
+func NewWhipHandler(options WhipHandlerOptions) *WhipHandler {
+	return &WhipHandler{
+		handler: newHandler("WhipHandler", options.Router, options.WebRtcTransportOptions, setupProducers),
+	}
+}
+
+func setupProducers(r *http.Request, router *mediasoup.Router, session *Session, answerOptions *sdp.AnswerOptions) error {
+	producerOptions, err := session.offer.ProducerOptions(router.RtpCapabilities())
+	if err != nil {
+		return err
+	}
+	if len(producerOptions) == 0 {
+		return errors.New("no media to publish")
+	}
+
+	for _, options := range producerOptions {
+		producer, err := session.transport.Produce(options)
+		if err != nil {
+			return err
+		}
+		session.producers = append(session.producers, producer)
+	}
+
+	answerOptions.Producers = session.producers
+
+	return nil
+}
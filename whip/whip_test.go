@@ -0,0 +1,326 @@
+package whip
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/sdp"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const publishOffer = `v=0
+o=- 1 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0 1
+m=audio 9 UDP/TLS/RTP/SAVPF 111
+c=IN IP4 0.0.0.0
+a=ice-ufrag:EsAw
+a=ice-pwd:P2uYro0UCOQ4zxjKXaWCBui1
+a=fingerprint:sha-256 D1:2C:BE:AD:C4:F6:64:5C:25:16:11:9C:AF:E7:0F:73:79:36:4E:9C:1E:15:54:39:0C:06:8B:ED:96:86:00:39
+a=setup:actpass
+a=mid:0
+a=sendonly
+a=rtcp-mux
+a=rtpmap:111 opus/48000/2
+a=ssrc:1001 cname:obs
+m=video 9 UDP/TLS/RTP/SAVPF 96
+c=IN IP4 0.0.0.0
+a=mid:1
+a=sendonly
+a=rtcp-mux
+a=rtpmap:96 VP8/90000
+a=ssrc:2001 cname:obs
+`
+
+const playOffer = `v=0
+o=- 1 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0 1
+a=ice-ufrag:PlAy
+a=ice-pwd:P2uYro0UCOQ4zxjKXaWCBui1
+a=fingerprint:sha-256 D1:2C:BE:AD:C4:F6:64:5C:25:16:11:9C:AF:E7:0F:73:79:36:4E:9C:1E:15:54:39:0C:06:8B:ED:96:86:00:39
+a=setup:actpass
+m=audio 9 UDP/TLS/RTP/SAVPF 100
+c=IN IP4 0.0.0.0
+a=mid:0
+a=recvonly
+a=rtcp-mux
+a=rtpmap:100 opus/48000/2
+m=video 9 UDP/TLS/RTP/SAVPF 101
+c=IN IP4 0.0.0.0
+a=mid:1
+a=recvonly
+a=rtcp-mux
+a=rtpmap:101 VP8/90000
+`
+
+// reversedPlayOffer receives the video before the audio, the opposite of the
+// order of the producers.
+const reversedPlayOffer = `v=0
+o=- 1 2 IN IP4 127.0.0.1
+s=-
+t=0 0
+a=group:BUNDLE 0 1
+a=ice-ufrag:PlAy
+a=ice-pwd:P2uYro0UCOQ4zxjKXaWCBui1
+a=fingerprint:sha-256 D1:2C:BE:AD:C4:F6:64:5C:25:16:11:9C:AF:E7:0F:73:79:36:4E:9C:1E:15:54:39:0C:06:8B:ED:96:86:00:39
+a=setup:actpass
+m=video 9 UDP/TLS/RTP/SAVPF 101
+c=IN IP4 0.0.0.0
+a=mid:0
+a=recvonly
+a=rtcp-mux
+a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid
+a=rtpmap:101 VP8/90000
+m=audio 9 UDP/TLS/RTP/SAVPF 100
+c=IN IP4 0.0.0.0
+a=mid:1
+a=recvonly
+a=rtcp-mux
+a=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid
+a=rtpmap:100 opus/48000/2
+`
+
+type testServer struct {
+	*httptest.Server
+	router *mediasoup.Router
+	whip   *WhipHandler
+	whep   *WhepHandler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	router, _ := workertest.NewRouter(t)
+
+	findRouter := func(r *http.Request) (*mediasoup.Router, error) {
+		if strings.Contains(r.URL.Path, "unknown") {
+			return nil, errors.New("unknown stream")
+		}
+		return router, nil
+	}
+	transportOptions := mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+	}
+
+	s := &testServer{router: router}
+
+	s.whip = NewWhipHandler(WhipHandlerOptions{
+		Router:                 findRouter,
+		WebRtcTransportOptions: transportOptions,
+	})
+	s.whep = NewWhepHandler(WhepHandlerOptions{
+		Router: findRouter,
+		Producers: func(r *http.Request, router *mediasoup.Router) (producers []*mediasoup.Producer, err error) {
+			for _, session := range s.whip.Sessions() {
+				producers = append(producers, session.Producers()...)
+			}
+			return
+		},
+		WebRtcTransportOptions: transportOptions,
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/whip/", s.whip)
+	mux.Handle("/whep/", s.whep)
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *testServer) do(t *testing.T, method, path, contentType, body string) (*http.Response, string) {
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp, string(data)
+}
+
+func TestWhipPublish(t *testing.T) {
+	s := newTestServer(t)
+
+	newSessionCh := make(chan *Session, 1)
+	s.whip.On("newsession", func(session *Session) { newSessionCh <- session })
+
+	resp, body := s.do(t, http.MethodPost, "/whip/live", sdpContentType, publishOffer)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+	assert.Equal(t, sdpContentType, resp.Header.Get("Content-Type"))
+
+	session := <-newSessionCh
+	location := resp.Header.Get("Location")
+	assert.Equal(t, "/whip/live/"+session.Id(), location)
+	assert.Equal(t, session, s.whip.Session(session.Id()))
+	require.Len(t, session.Producers(), 2)
+	assert.Equal(t, "0", session.Producers()[0].RtpParameters().Mid)
+	assert.Equal(t, mediasoup.MediaKind(mediasoup.MediaKind_Video), session.Producers()[1].Kind())
+
+	answer, err := sdp.Parse(body)
+	require.NoError(t, err)
+	require.Len(t, answer.Media, 2)
+	assert.Equal(t, "recvonly", answer.Media[0].Direction())
+	assert.Equal(t, "recvonly", answer.Media[1].Direction())
+
+	ufrag, _ := answer.Media[0].Attribute("ice-ufrag")
+	assert.Equal(t, session.Transport().IceParameters().UsernameFragment, ufrag)
+
+	// Trickle candidates are acknowledged.
+	resp, _ = s.do(t, http.MethodPatch, location, sdpFragContentType,
+		"a=ice-ufrag:EsAw\r\na=ice-pwd:P2uYro0UCOQ4zxjKXaWCBui1\r\nm=audio 9 UDP/TLS/RTP/SAVPF 0\r\na=mid:0\r\na=candidate:1 1 udp 1 10.0.0.1 5000 typ host\r\n")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	// A new ufrag restarts ICE.
+	resp, body = s.do(t, http.MethodPatch, location, sdpFragContentType,
+		"a=ice-ufrag:NeWu\r\na=ice-pwd:NewPasswordNewPassword12\r\n")
+	require.Equal(t, http.StatusOK, resp.StatusCode, body)
+	assert.Equal(t, sdpFragContentType, resp.Header.Get("Content-Type"))
+
+	iceParameters := session.Transport().IceParameters()
+	assert.NotEqual(t, ufrag, iceParameters.UsernameFragment)
+	assert.Contains(t, body, "a=ice-ufrag:"+iceParameters.UsernameFragment+"\r\n")
+	assert.Contains(t, body, "a=ice-pwd:"+iceParameters.Password+"\r\n")
+	assert.Contains(t, body, "a=mid:0\r\n")
+	assert.Contains(t, body, "a=candidate:")
+
+	onClose := make(chan struct{}, 1)
+	session.On("close", func() { onClose <- struct{}{} })
+
+	resp, _ = s.do(t, http.MethodDelete, location, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	select {
+	case <-onClose:
+	case <-time.After(time.Second):
+		t.Fatal("timeout")
+	}
+	assert.True(t, session.Transport().Closed())
+	assert.Nil(t, s.whip.Session(session.Id()))
+
+	resp, _ = s.do(t, http.MethodDelete, location, "", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestWhipPublish_Errors(t *testing.T) {
+	s := newTestServer(t)
+
+	resp, _ := s.do(t, http.MethodPost, "/whip/live", "text/plain", publishOffer)
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	resp, _ = s.do(t, http.MethodPost, "/whip/live", sdpContentType, "garbage")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, _ = s.do(t, http.MethodPost, "/whip/unknown", sdpContentType, publishOffer)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Nothing to publish.
+	resp, _ = s.do(t, http.MethodPost, "/whip/live", sdpContentType, playOffer)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Empty(t, s.whip.Sessions())
+
+	resp, _ = s.do(t, http.MethodPatch, "/whip/live/unknown", sdpFragContentType, "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = s.do(t, http.MethodGet, "/whip/live", "", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	assert.Equal(t, "POST, PATCH, DELETE", resp.Header.Get("Allow"))
+}
+
+func TestWhepPlay(t *testing.T) {
+	s := newTestServer(t)
+
+	// Nothing to play yet.
+	resp, _ := s.do(t, http.MethodPost, "/whep/live", sdpContentType, playOffer)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, body := s.do(t, http.MethodPost, "/whip/live", sdpContentType, publishOffer)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+
+	resp, body = s.do(t, http.MethodPost, "/whep/live", sdpContentType, playOffer)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+
+	sessions := s.whep.Sessions()
+	require.Len(t, sessions, 1)
+	session := sessions[0]
+	require.Len(t, session.Consumers(), 2)
+
+	answer, err := sdp.Parse(body)
+	require.NoError(t, err)
+	require.Len(t, answer.Media, 2)
+
+	for i, m := range answer.Media {
+		consumer := session.Consumers()[i]
+		assert.Equal(t, string(consumer.Kind()), m.Kind)
+		assert.Equal(t, "sendonly", m.Direction())
+		assert.NotEmpty(t, m.Values("ssrc"))
+	}
+
+	// The publisher leaves, so do the consumers.
+	s.whip.Sessions()[0].Close()
+
+	assert.Eventually(t, func() bool {
+		return session.Consumers()[0].Closed() && session.Consumers()[1].Closed()
+	}, time.Second, 5*time.Millisecond)
+
+	// The router is closed, so are the sessions.
+	s.router.Close()
+
+	assert.Eventually(t, func() bool { return len(s.whep.Sessions()) == 0 }, time.Second, 5*time.Millisecond)
+	assert.True(t, session.Closed())
+}
+
+func TestWhepPlay_SectionOrder(t *testing.T) {
+	s := newTestServer(t)
+
+	resp, body := s.do(t, http.MethodPost, "/whip/live", sdpContentType, publishOffer)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+
+	producers := s.whip.Sessions()[0].Producers()
+	require.Len(t, producers, 2)
+	require.Equal(t, mediasoup.MediaKind_Audio, producers[0].Kind())
+
+	resp, body = s.do(t, http.MethodPost, "/whep/live", sdpContentType, reversedPlayOffer)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+
+	session := s.whep.Sessions()[0]
+	require.Len(t, session.Consumers(), 2)
+
+	answer, err := sdp.Parse(body)
+	require.NoError(t, err)
+	require.Len(t, answer.Media, 2)
+
+	// Each consumer sends the mid of the section of its kind.
+	for _, consumer := range session.Consumers() {
+		var m *sdp.MediaDescription
+		for _, media := range answer.Media {
+			if media.Kind == string(consumer.Kind()) {
+				m = media
+			}
+		}
+		require.NotNil(t, m)
+		assert.Equal(t, m.Mid(), consumer.RtpParameters().Mid)
+		assert.Equal(t, "sendonly", m.Direction())
+		assert.Equal(t, []string{"4 urn:ietf:params:rtp-hdrext:sdes:mid"}, m.Values("extmap"))
+
+		msid, _ := m.Attribute("msid")
+		assert.Contains(t, msid, consumer.Id())
+	}
+}
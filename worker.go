@@ -2,6 +2,7 @@ package mediasoup
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -161,6 +162,36 @@ func init() {
 
 type Option func(w *WorkerSettings)
 
+/**
+ * WorkerProcess is a running mediasoup-worker process.
+ */
+type WorkerProcess interface {
+	// Pid returns the process identifier.
+	Pid() int
+	// Signal sends a signal to the process.
+	Signal(sig os.Signal) error
+	// Wait waits for the process to exit. It returns a *WorkerExitError if the
+	// process exits with a code other than 0 or because of a signal.
+	Wait() error
+}
+
+/**
+ * WorkerSpawner starts a mediasoup-worker process. files are the Channel and
+ * PayloadChannel sockets of the worker side, which the process must see as
+ * fds 3, 4, 5 and 6. The spawner takes the ownership of files.
+ */
+type WorkerSpawner func(bin string, args []string, files []*os.File) (WorkerProcess, error)
+
+// WorkerExitError describes how a mediasoup-worker process exited.
+type WorkerExitError struct {
+	Code   int
+	Signal os.Signal
+}
+
+func (e *WorkerExitError) Error() string {
+	return fmt.Sprintf("[code:%d, signal:%s]", e.Code, e.Signal)
+}
+
 /**
  * Worker
  * @emits died - (error: Error)
@@ -172,7 +203,7 @@ type Worker struct {
 	// Worker logger.
 	logger Logger
 	// mediasoup-worker child process.
-	child *exec.Cmd
+	child WorkerProcess
 	// Worker process PID.
 	pid int
 	// Channel instance.
@@ -185,6 +216,8 @@ type Worker struct {
 	appData interface{}
 	// Routers map.
 	routers sync.Map
+	// WebRtcServers map.
+	webRtcServers sync.Map
 	// Observer instance.
 	observer IEventEmitter
 
@@ -199,6 +232,7 @@ func NewWorker(options ...Option) (worker *Worker, err error) {
 		RtcMinPort: 10000,
 		RtcMaxPort: 59999,
 		AppData:    H{},
+		Spawner:    spawnWorkerProcess,
 	}
 
 	for _, option := range options {
@@ -243,48 +277,20 @@ func NewWorker(options ...Option) (worker *Worker, err error) {
 
 	logger.Debug("spawning worker process: %s %s", WorkerBin, strings.Join(settings.Args(), " "))
 
-	child := exec.Command(WorkerBin, settings.Args()...)
-	child.ExtraFiles = []*os.File{producerPair[1], consumerPair[1], payloadProducerPair[1], payloadConsumerPair[1]}
-	child.Env = []string{"MEDIASOUP_VERSION=" + VERSION}
-
-	stderr, err := child.StderrPipe()
-	if err != nil {
-		return
-	}
-	stdout, err := child.StdoutPipe()
+	child, err := settings.Spawner(WorkerBin, settings.Args(), []*os.File{
+		producerPair[1], consumerPair[1], payloadProducerPair[1], payloadConsumerPair[1],
+	})
 	if err != nil {
-		return
-	}
-	if err = child.Start(); err != nil {
+		producerSocket.Close()
+		consumerSocket.Close()
+		payloadProducerSocket.Close()
+		payloadConsumerSocket.Close()
 		return
 	}
 
-	pid := child.Process.Pid
+	pid := child.Pid()
 	channel := newChannel(producerSocket, consumerSocket, pid)
 	payloadChannel := newPayloadChannel(payloadProducerSocket, payloadConsumerSocket)
-	workerLogger := NewLogger(fmt.Sprintf("worker[pid:%d]", pid))
-
-	go func() {
-		r := bufio.NewReader(stderr)
-		for {
-			line, _, err := r.ReadLine()
-			if err != nil {
-				break
-			}
-			workerLogger.Error("(stderr) %s", line)
-		}
-	}()
-
-	go func() {
-		r := bufio.NewReader(stdout)
-		for {
-			line, _, err := r.ReadLine()
-			if err != nil {
-				break
-			}
-			workerLogger.Debug("(stdout) %s", line)
-		}
-	}()
 
 	worker = &Worker{
 		IEventEmitter:  NewEventEmitter(),
@@ -308,35 +314,34 @@ func NewWorker(options ...Option) (worker *Worker, err error) {
 		}
 	})
 
-	go worker.wait()
-
 	worker.Once("@failure", func(err error) { doneCh <- err })
 
+	// Start reading after the "running" listener is set, otherwise a fast
+	// worker could be missed.
+	go channel.runReadLoop()
+
+	go worker.wait(child)
+
 	err = <-doneCh
 
 	return
 }
 
-func (w *Worker) wait() {
-	err := w.child.Wait()
+func (w *Worker) wait(child WorkerProcess) {
+	err := child.Wait()
+
+	// The process exited because of Close().
+	if w.Closed() && w.spawnDone {
+		return
+	}
 
-	w.child = nil
 	w.Close()
 
 	var code int
 	var signal = os.Kill
 
-	if exiterr, ok := err.(*exec.ExitError); ok {
-		// The worker has exited with an exit code != 0
-		if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
-			code = status.ExitStatus()
-
-			if status.Signaled() {
-				signal = status.Signal()
-			} else {
-				signal = status.StopSignal()
-			}
-		}
+	if exiterr, ok := err.(*WorkerExitError); ok {
+		code, signal = exiterr.Code, exiterr.Signal
 	}
 
 	if !w.spawnDone {
@@ -382,6 +387,37 @@ func (w *Worker) Observer() IEventEmitter {
 	return w.observer
 }
 
+/**
+ * OnDied adds a listener of "died" and returns the function removing it.
+ */
+func (w *Worker) OnDied(listener func(error)) func() {
+	return subscribe(w.IEventEmitter, "died", listener)
+}
+
+/**
+ * Routers returns the alive routers of the worker.
+ */
+func (w *Worker) Routers() (routers []*Router) {
+	w.routers.Range(func(key, value interface{}) bool {
+		routers = append(routers, value.(*Router))
+		return true
+	})
+
+	return
+}
+
+/**
+ * WebRtcServers returns the alive WebRtcServers of the worker.
+ */
+func (w *Worker) WebRtcServers() (webRtcServers []*WebRtcServer) {
+	w.webRtcServers.Range(func(key, value interface{}) bool {
+		webRtcServers = append(webRtcServers, value.(*WebRtcServer))
+		return true
+	})
+
+	return
+}
+
 /**
  * Close the Worker.
  */
@@ -393,10 +429,7 @@ func (w *Worker) Close() {
 	w.logger.Debug("close()")
 
 	// Kill the worker process.
-	if w.child != nil {
-		w.child.Process.Signal(syscall.SIGTERM)
-		w.child = nil
-	}
+	w.child.Signal(syscall.SIGTERM)
 
 	// Close the Channel instance.
 	w.channel.Close()
@@ -410,17 +443,29 @@ func (w *Worker) Close() {
 		router.workerClosed()
 		return true
 	})
-	w.routers = sync.Map{}
+	syncMapClear(&w.routers)
+
+	// Close every WebRtcServer.
+	w.webRtcServers.Range(func(key, value interface{}) bool {
+		value.(*WebRtcServer).workerClosed()
+		return true
+	})
+	syncMapClear(&w.webRtcServers)
 
 	// Emit observer event.
 	w.observer.SafeEmit("close")
 }
 
 // Dump Worker.
-func (w *Worker) Dump() (dump WorkerDump, err error) {
+func (w *Worker) Dump() (WorkerDump, error) {
+	return w.DumpContext(context.Background())
+}
+
+// DumpContext is like Dump but with a context.
+func (w *Worker) DumpContext(ctx context.Context) (dump WorkerDump, err error) {
 	w.logger.Debug("dump()")
 
-	err = w.channel.Request("worker.dump", nil).Unmarshal(&dump)
+	err = w.channel.RequestContext(ctx, "worker.dump", nil).Unmarshal(&dump)
 
 	return
 }
@@ -428,10 +473,15 @@ func (w *Worker) Dump() (dump WorkerDump, err error) {
 /**
  * Get mediasoup-worker process resource usage.
  */
-func (w *Worker) GetResourceUsage() (usage WorkerResourceUsage, err error) {
+func (w *Worker) GetResourceUsage() (WorkerResourceUsage, error) {
+	return w.GetResourceUsageContext(context.Background())
+}
+
+// GetResourceUsageContext is like GetResourceUsage but with a context.
+func (w *Worker) GetResourceUsageContext(ctx context.Context) (usage WorkerResourceUsage, err error) {
 	w.logger.Debug("getResourceUsage()")
 
-	resp := w.channel.Request("worker.getResourceUsage", nil)
+	resp := w.channel.RequestContext(ctx, "worker.getResourceUsage", nil)
 	err = resp.Unmarshal(&usage)
 
 	return
@@ -439,18 +489,28 @@ func (w *Worker) GetResourceUsage() (usage WorkerResourceUsage, err error) {
 
 // UpdateSettings Update settings.
 func (w *Worker) UpdateSettings(settings WorkerUpdateableSettings) error {
+	return w.UpdateSettingsContext(context.Background(), settings)
+}
+
+// UpdateSettingsContext is like UpdateSettings but with a context.
+func (w *Worker) UpdateSettingsContext(ctx context.Context, settings WorkerUpdateableSettings) error {
 	w.logger.Debug("updateSettings()")
 
-	return w.channel.Request("worker.updateSettings", nil, settings).Err()
+	return w.channel.RequestContext(ctx, "worker.updateSettings", nil, settings).Err()
 }
 
 // CreateRouter creates a router.
-func (w *Worker) CreateRouter(options RouterOptions) (router *Router, err error) {
+func (w *Worker) CreateRouter(options RouterOptions) (*Router, error) {
+	return w.CreateRouterContext(context.Background(), options)
+}
+
+// CreateRouterContext is like CreateRouter but with a context.
+func (w *Worker) CreateRouterContext(ctx context.Context, options RouterOptions) (router *Router, err error) {
 	w.logger.Debug("createRouter()")
 
 	internal := internalData{RouterId: uuid.NewV4().String()}
 
-	rsp := w.channel.Request("worker.createRouter", internal, nil)
+	rsp := w.channel.RequestContext(ctx, "worker.createRouter", internal, nil)
 	if err = rsp.Err(); err != nil {
 		return
 	}
@@ -478,6 +538,161 @@ func (w *Worker) CreateRouter(options RouterOptions) (router *Router, err error)
 	return
 }
 
+/**
+ * CreateWebRtcServer creates a WebRtcServer, whose listening ports are shared
+ * by the WebRtcTransports created with it. It requires mediasoup-worker 3.10.0
+ * or later, and fails with an UnsupportedError on older workers.
+ */
+func (w *Worker) CreateWebRtcServer(options WebRtcServerOptions) (*WebRtcServer, error) {
+	return w.CreateWebRtcServerContext(context.Background(), options)
+}
+
+// CreateWebRtcServerContext is like CreateWebRtcServer but with a context.
+func (w *Worker) CreateWebRtcServerContext(ctx context.Context, options WebRtcServerOptions) (webRtcServer *WebRtcServer, err error) {
+	w.logger.Debug("createWebRtcServer()")
+
+	if len(options.ListenInfos) == 0 {
+		err = NewTypeError("empty listenInfos array provided")
+		return
+	}
+
+	internal := internalData{WebRtcServerId: uuid.NewV4().String()}
+	reqData := H{"listenInfos": options.ListenInfos}
+
+	rsp := w.channel.RequestContext(ctx, "worker.createWebRtcServer", internal, reqData)
+	if err = requireWorkerVersion(rsp.Err(), "WebRtcServer", "3.10.0"); err != nil {
+		return
+	}
+
+	appData := options.AppData
+	if appData == nil {
+		appData = H{}
+	}
+
+	webRtcServer = newWebRtcServer(webRtcServerParams{
+		internal: internal,
+		channel:  w.channel,
+		appData:  appData,
+	})
+
+	w.webRtcServers.Store(internal.WebRtcServerId, webRtcServer)
+	webRtcServer.On("@close", func() {
+		w.webRtcServers.Delete(internal.WebRtcServerId)
+	})
+	// Emit observer event.
+	w.observer.SafeEmit("newwebrtcserver", webRtcServer)
+
+	return
+}
+
+// requireWorkerVersion turns the rejection of a request unknown to the worker
+// into an UnsupportedError, telling the worker version the feature requires.
+func requireWorkerVersion(err error, feature, version string) error {
+	if err != nil && strings.Contains(err.Error(), "unknown method") {
+		return NewUnsupportedError("%s requires mediasoup-worker %s or later: %s", feature, version, err)
+	}
+	return err
+}
+
+// spawnWorkerProcess is the default WorkerSpawner which runs bin as a child
+// process.
+func spawnWorkerProcess(bin string, args []string, files []*os.File) (WorkerProcess, error) {
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	child := exec.Command(bin, args...)
+	child.ExtraFiles = files
+	child.Env = []string{"MEDIASOUP_VERSION=" + VERSION}
+
+	stderr, err := child.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := child.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = child.Start(); err != nil {
+		return nil, err
+	}
+
+	workerLogger := NewLogger(fmt.Sprintf("worker[pid:%d]", child.Process.Pid))
+
+	go func() {
+		r := bufio.NewReader(stderr)
+		for {
+			line, _, err := r.ReadLine()
+			if err != nil {
+				break
+			}
+			workerLogger.Error("(stderr) %s", line)
+		}
+	}()
+
+	go func() {
+		r := bufio.NewReader(stdout)
+		for {
+			line, _, err := r.ReadLine()
+			if err != nil {
+				break
+			}
+			workerLogger.Debug("(stdout) %s", line)
+		}
+	}()
+
+	return execWorkerProcess{child}, nil
+}
+
+type execWorkerProcess struct {
+	cmd *exec.Cmd
+}
+
+func (p execWorkerProcess) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+func (p execWorkerProcess) Signal(sig os.Signal) error {
+	return p.cmd.Process.Signal(sig)
+}
+
+func (p execWorkerProcess) Wait() error {
+	err := p.cmd.Wait()
+
+	if exiterr, ok := err.(*exec.ExitError); ok {
+		// The worker has exited with an exit code != 0
+		if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
+			e := &WorkerExitError{Code: status.ExitStatus(), Signal: os.Kill}
+
+			if status.Signaled() {
+				e.Signal = status.Signal()
+			} else {
+				e.Signal = status.StopSignal()
+			}
+
+			return e
+		}
+	}
+
+	return err
+}
+
+// load returns the number of routers and transports living in the worker.
+func (w *Worker) load() (n int) {
+	w.routers.Range(func(key, value interface{}) bool {
+		n++
+		value.(*Router).transports.Range(func(key, value interface{}) bool {
+			n++
+			return true
+		})
+		return true
+	})
+
+	return
+}
+
 func createSocketPair() (file [2]*os.File, err error) {
 	fd, err := syscall.Socketpair(syscall.AF_LOCAL, syscall.SOCK_STREAM, 0)
 	if err != nil {
@@ -0,0 +1,286 @@
+package mediasoup
+
+import (
+	"context"
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+type WorkerLoadType string
+
+const (
+	// Load is the number of routers and transports living in the worker.
+	WorkerLoad_Count WorkerLoadType = "count"
+	// Load is the CPU time consumed by the worker since the previous placement,
+	// as reported by GetResourceUsage().
+	WorkerLoad_Cpu = "cpu"
+)
+
+type WorkerPoolOptions struct {
+	/**
+	 * Number of workers. Default runtime.NumCPU().
+	 */
+	NumWorkers int
+
+	/**
+	 * How the load of a worker is measured when placing a router. Default
+	 * "count".
+	 */
+	LoadType WorkerLoadType
+
+	/**
+	 * Settings shared by all the workers.
+	 */
+	WorkerOptions []Option
+}
+
+/**
+ * WorkerPool manages several workers and places routers on the least loaded
+ * one.
+ */
+type WorkerPool struct {
+	logger   Logger
+	options  WorkerPoolOptions
+	workers  []*Worker
+	cpuTimes map[*Worker]int64
+	closed   uint32
+	observer IEventEmitter
+	locker   sync.Mutex
+}
+
+func NewWorkerPool(options WorkerPoolOptions) (pool *WorkerPool, err error) {
+	logger := NewLogger("WorkerPool")
+
+	logger.Debug("constructor()")
+
+	if options.NumWorkers <= 0 {
+		options.NumWorkers = runtime.NumCPU()
+	}
+	if len(options.LoadType) == 0 {
+		options.LoadType = WorkerLoad_Count
+	}
+	if options.LoadType != WorkerLoad_Count && options.LoadType != WorkerLoad_Cpu {
+		err = NewTypeError("invalid loadType: %s", options.LoadType)
+		return
+	}
+
+	pool = &WorkerPool{
+		logger:   logger,
+		options:  options,
+		cpuTimes: make(map[*Worker]int64),
+		observer: NewEventEmitter(),
+	}
+
+	for i := 0; i < options.NumWorkers; i++ {
+		if _, err = pool.AddWorker(); err != nil {
+			pool.Close()
+			return nil, err
+		}
+	}
+
+	return
+}
+
+/**
+ * Whether the WorkerPool is closed.
+ */
+func (pool *WorkerPool) Closed() bool {
+	return atomic.LoadUint32(&pool.closed) > 0
+}
+
+// Observer
+func (pool *WorkerPool) Observer() IEventEmitter {
+	return pool.observer
+}
+
+/**
+ * Workers returns the alive workers of the pool.
+ */
+func (pool *WorkerPool) Workers() []*Worker {
+	pool.locker.Lock()
+	defer pool.locker.Unlock()
+
+	return append([]*Worker{}, pool.workers...)
+}
+
+/**
+ * AddWorker spawns a new worker with the shared settings and adds it to the
+ * pool.
+ */
+func (pool *WorkerPool) AddWorker() (worker *Worker, err error) {
+	pool.logger.Debug("addWorker()")
+
+	if pool.Closed() {
+		err = NewInvalidStateError("WorkerPool closed")
+		return
+	}
+
+	if worker, err = NewWorker(pool.options.WorkerOptions...); err != nil {
+		return
+	}
+
+	pool.locker.Lock()
+	pool.workers = append(pool.workers, worker)
+	pool.locker.Unlock()
+
+	worker.On("died", func(err error) {
+		pool.logger.Error("worker died [pid:%d]: %s", worker.Pid(), err)
+
+		pool.removeWorker(worker)
+
+		// Emit observer event.
+		pool.observer.SafeEmit("workerdied", worker, err)
+	})
+	worker.Observer().On("close", func() {
+		pool.removeWorker(worker)
+	})
+
+	// Emit observer event.
+	pool.observer.SafeEmit("newworker", worker)
+
+	return
+}
+
+/**
+ * Close the WorkerPool and all its workers.
+ */
+func (pool *WorkerPool) Close() {
+	if !atomic.CompareAndSwapUint32(&pool.closed, 0, 1) {
+		return
+	}
+
+	pool.logger.Debug("close()")
+
+	for _, worker := range pool.Workers() {
+		worker.Close()
+	}
+
+	// Emit observer event.
+	pool.observer.SafeEmit("close")
+}
+
+/**
+ * CreateRouter creates a router on the least loaded worker.
+ */
+func (pool *WorkerPool) CreateRouter(options RouterOptions) (*Router, error) {
+	return pool.CreateRouterContext(context.Background(), options)
+}
+
+// CreateRouterContext is like CreateRouter but with a context.
+func (pool *WorkerPool) CreateRouterContext(ctx context.Context, options RouterOptions) (router *Router, err error) {
+	pool.logger.Debug("createRouter()")
+
+	worker, err := pool.LeastLoadedWorkerContext(ctx)
+	if err != nil {
+		return
+	}
+
+	return worker.CreateRouterContext(ctx, options)
+}
+
+/**
+ * LeastLoadedWorker returns the worker which a new router would be placed on.
+ */
+func (pool *WorkerPool) LeastLoadedWorker() (*Worker, error) {
+	return pool.LeastLoadedWorkerContext(context.Background())
+}
+
+// LeastLoadedWorkerContext is like LeastLoadedWorker but with a context.
+func (pool *WorkerPool) LeastLoadedWorkerContext(ctx context.Context) (worker *Worker, err error) {
+	if pool.Closed() {
+		err = NewInvalidStateError("WorkerPool closed")
+		return
+	}
+
+	workers := pool.Workers()
+
+	if len(workers) == 0 {
+		err = NewInvalidStateError("no worker available")
+		return
+	}
+
+	loads := make([]int64, len(workers))
+
+	if pool.options.LoadType == WorkerLoad_Cpu {
+		if loads, err = pool.cpuLoads(ctx, workers); err != nil {
+			return
+		}
+	} else {
+		for i, w := range workers {
+			loads[i] = int64(w.load())
+		}
+	}
+
+	worker = workers[0]
+	minLoad := loads[0]
+
+	for i := 1; i < len(workers); i++ {
+		if loads[i] < minLoad ||
+			(loads[i] == minLoad && workers[i].load() < worker.load()) {
+			worker, minLoad = workers[i], loads[i]
+		}
+	}
+
+	return
+}
+
+// cpuLoads returns the CPU time consumed by each worker since the previous
+// call.
+func (pool *WorkerPool) cpuLoads(ctx context.Context, workers []*Worker) (loads []int64, err error) {
+	loads = make([]int64, len(workers))
+	cpuTimes := make([]int64, len(workers))
+	errs := make([]error, len(workers))
+
+	var wg sync.WaitGroup
+
+	for i, worker := range workers {
+		wg.Add(1)
+
+		go func(i int, worker *Worker) {
+			defer wg.Done()
+
+			usage, err := worker.GetResourceUsageContext(ctx)
+			cpuTimes[i], errs[i] = usage.RU_Utime+usage.RU_Stime, err
+		}(i, worker)
+	}
+
+	wg.Wait()
+
+	pool.locker.Lock()
+	defer pool.locker.Unlock()
+
+	failed := 0
+
+	for i, worker := range workers {
+		// A worker failing to answer is not a placement candidate.
+		if errs[i] != nil {
+			pool.logger.Warn("getResourceUsage() failed [pid:%d]: %s", worker.Pid(), errs[i])
+			loads[i] = math.MaxInt64
+			failed++
+			continue
+		}
+		loads[i] = cpuTimes[i] - pool.cpuTimes[worker]
+		pool.cpuTimes[worker] = cpuTimes[i]
+	}
+
+	if failed == len(workers) {
+		return nil, errs[0]
+	}
+
+	return
+}
+
+func (pool *WorkerPool) removeWorker(worker *Worker) {
+	pool.locker.Lock()
+	defer pool.locker.Unlock()
+
+	for i, w := range pool.workers {
+		if w == worker {
+			pool.workers = append(pool.workers[:i], pool.workers[i+1:]...)
+			break
+		}
+	}
+	delete(pool.cpuTimes, worker)
+}
@@ -0,0 +1,167 @@
+package mediasoup
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var poolRouterOptions = RouterOptions{
+	MediaCodecs: []*RtpCodecCapability{
+		{
+			Kind:      "audio",
+			MimeType:  "audio/opus",
+			ClockRate: 48000,
+			Channels:  2,
+		},
+	},
+}
+
+func CreateTestWorkerPool(options WorkerPoolOptions) *WorkerPool {
+	options.WorkerOptions = append([]Option{WithLogLevel("warn")}, options.WorkerOptions...)
+
+	pool, err := NewWorkerPool(options)
+	if err != nil {
+		panic(err)
+	}
+
+	return pool
+}
+
+func TestCreateWorkerPool_Succeeds(t *testing.T) {
+	pool := CreateTestWorkerPool(WorkerPoolOptions{NumWorkers: 2})
+
+	assert.Len(t, pool.Workers(), 2)
+	assert.False(t, pool.Closed())
+
+	onObserverClose := NewMockFunc(t)
+	pool.Observer().Once("close", onObserverClose.Fn())
+
+	pool.Close()
+
+	onObserverClose.ExpectCalledTimes(1)
+	assert.True(t, pool.Closed())
+	assert.Empty(t, pool.Workers())
+}
+
+func TestCreateWorkerPool_TypeError(t *testing.T) {
+	_, err := NewWorkerPool(WorkerPoolOptions{LoadType: "chicken"})
+	assert.IsType(t, NewTypeError(""), err)
+
+	_, err = NewWorkerPool(WorkerPoolOptions{WorkerOptions: []Option{WithLogLevel("chicken")}})
+	assert.IsType(t, NewTypeError(""), err)
+}
+
+func TestWorkerPoolCreateRouter_Balanced(t *testing.T) {
+	pool := CreateTestWorkerPool(WorkerPoolOptions{NumWorkers: 3})
+	defer pool.Close()
+
+	for i := 0; i < 6; i++ {
+		_, err := pool.CreateRouter(poolRouterOptions)
+		require.NoError(t, err)
+	}
+
+	for _, worker := range pool.Workers() {
+		dump, err := worker.Dump()
+		require.NoError(t, err)
+		assert.Len(t, dump.RouterIds, 2)
+	}
+}
+
+func TestWorkerPoolCreateRouter_CountsTransports(t *testing.T) {
+	pool := CreateTestWorkerPool(WorkerPoolOptions{NumWorkers: 2})
+	defer pool.Close()
+
+	router1, err := pool.CreateRouter(poolRouterOptions)
+	require.NoError(t, err)
+	router2, err := pool.CreateRouter(poolRouterOptions)
+	require.NoError(t, err)
+
+	_, err = router1.CreateDirectTransport()
+	require.NoError(t, err)
+
+	router3, err := pool.CreateRouter(poolRouterOptions)
+	require.NoError(t, err)
+
+	routerIds := func(worker *Worker) []string {
+		dump, err := worker.Dump()
+		require.NoError(t, err)
+		return dump.RouterIds
+	}
+
+	for _, worker := range pool.Workers() {
+		ids := routerIds(worker)
+
+		if contains(ids, router1.Id()) {
+			assert.NotContains(t, ids, router3.Id())
+		}
+		if contains(ids, router2.Id()) {
+			assert.Contains(t, ids, router3.Id())
+		}
+	}
+}
+
+func TestWorkerPoolCreateRouter_Cpu(t *testing.T) {
+	pool := CreateTestWorkerPool(WorkerPoolOptions{NumWorkers: 2, LoadType: WorkerLoad_Cpu})
+	defer pool.Close()
+
+	router, err := pool.CreateRouter(poolRouterOptions)
+	require.NoError(t, err)
+	assert.False(t, router.Closed())
+}
+
+func TestWorkerPoolEmitsWorkerDied(t *testing.T) {
+	pool := CreateTestWorkerPool(WorkerPoolOptions{NumWorkers: 2})
+	defer pool.Close()
+
+	diedCh := make(chan *Worker, 1)
+	pool.Observer().On("workerdied", func(worker *Worker, err error) { diedCh <- worker })
+
+	worker := pool.Workers()[0]
+
+	process, err := os.FindProcess(worker.Pid())
+	require.NoError(t, err)
+	process.Signal(os.Kill)
+
+	select {
+	case died := <-diedCh:
+		assert.Equal(t, worker, died)
+	case <-time.NewTimer(time.Second).C:
+		t.Fatal("timeout")
+	}
+
+	assert.Len(t, pool.Workers(), 1)
+	assert.NotEqual(t, worker, pool.Workers()[0])
+
+	onObserverNewWorker := NewMockFunc(t)
+	pool.Observer().Once("newworker", onObserverNewWorker.Fn())
+
+	_, err = pool.AddWorker()
+	require.NoError(t, err)
+
+	onObserverNewWorker.ExpectCalledTimes(1)
+	assert.Len(t, pool.Workers(), 2)
+}
+
+func TestWorkerCloseDoesNotEmitDied(t *testing.T) {
+	worker := CreateTestWorker(WithLogLevel("warn"))
+
+	onDied := NewMockFunc(t)
+	worker.On("died", onDied.Fn())
+
+	worker.Close()
+
+	onDied.ExpectCalledTimes(0)
+}
+
+func contains(ids []string, id string) bool {
+	for _, item := range ids {
+		if item == id {
+			return true
+		}
+	}
+	return false
+}
@@ -44,6 +44,12 @@ type WorkerSettings struct {
 	 * Custom application data.
 	 */
 	AppData interface{} `json:"appData,omitempty"`
+
+	/**
+	 * Spawner starts the mediasoup-worker process. Default runs WorkerBin as a
+	 * child process.
+	 */
+	Spawner WorkerSpawner `json:"-"`
 }
 
 func (w WorkerSettings) Args() []string {
@@ -111,3 +117,9 @@ func WithDtlsCert(dtlsCertificateFile, dtlsPrivateKeyFile string) Option {
 		o.DtlsPrivateKeyFile = dtlsPrivateKeyFile
 	}
 }
+
+func WithSpawner(spawner WorkerSpawner) Option {
+	return func(o *WorkerSettings) {
+		o.Spawner = spawner
+	}
+}
@@ -0,0 +1,354 @@
+package mediasoup
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type WorkerSupervisorOptions struct {
+	/**
+	 * Delay before the first respawn attempt. It is doubled on every crash
+	 * within CrashLoopWindow. Default 1s.
+	 */
+	InitialBackoff time.Duration
+
+	/**
+	 * Maximum delay before a respawn attempt. Default 30s.
+	 */
+	MaxBackoff time.Duration
+
+	/**
+	 * Maximum number of crashes within CrashLoopWindow. Once exceeded the
+	 * supervisor gives up and emits "failed". Default 5.
+	 */
+	MaxCrashes int
+
+	/**
+	 * Window in which crashes are counted. Default 1 minute.
+	 */
+	CrashLoopWindow time.Duration
+
+	/**
+	 * Rehydrate is called with the respawned worker and the dead one. If set,
+	 * routers created with CreateRouter() are not replayed and, once it
+	 * succeeds, they are forgotten. An error is handled as a crash of the new
+	 * worker.
+	 */
+	Rehydrate func(worker, deadWorker *Worker) error
+}
+
+type supervisedRouter struct {
+	originalId string
+	options    RouterOptions
+	router     *Router
+}
+
+/**
+ * WorkerSupervisor keeps a worker alive. When the worker dies it is respawned
+ * with the same settings and the routers created with CreateRouter() are
+ * recreated on it, unless a Rehydrate callback is given.
+ *
+ * @emits died - (worker: *Worker, error: Error)
+ * @emits respawned - (worker: *Worker, deadWorker: *Worker)
+ * @emits routerrecreated - (router: *Router, originalRouterId: string)
+ * @emits failed - (error: Error)
+ */
+type WorkerSupervisor struct {
+	IEventEmitter
+	logger        Logger
+	options       WorkerSupervisorOptions
+	workerOptions []Option
+	worker        *Worker
+	routers       []*supervisedRouter
+	crashes       []time.Time
+	closed        uint32
+	locker        sync.Mutex
+}
+
+func NewWorkerSupervisor(options WorkerSupervisorOptions, workerOptions ...Option) (supervisor *WorkerSupervisor, err error) {
+	logger := NewLogger("WorkerSupervisor")
+
+	logger.Debug("constructor()")
+
+	if options.InitialBackoff <= 0 {
+		options.InitialBackoff = time.Second
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = 30 * time.Second
+	}
+	if options.MaxCrashes <= 0 {
+		options.MaxCrashes = 5
+	}
+	if options.CrashLoopWindow <= 0 {
+		options.CrashLoopWindow = time.Minute
+	}
+
+	worker, err := NewWorker(workerOptions...)
+	if err != nil {
+		return
+	}
+
+	supervisor = &WorkerSupervisor{
+		IEventEmitter: NewEventEmitter(),
+		logger:        logger,
+		options:       options,
+		workerOptions: workerOptions,
+	}
+	supervisor.watch(worker)
+
+	return
+}
+
+/**
+ * The current worker. It is closed while a respawn is pending.
+ */
+func (s *WorkerSupervisor) Worker() *Worker {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	return s.worker
+}
+
+/**
+ * Whether the WorkerSupervisor is closed.
+ */
+func (s *WorkerSupervisor) Closed() bool {
+	return atomic.LoadUint32(&s.closed) > 0
+}
+
+/**
+ * Close the WorkerSupervisor and its worker.
+ */
+func (s *WorkerSupervisor) Close() {
+	if !atomic.CompareAndSwapUint32(&s.closed, 0, 1) {
+		return
+	}
+
+	s.logger.Debug("close()")
+
+	s.Worker().Close()
+}
+
+/**
+ * CreateRouter creates a router on the current worker and records its options,
+ * so that it is recreated if the worker is respawned.
+ */
+func (s *WorkerSupervisor) CreateRouter(options RouterOptions) (*Router, error) {
+	return s.CreateRouterContext(context.Background(), options)
+}
+
+// CreateRouterContext is like CreateRouter but with a context.
+func (s *WorkerSupervisor) CreateRouterContext(ctx context.Context, options RouterOptions) (router *Router, err error) {
+	s.logger.Debug("createRouter()")
+
+	if s.Closed() {
+		err = NewInvalidStateError("WorkerSupervisor closed")
+		return
+	}
+
+	if router, err = s.Worker().CreateRouterContext(ctx, options); err != nil {
+		return
+	}
+
+	s.track(&supervisedRouter{
+		originalId: router.Id(),
+		options:    options,
+		router:     router,
+	})
+
+	return
+}
+
+/**
+ * Router returns the current router created with the given original router id,
+ * which is the id of the router returned by CreateRouter().
+ */
+func (s *WorkerSupervisor) Router(originalRouterId string) *Router {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	for _, item := range s.routers {
+		if item.originalId == originalRouterId {
+			return item.router
+		}
+	}
+
+	return nil
+}
+
+/**
+ * OriginalRouterId returns the id of the router returned by CreateRouter()
+ * which the given router recreates. It returns routerId for a router which
+ * was never recreated, and an empty string for an unknown router.
+ */
+func (s *WorkerSupervisor) OriginalRouterId(routerId string) string {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	for _, item := range s.routers {
+		if item.router.Id() == routerId {
+			return item.originalId
+		}
+	}
+
+	return ""
+}
+
+func (s *WorkerSupervisor) track(item *supervisedRouter) {
+	s.locker.Lock()
+	s.routers = append(s.routers, item)
+	s.locker.Unlock()
+
+	router := item.router
+
+	// The router is closed by the application, forget it.
+	router.On("@close", func() {
+		s.locker.Lock()
+		defer s.locker.Unlock()
+
+		for i, r := range s.routers {
+			if r.router == router {
+				s.routers = append(s.routers[:i], s.routers[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+func (s *WorkerSupervisor) watch(worker *Worker) {
+	s.locker.Lock()
+	s.worker = worker
+	s.locker.Unlock()
+
+	worker.On("died", func(err error) {
+		s.logger.Error("worker died [pid:%d]: %s", worker.Pid(), err)
+
+		s.SafeEmit("died", worker, err)
+
+		s.handleCrash(worker)
+	})
+}
+
+func (s *WorkerSupervisor) handleCrash(deadWorker *Worker) {
+	if s.Closed() {
+		return
+	}
+
+	now := time.Now()
+
+	s.locker.Lock()
+
+	crashes := []time.Time{}
+	for _, crash := range s.crashes {
+		if now.Sub(crash) < s.options.CrashLoopWindow {
+			crashes = append(crashes, crash)
+		}
+	}
+	crashes = append(crashes, now)
+	s.crashes = crashes
+
+	s.locker.Unlock()
+
+	if len(crashes) > s.options.MaxCrashes {
+		s.logger.Error("worker is crash looping, giving up [crashes:%d]", len(crashes))
+
+		atomic.StoreUint32(&s.closed, 1)
+
+		s.SafeEmit("failed", errors.New("worker crash loop"))
+
+		return
+	}
+
+	backoff := s.options.InitialBackoff << uint(len(crashes)-1)
+
+	if backoff > s.options.MaxBackoff || backoff <= 0 {
+		backoff = s.options.MaxBackoff
+	}
+
+	s.logger.Debug("respawning worker in %s", backoff)
+
+	time.AfterFunc(backoff, func() { s.respawn(deadWorker) })
+}
+
+func (s *WorkerSupervisor) respawn(deadWorker *Worker) {
+	if s.Closed() {
+		return
+	}
+
+	s.logger.Debug("respawn()")
+
+	worker, err := NewWorker(s.workerOptions...)
+	if err != nil {
+		s.logger.Error("respawn failed: %s", err)
+		s.handleCrash(deadWorker)
+		return
+	}
+
+	if s.options.Rehydrate != nil {
+		if err = s.options.Rehydrate(worker, deadWorker); err == nil {
+			s.forgetRouters()
+		}
+	} else {
+		err = s.replayRouters(worker)
+	}
+	if err != nil {
+		s.logger.Error("rehydration failed: %s", err)
+		worker.Close()
+		s.handleCrash(deadWorker)
+		return
+	}
+
+	s.watch(worker)
+
+	// Closed while respawning.
+	if s.Closed() {
+		worker.Close()
+		return
+	}
+
+	s.SafeEmit("respawned", worker, deadWorker)
+}
+
+// forgetRouters drops the records of the routers closed with the dead worker,
+// which the Rehydrate callback replaces.
+func (s *WorkerSupervisor) forgetRouters() {
+	s.locker.Lock()
+	s.routers = nil
+	s.locker.Unlock()
+}
+
+func (s *WorkerSupervisor) replayRouters(worker *Worker) error {
+	s.locker.Lock()
+	routers := s.routers
+	s.routers = nil
+	s.locker.Unlock()
+
+	recreated := []*supervisedRouter{}
+
+	for _, item := range routers {
+		router, err := worker.CreateRouter(item.options)
+		if err != nil {
+			// Keep the records for the next attempt.
+			s.locker.Lock()
+			s.routers = append(routers, s.routers...)
+			s.locker.Unlock()
+			return err
+		}
+		recreated = append(recreated, &supervisedRouter{
+			originalId: item.originalId,
+			options:    item.options,
+			router:     router,
+		})
+	}
+
+	for _, item := range recreated {
+		s.track(item)
+
+		s.SafeEmit("routerrecreated", item.router, item.originalId)
+	}
+
+	return nil
+}
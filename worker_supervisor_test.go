@@ -0,0 +1,168 @@
+package mediasoup
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var supervisorRouterOptions = RouterOptions{
+	MediaCodecs: []*RtpCodecCapability{
+		{Kind: "audio", MimeType: "audio/opus", ClockRate: 48000, Channels: 2},
+	},
+}
+
+func CreateTestWorkerSupervisor(options WorkerSupervisorOptions) *WorkerSupervisor {
+	if options.InitialBackoff == 0 {
+		options.InitialBackoff = 10 * time.Millisecond
+	}
+
+	supervisor, err := NewWorkerSupervisor(options, WithLogLevel("warn"))
+	if err != nil {
+		panic(err)
+	}
+
+	return supervisor
+}
+
+func killWorker(t *testing.T, worker *Worker) {
+	process, err := os.FindProcess(worker.Pid())
+	require.NoError(t, err)
+	process.Signal(os.Kill)
+}
+
+func TestWorkerSupervisorRespawnsAndReplaysRouters(t *testing.T) {
+	supervisor := CreateTestWorkerSupervisor(WorkerSupervisorOptions{})
+	defer supervisor.Close()
+
+	router, err := supervisor.CreateRouter(supervisorRouterOptions)
+	require.NoError(t, err)
+
+	closedRouter, err := supervisor.CreateRouter(supervisorRouterOptions)
+	require.NoError(t, err)
+	closedRouter.Close()
+
+	respawnedCh := make(chan *Worker, 1)
+	supervisor.On("respawned", func(worker, deadWorker *Worker) { respawnedCh <- worker })
+
+	onRouterRecreated := NewMockFunc(t)
+	supervisor.On("routerrecreated", onRouterRecreated.Fn())
+
+	deadWorker := supervisor.Worker()
+	killWorker(t, deadWorker)
+
+	var worker *Worker
+
+	select {
+	case worker = <-respawnedCh:
+	case <-time.NewTimer(time.Second).C:
+		t.Fatal("timeout")
+	}
+
+	assert.NotEqual(t, deadWorker, worker)
+	assert.Equal(t, worker, supervisor.Worker())
+	assert.True(t, router.Closed())
+
+	newRouter := supervisor.Router(router.Id())
+	require.NotNil(t, newRouter)
+	assert.NotEqual(t, router.Id(), newRouter.Id())
+	assert.Equal(t, router.Id(), supervisor.OriginalRouterId(newRouter.Id()))
+	assert.Equal(t, router.RtpCapabilities().Codecs[0].MimeType, newRouter.RtpCapabilities().Codecs[0].MimeType)
+	assert.Nil(t, supervisor.Router(closedRouter.Id()))
+
+	onRouterRecreated.ExpectCalledTimes(1)
+	onRouterRecreated.ExpectCalledWith(newRouter, router.Id())
+
+	dump, err := worker.Dump()
+	require.NoError(t, err)
+	assert.Equal(t, []string{newRouter.Id()}, dump.RouterIds)
+}
+
+func TestWorkerSupervisorRehydrate(t *testing.T) {
+	calls := 0
+
+	supervisor := CreateTestWorkerSupervisor(WorkerSupervisorOptions{
+		Rehydrate: func(worker, deadWorker *Worker) error {
+			calls++
+			if calls == 1 {
+				return errors.New("not yet")
+			}
+			return nil
+		},
+	})
+	defer supervisor.Close()
+
+	router, err := supervisor.CreateRouter(supervisorRouterOptions)
+	require.NoError(t, err)
+
+	respawnedCh := make(chan *Worker, 1)
+	supervisor.On("respawned", func(worker, deadWorker *Worker) { respawnedCh <- worker })
+
+	killWorker(t, supervisor.Worker())
+
+	select {
+	case worker := <-respawnedCh:
+		dump, err := worker.Dump()
+		require.NoError(t, err)
+		assert.Empty(t, dump.RouterIds)
+	case <-time.NewTimer(time.Second).C:
+		t.Fatal("timeout")
+	}
+
+	assert.Equal(t, 2, calls)
+	assert.True(t, router.Closed())
+	assert.Nil(t, supervisor.Router(router.Id()))
+	assert.Empty(t, supervisor.OriginalRouterId(router.Id()))
+}
+
+func TestWorkerSupervisorGivesUpOnCrashLoop(t *testing.T) {
+	supervisor := CreateTestWorkerSupervisor(WorkerSupervisorOptions{MaxCrashes: 1})
+	defer supervisor.Close()
+
+	respawnedCh := make(chan *Worker, 1)
+	supervisor.On("respawned", func(worker, deadWorker *Worker) { respawnedCh <- worker })
+
+	failedCh := make(chan error, 1)
+	supervisor.On("failed", func(err error) { failedCh <- err })
+
+	killWorker(t, supervisor.Worker())
+
+	select {
+	case worker := <-respawnedCh:
+		killWorker(t, worker)
+	case <-time.NewTimer(time.Second).C:
+		t.Fatal("timeout")
+	}
+
+	select {
+	case err := <-failedCh:
+		assert.Error(t, err)
+	case <-time.NewTimer(time.Second).C:
+		t.Fatal("timeout")
+	}
+
+	assert.True(t, supervisor.Closed())
+}
+
+func TestWorkerSupervisorClose(t *testing.T) {
+	supervisor := CreateTestWorkerSupervisor(WorkerSupervisorOptions{})
+
+	onRespawned := NewMockFunc(t)
+	supervisor.On("respawned", onRespawned.Fn())
+
+	worker := supervisor.Worker()
+	supervisor.Close()
+
+	assert.True(t, supervisor.Closed())
+	assert.True(t, worker.Closed())
+
+	_, err := supervisor.CreateRouter(supervisorRouterOptions)
+	assert.IsType(t, NewInvalidStateError(""), err)
+
+	time.Sleep(50 * time.Millisecond)
+	onRespawned.ExpectCalledTimes(0)
+}
@@ -1,6 +1,7 @@
 package mediasoup
 
 import (
+	"context"
 	"os"
 	"runtime"
 	"syscall"
@@ -15,6 +16,7 @@ var worker *Worker
 func init() {
 	os.Setenv("DEBUG_COLORS", "false")
 	DefaultLevel = WarnLevel
+	useFakeWorkerIfMissing()
 	worker = CreateTestWorker()
 }
 
@@ -106,6 +108,24 @@ func TestWorkerDump_InvalidStateError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestWorkerDumpContext_Canceled(t *testing.T) {
+	worker := CreateTestWorker()
+	defer worker.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := worker.DumpContext(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	dump, err := worker.DumpContext(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, worker.Pid(), dump.Pid)
+}
+
 func TestWorkerGetResourceUsage_Succeeds(t *testing.T) {
 	worker := CreateTestWorker()
 	defer worker.Close()
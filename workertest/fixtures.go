@@ -0,0 +1,129 @@
+package workertest
+
+import "github.com/jiyeyuran/mediasoup-go"
+
+// MediaCodecs returns router media codecs with Opus and VP8.
+func MediaCodecs() []*mediasoup.RtpCodecCapability {
+	return []*mediasoup.RtpCodecCapability{
+		{
+			Kind:      "audio",
+			MimeType:  "audio/opus",
+			ClockRate: 48000,
+			Channels:  2,
+		},
+		{
+			Kind:      "video",
+			MimeType:  "video/VP8",
+			ClockRate: 90000,
+		},
+	}
+}
+
+// DeviceRtpCapabilities returns the RTP capabilities of a device supporting
+// Opus and VP8.
+func DeviceRtpCapabilities() mediasoup.RtpCapabilities {
+	return mediasoup.RtpCapabilities{
+		Codecs: []*mediasoup.RtpCodecCapability{
+			{
+				Kind:                 "audio",
+				MimeType:             "audio/opus",
+				PreferredPayloadType: 100,
+				ClockRate:            48000,
+				Channels:             2,
+			},
+			{
+				Kind:                 "video",
+				MimeType:             "video/VP8",
+				PreferredPayloadType: 101,
+				ClockRate:            90000,
+				RtcpFeedback: []mediasoup.RtcpFeedback{
+					{Type: "nack"},
+					{Type: "nack", Parameter: "pli"},
+					{Type: "ccm", Parameter: "fir"},
+					{Type: "transport-cc"},
+				},
+			},
+		},
+		HeaderExtensions: []*mediasoup.RtpHeaderExtension{
+			{
+				Kind:        "audio",
+				Uri:         "urn:ietf:params:rtp-hdrext:ssrc-audio-level",
+				PreferredId: 10,
+			},
+			{
+				Kind:        "video",
+				Uri:         "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01",
+				PreferredId: 5,
+			},
+		},
+	}
+}
+
+// AudioProducerOptions returns the options of an Opus Producer.
+func AudioProducerOptions() mediasoup.ProducerOptions {
+	return mediasoup.ProducerOptions{
+		Kind: mediasoup.MediaKind_Audio,
+		RtpParameters: mediasoup.RtpParameters{
+			Mid: "AUDIO",
+			Codecs: []*mediasoup.RtpCodecParameters{
+				{
+					MimeType:    "audio/opus",
+					PayloadType: 111,
+					ClockRate:   48000,
+					Channels:    2,
+				},
+			},
+			HeaderExtensions: []mediasoup.RtpHeaderExtensionParameters{
+				{
+					Uri: "urn:ietf:params:rtp-hdrext:sdes:mid",
+					Id:  10,
+				},
+				{
+					Uri: "urn:ietf:params:rtp-hdrext:ssrc-audio-level",
+					Id:  12,
+				},
+			},
+			Encodings: []mediasoup.RtpEncodingParameters{{Ssrc: 11111111}},
+			Rtcp: mediasoup.RtcpParameters{
+				Cname: "audio-1",
+			},
+		},
+	}
+}
+
+// VideoProducerOptions returns the options of a VP8 Producer with three
+// simulcast streams.
+func VideoProducerOptions() mediasoup.ProducerOptions {
+	return mediasoup.ProducerOptions{
+		Kind: mediasoup.MediaKind_Video,
+		RtpParameters: mediasoup.RtpParameters{
+			Mid: "VIDEO",
+			Codecs: []*mediasoup.RtpCodecParameters{
+				{
+					MimeType:    "video/VP8",
+					PayloadType: 112,
+					ClockRate:   90000,
+					RtcpFeedback: []mediasoup.RtcpFeedback{
+						{Type: "nack"},
+						{Type: "nack", Parameter: "pli"},
+						{Type: "ccm", Parameter: "fir"},
+					},
+				},
+			},
+			HeaderExtensions: []mediasoup.RtpHeaderExtensionParameters{
+				{
+					Uri: "urn:ietf:params:rtp-hdrext:sdes:mid",
+					Id:  10,
+				},
+			},
+			Encodings: []mediasoup.RtpEncodingParameters{
+				{Ssrc: 22222222, MaxBitrate: 100000, ScalabilityMode: "S1T3"},
+				{Ssrc: 22222223, MaxBitrate: 300000, ScalabilityMode: "S1T3"},
+				{Ssrc: 22222224, MaxBitrate: 900000, ScalabilityMode: "S1T3"},
+			},
+			Rtcp: mediasoup.RtcpParameters{
+				Cname: "video-1",
+			},
+		},
+	}
+}
@@ -0,0 +1,60 @@
+// Command mediasoup-worker is the fake worker of the workertest package built
+// as a binary, to be used as mediasoup.WorkerBin where the C++ worker is not
+// available:
+//
+//	go build -o /tmp/mediasoup-worker github.com/jiyeyuran/mediasoup-go/workertest/mediasoup-worker
+//	MEDIASOUP_WORKER_BIN=/tmp/mediasoup-worker go test ./...
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/workertest"
+)
+
+func main() {
+	files := []*os.File{
+		os.NewFile(3, "channelIn"),
+		os.NewFile(4, "channelOut"),
+		os.NewFile(5, "payloadIn"),
+		os.NewFile(6, "payloadOut"),
+	}
+
+	// Like the real worker, ignore the signals it doesn't handle.
+	signal.Ignore(syscall.SIGPIPE, syscall.SIGHUP, syscall.SIGALRM, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	process, err := workertest.Serve(os.Args[1:], files)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	go func() {
+		process.Signal(<-signals)
+	}()
+
+	exitErr, _ := process.Wait().(*mediasoup.WorkerExitError)
+
+	switch {
+	case exitErr == nil:
+		os.Exit(0)
+
+	case exitErr.Code != 0:
+		os.Exit(exitErr.Code)
+
+	default:
+		// Die of the signal, as the real worker does.
+		if sig, ok := exitErr.Signal.(syscall.Signal); ok {
+			signal.Reset(sig)
+			syscall.Kill(os.Getpid(), sig)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,2017 @@
+package workertest
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+type webRtcServer struct {
+	id          string
+	listenInfos []mediasoup.WebRtcServerListenInfo
+	transports  map[string]*transport
+}
+
+type router struct {
+	id            string
+	transports    map[string]*transport
+	producers     map[string]*producer
+	dataProducers map[string]*dataProducer
+	rtpObservers  map[string]*rtpObserver
+}
+
+type transport struct {
+	id              string
+	router          *router
+	webRtcServer    *webRtcServer
+	kind            string
+	data            mediasoup.H
+	producers       map[string]*producer
+	consumers       map[string]*consumer
+	dataProducers   map[string]*dataProducer
+	dataConsumers   map[string]*dataConsumer
+	traceEventTypes []string
+}
+
+type producer struct {
+	id              string
+	transport       *transport
+	kind            string
+	typ             string
+	rtpParameters   mediasoup.RtpParameters
+	rtpMapping      json.RawMessage
+	paused          bool
+	traceEventTypes []string
+	consumers       map[string]*consumer
+	rtpObservers    map[string]*rtpObserver
+	packetCount     int64
+	byteCount       int64
+}
+
+type consumer struct {
+	id                     string
+	seq                    int
+	transport              *transport
+	producer               *producer
+	kind                   string
+	typ                    string
+	rtpParameters          mediasoup.RtpParameters
+	consumableRtpEncodings json.RawMessage
+	paused                 bool
+	priority               uint32
+	preferredLayers        *mediasoup.ConsumerLayers
+	traceEventTypes        []string
+	packetCount            int64
+	byteCount              int64
+}
+
+type dataProducer struct {
+	id                   string
+	transport            *transport
+	typ                  string
+	label                string
+	protocol             string
+	sctpStreamParameters *mediasoup.SctpStreamParameters
+	dataConsumers        map[string]*dataConsumer
+	messagesReceived     int64
+	bytesReceived        int64
+}
+
+type dataConsumer struct {
+	id                         string
+	transport                  *transport
+	dataProducer               *dataProducer
+	typ                        string
+	label                      string
+	protocol                   string
+	sctpStreamParameters       *mediasoup.SctpStreamParameters
+	bufferedAmountLowThreshold uint32
+	messagesSent               int64
+	bytesSent                  int64
+}
+
+type rtpObserver struct {
+	id        string
+	router    *router
+	paused    bool
+	producers map[string]*producer
+}
+
+// handleRequest answers the request with the in-memory model. p.locker must be
+// held.
+func (p *Process) handleRequest(req Request) (interface{}, error) {
+	switch strings.SplitN(req.Method, ".", 2)[0] {
+	case "worker":
+		return p.handleWorkerRequest(req)
+	case "webRtcServer":
+		return p.handleWebRtcServerRequest(req)
+	case "router":
+		return p.handleRouterRequest(req)
+	case "transport":
+		return p.handleTransportRequest(req)
+	case "producer":
+		return p.handleProducerRequest(req)
+	case "consumer":
+		return p.handleConsumerRequest(req)
+	case "dataProducer":
+		return p.handleDataProducerRequest(req)
+	case "dataConsumer":
+		return p.handleDataConsumerRequest(req)
+	case "rtpObserver":
+		return p.handleRtpObserverRequest(req)
+	}
+
+	return nil, fmt.Errorf("unknown method '%s'", req.Method)
+}
+
+func (p *Process) handleWorkerRequest(req Request) (interface{}, error) {
+	switch req.Method {
+	case "worker.dump":
+		return mediasoup.WorkerDump{
+			Pid:             p.pid,
+			RouterIds:       sortedKeys(p.routers),
+			WebRtcServerIds: sortedKeys(p.webRtcServers),
+		}, nil
+
+	case "worker.getResourceUsage":
+		return p.usage, nil
+
+	case "worker.updateSettings":
+		var settings struct {
+			LogLevel string   `json:"logLevel"`
+			LogTags  []string `json:"logTags"`
+		}
+		if err := json.Unmarshal(req.Data, &settings); err != nil {
+			return nil, err
+		}
+		if err := validateLogLevel(settings.LogLevel); err != nil {
+			return nil, err
+		}
+		if err := validateLogTags(settings.LogTags); err != nil {
+			return nil, err
+		}
+		if len(settings.LogLevel) > 0 {
+			p.logLevel = settings.LogLevel
+		}
+		if settings.LogTags != nil {
+			p.logTags = settings.LogTags
+		}
+		return nil, nil
+
+	case "worker.createRouter":
+		id := req.Internal.RouterId
+
+		if _, ok := p.routers[id]; ok {
+			return nil, errors.New("a Router with same routerId already exists")
+		}
+		p.routers[id] = &router{
+			id:            id,
+			transports:    make(map[string]*transport),
+			producers:     make(map[string]*producer),
+			dataProducers: make(map[string]*dataProducer),
+			rtpObservers:  make(map[string]*rtpObserver),
+		}
+		return nil, nil
+
+	case "worker.createWebRtcServer":
+		return p.createWebRtcServer(req)
+
+	case "worker.closeWebRtcServer":
+		webRtcServer, ok := p.webRtcServers[req.Internal.WebRtcServerId]
+		if !ok {
+			return nil, errors.New("WebRtcServer not found")
+		}
+		for _, transport := range webRtcServer.transports {
+			p.closeTransport(transport)
+		}
+		delete(p.webRtcServers, webRtcServer.id)
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown method '%s'", req.Method)
+}
+
+func (p *Process) createWebRtcServer(req Request) (interface{}, error) {
+	id := req.Internal.WebRtcServerId
+
+	if _, ok := p.webRtcServers[id]; ok {
+		return nil, errors.New("a WebRtcServer with same webRtcServerId already exists")
+	}
+
+	var options struct {
+		ListenInfos []mediasoup.WebRtcServerListenInfo `json:"listenInfos"`
+	}
+	if err := json.Unmarshal(req.Data, &options); err != nil {
+		return nil, err
+	}
+	if len(options.ListenInfos) == 0 {
+		return nil, mediasoup.NewTypeError("empty listenInfos array provided")
+	}
+
+	for i, listenInfo := range options.ListenInfos {
+		if listenInfo.Protocol != mediasoup.TransportProtocol_Udp &&
+			listenInfo.Protocol != mediasoup.TransportProtocol_Tcp {
+			return nil, mediasoup.NewTypeError("invalid listenInfo.protocol")
+		}
+		if len(listenInfo.Ip) == 0 {
+			return nil, mediasoup.NewTypeError("missing listenInfo.ip")
+		}
+		if err := validateListenIp(listenInfo.Ip); err != nil {
+			return nil, err
+		}
+		if listenInfo.Port == 0 {
+			port, err := p.allocatePort()
+			if err != nil {
+				return nil, err
+			}
+			options.ListenInfos[i].Port = uint16(port)
+			continue
+		}
+		for _, other := range p.webRtcServers {
+			for _, otherListenInfo := range other.listenInfos {
+				if otherListenInfo.Protocol == listenInfo.Protocol &&
+					otherListenInfo.Ip == listenInfo.Ip &&
+					otherListenInfo.Port == listenInfo.Port {
+					return nil, errors.New("address already in use")
+				}
+			}
+		}
+	}
+
+	p.webRtcServers[id] = &webRtcServer{
+		id:          id,
+		listenInfos: options.ListenInfos,
+		transports:  make(map[string]*transport),
+	}
+
+	return nil, nil
+}
+
+func (p *Process) handleWebRtcServerRequest(req Request) (interface{}, error) {
+	webRtcServer, ok := p.webRtcServers[req.Internal.WebRtcServerId]
+	if !ok {
+		return nil, errors.New("WebRtcServer not found")
+	}
+
+	switch req.Method {
+	case "webRtcServer.dump":
+		return p.dumpWebRtcServer(webRtcServer), nil
+	}
+
+	return nil, fmt.Errorf("unknown method '%s'", req.Method)
+}
+
+func (p *Process) handleRouterRequest(req Request) (interface{}, error) {
+	router, ok := p.routers[req.Internal.RouterId]
+	if !ok {
+		return nil, errors.New("Router not found")
+	}
+
+	switch req.Method {
+	case "router.close":
+		for _, transport := range router.transports {
+			p.closeTransport(transport)
+		}
+		for _, rtpObserver := range router.rtpObservers {
+			p.closeRtpObserver(rtpObserver)
+		}
+		delete(p.routers, router.id)
+		return nil, nil
+
+	case "router.dump":
+		return p.dumpRouter(router), nil
+
+	case "router.createWebRtcTransport",
+		"router.createWebRtcTransportWithServer",
+		"router.createPlainTransport",
+		"router.createPipeTransport",
+		"router.createDirectTransport":
+		id := req.Internal.TransportId
+
+		if _, ok := p.transports[id]; ok {
+			return nil, errors.New("a Transport with same transportId already exists")
+		}
+
+		var options transportOptions
+		if err := json.Unmarshal(req.Data, &options); err != nil {
+			return nil, err
+		}
+
+		transport := &transport{
+			id:            id,
+			router:        router,
+			producers:     make(map[string]*producer),
+			consumers:     make(map[string]*consumer),
+			dataProducers: make(map[string]*dataProducer),
+			dataConsumers: make(map[string]*dataConsumer),
+		}
+
+		var err error
+
+		switch req.Method {
+		case "router.createWebRtcTransport":
+			transport.kind = "webrtc"
+			transport.data, err = p.webRtcTransportData(options)
+		case "router.createWebRtcTransportWithServer":
+			webRtcServer, ok := p.webRtcServers[options.WebRtcServerId]
+			if !ok {
+				return nil, errors.New("WebRtcServer not found")
+			}
+			transport.kind = "webrtc"
+			transport.webRtcServer = webRtcServer
+			transport.data, err = p.webRtcTransportDataWithServer(webRtcServer, options)
+		case "router.createPlainTransport":
+			transport.kind = "plain"
+			transport.data, err = p.plainTransportData(options)
+		case "router.createPipeTransport":
+			transport.kind = "pipe"
+			transport.data, err = p.pipeTransportData(options)
+		default:
+			transport.kind = "direct"
+			transport.data = mediasoup.H{}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		router.transports[id] = transport
+		p.transports[id] = transport
+		if transport.webRtcServer != nil {
+			transport.webRtcServer.transports[id] = transport
+		}
+
+		return transport.data, nil
+
+	case "router.createAudioLevelObserver", "router.createActiveSpeakerObserver":
+		id := req.Internal.RtpObserverId
+
+		if _, ok := p.rtpObservers[id]; ok {
+			return nil, errors.New("an RtpObserver with same rtpObserverId already exists")
+		}
+		if req.Method == "router.createAudioLevelObserver" {
+			var options struct {
+				MaxEntries int `json:"maxEntries"`
+			}
+			if err := json.Unmarshal(req.Data, &options); err != nil {
+				return nil, err
+			}
+			if options.MaxEntries < 1 {
+				return nil, mediasoup.NewTypeError("invalid maxEntries value %d", options.MaxEntries)
+			}
+		}
+		rtpObserver := &rtpObserver{
+			id:        id,
+			router:    router,
+			producers: make(map[string]*producer),
+		}
+		router.rtpObservers[id] = rtpObserver
+		p.rtpObservers[id] = rtpObserver
+
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown method '%s'", req.Method)
+}
+
+type transportOptions struct {
+	WebRtcServerId     string                        `json:"webRtcServerId"`
+	ListenIps          []mediasoup.TransportListenIp `json:"listenIps"`
+	ListenIp           mediasoup.TransportListenIp   `json:"listenIp"`
+	EnableUdp          *bool                         `json:"enableUdp"`
+	EnableTcp          bool                          `json:"enableTcp"`
+	RtcpMux            *bool                         `json:"rtcpMux"`
+	Comedia            bool                          `json:"comedia"`
+	EnableSctp         bool                          `json:"enableSctp"`
+	NumSctpStreams     mediasoup.NumSctpStreams      `json:"numSctpStreams"`
+	MaxSctpMessageSize uint32                        `json:"maxSctpMessageSize"`
+	SctpSendBufferSize int                           `json:"sctpSendBufferSize"`
+	IsDataChannel      bool                          `json:"isDataChannel"`
+	EnableRtx          bool                          `json:"enableRtx"`
+	EnableSrtp         bool                          `json:"enableSrtp"`
+	SrtpCryptoSuite    mediasoup.SrtpCryptoSuite     `json:"srtpCryptoSuite"`
+}
+
+func (o transportOptions) sctpParameters() *mediasoup.SctpParameters {
+	if !o.EnableSctp {
+		return nil
+	}
+	return &mediasoup.SctpParameters{
+		Port:           5000,
+		OS:             o.NumSctpStreams.OS,
+		MIS:            o.NumSctpStreams.MIS,
+		MaxMessageSize: o.MaxSctpMessageSize,
+		IsDataChannel:  o.IsDataChannel,
+		SendBufferSize: o.SctpSendBufferSize,
+	}
+}
+
+func (p *Process) webRtcTransportData(options transportOptions) (mediasoup.H, error) {
+	if len(options.ListenIps) == 0 {
+		return nil, mediasoup.NewTypeError("empty listenIps array provided")
+	}
+
+	enableUdp := options.EnableUdp == nil || *options.EnableUdp
+	candidates := []mediasoup.IceCandidate{}
+
+	for _, listenIp := range options.ListenIps {
+		if err := validateListenIp(listenIp.Ip); err != nil {
+			return nil, err
+		}
+		ip := listenIp.AnnouncedIp
+		if len(ip) == 0 {
+			ip = listenIp.Ip
+		}
+		if enableUdp {
+			port, err := p.allocatePort()
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, mediasoup.IceCandidate{
+				Foundation: "udpcandidate",
+				Priority:   1076302079,
+				Ip:         ip,
+				Protocol:   mediasoup.TransportProtocol_Udp,
+				Port:       uint32(port),
+				Type:       "host",
+			})
+		}
+		if options.EnableTcp {
+			port, err := p.allocatePort()
+			if err != nil {
+				return nil, err
+			}
+			candidates = append(candidates, mediasoup.IceCandidate{
+				Foundation: "tcpcandidate",
+				Priority:   1076276479,
+				Ip:         ip,
+				Protocol:   mediasoup.TransportProtocol_Tcp,
+				Port:       uint32(port),
+				Type:       "host",
+				TcpType:    "passive",
+			})
+		}
+	}
+
+	return p.webRtcTransportDataWithCandidates(candidates, options), nil
+}
+
+func (p *Process) webRtcTransportDataWithServer(webRtcServer *webRtcServer, options transportOptions) (mediasoup.H, error) {
+	enableUdp := options.EnableUdp == nil || *options.EnableUdp
+	candidates := []mediasoup.IceCandidate{}
+
+	for _, listenInfo := range webRtcServer.listenInfos {
+		ip := listenInfo.AnnouncedIp
+		if len(ip) == 0 {
+			ip = listenInfo.Ip
+		}
+		switch {
+		case listenInfo.Protocol == mediasoup.TransportProtocol_Udp && enableUdp:
+			candidates = append(candidates, mediasoup.IceCandidate{
+				Foundation: "udpcandidate",
+				Priority:   1076302079,
+				Ip:         ip,
+				Protocol:   mediasoup.TransportProtocol_Udp,
+				Port:       uint32(listenInfo.Port),
+				Type:       "host",
+			})
+		case listenInfo.Protocol == mediasoup.TransportProtocol_Tcp && options.EnableTcp:
+			candidates = append(candidates, mediasoup.IceCandidate{
+				Foundation: "tcpcandidate",
+				Priority:   1076276479,
+				Ip:         ip,
+				Protocol:   mediasoup.TransportProtocol_Tcp,
+				Port:       uint32(listenInfo.Port),
+				Type:       "host",
+				TcpType:    "passive",
+			})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, mediasoup.NewTypeError("no ICE candidates for the enabled protocols")
+	}
+
+	return p.webRtcTransportDataWithCandidates(candidates, options), nil
+}
+
+func (p *Process) webRtcTransportDataWithCandidates(candidates []mediasoup.IceCandidate, options transportOptions) mediasoup.H {
+	data := mediasoup.H{
+		"iceRole":       "controlled",
+		"iceParameters": newIceParameters(),
+		"iceCandidates": candidates,
+		"iceState":      mediasoup.IceState_New,
+		"dtlsParameters": mediasoup.DtlsParameters{
+			Role: mediasoup.DtlsRole_Auto,
+			Fingerprints: []mediasoup.DtlsFingerprint{
+				{Algorithm: "sha-256", Value: fingerprint(32)},
+				{Algorithm: "sha-512", Value: fingerprint(64)},
+			},
+		},
+		"dtlsState": mediasoup.DtlsState_New,
+	}
+	if sctpParameters := options.sctpParameters(); sctpParameters != nil {
+		data["sctpParameters"] = sctpParameters
+		data["sctpState"] = mediasoup.SctpState_New
+	}
+
+	return data
+}
+
+func (p *Process) plainTransportData(options transportOptions) (mediasoup.H, error) {
+	tuple, err := p.localTuple(options.ListenIp)
+	if err != nil {
+		return nil, err
+	}
+
+	rtcpMux := options.RtcpMux == nil || *options.RtcpMux
+	data := mediasoup.H{
+		"rtcpMux": rtcpMux,
+		"comedia": options.Comedia,
+		"tuple":   tuple,
+	}
+	if !rtcpMux {
+		rtcpTuple, err := p.localTuple(options.ListenIp)
+		if err != nil {
+			return nil, err
+		}
+		data["rtcpTuple"] = rtcpTuple
+	}
+	if sctpParameters := options.sctpParameters(); sctpParameters != nil {
+		data["sctpParameters"] = sctpParameters
+		data["sctpState"] = mediasoup.SctpState_New
+	}
+	if options.EnableSrtp {
+		data["srtpParameters"] = newSrtpParameters(options.SrtpCryptoSuite)
+	}
+
+	return data, nil
+}
+
+func (p *Process) pipeTransportData(options transportOptions) (mediasoup.H, error) {
+	tuple, err := p.localTuple(options.ListenIp)
+	if err != nil {
+		return nil, err
+	}
+
+	data := mediasoup.H{
+		"tuple": tuple,
+		"rtx":   options.EnableRtx,
+	}
+	if sctpParameters := options.sctpParameters(); sctpParameters != nil {
+		data["sctpParameters"] = sctpParameters
+		data["sctpState"] = mediasoup.SctpState_New
+	}
+	if options.EnableSrtp {
+		data["srtpParameters"] = newSrtpParameters(mediasoup.AES_CM_128_HMAC_SHA1_80)
+	}
+
+	return data, nil
+}
+
+func (p *Process) handleTransportRequest(req Request) (interface{}, error) {
+	transport, ok := p.transports[req.Internal.TransportId]
+	if !ok {
+		return nil, errors.New("Transport not found")
+	}
+
+	switch req.Method {
+	case "transport.close":
+		p.closeTransport(transport)
+		return nil, nil
+
+	case "transport.dump":
+		return p.dumpTransport(transport), nil
+
+	case "transport.getStats":
+		if stats, ok := p.stats[transport.id]; ok {
+			return stats, nil
+		}
+		typ := transport.kind + "-transport"
+
+		if transport.kind == "plain" {
+			typ = "plain-rtp-transport"
+		}
+		stat := mediasoup.H{
+			"type":        typ,
+			"transportId": transport.id,
+			"timestamp":   now(),
+		}
+		if sctpState, ok := transport.data["sctpState"]; ok {
+			stat["sctpState"] = sctpState
+		}
+		for _, key := range []string{"iceRole", "iceState", "dtlsState", "rtcpMux", "comedia", "tuple"} {
+			if value, ok := transport.data[key]; ok {
+				stat[key] = value
+			}
+		}
+		// Unlike the tuple, the RTCP tuple is only known once connected.
+		if rtcpTuple, ok := transport.data["rtcpTuple"].(mediasoup.TransportTuple); ok && len(rtcpTuple.RemoteIp) > 0 {
+			stat["rtcpTuple"] = rtcpTuple
+		}
+		return []mediasoup.H{stat}, nil
+
+	case "transport.connect":
+		return p.connectTransport(transport, req.Data)
+
+	case "transport.restartIce":
+		if transport.kind != "webrtc" {
+			return nil, fmt.Errorf("unknown method '%s'", req.Method)
+		}
+		iceParameters := newIceParameters()
+		transport.data["iceParameters"] = iceParameters
+
+		return mediasoup.H{"iceParameters": iceParameters}, nil
+
+	case "transport.setMaxIncomingBitrate":
+		if transport.kind == "direct" {
+			return nil, fmt.Errorf("unknown method '%s'", req.Method)
+		}
+		return nil, nil
+
+	case "transport.enableTraceEvent":
+		var data struct {
+			Types []string `json:"types"`
+		}
+		if err := json.Unmarshal(req.Data, &data); err != nil {
+			return nil, err
+		}
+		transport.traceEventTypes = filterTraceEventTypes(data.Types, "probation", "bwe")
+
+		return nil, nil
+
+	case "transport.produce":
+		return p.produce(transport, req)
+
+	case "transport.consume":
+		return p.consume(transport, req)
+
+	case "transport.produceData":
+		return p.produceData(transport, req)
+
+	case "transport.consumeData":
+		return p.consumeData(transport, req)
+	}
+
+	return nil, fmt.Errorf("unknown method '%s'", req.Method)
+}
+
+func (p *Process) connectTransport(transport *transport, data json.RawMessage) (interface{}, error) {
+	var options mediasoup.TransportConnectOptions
+
+	if err := json.Unmarshal(data, &options); err != nil {
+		return nil, err
+	}
+
+	switch transport.kind {
+	case "webrtc":
+		if options.DtlsParameters == nil {
+			return nil, mediasoup.NewTypeError("missing dtlsParameters")
+		}
+		if transport.data["dtlsState"] != mediasoup.DtlsState_New {
+			return nil, errors.New("connect() already called")
+		}
+		if err := validateDtlsParameters(*options.DtlsParameters); err != nil {
+			return nil, err
+		}
+		dtlsLocalRole := mediasoup.DtlsRole_Client
+
+		if options.DtlsParameters.Role == mediasoup.DtlsRole_Client {
+			dtlsLocalRole = mediasoup.DtlsRole_Server
+		}
+		transport.data["dtlsState"] = mediasoup.DtlsState_Connecting
+
+		return mediasoup.H{"dtlsLocalRole": dtlsLocalRole}, nil
+
+	case "plain", "pipe":
+		tuple := transport.data["tuple"].(mediasoup.TransportTuple)
+
+		if len(tuple.RemoteIp) > 0 {
+			return nil, errors.New("connect() already called")
+		}
+
+		_, srtpEnabled := transport.data["srtpParameters"]
+
+		if !srtpEnabled && options.SrtpParameters != nil {
+			return nil, mediasoup.NewTypeError("invalid srtpParameters (SRTP not enabled)")
+		}
+		if srtpEnabled {
+			if options.SrtpParameters == nil {
+				return nil, mediasoup.NewTypeError("missing srtpParameters (SRTP enabled)")
+			}
+			if err := validateSrtpParameters(*options.SrtpParameters); err != nil {
+				return nil, err
+			}
+		}
+
+		result := mediasoup.H{}
+		comedia, _ := transport.data["comedia"].(bool)
+
+		if !comedia {
+			if len(options.Ip) == 0 || net.ParseIP(options.Ip) == nil {
+				return nil, mediasoup.NewTypeError("missing or invalid ip")
+			}
+			if options.Port == 0 {
+				return nil, mediasoup.NewTypeError("missing port")
+			}
+			tuple.RemoteIp = options.Ip
+			tuple.RemotePort = options.Port
+			transport.data["tuple"] = tuple
+			result["tuple"] = tuple
+
+			if rtcpTuple, ok := transport.data["rtcpTuple"].(mediasoup.TransportTuple); ok {
+				if options.RtcpPort == 0 {
+					return nil, mediasoup.NewTypeError("missing rtcpPort (required as rtcpMux is not set)")
+				}
+				rtcpTuple.RemoteIp = options.Ip
+				rtcpTuple.RemotePort = options.RtcpPort
+				transport.data["rtcpTuple"] = rtcpTuple
+				result["rtcpTuple"] = rtcpTuple
+			}
+		}
+
+		// The local keys follow the crypto suite of the remote ones.
+		if srtpEnabled {
+			srtpParameters := newSrtpParameters(options.SrtpParameters.CryptoSuite)
+			transport.data["srtpParameters"] = srtpParameters
+			result["srtpParameters"] = srtpParameters
+		}
+
+		return result, nil
+	}
+
+	return nil, nil
+}
+
+// canonicalMimeTypes maps the lower case mime types to the ones the real worker
+// sends.
+var canonicalMimeTypes = func() map[string]string {
+	mimeTypes := map[string]string{}
+
+	for _, codec := range mediasoup.GetSupportedRtpCapabilities().Codecs {
+		mimeTypes[strings.ToLower(codec.MimeType)] = codec.MimeType
+	}
+
+	return mimeTypes
+}()
+
+// validateRtpParameters checks the RTP parameters like the real worker, which
+// also sets the codecPayloadType of the encodings lacking it to the first
+// media codec.
+func validateRtpParameters(rtpParameters *mediasoup.RtpParameters) error {
+	if len(rtpParameters.Codecs) == 0 {
+		return mediasoup.NewTypeError("empty rtpParameters.codecs")
+	}
+	if len(rtpParameters.Encodings) == 0 {
+		return mediasoup.NewTypeError("empty rtpParameters.encodings")
+	}
+
+	mediaPayloadTypes := map[uint8]bool{}
+	var firstMediaPayloadType uint8
+
+	for _, codec := range rtpParameters.Codecs {
+		if mimeType, ok := canonicalMimeTypes[strings.ToLower(codec.MimeType)]; ok {
+			codec.MimeType = mimeType
+		}
+		if strings.HasSuffix(strings.ToLower(codec.MimeType), "/rtx") {
+			continue
+		}
+		if len(mediaPayloadTypes) == 0 {
+			firstMediaPayloadType = codec.PayloadType
+		}
+		mediaPayloadTypes[codec.PayloadType] = true
+	}
+	if len(mediaPayloadTypes) == 0 {
+		return mediasoup.NewTypeError("no media codecs found")
+	}
+	for _, codec := range rtpParameters.Codecs {
+		if strings.HasSuffix(strings.ToLower(codec.MimeType), "/rtx") &&
+			!mediaPayloadTypes[codec.Parameters.Apt] {
+			return mediasoup.NewTypeError("missing media codec found for RTX PT %d", codec.PayloadType)
+		}
+	}
+	for i, encoding := range rtpParameters.Encodings {
+		if encoding.CodecPayloadType == 0 {
+			rtpParameters.Encodings[i].CodecPayloadType = firstMediaPayloadType
+		} else if !mediaPayloadTypes[encoding.CodecPayloadType] {
+			return mediasoup.NewTypeError("invalid encoding.codecPayloadType")
+		}
+	}
+
+	return nil
+}
+
+func validateDtlsParameters(dtlsParameters mediasoup.DtlsParameters) error {
+	switch dtlsParameters.Role {
+	case "", mediasoup.DtlsRole_Auto, mediasoup.DtlsRole_Client, mediasoup.DtlsRole_Server:
+	default:
+		return mediasoup.NewTypeError("invalid dtlsParameters.role")
+	}
+	if len(dtlsParameters.Fingerprints) == 0 {
+		return mediasoup.NewTypeError("empty dtlsParameters.fingerprints array")
+	}
+	for _, fingerprint := range dtlsParameters.Fingerprints {
+		switch fingerprint.Algorithm {
+		case "sha-1", "sha-224", "sha-256", "sha-384", "sha-512":
+		default:
+			return mediasoup.NewTypeError("invalid fingerprint.algorithm value")
+		}
+	}
+
+	return nil
+}
+
+func validateSrtpParameters(srtpParameters mediasoup.SrtpParameters) error {
+	switch srtpParameters.CryptoSuite {
+	case mediasoup.AES_CM_128_HMAC_SHA1_80, mediasoup.AES_CM_128_HMAC_SHA1_32:
+	default:
+		return mediasoup.NewTypeError("invalid or missing srtpParameters.cryptoSuite")
+	}
+	if len(srtpParameters.KeyBase64) == 0 {
+		return mediasoup.NewTypeError("missing srtpParameters.keyBase64")
+	}
+
+	return nil
+}
+
+func (p *Process) produce(transport *transport, req Request) (interface{}, error) {
+	id := req.Internal.ProducerId
+
+	if _, ok := transport.router.producers[id]; ok {
+		return nil, errors.New("a Producer with same producerId already exists")
+	}
+
+	var data struct {
+		Kind          string                  `json:"kind"`
+		RtpParameters mediasoup.RtpParameters `json:"rtpParameters"`
+		RtpMapping    json.RawMessage         `json:"rtpMapping"`
+		Paused        bool                    `json:"paused"`
+	}
+	if err := json.Unmarshal(req.Data, &data); err != nil {
+		return nil, err
+	}
+	if data.Kind != "audio" && data.Kind != "video" {
+		return nil, mediasoup.NewTypeError("invalid kind")
+	}
+	if err := validateRtpParameters(&data.RtpParameters); err != nil {
+		return nil, err
+	}
+	for _, encoding := range data.RtpParameters.Encodings {
+		if encoding.Ssrc == 0 && len(encoding.Rid) == 0 && len(data.RtpParameters.Mid) == 0 {
+			return nil, mediasoup.NewTypeError("invalid encoding (missing ssrc, rid and mid)")
+		}
+	}
+	for _, other := range transport.producers {
+		if len(data.RtpParameters.Mid) > 0 && other.rtpParameters.Mid == data.RtpParameters.Mid {
+			return nil, fmt.Errorf("MID already exists in RTP listener [mid:%s]", data.RtpParameters.Mid)
+		}
+		for _, encoding := range data.RtpParameters.Encodings {
+			for _, otherEncoding := range other.rtpParameters.Encodings {
+				if encoding.Ssrc != 0 && encoding.Ssrc == otherEncoding.Ssrc {
+					return nil, fmt.Errorf("ssrc already exists in RTP listener [ssrc:%d]", encoding.Ssrc)
+				}
+			}
+		}
+	}
+
+	typ := "simple"
+
+	if encodings := data.RtpParameters.Encodings; len(encodings) > 1 {
+		typ = "simulcast"
+	} else if len(encodings) == 1 &&
+		mediasoup.ParseScalabilityMode(encodings[0].ScalabilityMode).SpatialLayers > 1 {
+		typ = "svc"
+	}
+
+	producer := &producer{
+		id:            id,
+		transport:     transport,
+		kind:          data.Kind,
+		typ:           typ,
+		rtpParameters: data.RtpParameters,
+		rtpMapping:    data.RtpMapping,
+		paused:        data.Paused,
+		consumers:     make(map[string]*consumer),
+		rtpObservers:  make(map[string]*rtpObserver),
+	}
+	transport.producers[id] = producer
+	transport.router.producers[id] = producer
+
+	return mediasoup.H{"type": typ}, nil
+}
+
+func (p *Process) consume(transport *transport, req Request) (interface{}, error) {
+	id := req.Internal.ConsumerId
+
+	if _, ok := p.consumers[id]; ok {
+		return nil, errors.New("a Consumer with same consumerId already exists")
+	}
+	producer, ok := transport.router.producers[req.Internal.ProducerId]
+	if !ok {
+		return nil, errors.New("Producer not found")
+	}
+
+	var data struct {
+		Kind                   string                    `json:"kind"`
+		Type                   string                    `json:"type"`
+		RtpParameters          mediasoup.RtpParameters   `json:"rtpParameters"`
+		ConsumableRtpEncodings json.RawMessage           `json:"consumableRtpEncodings"`
+		Paused                 bool                      `json:"paused"`
+		PreferredLayers        *mediasoup.ConsumerLayers `json:"preferredLayers"`
+	}
+	if err := json.Unmarshal(req.Data, &data); err != nil {
+		return nil, err
+	}
+	if err := validateRtpParameters(&data.RtpParameters); err != nil {
+		return nil, err
+	}
+
+	p.lastSeq++
+
+	consumer := &consumer{
+		id:                     id,
+		seq:                    p.lastSeq,
+		transport:              transport,
+		producer:               producer,
+		kind:                   data.Kind,
+		typ:                    data.Type,
+		rtpParameters:          data.RtpParameters,
+		consumableRtpEncodings: data.ConsumableRtpEncodings,
+		paused:                 data.Paused,
+		priority:               1,
+	}
+	consumer.preferredLayers = consumer.clampLayers(data.PreferredLayers)
+
+	transport.consumers[id] = consumer
+	producer.consumers[id] = consumer
+	p.consumers[id] = consumer
+
+	score := mediasoup.ConsumerScore{
+		Score:          10,
+		ProducerScores: make([]uint16, len(producer.rtpParameters.Encodings)),
+	}
+
+	// A pipe Consumer does not measure the Producer.
+	if consumer.typ == "pipe" {
+		score.ProducerScore = 10
+	}
+
+	return mediasoup.H{
+		"paused":         consumer.paused,
+		"producerPaused": producer.paused,
+		"score":          score,
+	}, nil
+}
+
+// consumerIds returns the ids of the Consumers of the producer, newest first
+// like the real worker lists them.
+func (p *producer) consumerIds() []string {
+	consumers := make([]*consumer, 0, len(p.consumers))
+
+	for _, consumer := range p.consumers {
+		consumers = append(consumers, consumer)
+	}
+	sort.Slice(consumers, func(i, j int) bool {
+		return consumers[i].seq > consumers[j].seq
+	})
+
+	ids := make([]string, len(consumers))
+
+	for i, consumer := range consumers {
+		ids[i] = consumer.id
+	}
+
+	return ids
+}
+
+// supportedCodecPayloadTypes returns the payload types of the media codecs of
+// the consumer.
+func (c *consumer) supportedCodecPayloadTypes() []uint32 {
+	payloadTypes := []uint32{}
+
+	for _, codec := range c.rtpParameters.Codecs {
+		if !strings.HasSuffix(strings.ToLower(codec.MimeType), "/rtx") {
+			payloadTypes = append(payloadTypes, uint32(codec.PayloadType))
+		}
+	}
+
+	return payloadTypes
+}
+
+// clampLayers returns the effective preferred layers, which are nil for simple
+// consumers.
+func (c *consumer) clampLayers(layers *mediasoup.ConsumerLayers) *mediasoup.ConsumerLayers {
+	if c.typ != "simulcast" && c.typ != "svc" {
+		return nil
+	}
+
+	var scalabilityMode mediasoup.ScalabilityMode
+
+	if len(c.rtpParameters.Encodings) > 0 {
+		scalabilityMode = mediasoup.ParseScalabilityMode(c.rtpParameters.Encodings[0].ScalabilityMode)
+	}
+
+	result := &mediasoup.ConsumerLayers{
+		SpatialLayer:  scalabilityMode.SpatialLayers - 1,
+		TemporalLayer: scalabilityMode.TemporalLayers - 1,
+	}
+	if layers != nil {
+		if layers.SpatialLayer < result.SpatialLayer {
+			result.SpatialLayer = layers.SpatialLayer
+		}
+		if layers.TemporalLayer < result.TemporalLayer {
+			result.TemporalLayer = layers.TemporalLayer
+		}
+	}
+
+	return result
+}
+
+func (p *Process) produceData(transport *transport, req Request) (interface{}, error) {
+	id := req.Internal.DataProducerId
+
+	if _, ok := transport.router.dataProducers[id]; ok {
+		return nil, errors.New("a DataProducer with same dataProducerId already exists")
+	}
+
+	var data struct {
+		Type                 string                          `json:"type"`
+		Label                string                          `json:"label"`
+		Protocol             string                          `json:"protocol"`
+		SctpStreamParameters *mediasoup.SctpStreamParameters `json:"sctpStreamParameters"`
+	}
+	if err := json.Unmarshal(req.Data, &data); err != nil {
+		return nil, err
+	}
+	if data.Type == "sctp" {
+		if _, ok := transport.data["sctpParameters"]; !ok {
+			return nil, mediasoup.NewTypeError("SCTP not enabled")
+		}
+		if data.SctpStreamParameters == nil {
+			return nil, mediasoup.NewTypeError("missing sctpStreamParameters")
+		}
+		for _, dataProducer := range transport.dataProducers {
+			if dataProducer.sctpStreamParameters.StreamId == data.SctpStreamParameters.StreamId {
+				return nil, mediasoup.NewTypeError("streamId already in use")
+			}
+		}
+	}
+
+	dataProducer := &dataProducer{
+		id:                   id,
+		transport:            transport,
+		typ:                  data.Type,
+		label:                data.Label,
+		protocol:             data.Protocol,
+		sctpStreamParameters: data.SctpStreamParameters,
+		dataConsumers:        make(map[string]*dataConsumer),
+	}
+	transport.dataProducers[id] = dataProducer
+	transport.router.dataProducers[id] = dataProducer
+
+	return p.dumpDataProducer(dataProducer), nil
+}
+
+func (p *Process) consumeData(transport *transport, req Request) (interface{}, error) {
+	id := req.Internal.DataConsumerId
+
+	if _, ok := p.dataConsumers[id]; ok {
+		return nil, errors.New("a DataConsumer with same dataConsumerId already exists")
+	}
+	dataProducer, ok := transport.router.dataProducers[req.Internal.DataProducerId]
+	if !ok {
+		return nil, errors.New("DataProducer not found")
+	}
+
+	var data struct {
+		Type                 string                          `json:"type"`
+		Label                string                          `json:"label"`
+		Protocol             string                          `json:"protocol"`
+		SctpStreamParameters *mediasoup.SctpStreamParameters `json:"sctpStreamParameters"`
+	}
+	if err := json.Unmarshal(req.Data, &data); err != nil {
+		return nil, err
+	}
+	if data.Type == "sctp" {
+		if _, ok := transport.data["sctpParameters"]; !ok {
+			return nil, mediasoup.NewTypeError("SCTP not enabled")
+		}
+	} else {
+		data.SctpStreamParameters = nil
+	}
+
+	dataConsumer := &dataConsumer{
+		id:                   id,
+		transport:            transport,
+		dataProducer:         dataProducer,
+		typ:                  data.Type,
+		label:                data.Label,
+		protocol:             data.Protocol,
+		sctpStreamParameters: data.SctpStreamParameters,
+	}
+	transport.dataConsumers[id] = dataConsumer
+	dataProducer.dataConsumers[id] = dataConsumer
+	p.dataConsumers[id] = dataConsumer
+
+	return p.dumpDataConsumer(dataConsumer), nil
+}
+
+func (p *Process) handleProducerRequest(req Request) (interface{}, error) {
+	producer, ok := p.producer(req.Internal)
+	if !ok {
+		return nil, errors.New("Producer not found")
+	}
+
+	switch req.Method {
+	case "producer.close":
+		p.closeProducer(producer)
+		return nil, nil
+
+	case "producer.dump":
+		return p.dumpProducer(producer), nil
+
+	case "producer.getStats":
+		if stats, ok := p.stats[producer.id]; ok {
+			return stats, nil
+		}
+		stats := []mediasoup.H{}
+
+		// Like the real worker, there is no stream until a packet arrives.
+		if producer.packetCount == 0 {
+			return stats, nil
+		}
+		for _, encoding := range producer.rtpParameters.Encodings {
+			stats = append(stats, mediasoup.H{
+				"type":        "inbound-rtp",
+				"timestamp":   now(),
+				"ssrc":        encoding.Ssrc,
+				"rid":         encoding.Rid,
+				"kind":        producer.kind,
+				"mimeType":    mimeType(producer.rtpParameters),
+				"packetCount": producer.packetCount,
+				"byteCount":   producer.byteCount,
+			})
+		}
+		return stats, nil
+
+	case "producer.pause":
+		if !producer.paused {
+			producer.paused = true
+
+			for _, consumer := range producer.consumers {
+				p.Notify(consumer.id, "producerpause", nil)
+			}
+		}
+		return nil, nil
+
+	case "producer.resume":
+		if producer.paused {
+			producer.paused = false
+
+			for _, consumer := range producer.consumers {
+				p.Notify(consumer.id, "producerresume", nil)
+			}
+		}
+		return nil, nil
+
+	case "producer.enableTraceEvent":
+		var data struct {
+			Types []string `json:"types"`
+		}
+		if err := json.Unmarshal(req.Data, &data); err != nil {
+			return nil, err
+		}
+		producer.traceEventTypes = filterTraceEventTypes(data.Types, "rtp", "keyframe", "nack", "pli", "fir")
+
+		return nil, nil
+
+	case "producer.send":
+		p.sendRtp(producer, req.Payload)
+
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown method '%s'", req.Method)
+}
+
+func (p *Process) handleConsumerRequest(req Request) (interface{}, error) {
+	consumer, ok := p.consumers[req.Internal.ConsumerId]
+	if !ok {
+		return nil, errors.New("Consumer not found")
+	}
+
+	switch req.Method {
+	case "consumer.close":
+		p.closeConsumer(consumer)
+		return nil, nil
+
+	case "consumer.dump":
+		return p.dumpConsumer(consumer), nil
+
+	case "consumer.getStats":
+		if stats, ok := p.stats[consumer.id]; ok {
+			return stats, nil
+		}
+		var ssrc uint32
+
+		if len(consumer.rtpParameters.Encodings) > 0 {
+			ssrc = consumer.rtpParameters.Encodings[0].Ssrc
+		}
+		return []mediasoup.H{
+			{
+				"type":        "outbound-rtp",
+				"timestamp":   now(),
+				"ssrc":        ssrc,
+				"kind":        consumer.kind,
+				"mimeType":    mimeType(consumer.rtpParameters),
+				"score":       10,
+				"packetCount": consumer.packetCount,
+				"byteCount":   consumer.byteCount,
+			},
+		}, nil
+
+	case "consumer.pause":
+		consumer.paused = true
+		return nil, nil
+
+	case "consumer.resume":
+		consumer.paused = false
+		return nil, nil
+
+	case "consumer.setPreferredLayers":
+		var layers mediasoup.ConsumerLayers
+		var data struct {
+			SpatialLayer *uint8 `json:"spatialLayer"`
+		}
+
+		if err := json.Unmarshal(req.Data, &layers); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(req.Data, &data); err != nil {
+			return nil, err
+		}
+		if data.SpatialLayer == nil && (consumer.typ == "simulcast" || consumer.typ == "svc") {
+			return nil, mediasoup.NewTypeError("missing spatialLayer")
+		}
+		consumer.preferredLayers = consumer.clampLayers(&layers)
+
+		if consumer.preferredLayers == nil {
+			return nil, nil
+		}
+		return consumer.preferredLayers, nil
+
+	case "consumer.setPriority":
+		var data struct {
+			Priority uint32 `json:"priority"`
+		}
+		if err := json.Unmarshal(req.Data, &data); err != nil {
+			return nil, err
+		}
+		if data.Priority < 1 {
+			return nil, mediasoup.NewTypeError("wrong priority (must be higher than 0)")
+		}
+		consumer.priority = data.Priority
+
+		return mediasoup.H{"priority": consumer.priority}, nil
+
+	case "consumer.requestKeyFrame":
+		return nil, nil
+
+	case "consumer.enableTraceEvent":
+		var data struct {
+			Types []string `json:"types"`
+		}
+		if err := json.Unmarshal(req.Data, &data); err != nil {
+			return nil, err
+		}
+		consumer.traceEventTypes = filterTraceEventTypes(data.Types, "rtp", "keyframe", "nack", "pli", "fir")
+
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown method '%s'", req.Method)
+}
+
+func (p *Process) handleDataProducerRequest(req Request) (interface{}, error) {
+	dataProducer, ok := p.dataProducer(req.Internal)
+	if !ok {
+		return nil, errors.New("DataProducer not found")
+	}
+
+	switch req.Method {
+	case "dataProducer.close":
+		p.closeDataProducer(dataProducer)
+		return nil, nil
+
+	case "dataProducer.dump":
+		return p.dumpDataProducer(dataProducer), nil
+
+	case "dataProducer.getStats":
+		if stats, ok := p.stats[dataProducer.id]; ok {
+			return stats, nil
+		}
+		return []mediasoup.H{
+			{
+				"type":             "data-producer",
+				"timestamp":        now(),
+				"label":            dataProducer.label,
+				"protocol":         dataProducer.protocol,
+				"messagesReceived": dataProducer.messagesReceived,
+				"bytesReceived":    dataProducer.bytesReceived,
+			},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown method '%s'", req.Method)
+}
+
+func (p *Process) handleDataConsumerRequest(req Request) (interface{}, error) {
+	dataConsumer, ok := p.dataConsumers[req.Internal.DataConsumerId]
+	if !ok {
+		return nil, errors.New("DataConsumer not found")
+	}
+
+	switch req.Method {
+	case "dataConsumer.close":
+		p.closeDataConsumer(dataConsumer)
+		return nil, nil
+
+	case "dataConsumer.dump":
+		return p.dumpDataConsumer(dataConsumer), nil
+
+	case "dataConsumer.getStats":
+		if stats, ok := p.stats[dataConsumer.id]; ok {
+			return stats, nil
+		}
+		return []mediasoup.H{
+			{
+				"type":         "data-consumer",
+				"timestamp":    now(),
+				"label":        dataConsumer.label,
+				"protocol":     dataConsumer.protocol,
+				"messagesSent": dataConsumer.messagesSent,
+				"bytesSent":    dataConsumer.bytesSent,
+			},
+		}, nil
+
+	case "dataConsumer.getBufferedAmount":
+		return mediasoup.H{"bufferedAmount": 0}, nil
+
+	case "dataConsumer.setBufferedAmountLowThreshold":
+		var data struct {
+			Threshold uint32 `json:"threshold"`
+		}
+		if err := json.Unmarshal(req.Data, &data); err != nil {
+			return nil, err
+		}
+		dataConsumer.bufferedAmountLowThreshold = data.Threshold
+
+		return nil, nil
+
+	case "dataConsumer.send":
+		var data struct {
+			Ppid *uint32 `json:"ppid"`
+		}
+		if err := json.Unmarshal(req.Data, &data); err != nil || data.Ppid == nil {
+			return nil, mediasoup.NewTypeError("invalid ppid")
+		}
+		dataConsumer.messagesSent++
+		dataConsumer.bytesSent += int64(len(req.Payload))
+
+		// A DirectTransport sends the message to its own DataConsumer.
+		if dataConsumer.transport.kind == "direct" {
+			p.NotifyPayload(dataConsumer.id, "message", mediasoup.H{"ppid": *data.Ppid}, req.Payload)
+		}
+
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown method '%s'", req.Method)
+}
+
+func (p *Process) handleRtpObserverRequest(req Request) (interface{}, error) {
+	rtpObserver, ok := p.rtpObservers[req.Internal.RtpObserverId]
+	if !ok {
+		return nil, errors.New("RtpObserver not found")
+	}
+
+	switch req.Method {
+	case "rtpObserver.close":
+		p.closeRtpObserver(rtpObserver)
+		return nil, nil
+
+	case "rtpObserver.pause":
+		rtpObserver.paused = true
+		return nil, nil
+
+	case "rtpObserver.resume":
+		rtpObserver.paused = false
+		return nil, nil
+
+	case "rtpObserver.addProducer":
+		producer, ok := rtpObserver.router.producers[req.Internal.ProducerId]
+		if !ok {
+			return nil, errors.New("Producer not found")
+		}
+		rtpObserver.producers[producer.id] = producer
+		producer.rtpObservers[rtpObserver.id] = rtpObserver
+
+		return nil, nil
+
+	case "rtpObserver.removeProducer":
+		producer, ok := rtpObserver.producers[req.Internal.ProducerId]
+		if !ok {
+			return nil, errors.New("Producer not found")
+		}
+		delete(rtpObserver.producers, producer.id)
+		delete(producer.rtpObservers, rtpObserver.id)
+
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown method '%s'", req.Method)
+}
+
+// handlePayloadNotification handles a PayloadChannel notification. p.locker
+// must be held.
+func (p *Process) handlePayloadNotification(req Request) {
+	switch req.Method {
+	case "dataProducer.send":
+		dataProducer, ok := p.dataProducer(req.Internal)
+		if !ok {
+			return
+		}
+		dataProducer.messagesReceived++
+		dataProducer.bytesReceived += int64(len(req.Payload))
+
+		var data struct {
+			Ppid int `json:"ppid"`
+		}
+		json.Unmarshal(req.Data, &data)
+
+		for _, dataConsumer := range dataProducer.dataConsumers {
+			dataConsumer.messagesSent++
+			dataConsumer.bytesSent += int64(len(req.Payload))
+
+			// The messages of the other DataConsumers go to their endpoint.
+			if dataConsumer.transport.kind != "direct" {
+				continue
+			}
+			p.NotifyPayload(dataConsumer.id, "message", mediasoup.H{"ppid": data.Ppid}, req.Payload)
+		}
+	}
+}
+
+// sendRtp forwards a packet sent by a Producer to the Consumers living in a
+// DirectTransport.
+func (p *Process) sendRtp(producer *producer, packet []byte) {
+	producer.packetCount++
+	producer.byteCount += int64(len(packet))
+
+	if producer.paused {
+		return
+	}
+
+	for _, consumer := range producer.consumers {
+		if consumer.paused || consumer.transport.kind != "direct" {
+			continue
+		}
+		consumer.packetCount++
+		consumer.byteCount += int64(len(packet))
+
+		p.NotifyPayload(consumer.id, "rtp", nil, consumer.rewriteRtp(packet))
+	}
+}
+
+// rewriteRtp returns a copy of a packet of the Producer with the payload type
+// and the ssrc of the Consumer, like the real worker sends it.
+func (c *consumer) rewriteRtp(packet []byte) []byte {
+	if len(packet) < 12 || len(c.rtpParameters.Encodings) == 0 {
+		return packet
+	}
+	packet = append([]byte(nil), packet...)
+
+	var rtpMapping struct {
+		Codecs []struct {
+			PayloadType       uint8
+			MappedPayloadType uint8
+		}
+	}
+	json.Unmarshal(c.producer.rtpMapping, &rtpMapping)
+
+	payloadType := packet[1] & 0x7f
+
+	for _, codec := range rtpMapping.Codecs {
+		if codec.PayloadType == payloadType {
+			packet[1] = packet[1]&0x80 | codec.MappedPayloadType
+			break
+		}
+	}
+
+	c.rewriteHeaderExtensionIds(packet)
+
+	ssrc := binary.BigEndian.Uint32(packet[8:12])
+	encoding := c.rtpParameters.Encodings[0]
+	isRtx := false
+
+	for _, producerEncoding := range c.producer.rtpParameters.Encodings {
+		if producerEncoding.Rtx != nil && producerEncoding.Rtx.Ssrc == ssrc {
+			isRtx = true
+		}
+	}
+	if isRtx && encoding.Rtx != nil {
+		binary.BigEndian.PutUint32(packet[8:12], encoding.Rtx.Ssrc)
+	} else {
+		binary.BigEndian.PutUint32(packet[8:12], encoding.Ssrc)
+	}
+
+	return packet
+}
+
+// rewriteHeaderExtensionIds gives the one-byte header extensions of a packet
+// of the Producer the ids the Consumer negotiated for their uri.
+func (c *consumer) rewriteHeaderExtensionIds(packet []byte) {
+	if packet[0]&0x10 == 0 {
+		return
+	}
+	offset := 12 + 4*int(packet[0]&0x0f)
+
+	if len(packet) < offset+4 || binary.BigEndian.Uint16(packet[offset:]) != 0xbede {
+		return
+	}
+	end := offset + 4 + 4*int(binary.BigEndian.Uint16(packet[offset+2:]))
+
+	if end > len(packet) {
+		return
+	}
+
+	ids := map[int]int{}
+
+	for _, producerExtension := range c.producer.rtpParameters.HeaderExtensions {
+		for _, consumerExtension := range c.rtpParameters.HeaderExtensions {
+			if producerExtension.Uri == consumerExtension.Uri {
+				ids[producerExtension.Id] = consumerExtension.Id
+			}
+		}
+	}
+
+	for i := offset + 4; i < end; {
+		id := packet[i] >> 4
+
+		// Skip the padding.
+		if id == 0 {
+			i++
+			continue
+		}
+		if id == 15 {
+			break
+		}
+		if newId, ok := ids[int(id)]; ok {
+			packet[i] = uint8(newId)<<4 | packet[i]&0x0f
+		}
+		i += 2 + int(packet[i]&0x0f)
+	}
+}
+
+// producer looks a Producer up in its Router since, like in the real worker,
+// Producer ids are only unique within a Router.
+func (p *Process) producer(internal Internal) (*producer, bool) {
+	router, ok := p.routers[internal.RouterId]
+	if !ok {
+		return nil, false
+	}
+	producer, ok := router.producers[internal.ProducerId]
+
+	return producer, ok
+}
+
+// dataProducer looks a DataProducer up in its Router, see producer.
+func (p *Process) dataProducer(internal Internal) (*dataProducer, bool) {
+	router, ok := p.routers[internal.RouterId]
+	if !ok {
+		return nil, false
+	}
+	dataProducer, ok := router.dataProducers[internal.DataProducerId]
+
+	return dataProducer, ok
+}
+
+func (p *Process) closeTransport(transport *transport) {
+	for _, producer := range transport.producers {
+		p.closeProducer(producer)
+	}
+	for _, consumer := range transport.consumers {
+		p.closeConsumer(consumer)
+	}
+	for _, dataProducer := range transport.dataProducers {
+		p.closeDataProducer(dataProducer)
+	}
+	for _, dataConsumer := range transport.dataConsumers {
+		p.closeDataConsumer(dataConsumer)
+	}
+	delete(transport.router.transports, transport.id)
+	delete(p.transports, transport.id)
+	if transport.webRtcServer != nil {
+		delete(transport.webRtcServer.transports, transport.id)
+	}
+}
+
+func (p *Process) closeProducer(producer *producer) {
+	for _, consumer := range producer.consumers {
+		p.closeConsumer(consumer)
+		p.Notify(consumer.id, "producerclose", nil)
+	}
+	for _, rtpObserver := range producer.rtpObservers {
+		delete(rtpObserver.producers, producer.id)
+	}
+	delete(producer.transport.producers, producer.id)
+	delete(producer.transport.router.producers, producer.id)
+}
+
+func (p *Process) closeConsumer(consumer *consumer) {
+	delete(consumer.producer.consumers, consumer.id)
+	delete(consumer.transport.consumers, consumer.id)
+	delete(p.consumers, consumer.id)
+}
+
+func (p *Process) closeDataProducer(dataProducer *dataProducer) {
+	for _, dataConsumer := range dataProducer.dataConsumers {
+		p.closeDataConsumer(dataConsumer)
+		p.Notify(dataConsumer.id, "dataproducerclose", nil)
+	}
+	delete(dataProducer.transport.dataProducers, dataProducer.id)
+	delete(dataProducer.transport.router.dataProducers, dataProducer.id)
+}
+
+func (p *Process) closeDataConsumer(dataConsumer *dataConsumer) {
+	delete(dataConsumer.dataProducer.dataConsumers, dataConsumer.id)
+	delete(dataConsumer.transport.dataConsumers, dataConsumer.id)
+	delete(p.dataConsumers, dataConsumer.id)
+}
+
+func (p *Process) closeRtpObserver(rtpObserver *rtpObserver) {
+	for _, producer := range rtpObserver.producers {
+		delete(producer.rtpObservers, rtpObserver.id)
+	}
+	delete(rtpObserver.router.rtpObservers, rtpObserver.id)
+	delete(p.rtpObservers, rtpObserver.id)
+}
+
+func (p *Process) dumpWebRtcServer(webRtcServer *webRtcServer) mediasoup.WebRtcServerDump {
+	dump := mediasoup.WebRtcServerDump{
+		Id:                        webRtcServer.id,
+		UdpSockets:                []mediasoup.IpPort{},
+		TcpServers:                []mediasoup.IpPort{},
+		WebRtcTransportIds:        sortedKeys(webRtcServer.transports),
+		LocalIceUsernameFragments: []mediasoup.IceUserNameFragment{},
+		TupleHashes:               []mediasoup.TupleHash{},
+	}
+
+	for _, listenInfo := range webRtcServer.listenInfos {
+		ipPort := mediasoup.IpPort{Ip: listenInfo.Ip, Port: listenInfo.Port}
+
+		if listenInfo.Protocol == mediasoup.TransportProtocol_Udp {
+			dump.UdpSockets = append(dump.UdpSockets, ipPort)
+		} else {
+			dump.TcpServers = append(dump.TcpServers, ipPort)
+		}
+	}
+	for _, id := range dump.WebRtcTransportIds {
+		iceParameters := webRtcServer.transports[id].data["iceParameters"].(mediasoup.IceParameters)
+
+		dump.LocalIceUsernameFragments = append(dump.LocalIceUsernameFragments, mediasoup.IceUserNameFragment{
+			LocalIceUsernameFragment: iceParameters.UsernameFragment,
+			WebRtcTransportId:        id,
+		})
+	}
+
+	return dump
+}
+
+// dumpRouter is not a mediasoup.RouterDump, whose omitempty tags would drop
+// the empty maps the real worker sends.
+func (p *Process) dumpRouter(router *router) mediasoup.H {
+	mapProducerIdConsumerIds := map[string][]string{}
+	mapConsumerIdProducerId := map[string]string{}
+	mapProducerIdObserverIds := map[string][]string{}
+	mapDataProducerIdDataConsumerIds := map[string][]string{}
+	mapDataConsumerIdDataProducerId := map[string]string{}
+
+	for _, transport := range router.transports {
+		for _, producer := range transport.producers {
+			mapProducerIdConsumerIds[producer.id] = producer.consumerIds()
+			mapProducerIdObserverIds[producer.id] = sortedKeys(producer.rtpObservers)
+		}
+		for _, consumer := range transport.consumers {
+			mapConsumerIdProducerId[consumer.id] = consumer.producer.id
+		}
+		for _, dataProducer := range transport.dataProducers {
+			mapDataProducerIdDataConsumerIds[dataProducer.id] = sortedKeys(dataProducer.dataConsumers)
+		}
+		for _, dataConsumer := range transport.dataConsumers {
+			mapDataConsumerIdDataProducerId[dataConsumer.id] = dataConsumer.dataProducer.id
+		}
+	}
+
+	return mediasoup.H{
+		"id":                               router.id,
+		"transportIds":                     sortedKeys(router.transports),
+		"rtpObserverIds":                   sortedKeys(router.rtpObservers),
+		"mapProducerIdConsumerIds":         mapProducerIdConsumerIds,
+		"mapConsumerIdProducerId":          mapConsumerIdProducerId,
+		"mapProducerIdObserverIds":         mapProducerIdObserverIds,
+		"mapDataProducerIdDataConsumerIds": mapDataProducerIdDataConsumerIds,
+		"mapDataConsumerIdDataProducerId":  mapDataConsumerIdDataProducerId,
+	}
+}
+
+func (p *Process) dumpTransport(transport *transport) mediasoup.H {
+	dump := mediasoup.H{
+		"id":                      transport.id,
+		"direct":                  transport.kind == "direct",
+		"producerIds":             sortedKeys(transport.producers),
+		"consumerIds":             sortedKeys(transport.consumers),
+		"dataProducerIds":         sortedKeys(transport.dataProducers),
+		"dataConsumerIds":         sortedKeys(transport.dataConsumers),
+		"traceEventTypes":         strings.Join(transport.traceEventTypes, ","),
+		"recvRtpHeaderExtensions": mediasoup.H{},
+		"rtpListener":             p.rtpListener(transport),
+	}
+	for key, value := range transport.data {
+		dump[key] = value
+	}
+
+	return dump
+}
+
+// rtpListener maps the ssrcs, mids and rids of the Producers of the transport
+// to their ids.
+func (p *Process) rtpListener(transport *transport) mediasoup.RtpListener {
+	listener := mediasoup.RtpListener{
+		SsrcTable: map[string]string{},
+		MidTable:  map[string]string{},
+		RidTable:  map[string]string{},
+	}
+
+	for _, producer := range transport.producers {
+		if mid := producer.rtpParameters.Mid; len(mid) > 0 {
+			listener.MidTable[mid] = producer.id
+		}
+		for _, encoding := range producer.rtpParameters.Encodings {
+			if encoding.Ssrc != 0 {
+				listener.SsrcTable[strconv.FormatUint(uint64(encoding.Ssrc), 10)] = producer.id
+			}
+			if len(encoding.Rid) > 0 {
+				listener.RidTable[encoding.Rid] = producer.id
+			}
+		}
+	}
+
+	return listener
+}
+
+func (p *Process) dumpProducer(producer *producer) mediasoup.H {
+	return mediasoup.H{
+		"id":              producer.id,
+		"kind":            producer.kind,
+		"type":            producer.typ,
+		"rtpParameters":   dumpRtpParameters(producer.rtpParameters),
+		"rtpMapping":      producer.rtpMapping,
+		"paused":          producer.paused,
+		"traceEventTypes": strings.Join(producer.traceEventTypes, ","),
+	}
+}
+
+func (p *Process) dumpConsumer(consumer *consumer) mediasoup.H {
+	return mediasoup.H{
+		"id":                         consumer.id,
+		"producerId":                 consumer.producer.id,
+		"kind":                       consumer.kind,
+		"type":                       consumer.typ,
+		"rtpParameters":              dumpRtpParameters(consumer.rtpParameters),
+		"supportedCodecPayloadTypes": consumer.supportedCodecPayloadTypes(),
+		"consumableRtpEncodings":     consumer.consumableRtpEncodings,
+		"paused":                     consumer.paused,
+		"producerPaused":             consumer.producer.paused,
+		"priority":                   consumer.priority,
+		"traceEventTypes":            strings.Join(consumer.traceEventTypes, ","),
+	}
+}
+
+// dumpRtpParameters adds the empty fields the real worker sends, which the
+// omitempty tags of mediasoup.RtpParameters drop.
+func dumpRtpParameters(rtpParameters mediasoup.RtpParameters) (dump mediasoup.H) {
+	data, _ := json.Marshal(rtpParameters)
+	json.Unmarshal(data, &dump)
+
+	codecs, _ := dump["codecs"].([]interface{})
+
+	for _, codec := range codecs {
+		codec := codec.(map[string]interface{})
+
+		if _, ok := codec["rtcpFeedback"]; !ok {
+			codec["rtcpFeedback"] = []interface{}{}
+		}
+	}
+
+	headerExtensions, _ := dump["headerExtensions"].([]interface{})
+
+	for _, headerExtension := range headerExtensions {
+		headerExtension := headerExtension.(map[string]interface{})
+
+		if _, ok := headerExtension["parameters"]; !ok {
+			headerExtension["parameters"] = mediasoup.H{}
+		}
+	}
+
+	return
+}
+
+func (p *Process) dumpDataProducer(dataProducer *dataProducer) mediasoup.H {
+	dump := mediasoup.H{
+		"id":       dataProducer.id,
+		"type":     dataProducer.typ,
+		"label":    dataProducer.label,
+		"protocol": dataProducer.protocol,
+	}
+	if dataProducer.sctpStreamParameters != nil {
+		dump["sctpStreamParameters"] = dataProducer.sctpStreamParameters
+	}
+
+	return dump
+}
+
+func (p *Process) dumpDataConsumer(dataConsumer *dataConsumer) mediasoup.H {
+	dump := mediasoup.H{
+		"id":                         dataConsumer.id,
+		"dataProducerId":             dataConsumer.dataProducer.id,
+		"type":                       dataConsumer.typ,
+		"label":                      dataConsumer.label,
+		"protocol":                   dataConsumer.protocol,
+		"bufferedAmountLowThreshold": dataConsumer.bufferedAmountLowThreshold,
+	}
+	if dataConsumer.sctpStreamParameters != nil {
+		dump["sctpStreamParameters"] = dataConsumer.sctpStreamParameters
+	}
+
+	return dump
+}
+
+func (p *Process) allocatePort() (int, error) {
+	if p.nextPort > p.rtcMaxPort {
+		return 0, errors.New("no more available ports")
+	}
+	port := p.nextPort
+	p.nextPort++
+
+	return port, nil
+}
+
+func (p *Process) localTuple(listenIp mediasoup.TransportListenIp) (tuple mediasoup.TransportTuple, err error) {
+	if len(listenIp.Ip) == 0 {
+		err = mediasoup.NewTypeError("missing listenIp")
+		return
+	}
+	if err = validateListenIp(listenIp.Ip); err != nil {
+		return
+	}
+	port, err := p.allocatePort()
+	if err != nil {
+		return
+	}
+	tuple.LocalIp = listenIp.AnnouncedIp
+	if len(tuple.LocalIp) == 0 {
+		tuple.LocalIp = listenIp.Ip
+	}
+	tuple.LocalPort = uint16(port)
+	tuple.Protocol = "udp"
+
+	return
+}
+
+// validateListenIp rejects, like the real worker, the invalid IPs and the ones
+// which cannot be bound.
+func validateListenIp(ip string) error {
+	if net.ParseIP(ip) == nil {
+		return mediasoup.NewTypeError("invalid IP '%s'", ip)
+	}
+	conn, err := net.ListenPacket("udp", net.JoinHostPort(ip, "0"))
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+func newIceParameters() mediasoup.IceParameters {
+	return mediasoup.IceParameters{
+		UsernameFragment: randomString(8),
+		Password:         randomString(16),
+		IceLite:          true,
+	}
+}
+
+func newSrtpParameters(cryptoSuite mediasoup.SrtpCryptoSuite) mediasoup.SrtpParameters {
+	if len(cryptoSuite) == 0 {
+		cryptoSuite = mediasoup.AES_CM_128_HMAC_SHA1_80
+	}
+	return mediasoup.SrtpParameters{
+		CryptoSuite: cryptoSuite,
+		KeyBase64:   "ZnQ3eWJraDg0d3ZoYzM5cXN1Y2pnaHU5NWxrZTVv",
+	}
+}
+
+func fingerprint(size int) string {
+	b := make([]byte, size)
+	rand.Read(b)
+
+	parts := make([]string, size)
+	for i, c := range b {
+		parts[i] = strings.ToUpper(hex.EncodeToString([]byte{c}))
+	}
+
+	return strings.Join(parts, ":")
+}
+
+func randomString(size int) string {
+	b := make([]byte, size/2)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func mimeType(rtpParameters mediasoup.RtpParameters) string {
+	if len(rtpParameters.Codecs) > 0 {
+		return rtpParameters.Codecs[0].MimeType
+	}
+	return ""
+}
+
+// filterTraceEventTypes drops the unknown types, which the real worker
+// ignores.
+func filterTraceEventTypes(types []string, known ...string) (result []string) {
+	for _, typ := range types {
+		for _, knownType := range known {
+			if typ == knownType {
+				result = append(result, typ)
+				break
+			}
+		}
+	}
+
+	return
+}
+
+func now() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+
+	switch m := m.(type) {
+	case map[string]*webRtcServer:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*router:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*transport:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*producer:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*consumer:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*dataProducer:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*dataConsumer:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]*rtpObserver:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,542 @@
+// Package workertest provides an in-process fake of the mediasoup-worker
+// binary.
+//
+// The fake speaks the same netstring protocol over fds 3 to 6 as the real
+// worker, keeps an in-memory model of webrtc servers, routers, transports,
+// producers, consumers, data producers, data consumers and rtp observers, and
+// answers the requests of the mediasoup package accordingly. Notifications
+// such as "score", "icestatechange" or "volumes" can be emitted on demand, so
+// the whole object graph can be exercised without the C++ worker:
+//
+//	worker, _ := workertest.NewWorker()
+//	process := workertest.ProcessOf(worker)
+//	process.Notify(transport.Id(), "icestatechange", mediasoup.H{"iceState": "connected"})
+package workertest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/jiyeyuran/mediasoup-go/netstring"
+)
+
+var (
+	lastPid   int32 = 90000
+	processes sync.Map
+)
+
+// Internal identifies the target entity of a request.
+type Internal struct {
+	RouterId       string `json:"routerId,omitempty"`
+	TransportId    string `json:"transportId,omitempty"`
+	ProducerId     string `json:"producerId,omitempty"`
+	ConsumerId     string `json:"consumerId,omitempty"`
+	DataProducerId string `json:"dataProducerId,omitempty"`
+	DataConsumerId string `json:"dataConsumerId,omitempty"`
+	RtpObserverId  string `json:"rtpObserverId,omitempty"`
+	WebRtcServerId string `json:"webRtcServerId,omitempty"`
+}
+
+// Request is a request received from the Channel or the PayloadChannel.
+type Request struct {
+	Id       int64           `json:"id,omitempty"`
+	Method   string          `json:"method,omitempty"`
+	Internal Internal        `json:"internal,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	// Payload is only set for PayloadChannel requests.
+	Payload []byte `json:"-"`
+}
+
+// Handler answers a request. The returned data is sent back as the response
+// data, a returned mediasoup.TypeError is rejected as "TypeError".
+type Handler func(req Request) (data interface{}, err error)
+
+// NewWorker creates a mediasoup.Worker backed by a fake worker process.
+func NewWorker(options ...mediasoup.Option) (*mediasoup.Worker, error) {
+	return mediasoup.NewWorker(append(options, mediasoup.WithSpawner(Spawn))...)
+}
+
+// ProcessOf returns the fake process of the given worker, or nil if the worker
+// was not spawned by Spawn.
+func ProcessOf(worker *mediasoup.Worker) *Process {
+	return Lookup(worker.Pid())
+}
+
+// Lookup returns the running fake process with the given pid.
+func Lookup(pid int) *Process {
+	if value, ok := processes.Load(pid); ok {
+		return value.(*Process)
+	}
+	return nil
+}
+
+// Spawn is a mediasoup.WorkerSpawner starting a fake worker in the current
+// process. bin is ignored, args are validated like the real worker does.
+func Spawn(bin string, args []string, files []*os.File) (mediasoup.WorkerProcess, error) {
+	p, err := start(int(atomic.AddInt32(&lastPid, 1)), args, files)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Serve runs the fake worker as the mediasoup-worker child process of a
+// mediasoup.Worker, over the given fds 3 to 6 and with the pid of the current
+// process. It is used by the workertest/mediasoup-worker command.
+func Serve(args []string, files []*os.File) (*Process, error) {
+	return start(os.Getpid(), args, files)
+}
+
+func start(pid int, args []string, files []*os.File) (*Process, error) {
+	if len(files) != 4 {
+		return nil, fmt.Errorf("workertest: expected 4 files, got %d", len(files))
+	}
+
+	var conns []net.Conn
+
+	for _, file := range files {
+		conn, err := net.FileConn(file)
+		file.Close()
+		if err != nil {
+			for _, conn := range conns {
+				conn.Close()
+			}
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+
+	p := &Process{
+		pid:           pid,
+		channelIn:     conns[0],
+		channelOut:    conns[1],
+		payloadIn:     conns[2],
+		payloadOut:    conns[3],
+		handlers:      make(map[string]Handler),
+		stats:         make(map[string]interface{}),
+		exitCh:        make(chan struct{}),
+		webRtcServers: make(map[string]*webRtcServer),
+		routers:       make(map[string]*router),
+		transports:    make(map[string]*transport),
+		consumers:     make(map[string]*consumer),
+		dataConsumers: make(map[string]*dataConsumer),
+		rtpObservers:  make(map[string]*rtpObserver),
+	}
+
+	if err := p.parseArgs(args); err != nil {
+		// The real worker exits with code 42 on wrong settings.
+		p.exit(&mediasoup.WorkerExitError{Code: 42, Signal: os.Kill})
+		return p, nil
+	}
+
+	processes.Store(p.pid, p)
+
+	go p.runChannelLoop()
+	go p.runPayloadChannelLoop()
+
+	p.Notify(strconv.Itoa(p.pid), "running", nil)
+
+	return p, nil
+}
+
+// Process is a fake mediasoup-worker process.
+type Process struct {
+	pid        int
+	channelIn  net.Conn
+	channelOut net.Conn
+	payloadIn  net.Conn
+	payloadOut net.Conn
+
+	channelLocker sync.Mutex
+	payloadLocker sync.Mutex
+
+	exitOnce sync.Once
+	exitCh   chan struct{}
+	exitErr  error
+
+	// locker guards the settings, the handlers and the in-memory model.
+	locker        sync.Mutex
+	logLevel      string
+	logTags       []string
+	rtcMinPort    int
+	rtcMaxPort    int
+	nextPort      int
+	handlers      map[string]Handler
+	stats         map[string]interface{}
+	usage         mediasoup.WorkerResourceUsage
+	webRtcServers map[string]*webRtcServer
+	routers       map[string]*router
+	transports    map[string]*transport
+	consumers     map[string]*consumer
+	dataConsumers map[string]*dataConsumer
+	rtpObservers  map[string]*rtpObserver
+	// lastSeq orders the Consumers by creation.
+	lastSeq int
+}
+
+// Pid returns the fake process identifier.
+func (p *Process) Pid() int {
+	return p.pid
+}
+
+// Signal terminates the fake process. The process exits with code 0 on
+// SIGTERM, like the real worker, and is killed by any other signal.
+func (p *Process) Signal(sig os.Signal) error {
+	select {
+	case <-p.exitCh:
+		return errors.New("os: process already finished")
+	default:
+	}
+
+	if sig == syscall.SIGTERM {
+		p.exit(nil)
+	} else {
+		p.exit(&mediasoup.WorkerExitError{Signal: sig})
+	}
+
+	return nil
+}
+
+// Wait waits for the fake process to exit.
+func (p *Process) Wait() error {
+	<-p.exitCh
+
+	return p.exitErr
+}
+
+// Kill simulates a crash of the worker, the Worker emits "died".
+func (p *Process) Kill() {
+	p.Signal(syscall.SIGKILL)
+}
+
+// Exit makes the fake process exit with the given code.
+func (p *Process) Exit(code int) {
+	if code == 0 {
+		p.exit(nil)
+	} else {
+		p.exit(&mediasoup.WorkerExitError{Code: code, Signal: os.Kill})
+	}
+}
+
+// Exited returns a channel which is closed once the process exited.
+func (p *Process) Exited() <-chan struct{} {
+	return p.exitCh
+}
+
+// Notify sends a Channel notification, data is encoded as JSON.
+func (p *Process) Notify(targetId, event string, data interface{}) error {
+	msg := mediasoup.H{"targetId": targetId, "event": event}
+
+	if data != nil {
+		msg["data"] = data
+	}
+
+	return p.writeChannel(msg)
+}
+
+// NotifyPayload sends a PayloadChannel notification carrying payload.
+func (p *Process) NotifyPayload(targetId, event string, data interface{}, payload []byte) error {
+	msg := mediasoup.H{"targetId": targetId, "event": event}
+
+	if data != nil {
+		msg["data"] = data
+	}
+	if payload == nil {
+		payload = []byte{}
+	}
+
+	return p.writePayloadChannel(msg, payload)
+}
+
+// SetHandler overrides the answer to the given method, or the handling of the
+// given notification, whose answer is ignored. A nil handler restores the
+// default behavior.
+func (p *Process) SetHandler(method string, handler Handler) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	if handler == nil {
+		delete(p.handlers, method)
+	} else {
+		p.handlers[method] = handler
+	}
+}
+
+// SetStats sets the answer to "getStats" requests of the entity with the
+// given id.
+func (p *Process) SetStats(id string, stats interface{}) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	if stats == nil {
+		delete(p.stats, id)
+	} else {
+		p.stats[id] = stats
+	}
+}
+
+// SetResourceUsage sets the answer to "worker.getResourceUsage" requests.
+func (p *Process) SetResourceUsage(usage mediasoup.WorkerResourceUsage) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	p.usage = usage
+}
+
+func (p *Process) exit(err error) {
+	p.exitOnce.Do(func() {
+		processes.Delete(p.pid)
+
+		p.exitErr = err
+		p.channelIn.Close()
+		p.channelOut.Close()
+		p.payloadIn.Close()
+		p.payloadOut.Close()
+
+		close(p.exitCh)
+	})
+}
+
+func (p *Process) parseArgs(args []string) (err error) {
+	p.rtcMinPort, p.rtcMaxPort = 10000, 59999
+
+	var certFile, keyFile string
+
+	for _, arg := range args {
+		kv := strings.SplitN(strings.TrimPrefix(arg, "--"), "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("unknown argument %q", arg)
+		}
+		key, value := kv[0], kv[1]
+
+		switch key {
+		case "logLevel":
+			p.logLevel = value
+		case "logTags":
+			p.logTags = append(p.logTags, value)
+		case "rtcMinPort":
+			p.rtcMinPort, err = strconv.Atoi(value)
+		case "rtcMaxPort":
+			p.rtcMaxPort, err = strconv.Atoi(value)
+		case "dtlsCertificateFile":
+			certFile = value
+		case "dtlsPrivateKeyFile":
+			keyFile = value
+		default:
+			err = fmt.Errorf("unknown argument %q", arg)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	if err = validateLogLevel(p.logLevel); err != nil {
+		return
+	}
+	if err = validateLogTags(p.logTags); err != nil {
+		return
+	}
+	if p.rtcMinPort > p.rtcMaxPort {
+		return errors.New("rtcMinPort cannot be higher than rtcMaxPort")
+	}
+	if len(certFile) > 0 {
+		if _, err = ioutil.ReadFile(certFile); err != nil {
+			return
+		}
+		if _, err = ioutil.ReadFile(keyFile); err != nil {
+			return
+		}
+	}
+	p.nextPort = p.rtcMinPort
+
+	return
+}
+
+func (p *Process) runChannelLoop() {
+	p.readLoop(p.channelIn, func(nsPayload []byte) {
+		var req Request
+
+		if err := json.Unmarshal(nsPayload, &req); err != nil {
+			return
+		}
+
+		p.respond(req, p.writeChannel)
+	})
+
+	// Like the real worker, exit once the Channel is closed.
+	p.exit(nil)
+}
+
+func (p *Process) runPayloadChannelLoop() {
+	// Each message is followed by its payload.
+	var ongoing *Request
+
+	p.readLoop(p.payloadIn, func(nsPayload []byte) {
+		if ongoing == nil {
+			var msg struct {
+				Request
+				Event string `json:"event,omitempty"`
+			}
+			if err := json.Unmarshal(nsPayload, &msg); err != nil {
+				return
+			}
+			req := msg.Request
+			if len(req.Method) == 0 {
+				req.Method = msg.Event
+			}
+			ongoing = &req
+			return
+		}
+
+		req := *ongoing
+		req.Payload = nsPayload
+		ongoing = nil
+
+		if req.Id == 0 {
+			p.locker.Lock()
+			handler, ok := p.handlers[req.Method]
+			p.locker.Unlock()
+
+			if ok {
+				handler(req)
+				return
+			}
+
+			p.locker.Lock()
+			p.handlePayloadNotification(req)
+			p.locker.Unlock()
+			return
+		}
+
+		p.respond(req, func(msg interface{}) error {
+			return p.writePayloadChannel(msg, nil)
+		})
+	})
+}
+
+func (p *Process) readLoop(conn net.Conn, process func(nsPayload []byte)) {
+	decoder := netstring.NewDecoder()
+	buf := make([]byte, 65536)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case nsPayload := <-decoder.Result():
+				process(nsPayload)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			break
+		}
+		decoder.Feed(buf[:n])
+	}
+
+	close(done)
+}
+
+func (p *Process) respond(req Request, write func(msg interface{}) error) {
+	p.locker.Lock()
+	handler, ok := p.handlers[req.Method]
+	p.locker.Unlock()
+
+	var data interface{}
+	var err error
+
+	if ok {
+		data, err = handler(req)
+	} else {
+		p.locker.Lock()
+		data, err = p.handleRequest(req)
+		p.locker.Unlock()
+	}
+
+	if err != nil {
+		errorName := "Error"
+
+		if _, ok := err.(mediasoup.TypeError); ok {
+			errorName = "TypeError"
+		}
+		write(mediasoup.H{"id": req.Id, "error": errorName, "reason": err.Error()})
+
+		return
+	}
+
+	msg := mediasoup.H{"id": req.Id, "accepted": true}
+
+	if data != nil {
+		msg["data"] = data
+	}
+
+	write(msg)
+}
+
+func (p *Process) writeChannel(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	p.channelLocker.Lock()
+	defer p.channelLocker.Unlock()
+
+	_, err = p.channelOut.Write(netstring.Encode(data))
+
+	return err
+}
+
+func (p *Process) writePayloadChannel(msg interface{}, payload []byte) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	p.payloadLocker.Lock()
+	defer p.payloadLocker.Unlock()
+
+	if _, err = p.payloadOut.Write(netstring.Encode(data)); err != nil {
+		return err
+	}
+	if payload != nil {
+		_, err = p.payloadOut.Write(netstring.Encode(payload))
+	}
+
+	return err
+}
+
+func validateLogLevel(logLevel string) error {
+	switch logLevel {
+	case "", "debug", "warn", "error", "none":
+		return nil
+	}
+	return mediasoup.NewTypeError("invalid logLevel: %s", logLevel)
+}
+
+func validateLogTags(logTags []string) error {
+	for _, tag := range logTags {
+		switch tag {
+		case "info", "ice", "dtls", "rtp", "srtp", "rtcp", "rtx", "bwe", "score",
+			"simulcast", "svc", "sctp", "message":
+		default:
+			return mediasoup.NewTypeError("invalid logTag: %s", tag)
+		}
+	}
+	return nil
+}
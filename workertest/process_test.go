@@ -0,0 +1,814 @@
+package workertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createWorker(t *testing.T) (*mediasoup.Worker, *Process) {
+	worker, err := NewWorker()
+	require.NoError(t, err)
+
+	process := ProcessOf(worker)
+	require.NotNil(t, process)
+
+	return worker, process
+}
+
+func createRouter(t *testing.T, worker *mediasoup.Worker) *mediasoup.Router {
+	router, err := worker.CreateRouter(mediasoup.RouterOptions{MediaCodecs: MediaCodecs()})
+	require.NoError(t, err)
+
+	return router
+}
+
+func createWebRtcTransport(t *testing.T, router *mediasoup.Router) *mediasoup.WebRtcTransport {
+	transport, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1", AnnouncedIp: "9.9.9.1"}},
+		EnableTcp: true,
+	})
+	require.NoError(t, err)
+
+	return transport
+}
+
+func TestNewWorker_Succeeds(t *testing.T) {
+	worker, process := createWorker(t)
+
+	assert.Equal(t, process.Pid(), worker.Pid())
+	assert.False(t, worker.Closed())
+
+	dump, err := worker.Dump()
+	require.NoError(t, err)
+	assert.Equal(t, worker.Pid(), dump.Pid)
+
+	worker.Close()
+	assert.True(t, worker.Closed())
+
+	select {
+	case <-process.Exited():
+	case <-time.After(time.Second):
+		t.Fatal("process did not exit")
+	}
+	assert.Nil(t, Lookup(worker.Pid()))
+}
+
+func TestNewWorker_TypeError(t *testing.T) {
+	_, err := NewWorker(mediasoup.WithLogLevel("chicken"))
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+
+	_, err = NewWorker(mediasoup.WithRtcMinPort(1000), mediasoup.WithRtcMaxPort(999))
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+}
+
+func TestWorkerUpdateSettings(t *testing.T) {
+	worker, _ := createWorker(t)
+	defer worker.Close()
+
+	assert.NoError(t, worker.UpdateSettings(mediasoup.WorkerUpdateableSettings{LogLevel: "debug"}))
+
+	err := worker.UpdateSettings(mediasoup.WorkerUpdateableSettings{LogLevel: "chicken"})
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+}
+
+func TestWorkerEmitsDied(t *testing.T) {
+	worker, process := createWorker(t)
+
+	died := make(chan error, 1)
+	worker.On("died", func(err error) { died <- err })
+
+	process.Kill()
+
+	select {
+	case err := <-died:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("worker did not emit died")
+	}
+	assert.True(t, worker.Closed())
+}
+
+func TestRouterDump(t *testing.T) {
+	worker, _ := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+	transport := createWebRtcTransport(t, router)
+
+	audioProducer, err := transport.Produce(AudioProducerOptions())
+	require.NoError(t, err)
+
+	consumer, err := transport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      audioProducer.Id(),
+		RtpCapabilities: DeviceRtpCapabilities(),
+	})
+	require.NoError(t, err)
+
+	workerDump, err := worker.Dump()
+	require.NoError(t, err)
+	assert.Equal(t, []string{router.Id()}, workerDump.RouterIds)
+
+	dump, err := router.Dump()
+	require.NoError(t, err)
+	assert.Equal(t, router.Id(), dump.Id)
+	assert.Equal(t, []string{transport.Id()}, dump.TransportIds)
+	assert.Equal(t, []string{consumer.Id()}, dump.MapProducerIdConsumerIds[audioProducer.Id()])
+	assert.Equal(t, audioProducer.Id(), dump.MapConsumerIdProducerId[consumer.Id()])
+
+	router.Close()
+
+	workerDump, err = worker.Dump()
+	require.NoError(t, err)
+	assert.Empty(t, workerDump.RouterIds)
+}
+
+func TestWebRtcTransport(t *testing.T) {
+	worker, process := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+	transport := createWebRtcTransport(t, router)
+
+	assert.Equal(t, "controlled", transport.IceRole())
+	assert.Len(t, transport.IceCandidates(), 2)
+	assert.Equal(t, "9.9.9.1", transport.IceCandidates()[0].Ip)
+	assert.EqualValues(t, "new", transport.IceState())
+	assert.EqualValues(t, "auto", transport.DtlsParameters().Role)
+
+	err := transport.Connect(mediasoup.TransportConnectOptions{
+		DtlsParameters: &mediasoup.DtlsParameters{
+			Role:         mediasoup.DtlsRole_Server,
+			Fingerprints: []mediasoup.DtlsFingerprint{{Algorithm: "sha-1", Value: "82:5A"}},
+		},
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, "client", transport.DtlsParameters().Role)
+
+	iceParameters := transport.IceParameters()
+	newIceParameters, err := transport.RestartIce()
+	require.NoError(t, err)
+	assert.NotEqual(t, iceParameters.UsernameFragment, newIceParameters.UsernameFragment)
+
+	iceStates := make(chan mediasoup.IceState, 1)
+	transport.On("icestatechange", func(iceState mediasoup.IceState) { iceStates <- iceState })
+
+	require.NoError(t, process.Notify(transport.Id(), "icestatechange", mediasoup.H{"iceState": "completed"}))
+
+	select {
+	case iceState := <-iceStates:
+		assert.EqualValues(t, "completed", iceState)
+	case <-time.After(time.Second):
+		t.Fatal("icestatechange not emitted")
+	}
+
+	stats, err := transport.GetStats()
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, "webrtc-transport", stats[0].Type)
+	assert.Equal(t, transport.Id(), stats[0].TransportId)
+}
+
+func TestProducerAndConsumer(t *testing.T) {
+	worker, process := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+	transport1 := createWebRtcTransport(t, router)
+	transport2 := createWebRtcTransport(t, router)
+
+	videoProducer, err := transport1.Produce(VideoProducerOptions())
+	require.NoError(t, err)
+	assert.EqualValues(t, "simulcast", videoProducer.Type())
+
+	consumer, err := transport2.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      videoProducer.Id(),
+		RtpCapabilities: DeviceRtpCapabilities(),
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, "simulcast", consumer.Type())
+	assert.EqualValues(t, 10, consumer.Score().Score)
+
+	require.NoError(t, consumer.SetPreferredLayers(mediasoup.ConsumerLayers{SpatialLayer: 1, TemporalLayer: 5}))
+	assert.EqualValues(t, 1, consumer.PreferredLayers().SpatialLayer)
+	assert.EqualValues(t, 2, consumer.PreferredLayers().TemporalLayer)
+
+	require.NoError(t, consumer.SetPriority(2))
+	assert.EqualValues(t, 2, consumer.Priority())
+	assert.IsType(t, mediasoup.NewTypeError(""), consumer.SetPriority(0))
+
+	producerPaused := make(chan struct{}, 1)
+	consumer.On("producerpause", func() { producerPaused <- struct{}{} })
+
+	require.NoError(t, videoProducer.Pause())
+
+	select {
+	case <-producerPaused:
+		assert.True(t, consumer.ProducerPaused())
+	case <-time.After(time.Second):
+		t.Fatal("producerpause not emitted")
+	}
+
+	scores := make(chan []mediasoup.ProducerScore, 1)
+	videoProducer.On("score", func(score []mediasoup.ProducerScore) { scores <- score })
+
+	require.NoError(t, process.Notify(videoProducer.Id(), "score", []mediasoup.ProducerScore{
+		{Ssrc: 22222222, Score: 9},
+	}))
+
+	select {
+	case score := <-scores:
+		assert.EqualValues(t, 9, score[0].Score)
+	case <-time.After(time.Second):
+		t.Fatal("score not emitted")
+	}
+
+	producerClosed := make(chan struct{}, 1)
+	consumer.On("producerclose", func() { producerClosed <- struct{}{} })
+
+	videoProducer.Close()
+
+	select {
+	case <-producerClosed:
+		assert.True(t, consumer.Closed())
+	case <-time.After(time.Second):
+		t.Fatal("producerclose not emitted")
+	}
+}
+
+func TestDirectTransport(t *testing.T) {
+	worker, _ := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+	sendTransport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+	recvTransport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+
+	producer, err := sendTransport.Produce(AudioProducerOptions())
+	require.NoError(t, err)
+
+	consumer, err := recvTransport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      producer.Id(),
+		RtpCapabilities: DeviceRtpCapabilities(),
+	})
+	require.NoError(t, err)
+
+	packets := make(chan []byte, 1)
+	consumer.On("rtp", func(packet []byte) { packets <- packet })
+
+	require.NoError(t, producer.Send([]byte{0x80, 0x6f, 0x00, 0x01}))
+
+	select {
+	case packet := <-packets:
+		assert.Equal(t, []byte{0x80, 0x6f, 0x00, 0x01}, packet)
+	case <-time.After(time.Second):
+		t.Fatal("rtp not emitted")
+	}
+
+	dataProducer, err := sendTransport.ProduceData(mediasoup.DataProducerOptions{Label: "chat"})
+	require.NoError(t, err)
+
+	dataConsumer, err := recvTransport.ConsumeData(mediasoup.DataConsumerOptions{
+		DataProducerId: dataProducer.Id(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "chat", dataConsumer.Label())
+
+	messages := make(chan []byte, 1)
+	dataConsumer.On("message", func(payload []byte, ppid int) { messages <- payload })
+
+	require.NoError(t, dataProducer.SendText("hello"))
+
+	select {
+	case message := <-messages:
+		assert.Equal(t, "hello", string(message))
+	case <-time.After(time.Second):
+		t.Fatal("message not emitted")
+	}
+
+	stats, err := dataProducer.GetStats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, stats[0].MessagesReceived)
+}
+
+func TestDirectTransport_Rtcp(t *testing.T) {
+	worker, process := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+	transport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+
+	sent := make(chan []byte, 1)
+	process.SetHandler("transport.sendRtcp", func(req Request) (interface{}, error) {
+		sent <- req.Payload
+		return nil, nil
+	})
+
+	require.NoError(t, transport.SendRtcpPackets(
+		&rtcp.PictureLossIndication{SenderSSRC: 1, MediaSSRC: 2},
+		&rtcp.ReceiverEstimatedMaximumBitrate{Bitrate: 500000, SSRCs: []uint32{2}},
+	))
+
+	select {
+	case payload := <-sent:
+		packets, err := rtcp.Unmarshal(payload)
+		require.NoError(t, err)
+		require.Len(t, packets, 2)
+		assert.IsType(t, &rtcp.PictureLossIndication{}, packets[0])
+		assert.IsType(t, &rtcp.ReceiverEstimatedMaximumBitrate{}, packets[1])
+	case <-time.After(time.Second):
+		t.Fatal("rtcp not sent")
+	}
+
+	received := make(chan []rtcp.Packet, 1)
+	transport.OnRtcpPackets(func(packets []rtcp.Packet) { received <- packets })
+
+	// Invalid packets are dropped.
+	require.NoError(t, process.NotifyPayload(transport.Id(), "rtcp", nil, []byte{0x80}))
+
+	compound, err := rtcp.Marshal([]rtcp.Packet{
+		&rtcp.SenderReport{SSRC: 2, NTPTime: 1 << 32, RTPTime: 960, PacketCount: 10, OctetCount: 1000},
+		&rtcp.ReceiverReport{SSRC: 1, Reports: []rtcp.ReceptionReport{{SSRC: 2, FractionLost: 64}}},
+		&rtcp.FullIntraRequest{SenderSSRC: 1, MediaSSRC: 2, FIR: []rtcp.FIREntry{{SSRC: 2, SequenceNumber: 1}}},
+		&rtcp.TransportLayerNack{SenderSSRC: 1, MediaSSRC: 2, Nacks: rtcp.NackPairsFromSequenceNumbers([]uint16{10, 12})},
+		&rtcp.TransportLayerCC{
+			// TransportLayerCC doesn't compute its header.
+			Header: rtcp.Header{
+				Padding: true,
+				Count:   rtcp.FormatTCC,
+				Type:    rtcp.TypeTransportSpecificFeedback,
+				Length:  5,
+			},
+			SenderSSRC:         1,
+			MediaSSRC:          2,
+			BaseSequenceNumber: 100,
+			PacketStatusCount:  1,
+			PacketChunks: []rtcp.PacketStatusChunk{
+				&rtcp.RunLengthChunk{PacketStatusSymbol: rtcp.TypeTCCPacketReceivedSmallDelta, RunLength: 1},
+			},
+			RecvDeltas: []*rtcp.RecvDelta{{Type: rtcp.TypeTCCPacketReceivedSmallDelta, Delta: 250}},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, process.NotifyPayload(transport.Id(), "rtcp", nil, compound))
+
+	select {
+	case packets := <-received:
+		require.Len(t, packets, 5)
+		assert.Equal(t, uint32(960), packets[0].(*rtcp.SenderReport).RTPTime)
+		assert.Equal(t, uint8(64), packets[1].(*rtcp.ReceiverReport).Reports[0].FractionLost)
+		assert.Equal(t, uint32(2), packets[2].(*rtcp.FullIntraRequest).FIR[0].SSRC)
+		assert.Equal(t, []uint16{10, 12}, packets[3].(*rtcp.TransportLayerNack).Nacks[0].PacketList())
+		assert.Equal(t, uint16(100), packets[4].(*rtcp.TransportLayerCC).BaseSequenceNumber)
+	case <-time.After(time.Second):
+		t.Fatal("rtcp not emitted")
+	}
+}
+
+func TestConsumer_RtpPacket(t *testing.T) {
+	worker, _ := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+	sendTransport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+	recvTransport, err := router.CreateDirectTransport()
+	require.NoError(t, err)
+
+	producer, err := sendTransport.Produce(AudioProducerOptions())
+	require.NoError(t, err)
+
+	consumer, err := recvTransport.Consume(mediasoup.ConsumerOptions{
+		ProducerId:      producer.Id(),
+		RtpCapabilities: DeviceRtpCapabilities(),
+	})
+	require.NoError(t, err)
+
+	packets := make(chan *rtp.Packet, 2)
+	consumer.OnRtpPacket(func(packet *rtp.Packet) { packets <- packet })
+
+	// Invalid packets are dropped.
+	require.NoError(t, producer.Send([]byte{0x80}))
+
+	packet := &rtp.Packet{
+		Header:  rtp.Header{Version: 2, PayloadType: 111, SequenceNumber: 7, SSRC: 11111111},
+		Payload: []byte{0xfc},
+	}
+	ids := producer.HeaderExtensionIds()
+	require.NoError(t, ids.Set(packet, mediasoup.RtpHeaderExtensionUri_AudioLevel, []byte{0x9e}))
+	require.NoError(t, producer.SendPacket(packet))
+
+	select {
+	case received := <-packets:
+		// The packet comes with the payload type, ssrc and header extension
+		// ids of the Consumer.
+		assert.EqualValues(t, 7, received.SequenceNumber)
+		assert.EqualValues(t, consumer.RtpParameters().Codecs[0].PayloadType, received.PayloadType)
+		assert.EqualValues(t, consumer.RtpParameters().Encodings[0].Ssrc, received.SSRC)
+		assert.Equal(t, []byte{0xfc}, received.Payload)
+		assert.Equal(t, []byte{0x9e}, consumer.HeaderExtensionIds().Get(received, mediasoup.RtpHeaderExtensionUri_AudioLevel))
+	case <-time.After(time.Second):
+		t.Fatal("rtp not emitted")
+	}
+}
+
+func TestAudioLevelObserver(t *testing.T) {
+	worker, process := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+	transport := createWebRtcTransport(t, router)
+	producer, err := transport.Produce(AudioProducerOptions())
+	require.NoError(t, err)
+
+	observer, err := router.CreateAudioLevelObserver()
+	require.NoError(t, err)
+	observer.AddProducer(producer.Id())
+
+	volumes := make(chan []mediasoup.AudioLevelObserverVolume, 1)
+	observer.On("volumes", func(v []mediasoup.AudioLevelObserverVolume) { volumes <- v })
+
+	require.NoError(t, process.Notify(observer.Id(), "volumes", []mediasoup.H{
+		{"producerId": producer.Id(), "volume": -50},
+	}))
+
+	select {
+	case v := <-volumes:
+		require.Len(t, v, 1)
+		assert.Equal(t, producer, v[0].Producer)
+		assert.Equal(t, -50, v[0].Volume)
+	case <-time.After(time.Second):
+		t.Fatal("volumes not emitted")
+	}
+
+	dump, err := router.Dump()
+	require.NoError(t, err)
+	assert.Equal(t, []string{observer.Id()}, dump.MapProducerIdObserverIds[producer.Id()])
+}
+
+func TestActiveSpeakerObserver(t *testing.T) {
+	worker, process := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+	transport := createWebRtcTransport(t, router)
+	producer, err := transport.Produce(AudioProducerOptions())
+	require.NoError(t, err)
+
+	rtpObserver, err := router.CreateActiveSpeakerObserver()
+	require.NoError(t, err)
+	rtpObserver.AddProducer(producer.Id())
+
+	observer := rtpObserver.(*mediasoup.ActiveSpeakerObserver)
+	activities := make(chan mediasoup.ActiveSpeakerObserverActivity, 2)
+	observer.OnDominantSpeaker(func(activity mediasoup.ActiveSpeakerObserverActivity) {
+		activities <- activity
+	})
+
+	// Unknown producers are ignored.
+	require.NoError(t, process.Notify(observer.Id(), "dominantspeaker", mediasoup.H{"producerId": "foo"}))
+	require.NoError(t, process.Notify(observer.Id(), "dominantspeaker", mediasoup.H{"producerId": producer.Id()}))
+
+	select {
+	case activity := <-activities:
+		assert.Equal(t, producer, activity.Producer)
+	case <-time.After(time.Second):
+		t.Fatal("dominantspeaker not emitted")
+	}
+
+	observer.Pause()
+	assert.True(t, observer.Paused())
+	observer.Resume()
+	assert.False(t, observer.Paused())
+
+	observer.RemoveProducer(producer.Id())
+	dump, err := router.Dump()
+	require.NoError(t, err)
+	assert.Equal(t, []string{observer.Id()}, dump.RtpObserverIds)
+	assert.Empty(t, dump.MapProducerIdObserverIds[producer.Id()])
+}
+
+func TestSetHandler(t *testing.T) {
+	worker, process := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+
+	process.SetHandler("router.createWebRtcTransport", func(req Request) (interface{}, error) {
+		return nil, errors.New("no ports")
+	})
+
+	_, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+	})
+	assert.EqualError(t, err, "no ports")
+
+	process.SetHandler("router.createWebRtcTransport", nil)
+
+	_, err = router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+	})
+	assert.NoError(t, err)
+}
+
+func TestActiveSpeakerObserver_OldWorker(t *testing.T) {
+	worker, process := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+
+	// Workers older than 3.8.0 don't know the method.
+	process.SetHandler("router.createActiveSpeakerObserver", func(req Request) (interface{}, error) {
+		return nil, fmt.Errorf("unknown method '%s'", req.Method)
+	})
+
+	_, err := router.CreateActiveSpeakerObserver()
+	assert.IsType(t, mediasoup.UnsupportedError{}, err)
+	assert.Contains(t, err.Error(), "requires mediasoup-worker 3.8.0")
+}
+
+func TestCreateWebRtcServer_OldWorker(t *testing.T) {
+	worker, process := createWorker(t)
+	defer worker.Close()
+
+	// Workers older than 3.10.0 don't know the method.
+	process.SetHandler("worker.createWebRtcServer", func(req Request) (interface{}, error) {
+		return nil, fmt.Errorf("unknown method '%s'", req.Method)
+	})
+
+	_, err := worker.CreateWebRtcServer(mediasoup.WebRtcServerOptions{
+		ListenInfos: []mediasoup.WebRtcServerListenInfo{
+			{Protocol: mediasoup.TransportProtocol_Udp, Ip: "127.0.0.1", Port: 44550},
+		},
+	})
+	assert.IsType(t, mediasoup.UnsupportedError{}, err)
+	assert.Contains(t, err.Error(), "requires mediasoup-worker 3.10.0")
+	assert.Empty(t, worker.WebRtcServers())
+}
+
+func TestSetStats(t *testing.T) {
+	worker, process := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+	transport := createWebRtcTransport(t, router)
+
+	process.SetStats(transport.Id(), []mediasoup.TransportStat{
+		{Type: "webrtc-transport", TransportId: transport.Id(), BytesReceived: 1000},
+	})
+
+	stats, err := transport.GetStats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1000, stats[0].BytesReceived)
+
+	process.SetResourceUsage(mediasoup.WorkerResourceUsage{RU_Utime: 100})
+
+	usage, err := worker.GetResourceUsage()
+	require.NoError(t, err)
+	assert.EqualValues(t, 100, usage.RU_Utime)
+}
+
+func TestPipeToRouter(t *testing.T) {
+	// Both Routers live in the same worker, where the pipe Producer has the id
+	// of its Producer.
+	worker, _ := createWorker(t)
+	defer worker.Close()
+
+	router1 := createRouter(t, worker)
+	router2 := createRouter(t, worker)
+
+	transport, err := router1.CreateDirectTransport()
+	require.NoError(t, err)
+	producer, err := transport.Produce(AudioProducerOptions())
+	require.NoError(t, err)
+
+	options := mediasoup.PipeToRouterOptions{
+		ProducerId: producer.Id(),
+		Router:     router2,
+	}
+
+	result, err := router1.PipeToRouter(options)
+	require.NoError(t, err)
+
+	// Piping twice returns the same result through the same PipeTransport pair.
+	again, err := router1.PipeToRouter(options)
+	require.NoError(t, err)
+	assert.Same(t, result, again)
+
+	dump, err := router1.Dump()
+	require.NoError(t, err)
+	assert.Len(t, dump.TransportIds, 2)
+
+	piped := router1.PipedProducers()
+	require.Len(t, piped, 1)
+	assert.Equal(t, producer.Id(), piped[0].ProducerId)
+	assert.Same(t, result, piped[0].Result)
+
+	// Un-piping closes the idle PipeTransport pair.
+	require.NoError(t, router1.UnpipeFromRouter(mediasoup.UnpipeFromRouterOptions{
+		ProducerId: producer.Id(),
+		Router:     router2,
+	}))
+	assert.True(t, result.PipeConsumer.Closed())
+	assert.True(t, result.PipeProducer.Closed())
+	assert.Empty(t, router1.PipedProducers())
+
+	dump, err = router1.Dump()
+	require.NoError(t, err)
+	assert.Len(t, dump.TransportIds, 1)
+	dump, err = router2.Dump()
+	require.NoError(t, err)
+	assert.Empty(t, dump.TransportIds)
+
+	err = router1.UnpipeFromRouter(mediasoup.UnpipeFromRouterOptions{
+		ProducerId: producer.Id(),
+		Router:     router2,
+	})
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+
+	// Closing the Producer closes the idle PipeTransport pair too.
+	result, err = router1.PipeToRouter(options)
+	require.NoError(t, err)
+
+	producer.Close()
+
+	assert.Eventually(t, func() bool {
+		dump, _ := router2.Dump()
+		return len(dump.TransportIds) == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.True(t, result.PipeProducer.Closed())
+	assert.Empty(t, router1.PipedProducers())
+}
+
+func TestSctpStreamIds(t *testing.T) {
+	worker, process := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+
+	numSctpStreams := mediasoup.NumSctpStreams{OS: 8, MIS: 8}
+
+	transport1, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps:      []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+		EnableSctp:     true,
+		NumSctpStreams: numSctpStreams,
+	})
+	require.NoError(t, err)
+	transport2, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps:      []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+		EnableSctp:     true,
+		NumSctpStreams: numSctpStreams,
+	})
+	require.NoError(t, err)
+
+	dataProducer, err := transport1.ProduceData(mediasoup.DataProducerOptions{
+		SctpStreamParameters: &mediasoup.SctpStreamParameters{StreamId: 1},
+	})
+	require.NoError(t, err)
+
+	usage := transport2.SctpStreamUsage()
+	assert.Equal(t, 8, usage.NumStreams)
+	assert.Empty(t, usage.StreamIds)
+
+	consume := func(sctpStreamId *uint16) (*mediasoup.DataConsumer, error) {
+		return transport2.ConsumeData(mediasoup.DataConsumerOptions{
+			DataProducerId: dataProducer.Id(),
+			SctpStreamId:   sctpStreamId,
+		})
+	}
+
+	// Open and close many more DataConsumers than stream ids.
+	for i := 0; i < 10*int(numSctpStreams.OS); i++ {
+		dataConsumer, err := consume(nil)
+		require.NoError(t, err, "DataConsumer #%d", i)
+		assert.EqualValues(t, i%8, dataConsumer.SctpStreamParameters().StreamId)
+		assert.Len(t, transport2.SctpStreamUsage().StreamIds, 1)
+		require.NoError(t, dataConsumer.Close())
+	}
+	assert.Empty(t, transport2.SctpStreamUsage().StreamIds)
+
+	// Closing the DataProducer releases the stream ids of its DataConsumers.
+	otherDataProducer, err := transport1.ProduceData(mediasoup.DataProducerOptions{
+		SctpStreamParameters: &mediasoup.SctpStreamParameters{StreamId: 2},
+	})
+	require.NoError(t, err)
+	_, err = transport2.ConsumeData(mediasoup.DataConsumerOptions{DataProducerId: otherDataProducer.Id()})
+	require.NoError(t, err)
+	assert.Len(t, transport2.SctpStreamUsage().StreamIds, 1)
+	otherDataProducer.Close()
+	assert.Eventually(t, func() bool {
+		return len(transport2.SctpStreamUsage().StreamIds) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	// Explicitly requested stream ids.
+	sctpStreamId := uint16(5)
+	dataConsumer, err := consume(&sctpStreamId)
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, dataConsumer.SctpStreamParameters().StreamId)
+
+	_, err = consume(&sctpStreamId)
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+
+	sctpStreamId = 8
+	_, err = consume(&sctpStreamId)
+	assert.IsType(t, mediasoup.NewTypeError(""), err)
+
+	// The remaining stream ids are taken, skipping the requested one.
+	for i := 0; i < 7; i++ {
+		dataConsumer, err := consume(nil)
+		require.NoError(t, err)
+		assert.NotEqualValues(t, 5, dataConsumer.SctpStreamParameters().StreamId)
+	}
+	usage = transport2.SctpStreamUsage()
+	assert.Equal(t, []uint16{0, 1, 2, 3, 4, 5, 6, 7}, usage.StreamIds)
+	assert.Equal(t, 0, usage.Available())
+
+	_, err = consume(nil)
+	assert.EqualError(t, err, "no sctpStreamId available")
+
+	// A failed request releases its stream id.
+	require.NoError(t, dataConsumer.Close())
+
+	process.SetHandler("transport.consumeData", func(req Request) (interface{}, error) {
+		return nil, errors.New("consumeData failed")
+	})
+	_, err = consume(nil)
+	assert.EqualError(t, err, "consumeData failed")
+	process.SetHandler("transport.consumeData", nil)
+
+	assert.Equal(t, 1, transport2.SctpStreamUsage().Available())
+
+	// Closing the transport releases all the stream ids.
+	transport2.Close()
+	assert.Empty(t, transport2.SctpStreamUsage().StreamIds)
+}
+
+func TestSctpStreamIds_UnansweredConsumeData(t *testing.T) {
+	worker, process := createWorker(t)
+	defer worker.Close()
+
+	router := createRouter(t, worker)
+
+	transport, err := router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps:  []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+		EnableSctp: true,
+	})
+	require.NoError(t, err)
+
+	dataProducer, err := transport.ProduceData(mediasoup.DataProducerOptions{
+		SctpStreamParameters: &mediasoup.SctpStreamParameters{StreamId: 1},
+	})
+	require.NoError(t, err)
+
+	answer := make(chan struct{})
+	process.SetHandler("transport.consumeData", func(req Request) (interface{}, error) {
+		<-answer
+		return nil, errors.New("too late")
+	})
+	closed := make(chan string, 1)
+	process.SetHandler("dataConsumer.close", func(req Request) (interface{}, error) {
+		closed <- req.Internal.DataConsumerId
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = transport.ConsumeDataContext(ctx, mediasoup.DataConsumerOptions{DataProducerId: dataProducer.Id()})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// The worker may still create the DataConsumer on the stream id.
+	assert.Equal(t, []uint16{0}, transport.SctpStreamUsage().StreamIds)
+
+	close(answer)
+
+	select {
+	case dataConsumerId := <-closed:
+		assert.NotEmpty(t, dataConsumerId)
+	case <-time.After(time.Second):
+		t.Fatal("dataConsumer.close not requested")
+	}
+	assert.Eventually(t, func() bool {
+		return len(transport.SctpStreamUsage().StreamIds) == 0
+	}, time.Second, 5*time.Millisecond)
+
+	// A rejection releases the stream id at once.
+	process.SetHandler("transport.consumeData", func(req Request) (interface{}, error) {
+		return nil, errors.New("rejected")
+	})
+
+	_, err = transport.ConsumeData(mediasoup.DataConsumerOptions{DataProducerId: dataProducer.Id()})
+	assert.EqualError(t, err, "rejected")
+	assert.Empty(t, transport.SctpStreamUsage().StreamIds)
+}
@@ -0,0 +1,31 @@
+package workertest
+
+import (
+	"testing"
+
+	"github.com/jiyeyuran/mediasoup-go"
+)
+
+// NewRouter creates a Router with the given media codecs, or MediaCodecs()
+// when none are given, on a new fake worker which is closed at the end of the
+// test. It returns the Process of the worker along with the Router.
+func NewRouter(t testing.TB, mediaCodecs ...*mediasoup.RtpCodecCapability) (*mediasoup.Router, *Process) {
+	t.Helper()
+
+	worker, err := NewWorker()
+	if err != nil {
+		t.Fatalf("creating worker: %s", err)
+	}
+	t.Cleanup(worker.Close)
+
+	if len(mediaCodecs) == 0 {
+		mediaCodecs = MediaCodecs()
+	}
+
+	router, err := worker.CreateRouter(mediasoup.RouterOptions{MediaCodecs: mediaCodecs})
+	if err != nil {
+		t.Fatalf("creating router: %s", err)
+	}
+
+	return router, ProcessOf(worker)
+}
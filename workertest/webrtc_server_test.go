@@ -0,0 +1,314 @@
+package workertest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jiyeyuran/mediasoup-go"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestWebRtcServerTestingSuite(t *testing.T) {
+	suite.Run(t, new(WebRtcServerTestingSuite))
+}
+
+type WebRtcServerTestingSuite struct {
+	suite.Suite
+	worker       *mediasoup.Worker
+	router       *mediasoup.Router
+	webRtcServer *mediasoup.WebRtcServer
+}
+
+func (suite *WebRtcServerTestingSuite) SetupTest() {
+	var err error
+
+	suite.worker, err = NewWorker()
+	suite.Require().NoError(err)
+
+	suite.router, err = suite.worker.CreateRouter(mediasoup.RouterOptions{MediaCodecs: MediaCodecs()})
+	suite.Require().NoError(err)
+
+	suite.webRtcServer, err = suite.worker.CreateWebRtcServer(mediasoup.WebRtcServerOptions{
+		ListenInfos: []mediasoup.WebRtcServerListenInfo{
+			{Protocol: mediasoup.TransportProtocol_Udp, Ip: "127.0.0.1", AnnouncedIp: "9.9.9.1", Port: 44444},
+			{Protocol: mediasoup.TransportProtocol_Tcp, Ip: "127.0.0.1", AnnouncedIp: "9.9.9.1", Port: 44444},
+		},
+	})
+	suite.Require().NoError(err)
+}
+
+func (suite *WebRtcServerTestingSuite) TearDownTest() {
+	suite.worker.Close()
+}
+
+func (suite *WebRtcServerTestingSuite) createWebRtcTransport(options mediasoup.WebRtcTransportOptions) *mediasoup.WebRtcTransport {
+	options.WebRtcServer = suite.webRtcServer
+
+	transport, err := suite.router.CreateWebRtcTransport(options)
+	suite.Require().NoError(err)
+
+	return transport
+}
+
+func (suite *WebRtcServerTestingSuite) TestCreateWebRtcServer_Succeeds() {
+	newWebRtcServers := make(chan *mediasoup.WebRtcServer, 1)
+	suite.worker.Observer().Once("newwebrtcserver", func(webRtcServer *mediasoup.WebRtcServer) {
+		newWebRtcServers <- webRtcServer
+	})
+
+	webRtcServer, err := suite.worker.CreateWebRtcServer(mediasoup.WebRtcServerOptions{
+		ListenInfos: []mediasoup.WebRtcServerListenInfo{
+			{Protocol: mediasoup.TransportProtocol_Udp, Ip: "0.0.0.0", Port: 44445},
+			{Protocol: mediasoup.TransportProtocol_Tcp, Ip: "127.0.0.1"},
+		},
+		AppData: mediasoup.H{"foo": "bar"},
+	})
+	suite.Require().NoError(err)
+
+	select {
+	case newWebRtcServer := <-newWebRtcServers:
+		suite.Equal(webRtcServer, newWebRtcServer)
+	case <-time.After(time.Second):
+		suite.Fail("newwebrtcserver not emitted")
+	}
+
+	suite.False(webRtcServer.Closed())
+	suite.Equal(mediasoup.H{"foo": "bar"}, webRtcServer.AppData())
+	suite.ElementsMatch([]*mediasoup.WebRtcServer{suite.webRtcServer, webRtcServer}, suite.worker.WebRtcServers())
+
+	workerDump, err := suite.worker.Dump()
+	suite.NoError(err)
+	suite.ElementsMatch([]string{suite.webRtcServer.Id(), webRtcServer.Id()}, workerDump.WebRtcServerIds)
+
+	dump, err := webRtcServer.Dump()
+	suite.NoError(err)
+	suite.Equal(webRtcServer.Id(), dump.Id)
+	suite.Equal([]mediasoup.IpPort{{Ip: "0.0.0.0", Port: 44445}}, dump.UdpSockets)
+	suite.Require().Len(dump.TcpServers, 1)
+	suite.Equal("127.0.0.1", dump.TcpServers[0].Ip)
+	suite.NotZero(dump.TcpServers[0].Port)
+	suite.Empty(dump.WebRtcTransportIds)
+	suite.Empty(dump.LocalIceUsernameFragments)
+	suite.Empty(dump.TupleHashes)
+}
+
+func (suite *WebRtcServerTestingSuite) TestCreateWebRtcServer_TypeError() {
+	_, err := suite.worker.CreateWebRtcServer(mediasoup.WebRtcServerOptions{})
+	suite.IsType(mediasoup.NewTypeError(""), err)
+
+	_, err = suite.worker.CreateWebRtcServer(mediasoup.WebRtcServerOptions{
+		ListenInfos: []mediasoup.WebRtcServerListenInfo{
+			{Protocol: "foo", Ip: "127.0.0.1", Port: 44446},
+		},
+	})
+	suite.IsType(mediasoup.NewTypeError(""), err)
+
+	_, err = suite.worker.CreateWebRtcServer(mediasoup.WebRtcServerOptions{
+		ListenInfos: []mediasoup.WebRtcServerListenInfo{
+			{Protocol: mediasoup.TransportProtocol_Udp, Port: 44446},
+		},
+	})
+	suite.IsType(mediasoup.NewTypeError(""), err)
+}
+
+func (suite *WebRtcServerTestingSuite) TestCreateWebRtcServer_AddressInUseError() {
+	_, err := suite.worker.CreateWebRtcServer(mediasoup.WebRtcServerOptions{
+		ListenInfos: []mediasoup.WebRtcServerListenInfo{
+			{Protocol: mediasoup.TransportProtocol_Udp, Ip: "127.0.0.1", Port: 44444},
+		},
+	})
+	suite.Error(err)
+	suite.Len(suite.worker.WebRtcServers(), 1)
+}
+
+func (suite *WebRtcServerTestingSuite) TestCreateWebRtcTransport_Succeeds() {
+	handled := make(chan *mediasoup.WebRtcTransport, 1)
+	suite.webRtcServer.Observer().Once("webrtctransporthandled", func(transport *mediasoup.WebRtcTransport) {
+		handled <- transport
+	})
+
+	transport := suite.createWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		EnableTcp:  true,
+		EnableSctp: true,
+		AppData:    mediasoup.H{"foo": "bar"},
+	})
+
+	select {
+	case handledTransport := <-handled:
+		suite.Equal(transport, handledTransport)
+	case <-time.After(time.Second):
+		suite.Fail("webrtctransporthandled not emitted")
+	}
+
+	suite.False(transport.Closed())
+	suite.Equal(mediasoup.H{"foo": "bar"}, transport.AppData())
+	suite.EqualValues("controlled", transport.IceRole())
+	suite.EqualValues("new", transport.IceState())
+	suite.EqualValues("new", transport.SctpState())
+
+	iceCandidates := transport.IceCandidates()
+	suite.Require().Len(iceCandidates, 2)
+	suite.Equal("9.9.9.1", iceCandidates[0].Ip)
+	suite.EqualValues("udp", iceCandidates[0].Protocol)
+	suite.EqualValues(44444, iceCandidates[0].Port)
+	suite.Equal("9.9.9.1", iceCandidates[1].Ip)
+	suite.EqualValues("tcp", iceCandidates[1].Protocol)
+	suite.EqualValues(44444, iceCandidates[1].Port)
+	suite.Equal("passive", iceCandidates[1].TcpType)
+	suite.Greater(iceCandidates[0].Priority, iceCandidates[1].Priority)
+
+	// Transports not given the WebRtcServer listen on their own ports.
+	otherTransport, err := suite.router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		ListenIps: []mediasoup.TransportListenIp{{Ip: "127.0.0.1"}},
+	})
+	suite.Require().NoError(err)
+	suite.NotEqualValues(44444, otherTransport.IceCandidates()[0].Port)
+
+	suite.Equal([]*mediasoup.WebRtcTransport{transport}, suite.webRtcServer.WebRtcTransports())
+
+	dump, err := suite.webRtcServer.Dump()
+	suite.NoError(err)
+	suite.Equal([]string{transport.Id()}, dump.WebRtcTransportIds)
+	suite.Equal([]mediasoup.IceUserNameFragment{
+		{
+			LocalIceUsernameFragment: transport.IceParameters().UsernameFragment,
+			WebRtcTransportId:        transport.Id(),
+		},
+	}, dump.LocalIceUsernameFragments)
+
+	routerDump, err := suite.router.Dump()
+	suite.NoError(err)
+	suite.ElementsMatch([]string{transport.Id(), otherTransport.Id()}, routerDump.TransportIds)
+}
+
+func (suite *WebRtcServerTestingSuite) TestCreateWebRtcTransport_TypeError() {
+	_, err := suite.router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{})
+	suite.IsType(mediasoup.NewTypeError(""), err)
+
+	// No listen info for UDP.
+	webRtcServer, err := suite.worker.CreateWebRtcServer(mediasoup.WebRtcServerOptions{
+		ListenInfos: []mediasoup.WebRtcServerListenInfo{
+			{Protocol: mediasoup.TransportProtocol_Tcp, Ip: "127.0.0.1"},
+		},
+	})
+	suite.Require().NoError(err)
+
+	_, err = suite.router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		WebRtcServer: webRtcServer,
+	})
+	suite.IsType(mediasoup.NewTypeError(""), err)
+	suite.Empty(webRtcServer.WebRtcTransports())
+}
+
+func (suite *WebRtcServerTestingSuite) TestCreateWebRtcTransport_RejectsIfWebRtcServerIsClosed() {
+	suite.webRtcServer.Close()
+
+	_, err := suite.router.CreateWebRtcTransport(mediasoup.WebRtcTransportOptions{
+		WebRtcServer: suite.webRtcServer,
+	})
+	suite.Error(err)
+}
+
+func (suite *WebRtcServerTestingSuite) TestWebRtcTransportClose_Unhandled() {
+	transport := suite.createWebRtcTransport(mediasoup.WebRtcTransportOptions{})
+
+	unhandled := make(chan *mediasoup.WebRtcTransport, 1)
+	suite.webRtcServer.Observer().Once("webrtctransportunhandled", func(transport *mediasoup.WebRtcTransport) {
+		unhandled <- transport
+	})
+
+	transport.Close()
+
+	select {
+	case unhandledTransport := <-unhandled:
+		suite.Equal(transport, unhandledTransport)
+	case <-time.After(time.Second):
+		suite.Fail("webrtctransportunhandled not emitted")
+	}
+
+	suite.Empty(suite.webRtcServer.WebRtcTransports())
+
+	dump, err := suite.webRtcServer.Dump()
+	suite.NoError(err)
+	suite.Empty(dump.WebRtcTransportIds)
+}
+
+func (suite *WebRtcServerTestingSuite) TestClose_ClosesWebRtcTransports() {
+	transport := suite.createWebRtcTransport(mediasoup.WebRtcTransportOptions{EnableSctp: true})
+
+	listenServerClosed := make(chan struct{})
+	transport.OnListenServerClose(func() { close(listenServerClosed) })
+
+	transportClosed := make(chan struct{})
+	transport.Observer().Once("close", func() { close(transportClosed) })
+
+	unhandled := make(chan *mediasoup.WebRtcTransport, 1)
+	suite.webRtcServer.Observer().On("webrtctransportunhandled", func(transport *mediasoup.WebRtcTransport) {
+		unhandled <- transport
+	})
+
+	serverClosed := make(chan struct{})
+	suite.webRtcServer.Observer().Once("close", func() { close(serverClosed) })
+
+	suite.webRtcServer.Close()
+
+	for _, ch := range []chan struct{}{listenServerClosed, transportClosed, serverClosed} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			suite.FailNow("event not emitted")
+		}
+	}
+	suite.Equal(transport, <-unhandled)
+
+	suite.True(suite.webRtcServer.Closed())
+	suite.True(transport.Closed())
+	suite.EqualValues("closed", transport.IceState())
+	suite.Nil(transport.IceSelectedTuple())
+	suite.EqualValues("closed", transport.DtlsState())
+	suite.EqualValues("closed", transport.SctpState())
+	suite.Empty(suite.webRtcServer.WebRtcTransports())
+	suite.Empty(suite.router.Transports())
+	suite.Empty(suite.worker.WebRtcServers())
+
+	// Emitted once.
+	time.Sleep(10 * time.Millisecond)
+	suite.Empty(unhandled)
+
+	_, err := suite.webRtcServer.Dump()
+	suite.Error(err)
+
+	workerDump, err := suite.worker.Dump()
+	suite.NoError(err)
+	suite.Empty(workerDump.WebRtcServerIds)
+
+	routerDump, err := suite.router.Dump()
+	suite.NoError(err)
+	suite.Empty(routerDump.TransportIds)
+}
+
+func (suite *WebRtcServerTestingSuite) TestEmitsWorkercloseIfWorkerIsClosed() {
+	transport := suite.createWebRtcTransport(mediasoup.WebRtcTransportOptions{})
+
+	workerClosed := make(chan struct{})
+	suite.webRtcServer.OnWorkerClose(func() { close(workerClosed) })
+
+	serverClosed := make(chan struct{})
+	suite.webRtcServer.Observer().Once("close", func() { close(serverClosed) })
+
+	suite.worker.Close()
+
+	for _, ch := range []chan struct{}{workerClosed, serverClosed} {
+		select {
+		case <-ch:
+		case <-time.After(time.Second):
+			suite.FailNow("event not emitted")
+		}
+	}
+
+	suite.True(suite.webRtcServer.Closed())
+	suite.True(transport.Closed())
+	suite.EqualValues("closed", transport.IceState())
+	suite.Empty(suite.webRtcServer.WebRtcTransports())
+	suite.Empty(suite.worker.WebRtcServers())
+}